@@ -3,17 +3,25 @@ package auth
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/internxt/rclone-adapter/config"
 	"github.com/internxt/rclone-adapter/crypto"
-	"github.com/internxt/rclone-adapter/errors"
-	"github.com/tyler-smith/go-bip39"
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
 )
 
+// ErrTFARequired is returned by the login flow when the account has two-factor
+// authentication enabled and no TFA code was supplied.
+var ErrTFARequired = errors.New("2FA code required")
+
 type LoginRequest struct {
 	Email string `json:"email"`
 }
@@ -64,7 +72,7 @@ func RefreshToken(ctx context.Context, cfg *config.Config) (*AccessResponse, err
 	if err != nil {
 		return nil, fmt.Errorf("failed to create refresh token request: %w", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	cfg.AuthorizeRequest(req)
 
 	resp, err := cfg.HTTPClient.Do(req)
 	if err != nil {
@@ -73,7 +81,7 @@ func RefreshToken(ctx context.Context, cfg *config.Config) (*AccessResponse, err
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.NewHTTPError(resp, "refresh token")
+		return nil, sdkerrors.NewHTTPError(resp, "refresh token")
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -93,6 +101,25 @@ func RefreshToken(ctx context.Context, cfg *config.Config) (*AccessResponse, err
 	return &ar, nil
 }
 
+// TokenRefresher is a config.TokenRefreshFunc backed by RefreshToken. Assign it
+// to cfg.TokenRefresher to have the default HTTP client renew rejected tokens.
+func TokenRefresher(ctx context.Context, cfg *config.Config) (string, error) {
+	ar, err := RefreshToken(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+	return ar.NewToken, nil
+}
+
+var _ config.TokenRefreshFunc = TokenRefresher
+
+// TokenExpiry returns the expiry time of a bearer token, read from its JWT
+// "exp" claim. Combine with cfg.TokenRefreshLeeway to renew tokens before
+// long transfers start failing with 401.
+func TokenExpiry(token string) (time.Time, error) {
+	return config.TokenExpiry(token)
+}
+
 func Login(ctx context.Context, cfg *config.Config, email string) (*LoginResponse, error) {
 	endpoint := cfg.Endpoints.Drive().Auth().Login()
 
@@ -115,7 +142,7 @@ func Login(ctx context.Context, cfg *config.Config, email string) (*LoginRespons
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.NewHTTPError(resp, "login")
+		return nil, sdkerrors.NewHTTPError(resp, "login")
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -165,7 +192,7 @@ func Access(ctx context.Context, cfg *config.Config, email, encryptedPassword, t
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.NewHTTPError(resp, "access")
+		return nil, sdkerrors.NewHTTPError(resp, "access")
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -194,7 +221,7 @@ func DoLogin(ctx context.Context, cfg *config.Config, email, password, tfa strin
 	}
 
 	if loginResp.TFA && tfa == "" {
-		return nil, fmt.Errorf("2FA code required")
+		return nil, ErrTFARequired
 	}
 
 	encryptedPassword, err := crypto.EncryptPasswordHash(password, loginResp.SKey)
@@ -207,16 +234,108 @@ func DoLogin(ctx context.Context, cfg *config.Config, email, password, tfa strin
 		return nil, fmt.Errorf("failed to access: %w", err)
 	}
 
-	decryptedMnemonic, err := crypto.DecryptTextWithKey(accessResp.User.Mnemonic, password)
+	decryptedMnemonic, err := DecryptMnemonic(accessResp.User.Mnemonic, password, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt mnemonic: %w", err)
-	}
-
-	if !bip39.IsMnemonicValid(decryptedMnemonic) {
-		return nil, fmt.Errorf("invalid mnemonic format")
+		return nil, err
 	}
 
 	accessResp.User.Mnemonic = decryptedMnemonic
 
 	return accessResp, nil
 }
+
+// DecryptMnemonic decrypts the encrypted mnemonic returned by the access
+// endpoint using the account password, the same way the web client does
+// (OpenSSL-compatible AES-256-CBC keyed by the password). The result is
+// validated as a BIP39 mnemonic. If cfg is non-nil, cfg.Mnemonic is set to
+// the decrypted value so it can be used for file keys right away.
+func DecryptMnemonic(encryptedMnemonic, password string, cfg *config.Config) (string, error) {
+	mnemonic, err := crypto.DecryptTextWithKey(encryptedMnemonic, password)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt mnemonic: %w", err)
+	}
+
+	if err := crypto.ValidateMnemonic(mnemonic); err != nil {
+		return "", fmt.Errorf("invalid mnemonic format: %w", err)
+	}
+
+	if cfg != nil {
+		cfg.SetMnemonic(mnemonic)
+	}
+	return mnemonic, nil
+}
+
+// DecryptPrivateKey decrypts the encrypted private key returned by the
+// access endpoint using the account password, the same way the web client
+// does. The result is the account's armored OpenPGP private key.
+func DecryptPrivateKey(encryptedPrivateKey, password string) (string, error) {
+	key, err := crypto.DecryptTextGCM(encryptedPrivateKey, password)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt private key: %w", err)
+	}
+	return key, nil
+}
+
+// LoginWithPassword performs the full email/password login flow and returns
+// a copy of cfg populated with everything needed for subsequent API calls:
+// the bearer token, root folder, bucket, decrypted mnemonic and private key,
+// and the network Basic Auth header. HTTPClient, Endpoints and
+// CredentialStore are carried over from cfg, and TokenRefresher is set so
+// the token is renewed automatically. The new credentials are saved to the CredentialStore, if any.
+//
+// Accounts with 2FA enabled fail with ErrTFARequired; use LoginWithPasswordTFA.
+func LoginWithPassword(ctx context.Context, cfg *config.Config, email, password string) (*config.Config, error) {
+	return LoginWithPasswordTFA(ctx, cfg, email, password, "")
+}
+
+// LoginWithPasswordTFA is LoginWithPassword for accounts with two-factor
+// authentication enabled. tfa is the current TOTP code from the authenticator app.
+func LoginWithPasswordTFA(ctx context.Context, cfg *config.Config, email, password, tfa string) (*config.Config, error) {
+	accessResp, err := DoLogin(ctx, cfg, email, password, tfa)
+	if err != nil {
+		return nil, err
+	}
+
+	out := configFromAccess(cfg, accessResp)
+	if accessResp.User.PrivateKey != "" {
+		// Only needed for items shared with the account; logins still
+		// succeed for accounts whose key cannot be read.
+		if key, err := DecryptPrivateKey(accessResp.User.PrivateKey, password); err == nil {
+			out.PrivateKey = key
+		} else {
+			out.Log().Warn("failed to decrypt private key", "error", err)
+		}
+	}
+	if err := out.SaveCredentials(); err != nil {
+		return nil, fmt.Errorf("failed to persist credentials: %w", err)
+	}
+	return out, nil
+}
+
+// configFromAccess builds a ready-to-use Config from a successful access response.
+func configFromAccess(cfg *config.Config, ar *AccessResponse) *config.Config {
+	out := cfg.Clone()
+	out.Token = ar.NewToken
+	if out.Token == "" {
+		out.Token = ar.Token
+	}
+	out.RootFolderID = ar.User.RootFolderID
+	out.Bucket = ar.User.Bucket
+	out.SetMnemonic(ar.User.Mnemonic)
+	out.BasicAuthHeader = NetworkBasicAuth(ar.User.BridgeUser, ar.User.UserID)
+	out.TokenRefresher = TokenRefresher
+	out.ApplyDefaults()
+	return out
+}
+
+// NetworkBasicAuth derives the BasicAuthHeader used by the network (bucket)
+// endpoints, the same way the official clients do: the password is hashed
+// with SHA-256 and sent hex-encoded, i.e. "Basic base64(user:hex(sha256(pass)))".
+// For a personal account, user is AccessResponse.User.BridgeUser and pass is
+// AccessResponse.User.UserID; for a workspace, use the workspace's
+// NetworkUser and NetworkPass credentials.
+func NetworkBasicAuth(user, pass string) string {
+	sum := sha256.Sum256([]byte(pass))
+	creds := user + ":" + hex.EncodeToString(sum[:])
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(creds))
+}
@@ -2,11 +2,17 @@ package auth
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/crypto"
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
 )
 
 // mockAccessResponse creates a valid AccessResponse for testing
@@ -363,3 +369,265 @@ func TestLoginRequestFormat(t *testing.T) {
 		t.Errorf("expected email test@example.com, got %s", capturedEmail)
 	}
 }
+
+// newLoginFlowServer mocks the login and access endpoints for a user whose
+// mnemonic was encrypted with the given password. When tfa is set, the access
+// endpoint rejects requests that don't carry testTFACode.
+func newLoginFlowServer(t *testing.T, password string, tfa bool) *httptest.Server {
+	t.Helper()
+
+	encSalt, err := crypto.EncryptText(testSaltHex)
+	if err != nil {
+		t.Fatalf("failed to encrypt salt: %v", err)
+	}
+	encMnemonic, err := crypto.EncryptTextWithKey(testMnemonic, password)
+	if err != nil {
+		t.Fatalf("failed to encrypt mnemonic: %v", err)
+	}
+	encPrivateKey, err := crypto.EncryptTextGCM(testPrivateKey, password)
+	if err != nil {
+		t.Fatalf("failed to encrypt private key: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/drive/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(LoginResponse{HasKeys: true, SKey: encSalt, TFA: tfa})
+	})
+	mux.HandleFunc("/drive/auth/cli/login/access", func(w http.ResponseWriter, r *http.Request) {
+		var req AccessRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if tfa && req.TFA != testTFACode {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"message":"Wrong 2-factor auth code"}`))
+			return
+		}
+		var ar AccessResponse
+		ar.Token = "legacy-token"
+		ar.NewToken = "new-token"
+		ar.User.UserID = "user-id"
+		ar.User.BridgeUser = "user@example.com"
+		ar.User.Bucket = "bucket-id"
+		ar.User.RootFolderID = "root-folder-uuid"
+		ar.User.Mnemonic = encMnemonic
+		ar.User.PrivateKey = encPrivateKey
+		json.NewEncoder(w).Encode(ar)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestLoginWithPassword(t *testing.T) {
+	server := newLoginFlowServer(t, "correct-password", false)
+	defer server.Close()
+
+	cfg := newTestConfig(server.URL, "")
+	store := &config.MemoryStore{}
+	cfg.CredentialStore = store
+
+	got, err := LoginWithPassword(context.Background(), cfg, "user@example.com", "correct-password")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got.Token != "new-token" {
+		t.Errorf("expected Token new-token, got %s", got.Token)
+	}
+	if got.MnemonicString() != testMnemonic {
+		t.Errorf("expected decrypted mnemonic, got %q", got.MnemonicString())
+	}
+	if got.PrivateKey != testPrivateKey {
+		t.Errorf("expected decrypted private key, got %q", got.PrivateKey)
+	}
+	if got.Bucket != "bucket-id" {
+		t.Errorf("expected Bucket bucket-id, got %s", got.Bucket)
+	}
+	if got.RootFolderID != "root-folder-uuid" {
+		t.Errorf("expected RootFolderID root-folder-uuid, got %s", got.RootFolderID)
+	}
+	if got.BasicAuthHeader != NetworkBasicAuth("user@example.com", "user-id") {
+		t.Errorf("unexpected BasicAuthHeader %s", got.BasicAuthHeader)
+	}
+	if got.Endpoints != cfg.Endpoints {
+		t.Error("expected Endpoints to be carried over")
+	}
+	if got.TokenRefresher == nil {
+		t.Error("expected TokenRefresher to be set")
+	}
+	if cfg.Token != "" {
+		t.Error("expected input config to be left untouched")
+	}
+	if stored, _ := store.Get(); stored.Token != "new-token" || stored.Mnemonic != testMnemonic {
+		t.Errorf("expected credentials to be persisted, got %+v", stored)
+	}
+}
+
+func TestLoginWithPasswordWrongPassword(t *testing.T) {
+	server := newLoginFlowServer(t, "correct-password", false)
+	defer server.Close()
+
+	cfg := newTestConfig(server.URL, "")
+
+	_, err := LoginWithPassword(context.Background(), cfg, "user@example.com", "wrong-password")
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "failed to decrypt mnemonic") {
+		t.Errorf("expected mnemonic decryption error, got %q", err.Error())
+	}
+}
+
+func TestNetworkBasicAuth(t *testing.T) {
+	testCases := []struct {
+		name string
+		user string
+		pass string
+		want string
+	}{
+		{
+			name: "personal account",
+			user: "user@example.com",
+			pass: "user-id",
+			// base64("user@example.com:" + hex(sha256("user-id")))
+			want: "Basic dXNlckBleGFtcGxlLmNvbTphNzU3MWRkZWMxZGY0MzA0NWFjNjY3ZDdjOTc2YmQxMTQ5ZmU5YTJkYmIzZmI1NTM1N2JlZWQ1ODJlMTE1Mzhk",
+		},
+		{
+			name: "empty password",
+			user: "user",
+			pass: "",
+			want: "Basic " + base64.StdEncoding.EncodeToString([]byte("user:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := NetworkBasicAuth(tc.user, tc.pass); got != tc.want {
+				t.Errorf("NetworkBasicAuth() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLoginWithPasswordTFA(t *testing.T) {
+	server := newLoginFlowServer(t, "correct-password", true)
+	defer server.Close()
+
+	cfg := newTestConfig(server.URL, "")
+
+	t.Run("missing code", func(t *testing.T) {
+		_, err := LoginWithPassword(context.Background(), cfg, "user@example.com", "correct-password")
+		if !errors.Is(err, ErrTFARequired) {
+			t.Fatalf("expected ErrTFARequired, got %v", err)
+		}
+	})
+
+	t.Run("wrong code", func(t *testing.T) {
+		_, err := LoginWithPasswordTFA(context.Background(), cfg, "user@example.com", "correct-password", "000000")
+		var httpErr *sdkerrors.HTTPError
+		if !errors.As(err, &httpErr) {
+			t.Fatalf("expected *sdkerrors.HTTPError, got %T: %v", err, err)
+		}
+		if httpErr.StatusCode() != http.StatusUnauthorized {
+			t.Errorf("expected status 401, got %d", httpErr.StatusCode())
+		}
+	})
+
+	t.Run("valid code", func(t *testing.T) {
+		got, err := LoginWithPasswordTFA(context.Background(), cfg, "user@example.com", "correct-password", testTFACode)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.MnemonicString() != testMnemonic {
+			t.Errorf("expected decrypted mnemonic, got %q", got.MnemonicString())
+		}
+	})
+}
+
+// TestTokenRefresherReplaysRequest verifies that a config wired with
+// TokenRefresher recovers from a 401 transparently.
+func TestTokenRefresherReplaysRequest(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/drive/users/cli/refresh":
+			json.NewEncoder(w).Encode(mockAccessResponse("fresh-token"))
+		case r.Header.Get("Authorization") == "Bearer fresh-token":
+			json.NewEncoder(w).Encode(mockLoginResponse())
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer mockServer.Close()
+
+	cfg := newTestConfig(mockServer.URL, "stale-token")
+	cfg.TokenRefresher = TokenRefresher
+
+	req, _ := http.NewRequest(http.MethodGet, mockServer.URL+"/drive/files", nil)
+	req.Header.Set("Authorization", "Bearer "+cfg.CurrentToken())
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200 after refresh, got %d", resp.StatusCode)
+	}
+	if cfg.CurrentToken() != "fresh-token" {
+		t.Errorf("expected token fresh-token, got %s", cfg.CurrentToken())
+	}
+}
+
+func TestDecryptMnemonic(t *testing.T) {
+	encrypted, err := crypto.EncryptTextWithKey(testMnemonic, "password")
+	if err != nil {
+		t.Fatalf("failed to encrypt mnemonic: %v", err)
+	}
+
+	t.Run("populates config", func(t *testing.T) {
+		cfg := newTestConfig("http://localhost", "")
+		got, err := DecryptMnemonic(encrypted, "password", cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != testMnemonic {
+			t.Errorf("expected %q, got %q", testMnemonic, got)
+		}
+		if cfg.MnemonicString() != testMnemonic {
+			t.Errorf("expected cfg.MnemonicString() to be set, got %q", cfg.MnemonicString())
+		}
+	})
+
+	t.Run("nil config", func(t *testing.T) {
+		if _, err := DecryptMnemonic(encrypted, "password", nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		_, err := DecryptMnemonic(encrypted, "wrong", nil)
+		if err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	})
+
+	t.Run("not a mnemonic", func(t *testing.T) {
+		notMnemonic, _ := crypto.EncryptTextWithKey("hello world", "password")
+		_, err := DecryptMnemonic(notMnemonic, "password", nil)
+		if err == nil || !strings.Contains(err.Error(), "invalid mnemonic format") {
+			t.Fatalf("expected invalid mnemonic error, got %v", err)
+		}
+	})
+}
+
+func TestTokenExpiry(t *testing.T) {
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"exp":1700000000}`))
+	got, err := TokenExpiry("header." + claims + ".signature")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Unix() != 1700000000 {
+		t.Errorf("expected exp 1700000000, got %d", got.Unix())
+	}
+
+	if _, err := TokenExpiry("not-a-jwt"); err == nil {
+		t.Error("expected error for non-JWT token")
+	}
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/crypto"
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
+)
+
+// Workspace describes a B2B team workspace
+type Workspace struct {
+	ID              string `json:"id"`
+	OwnerID         string `json:"ownerId"`
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	Avatar          string `json:"avatar"`
+	DefaultTeamID   string `json:"defaultTeamId"`
+	WorkspaceUserID string `json:"workspaceUserId"`
+	SetupCompleted  bool   `json:"setupCompleted"`
+	CreatedAt       string `json:"createdAt"`
+	UpdatedAt       string `json:"updatedAt"`
+}
+
+// WorkspaceUser holds the current user's membership details in a workspace.
+// Key is the workspace mnemonic encrypted with the member's keys.
+type WorkspaceUser struct {
+	ID           string `json:"id"`
+	MemberID     string `json:"memberId"`
+	Key          string `json:"key"`
+	WorkspaceID  string `json:"workspaceId"`
+	RootFolderID string `json:"rootFolderId"`
+	SpaceLimit   int64  `json:"spaceLimit"`
+	DriveUsage   int64  `json:"driveUsage"`
+	BackupsUsage int64  `json:"backupsUsage"`
+	Deactivated  bool   `json:"deactivated"`
+}
+
+// WorkspaceMembership is an entry of GET /drive/workspaces
+type WorkspaceMembership struct {
+	WorkspaceUser WorkspaceUser `json:"workspaceUser"`
+	Workspace     Workspace     `json:"workspace"`
+}
+
+// WorkspaceCredentials is the response of GET /drive/workspaces/{id}/credentials
+type WorkspaceCredentials struct {
+	WorkspaceID     string `json:"workspaceId"`
+	Bucket          string `json:"bucket"`
+	WorkspaceUserID string `json:"workspaceUserId"`
+	Email           string `json:"email"`
+	Credentials     struct {
+		NetworkPass string `json:"networkPass"`
+		NetworkUser string `json:"networkUser"`
+	} `json:"credentials"`
+	TokenHeader string `json:"tokenHeader"`
+}
+
+// ListWorkspaces returns the workspaces the authenticated user belongs to.
+func ListWorkspaces(ctx context.Context, cfg *config.Config) ([]WorkspaceMembership, error) {
+	endpoint := cfg.Endpoints.Drive().Workspaces().List()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create list workspaces request: %w", err)
+	}
+	cfg.AuthorizeRequest(req)
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute list workspaces request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, sdkerrors.NewHTTPError(resp, "list workspaces")
+	}
+
+	var result []WorkspaceMembership
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode list workspaces response: %w", err)
+	}
+	return result, nil
+}
+
+// GetWorkspaceCredentials returns the network bucket and credentials of a workspace.
+func GetWorkspaceCredentials(ctx context.Context, cfg *config.Config, workspaceID string) (*WorkspaceCredentials, error) {
+	endpoint := cfg.Endpoints.Drive().Workspaces().Credentials(workspaceID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create workspace credentials request: %w", err)
+	}
+	cfg.AuthorizeRequest(req)
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute workspace credentials request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, sdkerrors.NewHTTPError(resp, "get workspace credentials")
+	}
+
+	var result WorkspaceCredentials
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode workspace credentials response: %w", err)
+	}
+	return &result, nil
+}
+
+// UseWorkspace returns a copy of cfg pointed at the given workspace: Drive
+// requests are scoped with the workspace header and go to the workspace's
+// drive, RootFolderID is the member's workspace root, and
+// Bucket/BasicAuthHeader use the workspace network credentials. Mnemonic is
+// the workspace mnemonic, unwrapped from WorkspaceUser.Key with
+// cfg.PrivateKey; without a private key the caller must set it before
+// transferring files.
+func UseWorkspace(ctx context.Context, cfg *config.Config, workspaceID string) (*config.Config, error) {
+	memberships, err := ListWorkspaces(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var member *WorkspaceUser
+	for i := range memberships {
+		if memberships[i].Workspace.ID == workspaceID {
+			member = &memberships[i].WorkspaceUser
+			break
+		}
+	}
+	if member == nil {
+		return nil, fmt.Errorf("workspace %s not found for this account", workspaceID)
+	}
+
+	creds, err := GetWorkspaceCredentials(ctx, cfg, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	out := cfg.Clone()
+	if cfg.PrivateKey != "" && member.Key != "" {
+		mnemonic, err := crypto.DecryptWithPrivateKey(member.Key, cfg.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt key of workspace %s: %w", workspaceID, err)
+		}
+		out.SetMnemonic(mnemonic)
+		out.KeyDeriver = nil // Members derive the workspace's keys the standard way
+	}
+	out.WorkspaceID = workspaceID
+	out.RootFolderID = member.RootFolderID
+	out.Bucket = creds.Bucket
+	out.BasicAuthHeader = NetworkBasicAuth(creds.Credentials.NetworkUser, creds.Credentials.NetworkPass)
+	out.ApplyDefaults()
+	return out, nil
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/crypto"
+)
+
+// newWorkspaceServer serves the workspace ws-1, whose member key is key.
+func newWorkspaceServer(t *testing.T, key string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/drive/workspaces", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer user-token" {
+			t.Errorf("expected user bearer token, got %s", r.Header.Get("Authorization"))
+		}
+		json.NewEncoder(w).Encode([]WorkspaceMembership{
+			{
+				Workspace:     Workspace{ID: "ws-1", Name: "Team"},
+				WorkspaceUser: WorkspaceUser{WorkspaceID: "ws-1", RootFolderID: "ws-root-uuid", Key: key},
+			},
+		})
+	})
+	mux.HandleFunc("/drive/workspaces/ws-1/credentials", func(w http.ResponseWriter, r *http.Request) {
+		var creds WorkspaceCredentials
+		creds.WorkspaceID = "ws-1"
+		creds.Bucket = "ws-bucket"
+		creds.Credentials.NetworkUser = "network-user"
+		creds.Credentials.NetworkPass = "network-pass"
+		json.NewEncoder(w).Encode(creds)
+	})
+	mux.HandleFunc("/drive/folders/ws-root-uuid/meta", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(config.WorkspaceHeader)))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestListWorkspaces(t *testing.T) {
+	server := newWorkspaceServer(t, "encrypted-key")
+	defer server.Close()
+
+	cfg := newTestConfig(server.URL, "user-token")
+	got, err := ListWorkspaces(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 || got[0].Workspace.Name != "Team" {
+		t.Fatalf("unexpected workspaces: %+v", got)
+	}
+	if got[0].WorkspaceUser.Key != "encrypted-key" {
+		t.Errorf("expected workspace key to be decoded, got %q", got[0].WorkspaceUser.Key)
+	}
+}
+
+func TestGetWorkspaceCredentials(t *testing.T) {
+	server := newWorkspaceServer(t, "encrypted-key")
+	defer server.Close()
+
+	cfg := newTestConfig(server.URL, "user-token")
+	got, err := GetWorkspaceCredentials(context.Background(), cfg, "ws-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Bucket != "ws-bucket" || got.Credentials.NetworkUser != "network-user" {
+		t.Errorf("unexpected credentials: %+v", got)
+	}
+
+	_, err = GetWorkspaceCredentials(context.Background(), cfg, "unknown")
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected 404 error, got %v", err)
+	}
+}
+
+func TestUseWorkspace(t *testing.T) {
+	server := newWorkspaceServer(t, "encrypted-key")
+	defer server.Close()
+
+	cfg := newTestConfig(server.URL, "user-token")
+	cfg.RootFolderID = "personal-root"
+	cfg.Bucket = "personal-bucket"
+
+	ws, err := UseWorkspace(context.Background(), cfg, "ws-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ws.WorkspaceID != "ws-1" {
+		t.Errorf("expected WorkspaceID ws-1, got %s", ws.WorkspaceID)
+	}
+	if ws.RootFolderID != "ws-root-uuid" {
+		t.Errorf("expected workspace root, got %s", ws.RootFolderID)
+	}
+	if ws.Bucket != "ws-bucket" {
+		t.Errorf("expected workspace bucket, got %s", ws.Bucket)
+	}
+	if ws.BasicAuthHeader != NetworkBasicAuth("network-user", "network-pass") {
+		t.Errorf("unexpected BasicAuthHeader %s", ws.BasicAuthHeader)
+	}
+	if cfg.RootFolderID != "personal-root" || cfg.WorkspaceID != "" {
+		t.Error("expected personal config to be left untouched")
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/drive/folders/ws-root-uuid/meta", nil)
+	ws.AuthorizeRequest(req)
+	resp, err := ws.HTTPClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "ws-1" {
+		t.Errorf("expected workspace header ws-1 on Drive requests, got %q", body)
+	}
+
+	if _, err := UseWorkspace(context.Background(), cfg, "ws-unknown"); err == nil {
+		t.Error("expected error for unknown workspace")
+	}
+}
+
+func TestUseWorkspaceMnemonic(t *testing.T) {
+	entity, err := openpgp.NewEntity("member", "", "member@example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	var privateKey, publicKey bytes.Buffer
+	w, _ := armor.Encode(&privateKey, openpgp.PrivateKeyType, nil)
+	entity.SerializePrivate(w, nil)
+	w.Close()
+	w, _ = armor.Encode(&publicKey, openpgp.PublicKeyType, nil)
+	entity.Serialize(w)
+	w.Close()
+	key, err := crypto.EncryptWithPublicKey(testMnemonic, publicKey.String())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server := newWorkspaceServer(t, key)
+	defer server.Close()
+	cfg := newTestConfig(server.URL, "user-token")
+	cfg.SetMnemonic("personal mnemonic")
+	cfg.PrivateKey = privateKey.String()
+
+	ws, err := UseWorkspace(context.Background(), cfg, "ws-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ws.MnemonicString() != testMnemonic {
+		t.Errorf("expected the workspace mnemonic, got %q", ws.MnemonicString())
+	}
+	if cfg.MnemonicString() != "personal mnemonic" {
+		t.Error("expected personal config to be left untouched")
+	}
+
+	cfg.PrivateKey = testPrivateKey
+	if _, err := UseWorkspace(context.Background(), cfg, "ws-1"); err == nil || !strings.Contains(err.Error(), "failed to decrypt key of workspace") {
+		t.Errorf("expected key error, got %v", err)
+	}
+}
@@ -0,0 +1,403 @@
+// Package backend maps the SDK's UUID-based calls onto the path-based
+// operations of a filesystem backend: List, NewObject, Put, Mkdir, Rmdir,
+// Move, About, Hashes and Cleanup, with Objects that Open with a RangeOption
+// or SeekOption. It follows the shape of rclone's fs.Fs, fs.Object and
+// fs.Directory so an rclone backend (or a WebDAV, SFTP or FUSE frontend)
+// only has to wrap these types instead of reimplementing the glue, but it
+// does not import rclone or satisfy its interfaces: rclone imports this
+// module, so the thin adapter to fs.Fs lives in rclone's internxt backend.
+package backend
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/internxt/rclone-adapter/buckets"
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/errors"
+	"github.com/internxt/rclone-adapter/files"
+	"github.com/internxt/rclone-adapter/folders"
+	"github.com/internxt/rclone-adapter/users"
+)
+
+// Errors returned for paths of the wrong kind, mirroring rclone's
+// fs.ErrorIsDir, fs.ErrorIsFile and fs.ErrorDirectoryNotEmpty.
+var (
+	ErrIsDir       = stderrors.New("is a directory")
+	ErrIsFile      = stderrors.New("is a file")
+	ErrDirNotEmpty = stderrors.New("directory not empty")
+)
+
+// Entry is a file or directory returned by List.
+type Entry interface {
+	Remote() string
+	ModTime() time.Time
+	Size() int64
+}
+
+// Fs is a drive folder accessed by slash-separated paths relative to it.
+type Fs struct {
+	cfg  *config.Config
+	root string
+}
+
+// NewFs returns an Fs rooted at the folder rootUUID, or at cfg.RootFolderID
+// when rootUUID is empty.
+func NewFs(cfg *config.Config, rootUUID string) *Fs {
+	if rootUUID == "" {
+		rootUUID = cfg.RootFolderID
+	}
+	return &Fs{cfg: cfg, root: rootUUID}
+}
+
+// Root returns the UUID of the folder paths are relative to.
+func (f *Fs) Root() string {
+	return f.root
+}
+
+// Hashes returns the hash types the backend can verify. Drive stores no hash
+// of the plaintext, so the set is empty.
+func (f *Fs) Hashes() []string {
+	return nil
+}
+
+// List returns the files and directories directly inside dir.
+func (f *Fs) List(ctx context.Context, dir string) ([]Entry, error) {
+	folder, err := f.dir(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	subfolders, err := folders.ListAllFolders(ctx, f.cfg, folder.UUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %q: %w", dir, err)
+	}
+	children, err := folders.ListAllFiles(ctx, f.cfg, folder.UUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %q: %w", dir, err)
+	}
+
+	entries := make([]Entry, 0, len(subfolders)+len(children))
+	for i := range subfolders {
+		entries = append(entries, &Directory{remote: join(dir, subfolders[i].PlainName), folder: &subfolders[i]})
+	}
+	for i := range children {
+		entries = append(entries, &Object{fs: f, remote: join(dir, folders.FileName(&children[i])), file: &children[i]})
+	}
+	return entries, nil
+}
+
+// NewObject returns the file at remote. It fails with an error matching
+// errors.ErrNotFound if there is none, or ErrIsDir if remote is a directory.
+func (f *Fs) NewObject(ctx context.Context, remote string) (*Object, error) {
+	entry, err := folders.ResolvePath(ctx, f.cfg, f.root, remote)
+	if err != nil {
+		return nil, err
+	}
+	if entry.File == nil {
+		return nil, fmt.Errorf("failed to open %q: %w", remote, ErrIsDir)
+	}
+	return &Object{fs: f, remote: clean(remote), file: entry.File}, nil
+}
+
+// Stat returns the file or directory at remote.
+func (f *Fs) Stat(ctx context.Context, remote string) (Entry, error) {
+	entry, err := folders.ResolvePath(ctx, f.cfg, f.root, remote)
+	if err != nil {
+		return nil, err
+	}
+	if entry.File != nil {
+		return &Object{fs: f, remote: clean(remote), file: entry.File}, nil
+	}
+	return &Directory{remote: clean(remote), folder: entry.Folder}, nil
+}
+
+// Put uploads size bytes from in to remote, creating missing parent
+// directories. An existing file at remote is replaced once the upload
+// succeeds; if it fails, the existing file is kept. Until then the existing
+// file is renamed aside, and if the process stops in between it is left
+// behind for Cleanup to remove.
+func (f *Fs) Put(ctx context.Context, remote string, in io.Reader, size int64, modTime time.Time) (*Object, error) {
+	dir, name := path.Split(clean(remote))
+	parent, err := f.mkdirAll(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	old, err := f.setAside(ctx, remote, "")
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := buckets.UploadFileStreamAuto(ctx, f.cfg, parent.UUID, name, in, size, modTime)
+	if err != nil {
+		f.restore(ctx, old)
+		return nil, fmt.Errorf("failed to upload %q: %w", remote, err)
+	}
+	if err := f.removeAside(ctx, old); err != nil {
+		return nil, err
+	}
+
+	uploaded := &folders.File{
+		UUID:             meta.UUID,
+		FileID:           meta.FileID,
+		PlainName:        meta.PlainName,
+		Type:             meta.Type,
+		FolderUUID:       parent.UUID,
+		Bucket:           meta.Bucket,
+		Size:             meta.Size,
+		EncryptVersion:   meta.EncryptVersion,
+		ModificationTime: modTime,
+	}
+	if uploaded.Size == "" {
+		uploaded.Size = "0"
+	}
+	return &Object{fs: f, remote: clean(remote), file: uploaded}, nil
+}
+
+// Mkdir creates dir and any missing parents. It succeeds if dir exists.
+func (f *Fs) Mkdir(ctx context.Context, dir string) error {
+	_, err := f.mkdirAll(ctx, dir)
+	return err
+}
+
+// Rmdir removes the empty directory dir.
+func (f *Fs) Rmdir(ctx context.Context, dir string) error {
+	if clean(dir) == "" {
+		return fmt.Errorf("failed to remove root directory: %w", ErrDirNotEmpty)
+	}
+	folder, err := f.dir(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	subfolders, err := folders.ListFolders(ctx, f.cfg, folder.UUID, folders.ListOptions{Limit: 1})
+	if err != nil {
+		return fmt.Errorf("failed to list %q: %w", dir, err)
+	}
+	children, err := folders.ListFiles(ctx, f.cfg, folder.UUID, folders.ListOptions{Limit: 1})
+	if err != nil {
+		return fmt.Errorf("failed to list %q: %w", dir, err)
+	}
+	if len(subfolders) > 0 || len(children) > 0 {
+		return fmt.Errorf("failed to remove %q: %w", dir, ErrDirNotEmpty)
+	}
+	return folders.DeleteFolder(ctx, f.cfg, folder.UUID)
+}
+
+// Purge removes the directory dir and everything in it.
+func (f *Fs) Purge(ctx context.Context, dir string) error {
+	if clean(dir) == "" {
+		return fmt.Errorf("failed to purge root directory: %w", ErrIsDir)
+	}
+	folder, err := f.dir(ctx, dir)
+	if err != nil {
+		return err
+	}
+	return folders.DeleteFolder(ctx, f.cfg, folder.UUID)
+}
+
+// Move moves src to remote, creating missing parent directories, and returns
+// the moved object. Like Put, it replaces an existing file at remote once the
+// move succeeds; if it fails, the existing file is kept. As with Put, an
+// interrupted replace leaves the old file for Cleanup.
+func (f *Fs) Move(ctx context.Context, src *Object, remote string) (*Object, error) {
+	dir, name := path.Split(clean(remote))
+	parent, err := f.mkdirAll(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	old, err := f.setAside(ctx, remote, src.file.UUID)
+	if err != nil {
+		return nil, err
+	}
+
+	plainName, fileType := splitName(name)
+	if err := files.MoveFile(ctx, f.cfg, src.file.UUID, parent.UUID, plainName, fileType); err != nil {
+		f.restore(ctx, old)
+		return nil, fmt.Errorf("failed to move %q to %q: %w", src.remote, remote, err)
+	}
+	if err := f.removeAside(ctx, old); err != nil {
+		return nil, err
+	}
+
+	moved := *src.file
+	moved.FolderUUID = parent.UUID
+	moved.PlainName = plainName
+	moved.Type = fileType
+	return &Object{fs: f, remote: clean(remote), file: &moved}, nil
+}
+
+// DirMove moves the directory srcRemote to dstRemote, which must not exist.
+func (f *Fs) DirMove(ctx context.Context, srcRemote, dstRemote string) error {
+	src, err := f.dir(ctx, srcRemote)
+	if err != nil {
+		return err
+	}
+	dir, name := path.Split(clean(dstRemote))
+	parent, err := f.mkdirAll(ctx, dir)
+	if err != nil {
+		return err
+	}
+	if err := folders.MoveFolder(ctx, f.cfg, src.UUID, parent.UUID, name); err != nil {
+		return fmt.Errorf("failed to move %q to %q: %w", srcRemote, dstRemote, err)
+	}
+	return nil
+}
+
+// About returns the account's used, free and total storage.
+func (f *Fs) About(ctx context.Context) (*users.AboutInfo, error) {
+	return users.About(ctx, f.cfg)
+}
+
+// setAside renames the file at remote out of the way, since Drive rejects
+// duplicate names, and returns it. It returns nil if remote does not exist or
+// is the file keepUUID.
+func (f *Fs) setAside(ctx context.Context, remote, keepUUID string) (*Object, error) {
+	old, err := f.NewObject(ctx, remote)
+	switch {
+	case stderrors.Is(err, errors.ErrNotFound):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	case old.file.UUID == keepUUID:
+		return nil, nil
+	}
+	if err := files.RenameFile(ctx, f.cfg, old.file.UUID, old.file.PlainName+asideSuffix(old.file), old.file.Type); err != nil {
+		return nil, fmt.Errorf("failed to replace %q: %w", remote, err)
+	}
+	return old, nil
+}
+
+// restore gives a file set aside by setAside its name back.
+func (f *Fs) restore(ctx context.Context, old *Object) {
+	if old == nil {
+		return
+	}
+	if err := files.RenameFile(ctx, f.cfg, old.file.UUID, old.file.PlainName, old.file.Type); err != nil {
+		f.cfg.Log().Warn("failed to restore replaced file", "remote", old.remote, "error", err)
+	}
+}
+
+// removeAside deletes a file set aside by setAside once it has been replaced.
+func (f *Fs) removeAside(ctx context.Context, old *Object) error {
+	if old == nil {
+		return nil
+	}
+	if err := files.DeleteFile(ctx, f.cfg, old.file.UUID); err != nil {
+		return fmt.Errorf("failed to remove replaced %q: %w", old.remote, err)
+	}
+	return nil
+}
+
+// Cleanup deletes the files below the root that Put and Move set aside but
+// did not remove, because the process stopped before the replace finished.
+// Like rclone's fs.CleanUpper, frontends can run it at startup. Only files
+// whose name ends in their own asideSuffix are touched.
+func (f *Fs) Cleanup(ctx context.Context) error {
+	return f.cleanup(ctx, f.root)
+}
+
+func (f *Fs) cleanup(ctx context.Context, folderUUID string) error {
+	children, err := folders.ListAllFiles(ctx, f.cfg, folderUUID)
+	if err != nil {
+		return fmt.Errorf("failed to list folder %s: %w", folderUUID, err)
+	}
+	for i := range children {
+		file := &children[i]
+		if file.UUID == "" || !strings.HasSuffix(file.PlainName, asideSuffix(file)) {
+			continue
+		}
+		if err := files.DeleteFile(ctx, f.cfg, file.UUID); err != nil {
+			return fmt.Errorf("failed to remove replaced %q: %w", folders.FileName(file), err)
+		}
+		f.cfg.Log().Info("removed file left by an interrupted replace", "name", folders.FileName(file), "uuid", file.UUID)
+	}
+
+	subfolders, err := folders.ListAllFolders(ctx, f.cfg, folderUUID)
+	if err != nil {
+		return fmt.Errorf("failed to list folder %s: %w", folderUUID, err)
+	}
+	for i := range subfolders {
+		if err := f.cleanup(ctx, subfolders[i].UUID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// asideSuffix is appended to the plain name of a file set aside by setAside.
+// It embeds the file's own UUID, so Cleanup cannot mistake a user's file for one.
+func asideSuffix(file *folders.File) string {
+	return ".old-" + file.UUID
+}
+
+// dir resolves dir, failing with ErrIsFile if it names a file.
+func (f *Fs) dir(ctx context.Context, dir string) (*folders.Folder, error) {
+	entry, err := folders.ResolvePath(ctx, f.cfg, f.root, dir)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Folder == nil {
+		return nil, fmt.Errorf("failed to open directory %q: %w", dir, ErrIsFile)
+	}
+	return entry.Folder, nil
+}
+
+// mkdirAll returns the folder dir, creating it and its missing parents.
+func (f *Fs) mkdirAll(ctx context.Context, dir string) (*folders.Folder, error) {
+	folder := &folders.Folder{UUID: f.root}
+	var walked string
+	for _, name := range strings.Split(clean(dir), "/") {
+		if name == "" {
+			continue
+		}
+		walked = join(walked, name)
+
+		entry, err := folders.ResolvePath(ctx, f.cfg, folder.UUID, name)
+		switch {
+		case err == nil && entry.Folder != nil:
+			folder = entry.Folder
+			continue
+		case err == nil:
+			return nil, fmt.Errorf("failed to create directory %q: %w", walked, ErrIsFile)
+		case !stderrors.Is(err, errors.ErrNotFound):
+			return nil, err
+		}
+
+		created, err := folders.CreateFolder(ctx, f.cfg, folders.CreateFolderRequest{
+			PlainName:        name,
+			ParentFolderUUID: folder.UUID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create directory %q: %w", walked, err)
+		}
+		folder = created
+	}
+	return folder, nil
+}
+
+// splitName splits a file name into Drive's plain name and type, the same
+// way uploads do.
+func splitName(name string) (plainName, fileType string) {
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext), strings.TrimPrefix(ext, ".")
+}
+
+func clean(remote string) string {
+	return strings.Trim(path.Clean("/"+remote), "/")
+}
+
+func join(dir, name string) string {
+	if dir = clean(dir); dir == "" {
+		return name
+	}
+	return dir + "/" + name
+}
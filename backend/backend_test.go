@@ -0,0 +1,225 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
+	"github.com/internxt/rclone-adapter/folders"
+)
+
+// mockDrive serves a root folder holding the empty folder "docs" and the
+// file "notes.txt", and records mutating requests.
+type mockDrive struct {
+	mu       sync.Mutex
+	requests []string
+	failMove bool // Reject file moves with 409 Conflict
+}
+
+func (m *mockDrive) handler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			m.mu.Lock()
+			m.requests = append(m.requests, r.Method+" "+r.URL.Path)
+			m.mu.Unlock()
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/drive/folders":
+			var req folders.CreateFolderRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			json.NewEncoder(w).Encode(folders.Folder{UUID: req.PlainName + "-uuid", PlainName: req.PlainName, ParentUUID: req.ParentFolderUUID})
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/drive/folders/"):
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/drive/files/"):
+			if m.failMove {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/drive/files/"),
+			r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/drive/files/"):
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/drive/folders/content/root/folders":
+			json.NewEncoder(w).Encode(map[string][]folders.Folder{"folders": {{UUID: "docs-uuid", PlainName: "docs"}}})
+		case r.URL.Path == "/drive/folders/content/root/files":
+			json.NewEncoder(w).Encode(map[string][]folders.File{"files": {{UUID: "notes-uuid", FileID: "notes-id", PlainName: "notes", Type: "txt", Size: "42"}}})
+		case strings.HasSuffix(r.URL.Path, "/folders"):
+			json.NewEncoder(w).Encode(map[string][]folders.Folder{"folders": {}})
+		case strings.HasSuffix(r.URL.Path, "/files"):
+			json.NewEncoder(w).Encode(map[string][]folders.File{"files": {}})
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+func newTestFs(t *testing.T) (*Fs, *mockDrive) {
+	m := &mockDrive{}
+	server := httptest.NewServer(m.handler(t))
+	t.Cleanup(server.Close)
+	return NewFs(newTestConfig(server.URL), "root"), m
+}
+
+func TestList(t *testing.T) {
+	f, _ := newTestFs(t)
+
+	entries, err := f.List(context.Background(), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if d, ok := entries[0].(*Directory); !ok || d.Remote() != "docs" || d.ID() != "docs-uuid" {
+		t.Errorf("expected directory docs, got %+v", entries[0])
+	}
+	if o, ok := entries[1].(*Object); !ok || o.Remote() != "notes.txt" || o.Size() != 42 {
+		t.Errorf("expected object notes.txt of 42 bytes, got %+v", entries[1])
+	}
+
+	if _, err := f.List(context.Background(), "notes.txt"); !errors.Is(err, ErrIsFile) {
+		t.Errorf("expected ErrIsFile listing a file, got %v", err)
+	}
+}
+
+func TestNewObject(t *testing.T) {
+	f, _ := newTestFs(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		remote  string
+		wantErr error
+	}{
+		{"notes.txt", nil},
+		{"/notes.txt", nil},
+		{"docs", ErrIsDir},
+		{"missing.txt", sdkerrors.ErrNotFound},
+	}
+	for _, tt := range tests {
+		obj, err := f.NewObject(ctx, tt.remote)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%q: expected %v, got %v", tt.remote, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tt.remote, err)
+		}
+		if obj.Remote() != "notes.txt" || obj.UUID() != "notes-uuid" {
+			t.Errorf("%q: unexpected object %+v", tt.remote, obj)
+		}
+	}
+}
+
+func TestMkdirRmdir(t *testing.T) {
+	f, m := newTestFs(t)
+	ctx := context.Background()
+
+	if err := f.Mkdir(ctx, "docs"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(m.requests) != 0 {
+		t.Errorf("expected existing directory to be reused, got %v", m.requests)
+	}
+	if err := f.Mkdir(ctx, "photos"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := f.Mkdir(ctx, "notes.txt/sub"); !errors.Is(err, ErrIsFile) {
+		t.Errorf("expected ErrIsFile, got %v", err)
+	}
+
+	if err := f.Rmdir(ctx, "docs"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := f.Rmdir(ctx, ""); !errors.Is(err, ErrDirNotEmpty) {
+		t.Errorf("expected ErrDirNotEmpty removing the root, got %v", err)
+	}
+
+	want := []string{"POST /drive/folders", "DELETE /drive/folders/docs-uuid"}
+	if strings.Join(m.requests, ",") != strings.Join(want, ",") {
+		t.Errorf("expected requests %v, got %v", want, m.requests)
+	}
+}
+
+func TestMove(t *testing.T) {
+	f, m := newTestFs(t)
+	ctx := context.Background()
+
+	src, err := f.NewObject(ctx, "notes.txt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	moved, err := f.Move(ctx, src, "docs/todo.md")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if moved.Remote() != "docs/todo.md" || moved.File().FolderUUID != "docs-uuid" || moved.File().PlainName != "todo" || moved.File().Type != "md" {
+		t.Errorf("unexpected moved object %+v", moved.File())
+	}
+	if len(m.requests) != 1 || m.requests[0] != "PATCH /drive/files/notes-uuid" {
+		t.Errorf("expected a single move request, got %v", m.requests)
+	}
+}
+
+func TestMoveReplace(t *testing.T) {
+	tests := []struct {
+		name     string
+		failMove bool
+		want     []string
+	}{
+		{"replaces target", false, []string{"PUT /drive/files/notes-uuid/meta", "PATCH /drive/files/draft-uuid", "DELETE /drive/files/notes-uuid"}},
+		{"restores target on failure", true, []string{"PUT /drive/files/notes-uuid/meta", "PATCH /drive/files/draft-uuid", "PUT /drive/files/notes-uuid/meta"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, m := newTestFs(t)
+			m.failMove = tt.failMove
+			src := &Object{fs: f, remote: "docs/draft.txt", file: &folders.File{UUID: "draft-uuid", PlainName: "draft", Type: "txt", FolderUUID: "docs-uuid"}}
+
+			_, err := f.Move(context.Background(), src, "notes.txt")
+			if tt.failMove && err == nil {
+				t.Fatal("expected the move to fail")
+			}
+			if !tt.failMove && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if strings.Join(m.requests, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected requests %v, got %v", tt.want, m.requests)
+			}
+		})
+	}
+}
+
+func TestStatPurge(t *testing.T) {
+	f, m := newTestFs(t)
+	ctx := context.Background()
+
+	if entry, err := f.Stat(ctx, "docs"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if _, ok := entry.(*Directory); !ok {
+		t.Errorf("expected a directory, got %T", entry)
+	}
+	if entry, err := f.Stat(ctx, "notes.txt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	} else if _, ok := entry.(*Object); !ok {
+		t.Errorf("expected an object, got %T", entry)
+	}
+
+	if err := f.Purge(ctx, "docs"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := f.Purge(ctx, ""); !errors.Is(err, ErrIsDir) {
+		t.Errorf("expected purging the root to fail, got %v", err)
+	}
+	if len(m.requests) != 1 || m.requests[0] != "DELETE /drive/folders/docs-uuid" {
+		t.Errorf("expected a single delete request, got %v", m.requests)
+	}
+}
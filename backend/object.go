@@ -0,0 +1,140 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/internxt/rclone-adapter/buckets"
+	"github.com/internxt/rclone-adapter/files"
+	"github.com/internxt/rclone-adapter/folders"
+)
+
+// Object is a file in an Fs.
+type Object struct {
+	fs     *Fs
+	remote string
+	file   *folders.File
+}
+
+// Remote returns the path of the object relative to the Fs root.
+func (o *Object) Remote() string { return o.remote }
+
+// UUID returns the Drive UUID of the file.
+func (o *Object) UUID() string { return o.file.UUID }
+
+// File returns the Drive metadata of the file.
+func (o *Object) File() *folders.File { return o.file }
+
+// Size returns the plaintext size in bytes.
+func (o *Object) Size() int64 {
+	n, _ := o.file.Size.Int64()
+	return n
+}
+
+// ModTime returns the modification time, falling back to the time the Drive
+// entry was last updated.
+func (o *Object) ModTime() time.Time {
+	if !o.file.ModificationTime.IsZero() {
+		return o.file.ModificationTime
+	}
+	return o.file.UpdatedAt
+}
+
+// OpenOption selects the part of an Object that Open reads, mirroring
+// rclone's fs.OpenOption. It is implemented by *RangeOption and *SeekOption.
+type OpenOption interface {
+	openOption()
+}
+
+// RangeOption reads the bytes Start to End inclusive, like rclone's
+// fs.RangeOption. A negative End reads to the end of the file, and a
+// negative Start reads the last End bytes.
+type RangeOption struct {
+	Start, End int64
+}
+
+func (*RangeOption) openOption() {}
+
+// Decode returns the offset and length the option reads from a file of
+// size bytes. A negative length reads to the end of the file.
+func (o *RangeOption) Decode(size int64) (offset, limit int64) {
+	switch {
+	case o.Start >= 0 && o.End >= 0:
+		return o.Start, max(o.End-o.Start+1, 0)
+	case o.Start >= 0:
+		return o.Start, -1
+	case o.End >= 0:
+		return max(size-o.End, 0), -1
+	default:
+		return 0, -1
+	}
+}
+
+// SeekOption reads from Offset to the end of the file, like rclone's
+// fs.SeekOption.
+type SeekOption struct {
+	Offset int64
+}
+
+func (*SeekOption) openOption() {}
+
+// Open returns the decrypted contents, all of them or the part selected by
+// options. When several options are given the last one wins.
+func (o *Object) Open(ctx context.Context, options ...OpenOption) (io.ReadCloser, error) {
+	offset, limit := int64(0), int64(-1)
+	for _, option := range options {
+		switch x := option.(type) {
+		case *RangeOption:
+			offset, limit = x.Decode(o.Size())
+		case *SeekOption:
+			offset, limit = x.Offset, -1
+		}
+	}
+
+	var rng []string
+	switch {
+	case limit == 0:
+		return io.NopCloser(io.MultiReader()), nil
+	case limit > 0:
+		rng = append(rng, fmt.Sprintf("bytes=%d-%d", offset, offset+limit-1))
+	case offset > 0:
+		rng = append(rng, fmt.Sprintf("bytes=%d-", offset))
+	}
+	return buckets.DownloadFileStream(ctx, o.fs.cfg, o.file.FileID, o.file.EncryptVersion, rng...)
+}
+
+// Verify checks the stored contents of the file against their network hash
+// without decrypting them, see buckets.VerifyFile.
+func (o *Object) Verify(ctx context.Context) error {
+	return buckets.VerifyFile(ctx, o.fs.cfg, o.file.FileID)
+}
+
+// Remove deletes the file.
+func (o *Object) Remove(ctx context.Context) error {
+	return files.DeleteFile(ctx, o.fs.cfg, o.file.UUID)
+}
+
+// Directory is a folder in an Fs.
+type Directory struct {
+	remote string
+	folder *folders.Folder
+}
+
+// Remote returns the path of the directory relative to the Fs root.
+func (d *Directory) Remote() string { return d.remote }
+
+// ID returns the Drive UUID of the folder.
+func (d *Directory) ID() string { return d.folder.UUID }
+
+// Size returns the folder size reported by Drive, usually 0.
+func (d *Directory) Size() int64 { return d.folder.Size }
+
+// ModTime returns the modification time of the folder.
+func (d *Directory) ModTime() time.Time {
+	if !d.folder.ModificationTime.IsZero() {
+		return d.folder.ModificationTime
+	}
+	return d.folder.UpdatedAt
+}
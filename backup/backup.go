@@ -0,0 +1,194 @@
+// Package backup takes scheduled snapshots of local directories, like the
+// backup feature of the desktop app. Each snapshot is a full copy of the
+// source in a folder named after its UTC start time below the job's target
+// directory; old snapshots are pruned by a retention Policy.
+//
+// A snapshot is uploaded into a folder with a ".partial" suffix that is
+// renamed once complete. An interrupted snapshot is resumed by the next run,
+// which skips the files already uploaded with the same size and
+// modification time.
+//
+// Snapshots go wherever the Fs points: a folder of the drive, or the
+// account's backups bucket when the Fs config carries that bucket.
+package backup
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/internxt/rclone-adapter/backend"
+	"github.com/internxt/rclone-adapter/errors"
+)
+
+// SnapshotFormat is the time layout of snapshot folder names.
+const SnapshotFormat = "2006-01-02T15-04-05Z"
+
+const partialSuffix = ".partial"
+
+// Job describes what to back up, where, and how often.
+type Job struct {
+	Source    string        // Local directory to back up
+	Target    string        // Remote directory holding the snapshots
+	Interval  time.Duration // Time between scheduled snapshots (default DefaultInterval)
+	Retention Policy
+}
+
+// Result reports one snapshot.
+type Result struct {
+	Snapshot time.Time
+	Name     string      // Folder name below the job's target
+	Files    int         // Files uploaded
+	Bytes    int64       // Bytes uploaded
+	Resumed  int         // Files kept from an interrupted run
+	Pruned   []time.Time // Snapshots removed by the retention policy
+}
+
+// Backup takes one snapshot of job, resuming an interrupted one if
+// there is any, then applies the retention policy.
+func (s *Scheduler) Backup(ctx context.Context, job Job) (*Result, error) {
+	snapshots, partial, err := listSnapshots(ctx, s.fs, job.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	existing := map[string]backend.Entry{}
+	if partial.IsZero() {
+		result.Snapshot = s.now().UTC().Truncate(time.Second)
+		if len(snapshots) > 0 && !result.Snapshot.After(snapshots[len(snapshots)-1]) {
+			result.Snapshot = snapshots[len(snapshots)-1].Add(time.Second)
+		}
+	} else {
+		result.Snapshot = partial
+		if err := walkRemote(ctx, s.fs, path.Join(job.Target, partial.Format(SnapshotFormat)+partialSuffix), "", existing); err != nil {
+			return nil, err
+		}
+	}
+	result.Name = result.Snapshot.Format(SnapshotFormat)
+	dir := path.Join(job.Target, result.Name+partialSuffix)
+
+	if err := s.fs.Mkdir(ctx, dir); err != nil {
+		return nil, err
+	}
+	err = filepath.WalkDir(job.Source, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(job.Source, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			return s.fs.Mkdir(ctx, path.Join(dir, rel))
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if e, ok := existing[rel]; ok && e.Size() == info.Size() && e.ModTime().Unix() == info.ModTime().Unix() {
+			result.Resumed++
+			return nil
+		}
+		if err := s.upload(ctx, p, path.Join(dir, rel), info); err != nil {
+			return err
+		}
+		result.Files++
+		result.Bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to back up %q: %w", job.Source, err)
+	}
+	if err := s.fs.DirMove(ctx, dir, path.Join(job.Target, result.Name)); err != nil {
+		return result, fmt.Errorf("failed to complete snapshot %s: %w", result.Name, err)
+	}
+
+	snapshots = append(snapshots, result.Snapshot)
+	for _, t := range job.Retention.Expired(snapshots) {
+		if err := s.fs.Purge(ctx, path.Join(job.Target, t.Format(SnapshotFormat))); err != nil {
+			return result, fmt.Errorf("failed to prune snapshot %s: %w", t.Format(SnapshotFormat), err)
+		}
+		result.Pruned = append(result.Pruned, t)
+	}
+	return result, nil
+}
+
+func (s *Scheduler) upload(ctx context.Context, local, remote string, info fs.FileInfo) error {
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = s.fs.Put(ctx, remote, f, info.Size(), info.ModTime())
+	return err
+}
+
+// ListSnapshots returns the times of the complete snapshots in target,
+// oldest first.
+func ListSnapshots(ctx context.Context, f *backend.Fs, target string) ([]time.Time, error) {
+	snapshots, _, err := listSnapshots(ctx, f, target)
+	return snapshots, err
+}
+
+// listSnapshots returns the complete snapshots in target, oldest first, and
+// the time of the newest partial one, if any. A missing target has none.
+func listSnapshots(ctx context.Context, f *backend.Fs, target string) (snapshots []time.Time, partial time.Time, err error) {
+	entries, err := f.List(ctx, target)
+	if stderrors.Is(err, errors.ErrNotFound) {
+		return nil, time.Time{}, nil
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	for _, entry := range entries {
+		if _, ok := entry.(*backend.Directory); !ok {
+			continue
+		}
+		name := path.Base(entry.Remote())
+		isPartial := strings.HasSuffix(name, partialSuffix)
+		t, err := time.Parse(SnapshotFormat, strings.TrimSuffix(name, partialSuffix))
+		switch {
+		case err != nil:
+		case isPartial:
+			if t.After(partial) {
+				partial = t
+			}
+		default:
+			snapshots = append(snapshots, t)
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Before(snapshots[j]) })
+	return snapshots, partial, nil
+}
+
+// walkRemote adds the files below dir, keyed by their path relative to
+// root, to files.
+func walkRemote(ctx context.Context, f *backend.Fs, root, dir string, files map[string]backend.Entry) error {
+	entries, err := f.List(ctx, path.Join(root, dir))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		rel := path.Join(dir, path.Base(entry.Remote()))
+		if _, ok := entry.(*backend.Directory); ok {
+			if err := walkRemote(ctx, f, root, rel, files); err != nil {
+				return err
+			}
+			continue
+		}
+		files[rel] = entry
+	}
+	return nil
+}
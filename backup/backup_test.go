@@ -0,0 +1,150 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/internxt/rclone-adapter/backend"
+	"github.com/internxt/rclone-adapter/internal/fakedrive"
+)
+
+func newTestScheduler(t *testing.T) (*Scheduler, *fakedrive.Server, string) {
+	drive := fakedrive.New()
+	t.Cleanup(drive.Close)
+	source := t.TempDir()
+	for name, data := range map[string]string{"a.txt": "aaa", "docs/b.txt": "bb", "docs/empty/.keep": ""} {
+		p := filepath.Join(source, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewScheduler(backend.NewFs(drive.Config(), ""), nil), drive, source
+}
+
+func content(t *testing.T, drive *fakedrive.Server, p string) string {
+	uuid, isDir, ok := drive.Lookup(p)
+	if !ok || isDir {
+		t.Fatalf("expected file %s", p)
+	}
+	data, _ := drive.Content(uuid)
+	return string(data)
+}
+
+func TestBackup(t *testing.T) {
+	s, drive, source := newTestScheduler(t)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	job := Job{Source: source, Target: "backups/laptop", Retention: Policy{KeepLast: 2}}
+
+	result, err := s.Backup(ctx, job)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Name != "2026-03-01T12-00-00Z" || result.Files != 3 || result.Bytes != 5 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if got := content(t, drive, "backups/laptop/2026-03-01T12-00-00Z/docs/b.txt"); got != "bb" {
+		t.Errorf("unexpected content %q", got)
+	}
+
+	// Snapshots taken within the same second still get distinct names.
+	if result, err = s.Backup(ctx, job); err != nil || result.Name != "2026-03-01T12-00-01Z" {
+		t.Fatalf("unexpected result %+v, %v", result, err)
+	}
+
+	now = now.Add(time.Hour)
+	if result, err = s.Backup(ctx, job); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Pruned) != 1 || !result.Pruned[0].Equal(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the oldest snapshot pruned, got %v", result.Pruned)
+	}
+	snapshots, err := ListSnapshots(ctx, s.fs, job.Target)
+	if err != nil || len(snapshots) != 2 {
+		t.Errorf("expected 2 snapshots, got %v, %v", snapshots, err)
+	}
+	if _, _, ok := drive.Lookup("backups/laptop/2026-03-01T12-00-00Z"); ok {
+		t.Error("expected the pruned snapshot deleted")
+	}
+}
+
+func TestBackupResume(t *testing.T) {
+	s, drive, source := newTestScheduler(t)
+	ctx := context.Background()
+	s.now = func() time.Time { return time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC) }
+	job := Job{Source: source, Target: "backups"}
+
+	// An earlier run was interrupted after uploading a.txt.
+	info, err := os.Stat(filepath.Join(source, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	backups := drive.AddFolder(fakedrive.RootUUID, "backups")
+	partial := drive.AddFolder(backups, "2026-03-01T00-00-00Z.partial")
+	drive.AddFile(partial, "a.txt", []byte("aaa"), info.ModTime())
+
+	result, err := s.Backup(ctx, job)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Name != "2026-03-01T00-00-00Z" || result.Resumed != 1 || result.Files != 2 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if _, _, ok := drive.Lookup("backups/2026-03-01T00-00-00Z.partial"); ok {
+		t.Error("expected the partial snapshot renamed")
+	}
+	if got := content(t, drive, "backups/2026-03-01T00-00-00Z/a.txt"); got != "aaa" {
+		t.Errorf("unexpected content %q", got)
+	}
+}
+
+func TestFirstRun(t *testing.T) {
+	s, drive, _ := newTestScheduler(t)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	backups := drive.AddFolder(fakedrive.RootUUID, "backups")
+
+	job := Job{Target: "backups", Interval: 24 * time.Hour}
+	if got := s.firstRun(ctx, job); !got.Equal(now) {
+		t.Errorf("without snapshots: expected %v, got %v", now, got)
+	}
+	drive.AddFolder(backups, "2026-03-01T06-00-00Z")
+	if got, want := s.firstRun(ctx, job), time.Date(2026, 3, 2, 6, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("after a recent snapshot: expected %v, got %v", want, got)
+	}
+	drive.AddFolder(backups, "2026-03-01T12-00-00Z.partial")
+	if got := s.firstRun(ctx, job); !got.Equal(now) {
+		t.Errorf("with an interrupted snapshot: expected %v, got %v", now, got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	s, _, source := newTestScheduler(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Add(Job{Source: source, Target: "backups", Interval: time.Hour})
+
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if snapshots, _ := ListSnapshots(context.Background(), s.fs, "backups"); len(snapshots) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected a snapshot to be taken")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package backup
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Policy selects the snapshots to keep. A snapshot is kept if any rule
+// selects it; the zero Policy keeps everything. The grandfather-father-son
+// rules keep the newest snapshot of each of the last N days, ISO weeks,
+// months and years that have one, counted in UTC.
+type Policy struct {
+	KeepLast    int // Newest snapshots to keep
+	KeepDaily   int
+	KeepWeekly  int
+	KeepMonthly int
+	KeepYearly  int
+}
+
+func (p Policy) keepsAll() bool {
+	return p.KeepLast <= 0 && p.KeepDaily <= 0 && p.KeepWeekly <= 0 && p.KeepMonthly <= 0 && p.KeepYearly <= 0
+}
+
+// Expired returns the snapshot times p does not keep, oldest first.
+func (p Policy) Expired(snapshots []time.Time) []time.Time {
+	if p.keepsAll() {
+		return nil
+	}
+	newest := append([]time.Time(nil), snapshots...)
+	sort.Slice(newest, func(i, j int) bool { return newest[i].After(newest[j]) })
+
+	keep := make([]bool, len(newest))
+	for i := range min(p.KeepLast, len(newest)) {
+		keep[i] = true
+	}
+	rules := []struct {
+		n      int
+		period func(time.Time) string
+	}{
+		{p.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{p.KeepWeekly, func(t time.Time) string { y, w := t.ISOWeek(); return fmt.Sprintf("%d-W%02d", y, w) }},
+		{p.KeepMonthly, func(t time.Time) string { return t.Format("2006-01") }},
+		{p.KeepYearly, func(t time.Time) string { return t.Format("2006") }},
+	}
+	for _, rule := range rules {
+		seen := map[string]bool{}
+		for i, t := range newest {
+			if len(seen) >= rule.n {
+				break
+			}
+			if period := rule.period(t.UTC()); !seen[period] {
+				seen[period] = true
+				keep[i] = true
+			}
+		}
+	}
+
+	var expired []time.Time
+	for i := len(newest) - 1; i >= 0; i-- {
+		if !keep[i] {
+			expired = append(expired, newest[i])
+		}
+	}
+	return expired
+}
@@ -0,0 +1,48 @@
+package backup
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPolicyExpired(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2026, 1, d, h, 0, 0, 0, time.UTC) }
+	// Two snapshots a day from Thursday 1 January to Tuesday 20 January.
+	var snapshots []time.Time
+	for d := 1; d <= 20; d++ {
+		snapshots = append(snapshots, day(d, 6), day(d, 18))
+	}
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		wantKept []time.Time
+	}{
+		{"keep all", Policy{}, snapshots},
+		{"last", Policy{KeepLast: 3}, []time.Time{day(19, 18), day(20, 6), day(20, 18)}},
+		{"daily", Policy{KeepDaily: 2}, []time.Time{day(19, 18), day(20, 18)}},
+		{"weekly", Policy{KeepWeekly: 3}, []time.Time{day(11, 18), day(18, 18), day(20, 18)}},
+		{"monthly and last", Policy{KeepLast: 1, KeepMonthly: 5}, []time.Time{day(20, 18)}},
+		{"combined", Policy{KeepLast: 1, KeepDaily: 2, KeepWeekly: 2}, []time.Time{day(18, 18), day(19, 18), day(20, 18)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expired := tt.policy.Expired(snapshots)
+			if len(expired)+len(tt.wantKept) != len(snapshots) {
+				t.Fatalf("expected %d kept, got %d expired of %d", len(tt.wantKept), len(expired), len(snapshots))
+			}
+			gone := map[time.Time]bool{}
+			for i, e := range expired {
+				if i > 0 && !e.After(expired[i-1]) {
+					t.Errorf("expected expired snapshots oldest first")
+				}
+				gone[e] = true
+			}
+			for _, k := range tt.wantKept {
+				if gone[k] {
+					t.Errorf("expected %v kept", k)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package backup
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/internxt/rclone-adapter/backend"
+)
+
+// DefaultInterval is used by Add for jobs without an interval.
+const DefaultInterval = 24 * time.Hour
+
+// RetryDelay is how long a failed job waits before it is retried, unless
+// its interval is shorter.
+const RetryDelay = 5 * time.Minute
+
+// Scheduler runs backup jobs at their intervals.
+type Scheduler struct {
+	fs     *backend.Fs
+	logger *slog.Logger
+	now    func() time.Time
+	jobs   []*scheduledJob
+}
+
+type scheduledJob struct {
+	job  Job
+	next time.Time // zero until the job's snapshots have been listed
+}
+
+// NewScheduler returns a Scheduler backing up to fs. A nil logger discards
+// the job reports.
+func NewScheduler(fs *backend.Fs, logger *slog.Logger) *Scheduler {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Scheduler{fs: fs, logger: logger, now: time.Now}
+}
+
+// Add schedules job. Its first snapshot is due one interval after its
+// newest existing snapshot, so restarting the scheduler does not repeat a
+// recent backup.
+func (s *Scheduler) Add(job Job) {
+	if job.Interval <= 0 {
+		job.Interval = DefaultInterval
+	}
+	s.jobs = append(s.jobs, &scheduledJob{job: job})
+}
+
+// Run runs the jobs when due until ctx is done, and returns ctx's error.
+// Failed jobs are logged and retried after RetryDelay; the next attempt
+// resumes the interrupted snapshot.
+func (s *Scheduler) Run(ctx context.Context) error {
+	for {
+		var due *scheduledJob
+		for _, j := range s.jobs {
+			if j.next.IsZero() {
+				j.next = s.firstRun(ctx, j.job)
+			}
+			if due == nil || j.next.Before(due.next) {
+				due = j
+			}
+		}
+		if due == nil {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+
+		timer := time.NewTimer(due.next.Sub(s.now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		result, err := s.Backup(ctx, due.job)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			s.logger.Warn("backup failed", "source", due.job.Source, "target", due.job.Target, "error", err)
+			due.next = s.now().Add(min(RetryDelay, due.job.Interval))
+			continue
+		}
+		s.logger.Info("backup completed", "source", due.job.Source, "snapshot", result.Name,
+			"files", result.Files, "bytes", result.Bytes, "resumed", result.Resumed, "pruned", len(result.Pruned))
+		due.next = s.now().Add(due.job.Interval)
+	}
+}
+
+// firstRun returns when job is first due: at once if it has no snapshot or
+// an interrupted one, otherwise one interval after its newest snapshot.
+func (s *Scheduler) firstRun(ctx context.Context, job Job) time.Time {
+	now := s.now()
+	snapshots, partial, err := listSnapshots(ctx, s.fs, job.Target)
+	if err != nil {
+		s.logger.Warn("failed to list snapshots", "target", job.Target, "error", err)
+		return now
+	}
+	if !partial.IsZero() || len(snapshots) == 0 {
+		return now
+	}
+	if next := snapshots[len(snapshots)-1].Add(job.Interval); next.After(now) {
+		return next
+	}
+	return now
+}
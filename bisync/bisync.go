@@ -0,0 +1,334 @@
+// Package bisync keeps a local directory and a drive folder in step in both
+// directions. A State database records the size, modification times and
+// UUID of every file as of the last sync, which tells apart a file that was
+// created on one side from one deleted on the other, and a one-sided change
+// from a conflict. Conflicts are settled by a configurable Resolution.
+//
+// Only files are tracked: directories are created as needed to hold them,
+// and directories emptied by a sync are left in place.
+package bisync
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/internxt/rclone-adapter/backend"
+	"github.com/internxt/rclone-adapter/errors"
+)
+
+// Resolution decides what happens when a file changed on both sides.
+type Resolution int
+
+const (
+	// NewerWins keeps the side with the later modification time.
+	NewerWins Resolution = iota
+	// KeepBoth keeps the remote version at the path and the local one
+	// renamed with a ".conflict-<time>" suffix, on both sides.
+	KeepBoth
+)
+
+// Report lists the paths acted on by a sync.
+type Report struct {
+	Uploaded      []string
+	Downloaded    []string
+	DeletedLocal  []string
+	DeletedRemote []string
+	Conflicts     []string
+}
+
+// file is a file as seen on one side.
+type file struct {
+	size    int64
+	modTime time.Time
+	obj     *backend.Object // remote side only
+}
+
+// Syncer syncs the local directory localRoot with an Fs.
+type Syncer struct {
+	fs         *backend.Fs
+	localRoot  string
+	statePath  string
+	resolution Resolution
+	state      *State
+	now        func() time.Time
+}
+
+// New returns a Syncer for localRoot and fs, keeping its State at statePath.
+func New(fs *backend.Fs, localRoot, statePath string, resolution Resolution) (*Syncer, error) {
+	state, err := LoadState(statePath)
+	if err != nil {
+		return nil, err
+	}
+	return &Syncer{fs: fs, localRoot: localRoot, statePath: statePath, resolution: resolution, state: state, now: time.Now}, nil
+}
+
+// Run performs one sync and saves the state, also when it stops on an error
+// so that completed transfers are not repeated.
+func (s *Syncer) Run(ctx context.Context) (*Report, error) {
+	local, err := s.walkLocal()
+	if err != nil {
+		return nil, err
+	}
+	remote := map[string]file{}
+	if err := s.walkRemote(ctx, "", remote); err != nil {
+		return nil, err
+	}
+
+	paths := map[string]bool{}
+	for p := range local {
+		paths[p] = true
+	}
+	for p := range remote {
+		paths[p] = true
+	}
+	for p := range s.state.Entries {
+		paths[p] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	report := &Report{}
+	for _, p := range sorted {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		l, lok := local[p]
+		r, rok := remote[p]
+		if err = s.syncPath(ctx, report, p, l, lok, r, rok); err != nil {
+			err = fmt.Errorf("failed to sync %q: %w", p, err)
+			break
+		}
+	}
+
+	if serr := s.state.Save(s.statePath); err == nil {
+		err = serr
+	}
+	return report, err
+}
+
+// syncPath reconciles one path given what each side and the state hold.
+func (s *Syncer) syncPath(ctx context.Context, report *Report, p string, l file, lok bool, r file, rok bool) error {
+	prev, known := s.state.Entries[p]
+	localChanged := lok && (!known || l.size != prev.Size || !sameTime(l.modTime, prev.LocalModTime))
+	remoteChanged := rok && (!known || r.obj.UUID() != prev.UUID || r.size != prev.Size || !sameTime(r.modTime, prev.RemoteModTime))
+
+	switch {
+	case !lok && !rok:
+		delete(s.state.Entries, p)
+	case lok && !rok:
+		if known && !localChanged {
+			report.DeletedLocal = append(report.DeletedLocal, p)
+			return s.deleteLocal(p)
+		}
+		report.Uploaded = append(report.Uploaded, p)
+		return s.upload(ctx, p, p)
+	case !lok && rok:
+		if known && !remoteChanged {
+			report.DeletedRemote = append(report.DeletedRemote, p)
+			return s.deleteRemote(ctx, p, r)
+		}
+		report.Downloaded = append(report.Downloaded, p)
+		return s.download(ctx, p, r)
+	case !localChanged && !remoteChanged:
+	case localChanged && !remoteChanged:
+		report.Uploaded = append(report.Uploaded, p)
+		return s.upload(ctx, p, p)
+	case !localChanged && remoteChanged:
+		report.Downloaded = append(report.Downloaded, p)
+		return s.download(ctx, p, r)
+	case l.size == r.size && sameTime(l.modTime, r.modTime):
+		// Both sides changed the same way, e.g. on a first sync.
+		s.record(p, l, r.obj)
+	default:
+		report.Conflicts = append(report.Conflicts, p)
+		return s.resolve(ctx, report, p, l, r)
+	}
+	return nil
+}
+
+func (s *Syncer) resolve(ctx context.Context, report *Report, p string, l, r file) error {
+	if s.resolution == NewerWins {
+		if l.modTime.After(r.modTime) {
+			report.Uploaded = append(report.Uploaded, p)
+			return s.upload(ctx, p, p)
+		}
+		report.Downloaded = append(report.Downloaded, p)
+		return s.download(ctx, p, r)
+	}
+
+	conflict := conflictName(p, s.now())
+	if err := os.Rename(s.localPath(p), s.localPath(conflict)); err != nil {
+		return fmt.Errorf("failed to rename local conflict: %w", err)
+	}
+	report.Uploaded = append(report.Uploaded, conflict)
+	if err := s.upload(ctx, conflict, conflict); err != nil {
+		return err
+	}
+	report.Downloaded = append(report.Downloaded, p)
+	return s.download(ctx, p, r)
+}
+
+func (s *Syncer) upload(ctx context.Context, localRel, remote string) error {
+	f, err := os.Open(s.localPath(localRel))
+	if err != nil {
+		return fmt.Errorf("failed to open local file: %w", err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat local file: %w", err)
+	}
+
+	obj, err := s.fs.Put(ctx, remote, f, fi.Size(), fi.ModTime())
+	if err != nil {
+		return err
+	}
+	s.record(remote, file{size: fi.Size(), modTime: fi.ModTime()}, obj)
+	return nil
+}
+
+func (s *Syncer) download(ctx context.Context, p string, r file) error {
+	dst := s.localPath(p)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("failed to create local directory: %w", err)
+	}
+	rc, err := r.obj.Open(ctx)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".bisync-download-*")
+	if err != nil {
+		return fmt.Errorf("failed to create local file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, rc); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to download: %w", err)
+	}
+	if err := rc.Close(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to download: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write local file: %w", err)
+	}
+	if err := os.Chtimes(tmp.Name(), r.modTime, r.modTime); err != nil {
+		return fmt.Errorf("failed to set local modification time: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), dst); err != nil {
+		return fmt.Errorf("failed to replace local file: %w", err)
+	}
+
+	fi, err := os.Stat(dst)
+	if err != nil {
+		return fmt.Errorf("failed to stat local file: %w", err)
+	}
+	s.record(p, file{size: fi.Size(), modTime: fi.ModTime()}, r.obj)
+	return nil
+}
+
+func (s *Syncer) deleteLocal(p string) error {
+	if err := os.Remove(s.localPath(p)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete local file: %w", err)
+	}
+	delete(s.state.Entries, p)
+	return nil
+}
+
+func (s *Syncer) deleteRemote(ctx context.Context, p string, r file) error {
+	if err := r.obj.Remove(ctx); err != nil && !stderrors.Is(err, errors.ErrNotFound) {
+		return err
+	}
+	delete(s.state.Entries, p)
+	return nil
+}
+
+func (s *Syncer) record(p string, l file, obj *backend.Object) {
+	s.state.Entries[p] = Entry{
+		Size:          l.size,
+		LocalModTime:  l.modTime,
+		RemoteModTime: obj.ModTime(),
+		UUID:          obj.UUID(),
+	}
+}
+
+// walkLocal returns the regular files below localRoot, skipping the state
+// database and unfinished downloads.
+func (s *Syncer) walkLocal() (map[string]file, error) {
+	files := map[string]file{}
+	statePath, _ := filepath.Abs(s.statePath)
+	err := filepath.WalkDir(s.localRoot, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || strings.HasPrefix(d.Name(), ".bisync-") {
+			return nil
+		}
+		if abs, _ := filepath.Abs(p); abs == statePath {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.localRoot, p)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = file{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk local directory: %w", err)
+	}
+	return files, nil
+}
+
+// walkRemote adds the files below dir to files.
+func (s *Syncer) walkRemote(ctx context.Context, dir string, files map[string]file) error {
+	entries, err := s.fs.List(ctx, dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		switch e := entry.(type) {
+		case *backend.Object:
+			files[e.Remote()] = file{size: e.Size(), modTime: e.ModTime(), obj: e}
+		case *backend.Directory:
+			if err := s.walkRemote(ctx, e.Remote(), files); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Syncer) localPath(p string) string {
+	return filepath.Join(s.localRoot, filepath.FromSlash(p))
+}
+
+// conflictName inserts a conflict marker before the extension of p.
+func conflictName(p string, at time.Time) string {
+	ext := path.Ext(p)
+	return strings.TrimSuffix(p, ext) + ".conflict-" + at.UTC().Format("20060102-150405") + ext
+}
+
+// sameTime compares modification times at the one-second precision that
+// survives every filesystem and the Drive API.
+func sameTime(a, b time.Time) bool {
+	return a.Unix() == b.Unix()
+}
@@ -0,0 +1,191 @@
+package bisync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/internxt/rclone-adapter/backend"
+	"github.com/internxt/rclone-adapter/internal/fakedrive"
+)
+
+func newTestSyncer(t *testing.T, resolution Resolution) (*Syncer, *fakedrive.Server, string) {
+	server := fakedrive.New()
+	t.Cleanup(server.Close)
+	local := t.TempDir()
+	s, err := New(backend.NewFs(server.Config(), ""), local, filepath.Join(t.TempDir(), "state.json"), resolution)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s, server, local
+}
+
+func writeLocal(t *testing.T, local, name, data string, modTime time.Time) {
+	p := filepath.Join(local, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(p, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readLocal(t *testing.T, local, name string) string {
+	data, err := os.ReadFile(filepath.Join(local, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", name, err)
+	}
+	return string(data)
+}
+
+func remoteContent(t *testing.T, server *fakedrive.Server, name string) (string, bool) {
+	uuid, isDir, ok := server.Lookup(name)
+	if !ok || isDir {
+		return "", false
+	}
+	data, _ := server.Content(uuid)
+	return string(data), true
+}
+
+func run(t *testing.T, s *Syncer) *Report {
+	report, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return report
+}
+
+func TestRunPropagatesChanges(t *testing.T) {
+	s, server, local := newTestSyncer(t, NewerWins)
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	writeLocal(t, local, "docs/a.txt", "local a", old)
+	server.AddFile(fakedrive.RootUUID, "b.txt", []byte("remote b"), old)
+
+	report := run(t, s)
+	if len(report.Uploaded) != 1 || len(report.Downloaded) != 1 {
+		t.Fatalf("unexpected first report %+v", report)
+	}
+	if got, ok := remoteContent(t, server, "docs/a.txt"); !ok || got != "local a" {
+		t.Errorf("expected docs/a.txt uploaded, got %q", got)
+	}
+	if got := readLocal(t, local, "b.txt"); got != "remote b" {
+		t.Errorf("expected b.txt downloaded, got %q", got)
+	}
+
+	// A second sync with nothing changed does nothing.
+	if report := run(t, s); len(report.Uploaded)+len(report.Downloaded)+len(report.DeletedLocal)+len(report.DeletedRemote) != 0 {
+		t.Fatalf("expected no-op sync, got %+v", report)
+	}
+
+	// Deleting on one side deletes on the other; a local edit is uploaded.
+	if err := os.Remove(filepath.Join(local, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	writeLocal(t, local, "docs/a.txt", "local a v2", time.Now())
+	report = run(t, s)
+	if len(report.DeletedRemote) != 1 || len(report.Uploaded) != 1 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if _, ok := remoteContent(t, server, "b.txt"); ok {
+		t.Error("expected b.txt deleted remotely")
+	}
+	if got, _ := remoteContent(t, server, "docs/a.txt"); got != "local a v2" {
+		t.Errorf("expected docs/a.txt updated, got %q", got)
+	}
+
+	// State survives a new Syncer: the unchanged file is not transferred again.
+	s2, err := New(s.fs, local, s.statePath, NewerWins)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report := run(t, s2); len(report.Uploaded)+len(report.Downloaded) != 0 {
+		t.Fatalf("expected persisted state to prevent transfers, got %+v", report)
+	}
+}
+
+func TestRunConflicts(t *testing.T) {
+	tests := []struct {
+		name       string
+		resolution Resolution
+		localNewer bool
+		wantLocal  string
+		wantRemote string
+		wantCopy   bool
+	}{
+		{name: "newer wins local", resolution: NewerWins, localNewer: true, wantLocal: "local", wantRemote: "local"},
+		{name: "newer wins remote", resolution: NewerWins, localNewer: false, wantLocal: "remote!", wantRemote: "remote!"},
+		{name: "keep both", resolution: KeepBoth, localNewer: true, wantLocal: "remote!", wantRemote: "remote!", wantCopy: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, server, local := newTestSyncer(t, tt.resolution)
+			s.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+			base := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+			writeLocal(t, local, "f.txt", "base", base)
+			run(t, s)
+
+			localTime, remoteTime := base.Add(time.Hour), base.Add(30*time.Minute)
+			if !tt.localNewer {
+				localTime, remoteTime = remoteTime, localTime
+			}
+			writeLocal(t, local, "f.txt", "local", localTime)
+			obj, err := s.fs.NewObject(context.Background(), "f.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := obj.Remove(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			server.AddFile(fakedrive.RootUUID, "f.txt", []byte("remote!"), remoteTime)
+
+			report := run(t, s)
+			if len(report.Conflicts) != 1 {
+				t.Fatalf("expected one conflict, got %+v", report)
+			}
+			if got := readLocal(t, local, "f.txt"); got != tt.wantLocal {
+				t.Errorf("local: expected %q, got %q", tt.wantLocal, got)
+			}
+			if got, _ := remoteContent(t, server, "f.txt"); got != tt.wantRemote {
+				t.Errorf("remote: expected %q, got %q", tt.wantRemote, got)
+			}
+			const copyName = "f.conflict-20260102-030405.txt"
+			_, hasCopy := remoteContent(t, server, copyName)
+			if hasCopy != tt.wantCopy {
+				t.Errorf("expected conflict copy %v, got %v", tt.wantCopy, hasCopy)
+			}
+			if tt.wantCopy && readLocal(t, local, copyName) != "local" {
+				t.Error("expected local conflict copy to keep the local content")
+			}
+
+			if report := run(t, s); len(report.Conflicts)+len(report.Uploaded)+len(report.Downloaded) != 0 {
+				t.Errorf("expected conflict settled, got %+v", report)
+			}
+		})
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "state.json")
+	s, err := LoadState(path)
+	if err != nil || len(s.Entries) != 0 {
+		t.Fatalf("expected empty state, got %+v, %v", s, err)
+	}
+	s.Entries["a"] = Entry{Size: 3, UUID: "u", LocalModTime: time.Unix(100, 0).UTC()}
+	if err := s.Save(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loaded, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := loaded.Entries["a"]; got.Size != 3 || got.UUID != "u" || !got.LocalModTime.Equal(time.Unix(100, 0)) {
+		t.Errorf("unexpected entry %+v", got)
+	}
+}
@@ -0,0 +1,75 @@
+package bisync
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Entry is what the previous sync saw at a path on both sides.
+type Entry struct {
+	Size          int64     `json:"size"`
+	LocalModTime  time.Time `json:"local_mod_time"`
+	RemoteModTime time.Time `json:"remote_mod_time"`
+	UUID          string    `json:"uuid"` // Drive UUID of the remote file
+}
+
+// State is the sync database: the last-seen entry of every synced file,
+// keyed by slash-separated path relative to the sync roots.
+type State struct {
+	Entries map[string]Entry `json:"entries"`
+}
+
+// LoadState reads the state saved at path. A missing file yields an empty
+// state, as for a first sync.
+func LoadState(path string) (*State, error) {
+	s := &State{Entries: map[string]Entry{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal sync state: %w", err)
+	}
+	if s.Entries == nil {
+		s.Entries = map[string]Entry{}
+	}
+	return s, nil
+}
+
+// Save writes the state to path through a temporary file, so a crash never
+// leaves a truncated database behind.
+func (s *State) Save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal sync state: %w", err)
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create sync state directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".bisync-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary sync state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace sync state file: %w", err)
+	}
+	return nil
+}
@@ -2,11 +2,22 @@ package buckets
 
 import (
 	"bytes"
+	"context"
 	"crypto/aes"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/crypto"
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
 )
 
 var (
@@ -400,3 +411,463 @@ func TestHashEncryptedData(t *testing.T) {
 		}
 	})
 }
+
+func TestEncryptChunk(t *testing.T) {
+	session := newTestSession(t)
+	session.chunkSize = 100
+	session.totalSize = 250
+	session.numParts = 3
+
+	plaintext := make([]byte, session.totalSize)
+	for i := range plaintext {
+		plaintext[i] = byte(i)
+	}
+	want := make([]byte, len(plaintext))
+	seqStream, err := NewAES256CTRCipher(session.fileKey, session.iv)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	seqStream.XORKeyStream(want, plaintext)
+
+	t.Run("rejects a chunk of the wrong size", func(t *testing.T) {
+		if _, err := session.EncryptChunk(0, plaintext[:99]); err == nil {
+			t.Error("expected error for a short chunk, got nil")
+		}
+		if _, err := session.EncryptChunk(3, plaintext[:50]); err == nil {
+			t.Error("expected error for an out-of-range chunk, got nil")
+		}
+	})
+
+	t.Run("chunks encrypted out of order match the sequential cipher", func(t *testing.T) {
+		got := make([]byte, len(plaintext))
+		for _, i := range []int{2, 0, 1} {
+			start, end := i*100, min((i+1)*100, len(plaintext))
+			ct, err := session.EncryptChunk(i, plaintext[start:end])
+			if err != nil {
+				t.Fatalf("chunk %d: unexpected error: %v", i, err)
+			}
+			copy(got[start:], ct)
+		}
+		if !bytes.Equal(got, want) {
+			t.Error("out-of-order ciphertext differs from sequential cipher")
+		}
+
+		wantHash := sha256.Sum256(want)
+		if gotHash := session.sha256Hash.Sum(nil); !bytes.Equal(gotHash, wantHash[:]) {
+			t.Errorf("hash mismatch:\n  got  %x\n  want %x", gotHash, wantHash)
+		}
+	})
+
+	t.Run("encrypts a chunk again for a retry", func(t *testing.T) {
+		ct, err := session.EncryptChunk(1, plaintext[100:200])
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(ct, want[100:200]) {
+			t.Error("expected the same ciphertext as the first time")
+		}
+		wantHash := sha256.Sum256(want)
+		if gotHash := session.sha256Hash.Sum(nil); !bytes.Equal(gotHash, wantHash[:]) {
+			t.Error("expected the chunk not to be hashed again")
+		}
+		if got := session.Stats().BytesEncrypted; got != session.totalSize {
+			t.Errorf("expected %d bytes encrypted once, got %d", session.totalSize, got)
+		}
+	})
+
+	t.Run("rejects a retry with other data", func(t *testing.T) {
+		if _, err := session.EncryptChunk(1, make([]byte, 100)); err == nil {
+			t.Error("expected error for a chunk with other data, got nil")
+		}
+	})
+}
+
+func TestChunkUploadSessionAbort(t *testing.T) {
+	var aborted map[string]string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/files/start"):
+			json.NewEncoder(w).Encode(StartUploadResp{
+				Uploads: []UploadPart{{UUID: "uuid", UploadId: "upload-id", URLs: []string{"url-1", "url-2"}}},
+			})
+		case strings.HasSuffix(r.URL.Path, "/files/abort"):
+			json.NewDecoder(r.Body).Decode(&aborted)
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer mockServer.Close()
+
+	cfg := newTestConfig(mockServer.URL)
+	cfg.MinChunkSize = 100
+	session, err := NewChunkUploadSession(context.Background(), cfg, 200, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := session.Abort(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v := session.EncryptVersion(); v != crypto.EncryptVersionAES {
+		t.Errorf("expected encryption version %s, got %s", crypto.EncryptVersionAES, v)
+	}
+	if aborted["UploadId"] != "upload-id" || aborted["uuid"] != "uuid" {
+		t.Errorf("expected the upload to be aborted, got abort payload %v", aborted)
+	}
+
+	if _, err := session.UploadChunk(context.Background(), 0, bytes.NewReader(make([]byte, 100)), 100); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("expected ErrSessionClosed from UploadChunk, got %v", err)
+	}
+	if _, err := session.EncryptChunk(0, make([]byte, 100)); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("expected ErrSessionClosed from EncryptChunk, got %v", err)
+	}
+	if _, err := session.Finish(context.Background(), nil); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("expected ErrSessionClosed from Finish, got %v", err)
+	}
+	if err := session.Abort(context.Background()); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("expected ErrSessionClosed from a second Abort, got %v", err)
+	}
+}
+
+// TestChunkUploadSessionAbortBestEffort tests that Abort closes the session
+// and only logs a network that does not discard the chunks
+func TestChunkUploadSessionAbortBestEffort(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/files/start") {
+			json.NewEncoder(w).Encode(StartUploadResp{
+				Uploads: []UploadPart{{UUID: "uuid", UploadId: "upload-id", URLs: []string{"url-1", "url-2"}}},
+			})
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer mockServer.Close()
+
+	var logs bytes.Buffer
+	cfg := newTestConfig(mockServer.URL)
+	cfg.MinChunkSize = 100
+	cfg.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	session, err := NewChunkUploadSession(context.Background(), cfg, 200, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := session.Abort(context.Background()); err != nil {
+		t.Fatalf("expected a failed discard not to fail Abort, got %v", err)
+	}
+	if !strings.Contains(logs.String(), "failed to discard") || !strings.Contains(logs.String(), "upload-id") {
+		t.Errorf("expected the failed discard to be logged, got %q", logs.String())
+	}
+	if _, err := session.Finish(context.Background(), nil); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("expected ErrSessionClosed from Finish, got %v", err)
+	}
+}
+
+func TestChunkUploadSessionStats(t *testing.T) {
+	var serverURL string
+	var failed bool
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/files/start"):
+			json.NewEncoder(w).Encode(StartUploadResp{
+				Uploads: []UploadPart{{UUID: "uuid", UploadId: "upload-id", URLs: []string{serverURL + "/part/1", serverURL + "/part/2"}}},
+			})
+		case r.URL.Path == "/part/2" && !failed:
+			failed = true
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.Header().Set("ETag", `"etag"`)
+		}
+	}))
+	defer mockServer.Close()
+	serverURL = mockServer.URL
+
+	cfg := newTestConfig(mockServer.URL)
+	cfg.MinChunkSize = 100
+	session, err := NewChunkUploadSession(context.Background(), cfg, 150, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	plaintext := make([]byte, 150)
+	for i, size := range []int{100, 50} {
+		ct, err := session.EncryptChunk(i, plaintext[i*100:i*100+size])
+		if err != nil {
+			t.Fatalf("chunk %d: unexpected error: %v", i, err)
+		}
+		if _, err := session.UploadChunk(context.Background(), i, bytes.NewReader(ct), int64(size)); err != nil && i == 0 {
+			t.Fatalf("chunk %d: unexpected error: %v", i, err)
+		}
+	}
+	want := ChunkSessionStats{BytesEncrypted: 150, BytesUploaded: 100, PartsCompleted: 1, PartsFailed: 1}
+	if got := session.Stats(); got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+
+	if _, err := session.UploadChunk(context.Background(), 1, bytes.NewReader(make([]byte, 50)), 50); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = ChunkSessionStats{BytesEncrypted: 150, BytesUploaded: 150, PartsCompleted: 2, PartsFailed: 1, Retries: 1}
+	if got := session.Stats(); got != want {
+		t.Errorf("expected %+v after the retry, got %+v", want, got)
+	}
+}
+
+func TestChunkUploadSessionSinglePart(t *testing.T) {
+	var finished struct {
+		Index  string           `json:"index"`
+		Shards []map[string]any `json:"shards"`
+	}
+	var serverURL string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/files/start"):
+			json.NewEncoder(w).Encode(StartUploadResp{
+				Uploads: []UploadPart{{UUID: "uuid", URL: serverURL + "/part"}},
+			})
+		case strings.HasSuffix(r.URL.Path, "/files/finish"):
+			json.NewDecoder(r.Body).Decode(&finished)
+			json.NewEncoder(w).Encode(FinishUploadResp{ID: "file-id"})
+		case r.URL.Path == "/part":
+			w.Header().Set("ETag", `"etag"`)
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer mockServer.Close()
+	serverURL = mockServer.URL
+
+	cfg := newTestConfig(mockServer.URL)
+	session, err := NewChunkUploadSession(context.Background(), cfg, 40, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ct, err := session.EncryptChunk(0, make([]byte, 40))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	etag, err := session.UploadChunk(context.Background(), 0, bytes.NewReader(ct), 40)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := session.Finish(context.Background(), []CompletedPart{{PartNumber: 1, ETag: etag}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sum := sha256.Sum256(ct)
+	if resp.ID != "file-id" || finished.Index != session.EncIndex() || len(finished.Shards) != 1 {
+		t.Fatalf("unexpected finish request %+v", finished)
+	}
+	if shard := finished.Shards[0]; shard["hash"] != ComputeFileHash(sum[:]) || shard["uuid"] != "uuid" || shard["UploadId"] != nil {
+		t.Errorf("expected a single-part shard, got %v", shard)
+	}
+}
+
+func TestChunkUploadSessionFinishRetry(t *testing.T) {
+	var serverURL string
+	var finishes int
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/files/start"):
+			json.NewEncoder(w).Encode(StartUploadResp{
+				Uploads: []UploadPart{{UUID: "uuid", URL: serverURL + "/part"}},
+			})
+		case strings.HasSuffix(r.URL.Path, "/files/finish"):
+			if finishes++; finishes == 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(FinishUploadResp{ID: "file-id"})
+		default:
+			w.Header().Set("ETag", `"etag"`)
+		}
+	}))
+	defer mockServer.Close()
+	serverURL = mockServer.URL
+
+	cfg := newTestConfig(mockServer.URL)
+	session, err := NewChunkUploadSession(context.Background(), cfg, 40, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	plaintext := make([]byte, 40)
+	ct, err := session.EncryptChunk(0, plaintext)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	etag, err := session.UploadChunk(context.Background(), 0, bytes.NewReader(ct), 40)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parts := []CompletedPart{{PartNumber: 1, ETag: etag}}
+
+	if _, err := session.Finish(context.Background(), parts); err == nil {
+		t.Fatal("expected the first Finish to fail")
+	}
+	if bytes.Equal(session.fileKey, make([]byte, len(session.fileKey))) {
+		t.Fatal("expected the file key to survive a failed Finish")
+	}
+	resp, err := session.Finish(context.Background(), parts)
+	if err != nil {
+		t.Fatalf("unexpected error on retry: %v", err)
+	}
+	if resp.ID != "file-id" {
+		t.Errorf("expected file-id, got %q", resp.ID)
+	}
+	if !bytes.Equal(session.fileKey, make([]byte, len(session.fileKey))) {
+		t.Error("expected the file key to be wiped after Finish")
+	}
+}
+
+func TestChunkUploadSessionPartHashes(t *testing.T) {
+	var finished map[string][]MultipartShard
+	var serverURL string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/files/start"):
+			json.NewEncoder(w).Encode(StartUploadResp{
+				Uploads: []UploadPart{{UUID: "uuid", UploadId: "upload-id", URLs: []string{serverURL + "/part/1", serverURL + "/part/2"}}},
+			})
+		case strings.HasSuffix(r.URL.Path, "/files/finish"):
+			json.NewDecoder(r.Body).Decode(&finished)
+			json.NewEncoder(w).Encode(FinishUploadResp{ID: "file-id"})
+		default:
+			w.Header().Set("ETag", `"etag`+strings.TrimPrefix(r.URL.Path, "/part/")+`"`)
+		}
+	}))
+	defer mockServer.Close()
+	serverURL = mockServer.URL
+
+	cfg := newTestConfig(mockServer.URL)
+	cfg.MinChunkSize = 100
+	session, err := NewChunkUploadSession(context.Background(), cfg, 150, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var parts []CompletedPart
+	var wantHashes []string
+	for i, size := range []int{100, 50} {
+		ct, err := session.EncryptChunk(i, make([]byte, size))
+		if err != nil {
+			t.Fatalf("chunk %d: unexpected error: %v", i, err)
+		}
+		etag, err := session.UploadChunk(context.Background(), i, bytes.NewReader(ct), int64(size))
+		if err != nil {
+			t.Fatalf("chunk %d: unexpected error: %v", i, err)
+		}
+		sum := sha256.Sum256(ct)
+		wantHashes = append(wantHashes, hex.EncodeToString(sum[:]))
+		parts = append(parts, CompletedPart{PartNumber: i + 1, ETag: etag})
+	}
+
+	for i, p := range session.CompletedParts() {
+		if p.SHA256 != wantHashes[i] {
+			t.Errorf("part %d: expected SHA-256 %s, got %s", i+1, wantHashes[i], p.SHA256)
+		}
+	}
+
+	bad := []CompletedPart{parts[0], {PartNumber: 2, ETag: parts[1].ETag, SHA256: wantHashes[0]}}
+	if _, err := session.Finish(context.Background(), bad); !errors.Is(err, crypto.ErrHashMismatch) || !strings.Contains(err.Error(), "part 2") {
+		t.Fatalf("expected a hash mismatch for part 2, got %v", err)
+	}
+
+	if _, err := session.Finish(context.Background(), parts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := finished["shards"][0].Parts
+	if len(got) != 2 || got[0].SHA256 != wantHashes[0] || got[1].SHA256 != wantHashes[1] {
+		t.Errorf("expected part hashes in the finish request, got %+v", got)
+	}
+}
+
+func TestNewChunkUploadSessionChunkSize(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL.Path)
+	}))
+	defer mockServer.Close()
+	cfg := newTestConfig(mockServer.URL)
+
+	testCases := []struct {
+		name      string
+		totalSize int64
+		chunkSize int64
+		want      string
+	}{
+		{"negative size", -1, config.MinChunkSize, "invalid total size"},
+		{"below minimum", 3 * 1024 * 1024, 1024 * 1024, "below the 5242880 byte minimum"},
+		{"above maximum", 20 * 1024 * 1024 * 1024, 6 * 1024 * 1024 * 1024, "exceeds the 5368709120 byte maximum"},
+		{"too many parts", 100 * 1024 * 1024 * 1024, config.MinChunkSize, "use a chunk size of at least 10737419"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewChunkUploadSession(context.Background(), cfg, tc.totalSize, tc.chunkSize)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+
+	_, err := NewChunkUploadSession(context.Background(), cfg, 100*1024*1024*1024, config.MinChunkSize)
+	if !errors.Is(err, sdkerrors.ErrFileTooLarge) {
+		t.Errorf("expected ErrFileTooLarge, got %v", err)
+	}
+
+	cfg.EncryptVersion = crypto.EncryptVersionAESGCM
+	if _, err := NewChunkUploadSession(context.Background(), cfg, 100, 100); err == nil || !strings.Contains(err.Error(), "only support encryption version 03-aes") {
+		t.Errorf("expected 04-aes-gcm to be rejected, got %v", err)
+	}
+}
+
+func TestUploadChunkNonSeekable(t *testing.T) {
+	var received [][]byte
+	var serverURL string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/files/start"):
+			json.NewEncoder(w).Encode(StartUploadResp{
+				Uploads: []UploadPart{{UUID: "uuid", UploadId: "upload-id", URLs: []string{serverURL + "/part/1", serverURL + "/part/2"}}},
+			})
+		default:
+			body, _ := io.ReadAll(r.Body)
+			received = append(received, body)
+			w.Header().Set("ETag", `"etag"`)
+		}
+	}))
+	defer mockServer.Close()
+	serverURL = mockServer.URL
+
+	cfg := newTestConfig(mockServer.URL)
+	cfg.MinChunkSize = 100
+	session, err := NewChunkUploadSession(context.Background(), cfg, 150, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ct, err := session.EncryptChunk(0, make([]byte, 100))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write(ct[:40])
+		pw.Write(ct[40:])
+		pw.Close()
+	}()
+	if _, err := session.UploadChunk(context.Background(), 0, pr, 100); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sum := sha256.Sum256(ct)
+	if len(received) != 1 || !bytes.Equal(received[0], ct) {
+		t.Error("expected the piped chunk to be uploaded")
+	}
+	if parts := session.CompletedParts(); len(parts) != 1 || parts[0].SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("unexpected completed parts %+v", parts)
+	}
+
+	short := io.MultiReader(bytes.NewReader(make([]byte, 20)))
+	if _, err := session.UploadChunk(context.Background(), 1, short, 50); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected io.ErrUnexpectedEOF for a short chunk, got %v", err)
+	}
+}
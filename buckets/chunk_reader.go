@@ -0,0 +1,242 @@
+package buckets
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/consistency"
+	"github.com/internxt/rclone-adapter/crypto"
+	"github.com/internxt/rclone-adapter/errors"
+)
+
+// ChunkDownloadSession gives random access to the decrypted contents of a
+// file, so the caller (rclone) can download chunks of it in parallel. Each
+// read fetches only the stored bytes it needs. It is the download
+// counterpart of ChunkUploadSession; ranged reads skip hash validation.
+type ChunkDownloadSession struct {
+	ctx       context.Context // Used by ReadAt, which takes none
+	cfg       *config.Config
+	fileUUID  string
+	fc        crypto.FileCipher
+	size      int64
+	chunkSize int64
+	fileKey   []byte
+	iv        []byte
+
+	keyMu  sync.RWMutex // Held for reading while fileKey and iv are in use
+	closed bool         // Set by Close once fileKey and iv are wiped
+
+	mu       sync.Mutex
+	shardURL string
+}
+
+// NewChunkDownloadSession fetches the location and key of the file with the
+// given UUID, encryptVersion and plaintext size, the encryptVersion and size
+// of its Drive metadata. Chunks are chunkSize bytes; a chunkSize <= 0 uses
+// cfg.ChunkSize. ReadAt uses ctx for its requests.
+func NewChunkDownloadSession(ctx context.Context, cfg *config.Config, fileUUID, encryptVersion string, size, chunkSize int64) (*ChunkDownloadSession, error) {
+	fc, err := crypto.CipherFor(encryptVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file %s: %w", fileUUID, err)
+	}
+	if chunkSize <= 0 {
+		chunkSize = cfg.ChunkSize
+	}
+	if chunkSize <= 0 {
+		chunkSize = config.DefaultChunkSize
+	}
+
+	if err := consistency.AwaitFile(ctx, fileUUID); err != nil {
+		return nil, err
+	}
+	info, err := GetBucketFileInfo(ctx, cfg, cfg.Bucket, fileUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bucket file info: %w", err)
+	}
+	if size > 0 && len(info.Shards) == 0 {
+		return nil, fmt.Errorf("no shards found for file %s", fileUUID)
+	}
+	if len(info.Shards) > 1 {
+		return nil, fmt.Errorf("file %s has %d shards; chunked reads need a single shard", fileUUID, len(info.Shards))
+	}
+
+	fileKey, iv, err := cfg.FileKey(info.Index)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate file key: %w", err)
+	}
+
+	s := &ChunkDownloadSession{
+		ctx:       ctx,
+		cfg:       cfg,
+		fileUUID:  fileUUID,
+		fc:        fc,
+		size:      size,
+		chunkSize: chunkSize,
+		fileKey:   fileKey,
+		iv:        iv,
+	}
+	if len(info.Shards) > 0 {
+		s.shardURL = info.Shards[0].URL
+	}
+	return s, nil
+}
+
+// Size returns the plaintext size of the file.
+func (s *ChunkDownloadSession) Size() int64 {
+	return s.size
+}
+
+// ChunkSize returns the size of every chunk but the last one.
+func (s *ChunkDownloadSession) ChunkSize() int64 {
+	return s.chunkSize
+}
+
+// NumChunks returns the number of chunks of the file.
+func (s *ChunkDownloadSession) NumChunks() int {
+	return int((s.size + s.chunkSize - 1) / s.chunkSize)
+}
+
+// ReadChunk reads chunk partIndex into p, which must hold ChunkSize bytes
+// (less for the last chunk), and returns its length.
+func (s *ChunkDownloadSession) ReadChunk(ctx context.Context, partIndex int, p []byte) (int, error) {
+	if partIndex < 0 || partIndex >= s.NumChunks() {
+		return 0, fmt.Errorf("chunk index %d out of range [0, %d)", partIndex, s.NumChunks())
+	}
+	off := int64(partIndex) * s.chunkSize
+	length := min(s.chunkSize, s.size-off)
+	if int64(len(p)) < length {
+		return 0, fmt.Errorf("buffer of %d bytes is too small for chunk %d of %d bytes", len(p), partIndex, length)
+	}
+	return s.readAt(ctx, p[:length], off)
+}
+
+// ReadAt reads len(p) bytes at off. It implements io.ReaderAt, so parts of
+// the file can be read concurrently.
+func (s *ChunkDownloadSession) ReadAt(p []byte, off int64) (int, error) {
+	return s.readAt(s.ctx, p, off)
+}
+
+func (s *ChunkDownloadSession) readAt(ctx context.Context, p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if off >= s.size {
+		return 0, io.EOF
+	}
+	length := min(int64(len(p)), s.size-off)
+	rc, err := s.OpenRange(ctx, off, length)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+	n, err := io.ReadFull(rc, p[:length])
+	if err != nil {
+		return n, fmt.Errorf("failed to read %d bytes at %d: %w", length, off, err)
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// OpenRange returns the length decrypted bytes at offset. The caller must
+// close it.
+func (s *ChunkDownloadSession) OpenRange(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	s.keyMu.RLock()
+	defer s.keyMu.RUnlock()
+	if s.closed {
+		return nil, ErrSessionClosed
+	}
+	if offset < 0 || length <= 0 || offset+length > s.size {
+		return nil, fmt.Errorf("range %d+%d outside file of %d bytes", offset, length, s.size)
+	}
+	encStart, encEnd, skip := s.fc.EncryptedRange(offset, offset+length-1)
+	encRange := fmt.Sprintf("bytes=%d-", encStart)
+	if encEnd >= 0 {
+		encRange += fmt.Sprint(encEnd)
+	}
+
+	resp, err := s.openShard(ctx, encRange)
+	if err != nil {
+		return nil, err
+	}
+	decReader, err := s.fc.DecryptReaderAt(resp.Body, s.fileKey, s.iv, encStart)
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to create decrypt reader: %w", err)
+	}
+	if skip > 0 {
+		if _, err := io.CopyN(io.Discard, decReader, skip); err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to discard offset bytes: %w", err)
+		}
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{Reader: io.LimitReader(decReader, length), Closer: resp.Body}, nil
+}
+
+// Close wipes the file key, waiting for ranges being opened. Reads fail
+// with ErrSessionClosed afterwards; readers already returned by OpenRange
+// stay usable.
+func (s *ChunkDownloadSession) Close() error {
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+	if s.closed {
+		return ErrSessionClosed
+	}
+	s.closed = true
+	crypto.Wipe(s.fileKey, s.iv)
+	return nil
+}
+
+// openShard fetches encRange of the shard, refreshing its presigned URL
+// shortly before it expires or once if the storage rejects it with 403.
+func (s *ChunkDownloadSession) openShard(ctx context.Context, encRange string) (*http.Response, error) {
+	s.mu.Lock()
+	shardURL := s.shardURL
+	s.mu.Unlock()
+	if exp := presignedExpiry(shardURL); !exp.IsZero() && time.Until(exp) < urlRefreshMargin {
+		var err error
+		if shardURL, err = s.refreshURL(ctx, shardURL); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := openShard(ctx, s.cfg, shardURL, encRange, "chunk download")
+	var httpErr *errors.HTTPError
+	if err == nil || !stderrors.As(err, &httpErr) || httpErr.StatusCode() != http.StatusForbidden {
+		return resp, err
+	}
+	if shardURL, err = s.refreshURL(ctx, shardURL); err != nil {
+		return nil, err
+	}
+	return openShard(ctx, s.cfg, shardURL, encRange, "chunk download")
+}
+
+// refreshURL fetches a new shard URL unless another read already replaced
+// stale, and returns the current one.
+func (s *ChunkDownloadSession) refreshURL(ctx context.Context, stale string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shardURL != stale {
+		return s.shardURL, nil
+	}
+	info, err := GetBucketFileInfo(ctx, s.cfg, s.cfg.Bucket, s.fileUUID)
+	if err != nil {
+		return "", fmt.Errorf("failed to refresh download URL: %w", err)
+	}
+	if len(info.Shards) == 0 {
+		return "", fmt.Errorf("no shards found for file %s", s.fileUUID)
+	}
+	s.shardURL = info.Shards[0].URL
+	s.cfg.Log().DebugContext(ctx, "refreshed download URL", "file_uuid", s.fileUUID, "expires", presignedExpiry(s.shardURL))
+	return s.shardURL, nil
+}
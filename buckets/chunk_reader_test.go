@@ -0,0 +1,117 @@
+package buckets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestChunkDownloadSession(t *testing.T) {
+	index := TestIndex[:64]
+	plaintext := make([]byte, 1000)
+	for i := range plaintext {
+		plaintext[i] = byte(i * 7)
+	}
+
+	cfg := newTestConfig("")
+	key, iv, err := cfg.FileKey(index)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	encReader, _ := EncryptReader(bytes.NewReader(plaintext), key, iv)
+	encData, _ := io.ReadAll(encReader)
+
+	var infoCalls atomic.Int32
+	var serverURL string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "/info"):
+			// The first URL handed out has expired, later ones work
+			shardURL := serverURL + "/expired"
+			if infoCalls.Add(1) > 1 {
+				shardURL = serverURL + "/shard"
+			}
+			json.NewEncoder(w).Encode(BucketFileInfo{
+				Bucket: TestBucket1,
+				Index:  index,
+				Size:   int64(len(encData)),
+				ID:     testFileUUID,
+				Shards: []ShardInfo{{Index: 0, URL: shardURL}},
+			})
+		case r.URL.Path == "/shard":
+			var start, end int
+			if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err != nil {
+				t.Errorf("unexpected Range header %q", r.Header.Get("Range"))
+			}
+			end = min(end, len(encData)-1)
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(encData)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(encData[start : end+1])
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer mockServer.Close()
+	serverURL = mockServer.URL
+	cfg.Endpoints = newTestConfig(mockServer.URL).Endpoints
+
+	session, err := NewChunkDownloadSession(context.Background(), cfg, testFileUUID, "", int64(len(plaintext)), 300)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if session.NumChunks() != 4 {
+		t.Fatalf("expected 4 chunks, got %d", session.NumChunks())
+	}
+	got := make([]byte, 0, len(plaintext))
+	buf := make([]byte, session.ChunkSize())
+	for i := range session.NumChunks() {
+		n, err := session.ReadChunk(context.Background(), i, buf)
+		if err != nil {
+			t.Fatalf("chunk %d: unexpected error: %v", i, err)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Error("decrypted chunks do not match the plaintext")
+	}
+	if infoCalls.Load() != 2 {
+		t.Errorf("expected the expired URL to be refreshed once, got %d info requests", infoCalls.Load())
+	}
+
+	// Unaligned reads need the IV advanced to the right block
+	p := make([]byte, 50)
+	if n, err := session.ReadAt(p, 333); err != nil || n != 50 || !bytes.Equal(p, plaintext[333:383]) {
+		t.Errorf("unexpected ReadAt result: n=%d err=%v", n, err)
+	}
+	if n, err := session.ReadAt(p, 980); !errors.Is(err, io.EOF) || n != 20 || !bytes.Equal(p[:n], plaintext[980:]) {
+		t.Errorf("expected a short read with io.EOF at the end, got n=%d err=%v", n, err)
+	}
+	if _, err := session.ReadChunk(context.Background(), 4, buf); err == nil {
+		t.Error("expected error for an out of range chunk, got nil")
+	}
+
+	if err := session.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := session.ReadChunk(context.Background(), 0, buf); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("expected ErrSessionClosed from ReadChunk, got %v", err)
+	}
+	if _, err := session.ReadAt(p, 0); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("expected ErrSessionClosed from ReadAt, got %v", err)
+	}
+	if _, err := session.OpenRange(context.Background(), 0, 10); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("expected ErrSessionClosed from OpenRange, got %v", err)
+	}
+	if err := session.Close(); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("expected ErrSessionClosed from a second Close, got %v", err)
+	}
+}
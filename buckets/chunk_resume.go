@@ -0,0 +1,157 @@
+package buckets
+
+import (
+	"crypto/sha256"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/internxt/rclone-adapter/config"
+)
+
+// chunkSessionStateVersion is bumped whenever chunkSessionState changes
+// incompatibly.
+const chunkSessionStateVersion = 1
+
+// chunkSessionState is the persisted form of a ChunkUploadSession. It holds
+// no key material: the file key is derived again from EncIndex on resume.
+type chunkSessionState struct {
+	Version    int             `json:"version"`
+	Bucket     string          `json:"bucket"`
+	EncIndex   string          `json:"enc_index"`
+	UploadID   string          `json:"upload_id"`
+	UUID       string          `json:"uuid"`
+	TotalSize  int64           `json:"total_size"`
+	ChunkSize  int64           `json:"chunk_size"`
+	SinglePart bool            `json:"single_part,omitempty"`
+	URLs       []string        `json:"urls"`
+	Parts      []CompletedPart `json:"parts"`
+	HashState  []byte          `json:"hash_state"` // SHA-256 state of the ciphertext hashed so far
+	NextHash   int64           `json:"next_hash"`  // First chunk EncryptChunk has not hashed
+}
+
+// CompletedParts returns the chunks uploaded by UploadChunk so far, with
+// their SHA-256, in part order, as Finish expects them.
+func (s *ChunkUploadSession) CompletedParts() []CompletedPart {
+	s.partsMu.Lock()
+	defer s.partsMu.Unlock()
+	parts := make([]CompletedPart, 0, len(s.completed))
+	for _, part := range s.completed {
+		parts = append(parts, part)
+	}
+	slices.SortFunc(parts, func(a, b CompletedPart) int { return a.PartNumber - b.PartNumber })
+	return parts
+}
+
+// Marshal returns the state of s for ResumeChunkUploadSession: the upload
+// session, its current URLs, the completed parts and the file hash so far.
+// Keys are not included. Chunks encrypted by EncryptChunk ahead of an earlier
+// one that is still missing are not hashed yet, so after resuming they must
+// be encrypted again, though CompletedParts tells whether to upload them.
+func (s *ChunkUploadSession) Marshal() ([]byte, error) {
+	if s.closed.Load() {
+		return nil, ErrSessionClosed
+	}
+
+	s.hashMu.Lock()
+	hashState, err := s.sha256Hash.(encoding.BinaryMarshaler).MarshalBinary()
+	nextHash := s.nextHash
+	s.hashMu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to save hash state: %w", err)
+	}
+
+	upload := s.upload()
+	state := chunkSessionState{
+		Version:    chunkSessionStateVersion,
+		Bucket:     s.cfg.Bucket,
+		EncIndex:   s.encIndex,
+		UploadID:   upload.UploadId,
+		UUID:       upload.UUID,
+		TotalSize:  s.totalSize,
+		ChunkSize:  s.chunkSize,
+		SinglePart: s.singlePart,
+		URLs:       upload.URLs,
+		Parts:      s.CompletedParts(),
+		HashState:  hashState,
+		NextHash:   nextHash,
+	}
+	return json.Marshal(state)
+}
+
+// ResumeChunkUploadSession restores a session saved by Marshal, deriving its
+// file key again from cfg, which must be for the same account and bucket.
+func ResumeChunkUploadSession(cfg *config.Config, data []byte) (*ChunkUploadSession, error) {
+	var state chunkSessionState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to read upload session state: %w", err)
+	}
+	if state.Version != chunkSessionStateVersion {
+		return nil, fmt.Errorf("unsupported upload session state version %d", state.Version)
+	}
+	if state.Bucket != cfg.Bucket {
+		return nil, fmt.Errorf("upload session belongs to bucket %s, not %s", state.Bucket, cfg.Bucket)
+	}
+	if state.ChunkSize <= 0 {
+		return nil, fmt.Errorf("invalid chunk size %d", state.ChunkSize)
+	}
+	if state.TotalSize < 0 {
+		return nil, fmt.Errorf("invalid total size %d", state.TotalSize)
+	}
+
+	numParts := (state.TotalSize + state.ChunkSize - 1) / state.ChunkSize
+	if state.SinglePart && len(state.URLs) != 1 {
+		return nil, fmt.Errorf("expected 1 URL, got %d", len(state.URLs))
+	}
+	if !state.SinglePart && int64(len(state.URLs)) != numParts {
+		return nil, fmt.Errorf("expected %d URLs, got %d", numParts, len(state.URLs))
+	}
+	if state.NextHash < 0 || state.NextHash > numParts {
+		return nil, fmt.Errorf("next hashed chunk %d out of range [0, %d]", state.NextHash, numParts)
+	}
+	for _, p := range state.Parts {
+		if p.PartNumber < 1 || int64(p.PartNumber) > numParts {
+			return nil, fmt.Errorf("part number %d out of range [1, %d]", p.PartNumber, numParts)
+		}
+	}
+
+	hasher := sha256.New()
+	if err := hasher.(encoding.BinaryUnmarshaler).UnmarshalBinary(state.HashState); err != nil {
+		return nil, fmt.Errorf("failed to restore hash state: %w", err)
+	}
+
+	fileKey, iv, err := cfg.FileKey(state.EncIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate file key: %w", err)
+	}
+
+	completed := make(map[int]CompletedPart, len(state.Parts))
+	var uploaded int64
+	for _, p := range state.Parts {
+		completed[p.PartNumber-1] = p
+		uploaded += min(state.ChunkSize, state.TotalSize-int64(p.PartNumber-1)*state.ChunkSize)
+	}
+
+	s := &ChunkUploadSession{
+		cfg:        cfg,
+		encIndex:   state.EncIndex,
+		sha256Hash: hasher,
+		startResp: &StartUploadResp{
+			Uploads: []UploadPart{{UUID: state.UUID, UploadId: state.UploadID, URLs: state.URLs}},
+		},
+		totalSize:  state.TotalSize,
+		chunkSize:  state.ChunkSize,
+		numParts:   numParts,
+		singlePart: state.SinglePart,
+		fileKey:    fileKey,
+		iv:         iv,
+		nextHash:   state.NextHash,
+		completed:  completed,
+	}
+	s.urls.stored = len(completed) > 0
+	s.urls.start = restartUpload(cfg, []UploadPartSpec{{Index: 0, Size: state.TotalSize}}, len(state.URLs))
+	s.stats.partsCompleted.Store(int64(len(completed)))
+	s.stats.bytesUploaded.Store(uploaded)
+	return s, nil
+}
@@ -0,0 +1,153 @@
+package buckets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/internxt/rclone-adapter/crypto"
+)
+
+func TestChunkUploadSessionResume(t *testing.T) {
+	var finished map[string][]MultipartShard
+	var serverURL string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/files/start"):
+			urls := []string{serverURL + "/part/1", serverURL + "/part/2", serverURL + "/part/3"}
+			json.NewEncoder(w).Encode(StartUploadResp{
+				Uploads: []UploadPart{{UUID: "uuid", UploadId: "upload-id", URLs: urls}},
+			})
+		case strings.HasSuffix(r.URL.Path, "/files/finish"):
+			json.NewDecoder(r.Body).Decode(&finished)
+			json.NewEncoder(w).Encode(FinishUploadResp{ID: "file-id"})
+		default:
+			w.Header().Set("ETag", `"etag`+strings.TrimPrefix(r.URL.Path, "/part/")+`"`)
+		}
+	}))
+	defer mockServer.Close()
+	serverURL = mockServer.URL
+
+	cfg := newTestConfig(mockServer.URL)
+	cfg.MinChunkSize = 100
+	session, err := NewChunkUploadSession(context.Background(), cfg, 250, 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	plaintext := make([]byte, 250)
+	for i := range plaintext {
+		plaintext[i] = byte(i)
+	}
+	stream, _ := session.NewCipherAtOffset(0)
+	ciphertext := make([]byte, len(plaintext))
+	stream.XORKeyStream(ciphertext, plaintext)
+	sum := sha256.Sum256(ciphertext)
+	wantHash := crypto.ComputeFileHash(sum[:])
+	keyHex := hex.EncodeToString(session.fileKey)
+
+	upload := func(s *ChunkUploadSession, i int) {
+		t.Helper()
+		start, end := i*100, min((i+1)*100, len(plaintext))
+		ct, err := s.EncryptChunk(i, plaintext[start:end])
+		if err != nil {
+			t.Fatalf("chunk %d: unexpected error: %v", i, err)
+		}
+		if _, err := s.UploadChunk(context.Background(), i, bytes.NewReader(ct), int64(len(ct))); err != nil {
+			t.Fatalf("chunk %d: unexpected error: %v", i, err)
+		}
+	}
+	upload(session, 0)
+	upload(session, 2)
+
+	data, err := session.Marshal()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(string(data), keyHex) || strings.Contains(string(data), TestMnemonic) {
+		t.Fatal("expected the saved state not to contain key material")
+	}
+
+	resumed, err := ResumeChunkUploadSession(cfg, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parts := resumed.CompletedParts()
+	if len(parts) != 2 || parts[0].PartNumber != 1 || parts[1].PartNumber != 3 || parts[1].ETag != "etag3" {
+		t.Fatalf("unexpected completed parts %+v", parts)
+	}
+	if stats := resumed.Stats(); stats.PartsCompleted != 2 || stats.BytesUploaded != 150 {
+		t.Errorf("expected the saved parts to count as uploaded, got %+v", stats)
+	}
+
+	upload(resumed, 1)
+	if _, err := resumed.EncryptChunk(2, plaintext[200:]); err != nil { // Hashed, but already uploaded
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := resumed.Finish(context.Background(), resumed.CompletedParts()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	shards := finished["shards"]
+	if len(shards) != 1 || shards[0].Hash != wantHash || len(shards[0].Parts) != 3 {
+		t.Errorf("expected hash %s with 3 parts, got %+v", wantHash, shards)
+	}
+
+	other := newTestConfig(mockServer.URL)
+	other.Bucket = TestBucket2
+	if _, err := ResumeChunkUploadSession(other, data); err == nil {
+		t.Error("expected error when resuming into another bucket, got nil")
+	}
+}
+
+func TestResumeChunkUploadSessionInvalid(t *testing.T) {
+	cfg := newTestConfig("http://localhost")
+	hashState, _ := sha256.New().(encoding.BinaryMarshaler).MarshalBinary()
+	valid := func() chunkSessionState {
+		return chunkSessionState{
+			Version:   chunkSessionStateVersion,
+			Bucket:    cfg.Bucket,
+			EncIndex:  testIndex,
+			TotalSize: 250,
+			ChunkSize: 100,
+			URLs:      []string{"part/1", "part/2", "part/3"},
+			Parts:     []CompletedPart{{PartNumber: 1, ETag: "etag1"}},
+			HashState: hashState,
+			NextHash:  1,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*chunkSessionState)
+		want   string
+	}{
+		{"single part without URL", func(s *chunkSessionState) { s.SinglePart, s.TotalSize, s.URLs, s.Parts = true, 50, nil, nil }, "expected 1 URL"},
+		{"negative total size", func(s *chunkSessionState) { s.TotalSize, s.URLs, s.Parts, s.NextHash = -1, nil, nil, 0 }, "invalid total size"},
+		{"part number zero", func(s *chunkSessionState) { s.Parts[0].PartNumber = 0 }, "part number 0 out of range"},
+		{"part number past the end", func(s *chunkSessionState) { s.Parts[0].PartNumber = 4 }, "part number 4 out of range"},
+		{"next hash past the end", func(s *chunkSessionState) { s.NextHash = 4 }, "next hashed chunk 4 out of range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := valid()
+			tt.modify(&state)
+			data, _ := json.Marshal(state)
+			if _, err := ResumeChunkUploadSession(cfg, data); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	data, _ := json.Marshal(valid())
+	if _, err := ResumeChunkUploadSession(cfg, data); err != nil {
+		t.Errorf("unexpected error for a valid state: %v", err)
+	}
+}
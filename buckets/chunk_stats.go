@@ -0,0 +1,36 @@
+package buckets
+
+import "sync/atomic"
+
+// ChunkSessionStats is a snapshot of the progress of a ChunkUploadSession.
+type ChunkSessionStats struct {
+	BytesEncrypted int64 // Passed to EncryptChunk or HashEncryptedData
+	BytesUploaded  int64 // Of the chunks UploadChunk completed
+	PartsCompleted int64
+	PartsFailed    int64 // Failed UploadChunk calls, including ones retried later
+	Retries        int64 // UploadChunk calls for a part that was already tried
+}
+
+// chunkSessionCounters backs ChunkUploadSession.Stats. A part uploaded again
+// after it completed counts once in BytesUploaded and PartsCompleted.
+type chunkSessionCounters struct {
+	bytesEncrypted atomic.Int64
+	bytesUploaded  atomic.Int64
+	partsCompleted atomic.Int64
+	partsFailed    atomic.Int64
+	retries        atomic.Int64
+}
+
+// Stats returns the progress of the session so far. It is safe to call while
+// chunks are being encrypted and uploaded. A session restored by
+// ResumeChunkUploadSession counts the parts completed before it was saved,
+// but not the bytes encrypted or the failures.
+func (s *ChunkUploadSession) Stats() ChunkSessionStats {
+	return ChunkSessionStats{
+		BytesEncrypted: s.stats.bytesEncrypted.Load(),
+		BytesUploaded:  s.stats.bytesUploaded.Load(),
+		PartsCompleted: s.stats.partsCompleted.Load(),
+		PartsFailed:    s.stats.partsFailed.Load(),
+		Retries:        s.stats.retries.Load(),
+	}
+}
@@ -1,47 +1,97 @@
 package buckets
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/md5"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	stderrors "errors"
 	"fmt"
 	"hash"
 	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"context"
 
 	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/crypto"
+	"github.com/internxt/rclone-adapter/errors"
 )
 
+// ErrSessionClosed is returned by the methods of a ChunkUploadSession that
+// has been aborted or finished, and of a closed ChunkDownloadSession.
+var ErrSessionClosed = stderrors.New("session closed")
+
 // ChunkUploadSession holds the state for a chunked upload session
 // where the caller (rclone) controls concurrency and buffer management
 type ChunkUploadSession struct {
 	cfg        *config.Config
 	encIndex   string
 	sha256Hash hash.Hash
-	startResp  *StartUploadResp
-	uploadID   string
-	uuid       string
+	startResp  *StartUploadResp // Its upload is replaced when restarted for new URLs, see presignedURLs
 	totalSize  int64
 	chunkSize  int64
 	numParts   int64
+	singlePart bool // Started by StartUpload rather than StartUploadMultipart
 	fileKey []byte
 	iv      []byte
+	urls    presignedURLs
+
+	hashMu   sync.Mutex
+	nextHash int64                       // Index of the next chunk EncryptChunk feeds into sha256Hash
+	pending  map[int64][]byte            // Chunks encrypted by EncryptChunk ahead of nextHash
+	sums     map[int64][sha256.Size]byte // SHA-256 of the chunks EncryptChunk returned
+
+	partsMu   sync.Mutex
+	completed map[int]CompletedPart // Chunks uploaded by UploadChunk, by part index
+	attempted map[int]bool   // Parts UploadChunk was called for
+
+	stats chunkSessionCounters
+
+	closed atomic.Bool // Set by Abort and a successful Finish
 }
 
 // NewChunkUploadSession initializes encryption and starts the multipart
 // upload session on the Internxt network. The caller specifies totalSize
-// and chunkSize
+// and chunkSize; a chunkSize <= 0 uses cfg.ChunkSize. A file of at most one
+// chunk is uploaded as a single part, which callers use the same way. Sizes
+// the network would reject are refused up front, see config.MinChunkSize.
+// Chunks are encrypted with AES-256-CTR, so cfg.EncryptVersion must select
+// 03-aes, which EncryptVersion reports for the file metadata.
 func NewChunkUploadSession(ctx context.Context, cfg *config.Config, totalSize, chunkSize int64) (*ChunkUploadSession, error) {
+	fc, err := fileCipher(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if fc.Version() != crypto.EncryptVersionAES {
+		return nil, fmt.Errorf("chunked uploads only support encryption version %s, not %s", crypto.EncryptVersionAES, fc.Version())
+	}
+	if chunkSize <= 0 {
+		chunkSize = cfg.ChunkSize
+	}
+	if chunkSize <= 0 {
+		chunkSize = config.DefaultChunkSize
+	}
+	if err := checkChunkSize(cfg, totalSize, chunkSize); err != nil {
+		return nil, err
+	}
+	if err := checkQuota(ctx, cfg, totalSize); err != nil {
+		return nil, err
+	}
+
 	var ph [32]byte
 	if _, err := rand.Read(ph[:]); err != nil {
 		return nil, fmt.Errorf("cannot generate random index: %w", err)
 	}
 	plainIndex := hex.EncodeToString(ph[:])
 
-	fileKey, iv, err := GenerateFileKey(cfg.Mnemonic, cfg.Bucket, plainIndex)
+	fileKey, iv, err := cfg.FileKey(plainIndex)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate file key: %w", err)
 	}
@@ -63,63 +113,253 @@ func NewChunkUploadSession(ctx context.Context, cfg *config.Config, totalSize, c
 	}
 
 	specs := []UploadPartSpec{{Index: 0, Size: totalSize}}
-	s.startResp, err = StartUploadMultipart(ctx, cfg, cfg.Bucket, specs, int(numParts))
-	if err != nil {
-		return nil, fmt.Errorf("failed to start multipart upload: %w", err)
+	if numParts <= 1 {
+		// The network may reject a multipart upload of a single part, so
+		// small files go through the plain start/finish flow instead
+		s.singlePart = true
+		s.startResp, err = StartUpload(ctx, cfg, cfg.Bucket, specs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to start upload: %w", err)
+		}
+	} else {
+		s.startResp, err = StartUploadMultipart(ctx, cfg, cfg.Bucket, specs, int(numParts))
+		if err != nil {
+			return nil, fmt.Errorf("failed to start multipart upload: %w", err)
+		}
 	}
 
 	if len(s.startResp.Uploads) != 1 {
 		return nil, fmt.Errorf("expected 1 upload entry, got %d", len(s.startResp.Uploads))
 	}
 
-	uploadInfo := s.startResp.Uploads[0]
-	if len(uploadInfo.URLs) != int(numParts) {
+	uploadInfo := &s.startResp.Uploads[0]
+	if s.singlePart && len(uploadInfo.URLs) == 0 && uploadInfo.URL != "" {
+		uploadInfo.URLs = []string{uploadInfo.URL}
+	}
+	if s.singlePart && len(uploadInfo.URLs) != 1 {
+		return nil, fmt.Errorf("expected 1 URL, got %d", len(uploadInfo.URLs))
+	}
+	if !s.singlePart && len(uploadInfo.URLs) != int(numParts) {
 		return nil, fmt.Errorf("expected %d URLs, got %d", numParts, len(uploadInfo.URLs))
 	}
 
-	s.uploadID = uploadInfo.UploadId
-	s.uuid = uploadInfo.UUID
+	s.urls.start = restartUpload(cfg, specs, len(uploadInfo.URLs))
 
 	return s, nil
 }
 
+// checkChunkSize fails before anything is uploaded if the network would
+// reject the multipart upload of totalSize bytes in parts of chunkSize once
+// all of them were sent. A file of one part may use any chunkSize.
+func checkChunkSize(cfg *config.Config, totalSize, chunkSize int64) error {
+	if totalSize < 0 {
+		return fmt.Errorf("invalid total size %d", totalSize)
+	}
+	if totalSize <= chunkSize {
+		return nil
+	}
+	minSize := cfg.MinChunkSize
+	if minSize <= 0 {
+		minSize = config.MinChunkSize
+	}
+	if chunkSize < minSize {
+		return fmt.Errorf("chunk size %d is below the %d byte minimum for multipart uploads", chunkSize, minSize)
+	}
+	if chunkSize > config.MaxChunkSize {
+		return fmt.Errorf("chunk size %d exceeds the %d byte maximum", chunkSize, int64(config.MaxChunkSize))
+	}
+	if parts := (totalSize + chunkSize - 1) / chunkSize; parts > config.MaxMultipartParts {
+		return fmt.Errorf("file of %d bytes needs %d parts of %d bytes, more than the %d allowed; use a chunk size of at least %d: %w",
+			totalSize, parts, chunkSize, config.MaxMultipartParts, (totalSize+config.MaxMultipartParts-1)/config.MaxMultipartParts, errors.ErrFileTooLarge)
+	}
+	return nil
+}
+
 // UploadChunk uploads encrypted data to the presigned URL for the given
-// partIndex. Returns the ETag from the server
-func (s *ChunkUploadSession) UploadChunk(ctx context.Context, partIndex int, data io.ReadSeeker, size int64) (string, error) {
-	if partIndex < 0 || partIndex >= len(s.startResp.Uploads[0].URLs) {
-		return "", fmt.Errorf("part index %d out of range [0, %d)", partIndex, len(s.startResp.Uploads[0].URLs))
+// partIndex. Returns the ETag from the server, checked against the MD5 of
+// data when cfg.VerifyPartETags is set. The SHA-256 of data is recorded
+// for CompletedParts and Finish. data is read from its current position if
+// it is an io.ReadSeeker, and otherwise buffered in memory first
+func (s *ChunkUploadSession) UploadChunk(ctx context.Context, partIndex int, data io.Reader, size int64) (string, error) {
+	if s.closed.Load() {
+		return "", ErrSessionClosed
+	}
+	if partIndex < 0 || int64(partIndex) >= s.numParts {
+		return "", fmt.Errorf("part index %d out of range [0, %d)", partIndex, s.numParts)
+	}
+
+	s.partsMu.Lock()
+	if s.attempted == nil {
+		s.attempted = make(map[int]bool)
+	}
+	if s.attempted[partIndex] {
+		s.stats.retries.Add(1)
+	}
+	s.attempted[partIndex] = true
+	s.partsMu.Unlock()
+
+	part, err := s.uploadChunk(ctx, partIndex, data, size)
+	if err != nil {
+		s.stats.partsFailed.Add(1)
+		return "", err
+	}
+
+	s.partsMu.Lock()
+	if s.completed == nil {
+		s.completed = make(map[int]CompletedPart)
+	}
+	if _, ok := s.completed[partIndex]; !ok {
+		s.stats.partsCompleted.Add(1)
+		s.stats.bytesUploaded.Add(size)
+	}
+	s.completed[partIndex] = part
+	s.partsMu.Unlock()
+	return part.ETag, nil
+}
+
+func (s *ChunkUploadSession) uploadChunk(ctx context.Context, partIndex int, r io.Reader, size int64) (CompletedPart, error) {
+	data, ok := r.(io.ReadSeeker)
+	if !ok {
+		bufPtr := getChunkBuffer(size)
+		defer putChunkBuffer(bufPtr)
+		if _, err := io.ReadFull(r, *bufPtr); err != nil {
+			return CompletedPart{}, fmt.Errorf("failed to read chunk %d: %w", partIndex, err)
+		}
+		data = bytes.NewReader(*bufPtr)
+	}
+
+	sha256Hasher := sha256.New()
+	var md5Hasher hash.Hash
+	w := io.Writer(sha256Hasher)
+	if s.cfg.VerifyPartETags {
+		md5Hasher = md5.New()
+		w = io.MultiWriter(sha256Hasher, md5Hasher)
+	}
+	start, err := data.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = io.CopyN(w, data, size)
+	}
+	if err != nil {
+		return CompletedPart{}, fmt.Errorf("failed to hash chunk %d: %w", partIndex, err)
+	}
+	if _, err := data.Seek(start, io.SeekStart); err != nil {
+		return CompletedPart{}, fmt.Errorf("failed to rewind chunk %d: %w", partIndex, err)
 	}
 
-	uploadURL := s.startResp.Uploads[0].URLs[partIndex]
-	result, err := Transfer(ctx, s.cfg, uploadURL, data, size)
+	result, err := s.urls.transfer(ctx, s.cfg, &s.startResp.Uploads[0], partIndex, data, size)
 	if err != nil {
-		return "", fmt.Errorf("failed to upload chunk %d: %w", partIndex, err)
+		return CompletedPart{}, fmt.Errorf("failed to upload chunk %d: %w", partIndex, err)
 	}
-	return result.ETag, nil
+	if md5Hasher != nil {
+		if err := checkPartETag(partIndex, result.ETag, md5Hasher.Sum(nil)); err != nil {
+			return CompletedPart{}, err
+		}
+	}
+	return CompletedPart{
+		PartNumber: partIndex + 1,
+		ETag:       result.ETag,
+		SHA256:     hex.EncodeToString(sha256Hasher.Sum(nil)),
+	}, nil
 }
 
 // Finish computes the final file hash (RIPEMD-160(SHA-256(encrypted_data)))
-// and completes the multipart upload on the Internxt network
+// and completes the multipart upload on the Internxt network. parts is not
+// needed for a single-part upload
 func (s *ChunkUploadSession) Finish(ctx context.Context, parts []CompletedPart) (*FinishUploadResp, error) {
+	if s.closed.Load() {
+		return nil, ErrSessionClosed
+	}
+	s.hashMu.Lock()
+	if (s.nextHash > 0 || len(s.pending) > 0) && s.nextHash < s.numParts {
+		s.hashMu.Unlock()
+		return nil, fmt.Errorf("chunk %d was not encrypted", s.nextHash)
+	}
+	s.hashMu.Unlock()
+
+	parts, err := s.checkParts(parts)
+	if err != nil {
+		return nil, err
+	}
+
 	sha256Result := s.sha256Hash.Sum(nil)
-	overallHash := ComputeFileHash(sha256Result)
+	overallHash := crypto.ComputeFileHash(sha256Result)
 
-	shard := MultipartShard{
-		UUID:     s.uuid,
-		Hash:     overallHash,
-		UploadId: s.uploadID,
-		Parts:    parts,
+	upload := s.upload()
+	var resp *FinishUploadResp
+	if s.singlePart {
+		resp, err = FinishUpload(ctx, s.cfg, s.cfg.Bucket, s.encIndex, []Shard{{Hash: overallHash, UUID: upload.UUID}})
+	} else {
+		shard := MultipartShard{
+			UUID:     upload.UUID,
+			Hash:     overallHash,
+			UploadId: upload.UploadId,
+			Parts:    parts,
+		}
+		resp, err = FinishMultipartUpload(ctx, s.cfg, s.cfg.Bucket, s.encIndex, shard)
 	}
+	if err != nil {
+		// Keep the key, so the session can still be resumed or finished again
+		return nil, err
+	}
+	s.closed.Store(true)
+	crypto.Wipe(s.fileKey, s.iv) // No chunk is encrypted after Finish
+	return resp, nil
+}
 
-	return FinishMultipartUpload(ctx, s.cfg, s.cfg.Bucket, s.encIndex, shard)
+// checkParts fills in the SHA-256 of the parts UploadChunk uploaded, and
+// fails with crypto.ErrHashMismatch naming the part if one given by the
+// caller differs from what was uploaded, so that part can be sent again.
+func (s *ChunkUploadSession) checkParts(parts []CompletedPart) ([]CompletedPart, error) {
+	s.partsMu.Lock()
+	defer s.partsMu.Unlock()
+	checked := make([]CompletedPart, len(parts))
+	for i, p := range parts {
+		checked[i] = p
+		uploaded, ok := s.completed[p.PartNumber-1]
+		if !ok || uploaded.ETag != p.ETag {
+			continue // Not uploaded through this session
+		}
+		if p.SHA256 != "" && !strings.EqualFold(p.SHA256, uploaded.SHA256) {
+			return nil, fmt.Errorf("%w: part %d has SHA-256 %s, uploaded %s", crypto.ErrHashMismatch, p.PartNumber, p.SHA256, uploaded.SHA256)
+		}
+		checked[i].SHA256 = uploaded.SHA256
+	}
+	return checked, nil
+}
+
+// Abort closes the session, after which its methods return ErrSessionClosed,
+// and asks the network to discard the chunks uploaded so far. Call it instead
+// of Finish, or after Finish fails, when the upload cannot be completed.
+// Discarding is best-effort, see AbortMultipartUpload: a failure is logged
+// rather than returned, and the chunks stay stored until the unfinished upload
+// expires
+func (s *ChunkUploadSession) Abort(ctx context.Context) error {
+	if s.closed.Swap(true) {
+		return ErrSessionClosed
+	}
+	crypto.Wipe(s.fileKey, s.iv)
+	s.hashMu.Lock()
+	s.pending, s.sums = nil, nil
+	s.hashMu.Unlock()
+	if s.singlePart {
+		return nil // Nothing to discard until Finish
+	}
+	upload := s.upload()
+	if err := AbortMultipartUpload(ctx, s.cfg, s.cfg.Bucket, upload.UploadId, upload.UUID); err != nil {
+		s.cfg.Log().Warn("failed to discard the chunks of an aborted upload", "upload_id", upload.UploadId, "error", err)
+	}
+	return nil
 }
 
 // NewCipherAtOffset returns an AES-256-CTR cipher.Stream positioned at byteOffset.
 // Handles both block-aligned and non-aligned offsets.
 func (s *ChunkUploadSession) NewCipherAtOffset(byteOffset int64) (cipher.Stream, error) {
+	if s.closed.Load() {
+		return nil, ErrSessionClosed
+	}
 	blockNum := byteOffset / int64(aes.BlockSize)
-	adjustedIV := AddToIV(s.iv, blockNum)
-	stream, err := NewAES256CTRCipher(s.fileKey, adjustedIV)
+	adjustedIV := crypto.AddToIV(s.iv, blockNum)
+	stream, err := crypto.NewAES256CTRCipher(s.fileKey, adjustedIV)
 	if err != nil {
 		return nil, err
 	}
@@ -131,26 +371,101 @@ func (s *ChunkUploadSession) NewCipherAtOffset(byteOffset int64) (cipher.Stream,
 	return stream, nil
 }
 
+// EncryptChunk encrypts plaintext, the whole of part partIndex, and returns
+// the ciphertext to pass to UploadChunk. Chunks may be encrypted in any order
+// and from several goroutines. Since the file hash covers the ciphertext in
+// order, a chunk encrypted ahead of an earlier one is kept until that one is
+// encrypted too, and the returned slice must not be modified. A chunk may be
+// encrypted again to retry its upload; it must hold the same plaintext, and
+// is only hashed once. Use either EncryptChunk or HashEncryptedData for a
+// session, not both.
+func (s *ChunkUploadSession) EncryptChunk(partIndex int, plaintext []byte) ([]byte, error) {
+	index := int64(partIndex)
+	if index < 0 || index >= s.numParts {
+		return nil, fmt.Errorf("part index %d out of range [0, %d)", partIndex, s.numParts)
+	}
+	if want := min(s.chunkSize, s.totalSize-index*s.chunkSize); int64(len(plaintext)) != want {
+		return nil, fmt.Errorf("chunk %d is %d bytes, expected %d", partIndex, len(plaintext), want)
+	}
+
+	stream, err := s.NewCipherAtOffset(index * s.chunkSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher for chunk %d: %w", partIndex, err)
+	}
+	ciphertext := make([]byte, len(plaintext))
+	stream.XORKeyStream(ciphertext, plaintext)
+	sum := sha256.Sum256(ciphertext)
+
+	s.hashMu.Lock()
+	defer s.hashMu.Unlock()
+	if first, ok := s.sums[index]; ok || index < s.nextHash {
+		// Encrypting again for a retry: the keystream is the same, so the
+		// ciphertext must be too, and it was hashed already
+		if ok && first != sum {
+			return nil, fmt.Errorf("chunk %d differs from when it was first encrypted", partIndex)
+		}
+		return ciphertext, nil
+	}
+	s.stats.bytesEncrypted.Add(int64(len(plaintext)))
+	if s.pending == nil {
+		s.pending = make(map[int64][]byte)
+		s.sums = make(map[int64][sha256.Size]byte)
+	}
+	s.sums[index] = sum
+	s.pending[index] = ciphertext
+	for {
+		chunk, ok := s.pending[s.nextHash]
+		if !ok {
+			break
+		}
+		s.sha256Hash.Write(chunk)
+		delete(s.pending, s.nextHash)
+		s.nextHash++
+	}
+	return ciphertext, nil
+}
+
 // HashEncryptedData feeds already-encrypted bytes into the session's SHA-256 hasher.
 // Caller must ensure data is fed in sequential byte order.
 func (s *ChunkUploadSession) HashEncryptedData(data []byte) {
+	s.stats.bytesEncrypted.Add(int64(len(data)))
 	s.sha256Hash.Write(data)
 }
 
-// URLs returns the presigned upload URLs for all parts
+// URLs returns the presigned upload URLs for all parts. UploadChunk renews
+// them as they expire by restarting the upload while no chunk is stored, and
+// fails with ErrUploadURLsExpired afterwards, see URLsExpireAt
 func (s *ChunkUploadSession) URLs() []string {
-	if s.startResp == nil || len(s.startResp.Uploads) == 0 {
-		return nil
-	}
-	return s.startResp.Uploads[0].URLs
+	return s.upload().URLs
 }
 
-// UUID returns the upload session UUID
+// URLsExpireAt returns when the current presigned URLs expire, or the zero
+// time if they do not say
+func (s *ChunkUploadSession) URLsExpireAt() time.Time {
+	return s.upload().ExpiresAt()
+}
+
+// UUID returns the upload session UUID, which changes if the upload is
+// restarted for new URLs before any chunk is stored
 func (s *ChunkUploadSession) UUID() string {
-	return s.uuid
+	return s.upload().UUID
+}
+
+// upload returns the network upload of the session
+func (s *ChunkUploadSession) upload() UploadPart {
+	if s.startResp == nil || len(s.startResp.Uploads) == 0 {
+		return UploadPart{}
+	}
+	return s.urls.current(&s.startResp.Uploads[0])
 }
 
 // EncIndex returns the encryption index for metadata creation
 func (s *ChunkUploadSession) EncIndex() string {
 	return s.encIndex
 }
+
+// EncryptVersion returns the encryption version of the chunks, to record
+// in the file metadata.
+func (s *ChunkUploadSession) EncryptVersion() string {
+	return crypto.EncryptVersionAES
+}
@@ -3,7 +3,6 @@ package buckets
 import (
 	"bytes"
 	"context"
-	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,6 +12,8 @@ import (
 	"strings"
 
 	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/consistency"
+	"github.com/internxt/rclone-adapter/crypto"
 	"github.com/internxt/rclone-adapter/errors"
 )
 
@@ -21,6 +22,7 @@ type ShardInfo struct {
 	Index int    `json:"index"`
 	Hash  string `json:"hash"`
 	URL   string `json:"url"`
+	Size  int64  `json:"size,omitempty"` // Encrypted bytes in the shard, needed for ranges over several shards
 }
 
 // BucketFileInfo is the metadata returned by GET /buckets/{bucketID}/files/{fileID}/info
@@ -37,8 +39,18 @@ type BucketFileInfo struct {
 	Shards   []ShardInfo `json:"shards"`
 }
 
-// GetBucketFileInfo calls the correct /info endpoint and parses its JSON.
+// GetBucketFileInfo calls the correct /info endpoint and parses its JSON,
+// retrying transient failures according to cfg.RetryPolicy.
 func GetBucketFileInfo(ctx context.Context, cfg *config.Config, bucketID, fileID string) (*BucketFileInfo, error) {
+	var info *BucketFileInfo
+	err := cfg.Retry().Do(ctx, isTransientError, func() (err error) {
+		info, err = getBucketFileInfo(ctx, cfg, bucketID, fileID)
+		return err
+	})
+	return info, err
+}
+
+func getBucketFileInfo(ctx context.Context, cfg *config.Config, bucketID, fileID string) (*BucketFileInfo, error) {
 	url := cfg.Endpoints.Network().FileInfo(bucketID, fileID)
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
@@ -64,15 +76,28 @@ func GetBucketFileInfo(ctx context.Context, cfg *config.Config, bucketID, fileID
 	return &info, nil
 }
 
-// DownloadFile downloads and decrypts the first shard of the given file.
-func DownloadFile(ctx context.Context, cfg *config.Config, fileID, destPath string) error {
+// DownloadFile downloads and decrypts the given file, reading its shards in
+// index order. encryptVersion is the version the file was written with, the
+// encryptVersion of its Drive metadata; reads never assume
+// cfg.EncryptVersion, which only applies to new uploads.
+func DownloadFile(ctx context.Context, cfg *config.Config, fileID, encryptVersion, destPath string) error {
+	fc, err := crypto.CipherFor(encryptVersion)
+	if err != nil {
+		return fmt.Errorf("failed to download file %s: %w", fileID, err)
+	}
+
 	// 1) fetch file info from the bucket API
+	if err := consistency.AwaitFile(ctx, fileID); err != nil {
+		return err
+	}
 	info, err := GetBucketFileInfo(ctx, cfg, cfg.Bucket, fileID)
 	if err != nil {
 		return fmt.Errorf("failed to get bucket file info: %w", err)
 	}
 
-	if info.Size == 0 {
+	// Empty 04-aes-gcm content still holds its last chunk, which must
+	// authenticate, so only ciphers that store nothing skip the download
+	if info.Size == 0 && fc.EncryptedSize(0) == 0 {
 		out, err := os.Create(destPath)
 		if err != nil {
 			return fmt.Errorf("failed to create empty file %s: %w", destPath, err)
@@ -83,45 +108,33 @@ func DownloadFile(ctx context.Context, cfg *config.Config, fileID, destPath stri
 	if len(info.Shards) == 0 {
 		return fmt.Errorf("no shards found for file %s", fileID)
 	}
-	shard := info.Shards[0]
-
-	// 2) derive fileKey+iv using the stored index (hex of random index)
-	key, iv, err := GenerateFileKey(cfg.Mnemonic, cfg.Bucket, info.Index)
+	parts, err := shardParts(info, 0, -1, false)
 	if err != nil {
-		return fmt.Errorf("failed to generate file key: %w", err)
+		return err
 	}
 
-	// 3) GET the encrypted shard directly from its presigned URL
-	req, err := http.NewRequestWithContext(ctx, "GET", shard.URL, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create download request: %w", err)
-	}
-	resp, err := cfg.HTTPClient.Do(req)
+	// 2) derive fileKey+iv using the stored index (hex of random index)
+	key, iv, err := cfg.FileKey(info.Index)
 	if err != nil {
-		return fmt.Errorf("failed to execute download request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return errors.NewHTTPError(resp, "shard download")
+		return fmt.Errorf("failed to generate file key: %w", err)
 	}
+	defer crypto.Wipe(key, iv)
 
-	// 4) Set up hash computation for encrypted data stream
-	// Hash algorithm: RIPEMD-160(SHA-256(encrypted_data))
-	var readStream io.Reader = resp.Body
-	var sha256Hasher io.Writer
-	if !cfg.SkipHashValidation {
-		sha256Hasher = sha256.New()
-		readStream = io.TeeReader(resp.Body, sha256Hasher)
+	// 3) GET the encrypted shards directly from their presigned URLs, hashing
+	// each one: RIPEMD-160(SHA-256(encrypted_data))
+	shards := newShardReader(ctx, cfg, fileID, "download", parts, !cfg.SkipHashValidation)
+	if err := shards.open(); err != nil {
+		return err
 	}
+	defer shards.Close()
 
-	// 5) wrap in AES‑CTR decryptor
-	decReader, err := DecryptReader(readStream, key, iv)
+	// 4) wrap in the decryptor, which continues across shards
+	decReader, err := fc.DecryptReaderAt(shards, key, iv, 0)
 	if err != nil {
 		return fmt.Errorf("failed to create decrypt reader: %w", err)
 	}
 
-	// 6) write plaintext to file
+	// 5) write plaintext to file
 	out, err := os.Create(destPath)
 	if err != nil {
 		return fmt.Errorf("failed to create destination file %s: %w", destPath, err)
@@ -132,19 +145,12 @@ func DownloadFile(ctx context.Context, cfg *config.Config, fileID, destPath stri
 		return fmt.Errorf("failed to write decrypted data to file: %w", err)
 	}
 
-	// 7) Validate hash after download completes
-	if !cfg.SkipHashValidation {
-		// Compute RIPEMD-160(SHA-256(encrypted_data)) to match web client
-		sha256Result := sha256Hasher.(interface{ Sum([]byte) []byte }).Sum(nil)
-		computedHash := ComputeFileHash(sha256Result)
-
-		if computedHash != shard.Hash {
-			// Clean up corrupted file
-			out.Close()
-			os.Remove(destPath)
-			return fmt.Errorf("hash mismatch for file %s: expected %s, got %s (file removed)",
-				fileID, shard.Hash, computedHash)
-		}
+	// 6) Validate hashes after download completes
+	if err := shards.Close(); err != nil {
+		// Clean up corrupted file
+		out.Close()
+		os.Remove(destPath)
+		return fmt.Errorf("%w (file removed)", err)
 	}
 
 	return nil
@@ -152,125 +158,212 @@ func DownloadFile(ctx context.Context, cfg *config.Config, fileID, destPath stri
 
 // DownloadFileStream returns a ReadCloser that streams the decrypted contents
 // of the file with the given UUID. The caller must close the returned ReadCloser.
-// It takes an optional range header in the format of either "bytes=100-199" or "bytes=100-".
-func DownloadFileStream(ctx context.Context, cfg *config.Config, fileUUID string, optionalRange ...string) (io.ReadCloser, error) {
+// encryptVersion is the version the file was written with, as for
+// DownloadFile. It takes an optional range header in the format of either
+// "bytes=100-199" or "bytes=100-".
+func DownloadFileStream(ctx context.Context, cfg *config.Config, fileUUID, encryptVersion string, optionalRange ...string) (io.ReadCloser, error) {
 	rangeValue := ""
 	if len(optionalRange) > 0 {
 		rangeValue = optionalRange[0]
 	}
+	fc, err := crypto.CipherFor(encryptVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file %s: %w", fileUUID, err)
+	}
 
 	// 1) Fetch file info (including shards and index)
+	if err := consistency.AwaitFile(ctx, fileUUID); err != nil {
+		return nil, err
+	}
 	info, err := GetBucketFileInfo(ctx, cfg, cfg.Bucket, fileUUID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get bucket file info: %w", err)
 	}
 
-	if info.Size == 0 {
+	if info.Size == 0 && fc.EncryptedSize(0) == 0 {
 		return io.NopCloser(bytes.NewReader(nil)), nil
 	}
 
 	if len(info.Shards) == 0 {
 		return nil, fmt.Errorf("no shards found for file %s", fileUUID)
 	}
-	shard := info.Shards[0]
 
 	// 2) Derive fileKey and IV from the stored index
-	key, iv, err := GenerateFileKey(cfg.Mnemonic, cfg.Bucket, info.Index)
+	key, iv, err := cfg.FileKey(info.Index)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate file key: %w", err)
 	}
+	defer crypto.Wipe(key, iv) // The decrypting reader keeps its own copy
 
-	// 3) Calculate the IV for the requested range
+	// 3) Map the requested range to the encrypted bytes to fetch, and those
+	// to the shards holding them. Decryption starts at a block (or chunk)
+	// boundary; the bytes before the requested start are discarded after
+	// decrypting.
+	var encStart, encEnd, skip, length int64 = 0, -1, 0, -1
 	if rangeValue != "" {
 		startByte, endByte, err := getStartByteAndEndByte(rangeValue)
 		if err != nil {
 			return nil, fmt.Errorf("invalid range: %w", err)
 		}
-
-		// Ensure AES block alignment for correct decryption
-		// Find the nearest block and call this function again with the adjusted range, then discard the unwanted bytes before returning
-		if offset := startByte % 16; offset != 0 {
-			alignedStart := startByte - offset
-			var adjustedRange string
-			if endByte == -1 {
-				adjustedRange = fmt.Sprintf("bytes=%d-", alignedStart)
-			} else {
-				adjustedRange = fmt.Sprintf("bytes=%d-%d", alignedStart, endByte)
-			}
-
-			stream, err := DownloadFileStream(ctx, cfg, fileUUID, adjustedRange)
-			if err != nil {
-				return nil, fmt.Errorf("failed to download aligned stream: %w", err)
-			}
-
-			// Discard unwanted bytes and return the requested range exactly
-			if _, err := io.CopyN(io.Discard, stream, int64(offset)); err != nil {
-				stream.Close()
-				return nil, fmt.Errorf("failed to discard offset bytes: %w", err)
-			}
-			return stream, nil
+		encStart, encEnd, skip = fc.EncryptedRange(int64(startByte), int64(endByte))
+		if endByte != -1 {
+			length = int64(endByte - startByte + 1)
+		} else {
+			encEnd = -1
 		}
-
-		iv = AddToIV(iv, int64(startByte/16))
 	}
-
-	// 4) Download the encrypted shard, include the Range header if any
-	req, err := http.NewRequestWithContext(ctx, "GET", shard.URL, nil)
+	parts, err := shardParts(info, encStart, encEnd, rangeValue != "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
-	if rangeValue != "" {
-		req.Header.Set("Range", rangeValue)
+
+	// 4) Download the encrypted shards, with Range headers if any. Full
+	// downloads are checked against the shard hashes on Close; range
+	// requests skip validation.
+	// Hash algorithm: RIPEMD-160(SHA-256(encrypted_data)) - matches web client
+	shards := newShardReader(ctx, cfg, fileUUID, "download stream", parts, rangeValue == "" && !cfg.SkipHashValidation)
+	if err := shards.open(); err != nil {
+		return nil, err
 	}
 
-	resp, err := cfg.HTTPClient.Do(req)
+	decReader, err := fc.DecryptReaderAt(shards, key, iv, encStart)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute download stream request: %w", err)
+		shards.closeBody()
+		return nil, fmt.Errorf("failed to create decrypt reader: %w", err)
 	}
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		httpErr := errors.NewHTTPError(resp, "shard download stream")
-		resp.Body.Close()
-		return nil, httpErr
+	if skip > 0 {
+		if _, err := io.CopyN(io.Discard, decReader, skip); err != nil {
+			shards.closeBody()
+			return nil, fmt.Errorf("failed to discard offset bytes: %w", err)
+		}
+	}
+	if length >= 0 {
+		decReader = io.LimitReader(decReader, length)
 	}
 
-	// 5) Set up hash computation for full downloads only (range requests skip validation)
-	// Hash algorithm: RIPEMD-160(SHA-256(encrypted_data)) - matches web client
-	var readStream io.Reader = resp.Body
-
-	if rangeValue == "" && !cfg.SkipHashValidation {
-		// Full download - validate hash on Close()
-		sha256Hasher := sha256.New()
-		readStream = io.TeeReader(resp.Body, sha256Hasher)
+	// 5) Return a ReadCloser that closes the shards when closed
+	return struct {
+		io.Reader
+		io.Closer
+	}{Reader: decReader, Closer: shards}, nil
+}
 
-		decReader, err := DecryptReader(readStream, key, iv)
+// openShard GETs a shard from its presigned URL, optionally with a Range
+// header, retrying transient failures according to cfg.RetryPolicy. Only
+// establishing the response is retried; the caller must close its body.
+func openShard(ctx context.Context, cfg *config.Config, shardURL, rangeValue, kind string) (*http.Response, error) {
+	var resp *http.Response
+	err := cfg.Retry().Do(ctx, isTransientError, func() error {
+		req, err := http.NewRequestWithContext(ctx, "GET", shardURL, nil)
 		if err != nil {
-			resp.Body.Close()
-			return nil, fmt.Errorf("failed to create decrypt reader: %w", err)
+			return fmt.Errorf("failed to create %s request: %w", kind, err)
+		}
+		if rangeValue != "" {
+			req.Header.Set("Range", rangeValue)
 		}
 
-		// Return validating reader that checks hash when closed
-		return &hashValidatingReader{
-			Reader:       decReader,
-			body:         resp.Body,
-			sha256Hasher: sha256Hasher,
-			expectedHash: shard.Hash,
-			fileUUID:     fileUUID,
-		}, nil
+		r, err := doShardRequest(cfg, req)
+		if err != nil {
+			return fmt.Errorf("failed to execute %s request: %w", kind, err)
+		}
+		if r.StatusCode < 200 || r.StatusCode >= 300 {
+			httpErr := errors.NewHTTPError(r, "shard "+kind)
+			r.Body.Close()
+			return httpErr
+		}
+		resp = r
+		return nil
+	})
+	if err != nil || rangeValue == "" {
+		return resp, err
 	}
-
-	// Range request or validation skipped - no hash check
-	decReader, err := DecryptReader(readStream, key, iv)
+	body, err := rangeBody(resp, rangeValue)
 	if err != nil {
 		resp.Body.Close()
-		return nil, fmt.Errorf("failed to create decrypt reader: %w", err)
+		return nil, fmt.Errorf("%s of %s: %w", kind, rangeValue, err)
 	}
+	if resp.StatusCode == http.StatusOK {
+		cfg.Log().DebugContext(ctx, "storage ignored Range header, skipping to the range", "range", rangeValue)
+	}
+	resp.Body = body
+	return resp, nil
+}
 
-	// 6) Return a ReadCloser that closes the HTTP body when closed
+// rangeBody returns the body of a response to a request for rangeValue
+// trimmed to exactly the requested bytes. The bytes are decrypted at the
+// requested offset, so any others would turn the output into garbage: a
+// whole shard (200, the Range header ignored) or a wider Content-Range is
+// trimmed, anything else fails with ErrRangeNotSatisfied.
+func rangeBody(resp *http.Response, rangeValue string) (io.ReadCloser, error) {
+	start, end, err := getStartByteAndEndByte(rangeValue)
+	if err != nil {
+		return nil, err
+	}
+	var first, last, total int64 = 0, -1, -1 // Bytes in the body, -1 if unknown
+	switch resp.StatusCode {
+	case http.StatusOK:
+		total = resp.ContentLength
+		if total >= 0 {
+			last = total - 1
+		}
+	case http.StatusPartialContent:
+		contentRange := resp.Header.Get("Content-Range")
+		if first, last, total, err = parseContentRange(contentRange); err != nil {
+			return nil, fmt.Errorf("%w: %v", errors.ErrRangeNotSatisfied, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unexpected status %d", errors.ErrRangeNotSatisfied, resp.StatusCode)
+	}
+
+	// The end may be cut short by the end of the shard, but no earlier
+	short := end >= 0 && last >= 0 && last < int64(end) && (total < 0 || last != total-1)
+	if first > int64(start) || short {
+		return nil, fmt.Errorf("%w: got bytes %d-%d", errors.ErrRangeNotSatisfied, first, last)
+	}
+
+	var body io.Reader = resp.Body
+	if skip := int64(start) - first; skip > 0 {
+		if _, err := io.CopyN(io.Discard, body, skip); err != nil {
+			return nil, fmt.Errorf("failed to skip to the start of the range: %w", err)
+		}
+	}
+	if end >= 0 {
+		body = io.LimitReader(body, int64(end-start+1))
+	}
 	return struct {
 		io.Reader
 		io.Closer
-	}{Reader: decReader, Closer: resp.Body}, nil
+	}{Reader: body, Closer: resp.Body}, nil
+}
+
+// parseContentRange parses a Content-Range header of the form
+// "bytes first-last/total", where total may be "*" (returned as -1).
+func parseContentRange(contentRange string) (first, last, total int64, err error) {
+	spec, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	byteRange, size, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	firstStr, lastStr, ok := strings.Cut(byteRange, "-")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	if first, err = strconv.ParseInt(firstStr, 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	if last, err = strconv.ParseInt(lastStr, 10, 64); err != nil || last < first {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	total = -1
+	if size != "*" {
+		if total, err = strconv.ParseInt(size, 10, 64); err != nil || total <= last {
+			return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", contentRange)
+		}
+	}
+	return first, last, total, nil
 }
 
 // This will return the startByte and endByte of a range header in these formats: "bytes=100-199" or "bytes=100-"
@@ -304,50 +397,3 @@ func getStartByteAndEndByte(rangeHeader string) (int, int, error) {
 
 	return startByte, endByte, nil
 }
-
-// hashValidatingReader wraps a reader and validates the hash on Close().
-// It computes RIPEMD-160(SHA-256(encrypted_data)) and compares it
-// to the expected hash when the stream is closed
-type hashValidatingReader struct {
-	io.Reader
-	body         io.Closer
-	sha256Hasher io.Writer
-	expectedHash string
-	fileUUID     string
-	validated    bool
-}
-
-// Close closes the underlying body and validates the computed hash.
-// NOTE: The hash is only valid if the ENTIRE stream was read before calling Close().
-// If only partial data was read, the hash will be incorrect.
-func (h *hashValidatingReader) Close() error {
-	if h.body == nil {
-		return nil
-	}
-
-	// Validate hash BEFORE closing body (in case we need to drain remaining data)
-	if !h.validated {
-		h.validated = true
-
-		// IMPORTANT: Drain any remaining data in the stream to ensure complete hash
-		// This happens if the caller didn't read the entire stream
-		remaining, err := io.Copy(io.Discard, h.Reader)
-		if err != nil {
-			h.body.Close()
-			return fmt.Errorf("failed to drain remaining stream data: %w", err)
-		}
-
-		// Compute RIPEMD-160(SHA-256(encrypted_data)) to match web client
-		sha256Result := h.sha256Hasher.(interface{ Sum([]byte) []byte }).Sum(nil)
-		computedHash := ComputeFileHash(sha256Result)
-
-		if computedHash != h.expectedHash {
-			h.body.Close()
-			return fmt.Errorf("hash mismatch for file %s: expected %s, got %s (remaining bytes: %d)",
-				h.fileUUID, h.expectedHash, computedHash, remaining)
-		}
-	}
-
-	// Close underlying body
-	return h.body.Close()
-}
@@ -8,16 +8,23 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/crypto"
 	"github.com/internxt/rclone-adapter/endpoints"
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
 )
 
 const (
@@ -80,7 +87,7 @@ func TestDownloadFile_ValidHash(t *testing.T) {
 	defer infoServer.Close()
 
 	cfg := &config.Config{
-		Mnemonic:           TestMnemonic,
+		Mnemonic:           config.NewSecureBytes([]byte(TestMnemonic)),
 		Bucket:             TestBucket1,
 		BasicAuthHeader:    TestBasicAuth,
 		HTTPClient:         &http.Client{},
@@ -89,7 +96,7 @@ func TestDownloadFile_ValidHash(t *testing.T) {
 	}
 
 	tmpFile := t.TempDir() + "/download.dat"
-	err = DownloadFile(context.Background(), cfg, testFileUUID, tmpFile)
+	err = DownloadFile(context.Background(), cfg, testFileUUID, crypto.EncryptVersionAES, tmpFile)
 	if err != nil {
 		t.Fatalf("DownloadFile failed: %v", err)
 	}
@@ -141,7 +148,7 @@ func TestDownloadFile_HashMismatch(t *testing.T) {
 	defer infoServer.Close()
 
 	cfg := &config.Config{
-		Mnemonic:           TestMnemonic,
+		Mnemonic:           config.NewSecureBytes([]byte(TestMnemonic)),
 		Bucket:             TestBucket1,
 		BasicAuthHeader:    TestBasicAuth,
 		HTTPClient:         &http.Client{},
@@ -150,7 +157,7 @@ func TestDownloadFile_HashMismatch(t *testing.T) {
 	}
 
 	tmpFile := t.TempDir() + "/corrupted.dat"
-	err := DownloadFile(context.Background(), cfg, testFileUUID, tmpFile)
+	err := DownloadFile(context.Background(), cfg, testFileUUID, crypto.EncryptVersionAES, tmpFile)
 
 	if err == nil {
 		t.Fatal("expected hash mismatch error, got nil")
@@ -196,7 +203,7 @@ func TestDownloadFile_SkipValidation(t *testing.T) {
 	defer infoServer.Close()
 
 	cfg := &config.Config{
-		Mnemonic:           TestMnemonic,
+		Mnemonic:           config.NewSecureBytes([]byte(TestMnemonic)),
 		Bucket:             TestBucket1,
 		BasicAuthHeader:    TestBasicAuth,
 		HTTPClient:         &http.Client{},
@@ -205,7 +212,7 @@ func TestDownloadFile_SkipValidation(t *testing.T) {
 	}
 
 	tmpFile := t.TempDir() + "/skip-validation.dat"
-	err := DownloadFile(context.Background(), cfg, testFileUUID, tmpFile)
+	err := DownloadFile(context.Background(), cfg, testFileUUID, crypto.EncryptVersionAES, tmpFile)
 
 	if err != nil {
 		t.Fatalf("expected success with SkipHashValidation=true, got: %v", err)
@@ -252,7 +259,7 @@ func TestDownloadFileStream_FullDownload_ValidHash(t *testing.T) {
 	defer infoServer.Close()
 
 	cfg := &config.Config{
-		Mnemonic:           TestMnemonic,
+		Mnemonic:           config.NewSecureBytes([]byte(TestMnemonic)),
 		Bucket:             TestBucket1,
 		BasicAuthHeader:    TestBasicAuth,
 		HTTPClient:         &http.Client{},
@@ -260,7 +267,7 @@ func TestDownloadFileStream_FullDownload_ValidHash(t *testing.T) {
 		SkipHashValidation: false,
 	}
 
-	stream, err := DownloadFileStream(context.Background(), cfg, testFileUUID)
+	stream, err := DownloadFileStream(context.Background(), cfg, testFileUUID, crypto.EncryptVersionAES)
 	if err != nil {
 		t.Fatalf("DownloadFileStream failed: %v", err)
 	}
@@ -315,7 +322,7 @@ func TestDownloadFileStream_RangeRequest_NoValidation(t *testing.T) {
 	defer infoServer.Close()
 
 	cfg := &config.Config{
-		Mnemonic:           TestMnemonic,
+		Mnemonic:           config.NewSecureBytes([]byte(TestMnemonic)),
 		Bucket:             TestBucket1,
 		BasicAuthHeader:    TestBasicAuth,
 		HTTPClient:         &http.Client{},
@@ -323,7 +330,7 @@ func TestDownloadFileStream_RangeRequest_NoValidation(t *testing.T) {
 		SkipHashValidation: false,
 	}
 
-	stream, err := DownloadFileStream(context.Background(), cfg, testFileUUID, "bytes=0-15")
+	stream, err := DownloadFileStream(context.Background(), cfg, testFileUUID, crypto.EncryptVersionAES, "bytes=0-15")
 	if err != nil {
 		t.Fatalf("DownloadFileStream failed: %v", err)
 	}
@@ -339,6 +346,93 @@ func TestDownloadFileStream_RangeRequest_NoValidation(t *testing.T) {
 	}
 }
 
+// TestDownloadFileStream_RangeBeyond4GiB : ranges deep into a large file
+// decrypt with the counter advanced past 2^32 blocks
+func TestDownloadFileStream_RangeBeyond4GiB(t *testing.T) {
+	const fileSize = 1 << 40 // 1 TiB, never materialized
+	key, iv, _ := GenerateFileKey(TestMnemonic, TestBucket1, testIndex)
+	plainAt := func(off int64) byte { return byte(off % 251) }
+
+	// The server encrypts the requested bytes on the fly, with the counter
+	// computed independently of AddToIV
+	downloadServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var start, end int64
+		if n, _ := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); n < 1 {
+			t.Errorf("unexpected Range header %q", r.Header.Get("Range"))
+		} else if n == 1 {
+			end = fileSize - 1
+		}
+		if start%aes.BlockSize != 0 {
+			t.Errorf("expected a block aligned range, got %q", r.Header.Get("Range"))
+		}
+		counter := new(big.Int).SetBytes(iv)
+		counter.Add(counter, big.NewInt(start/aes.BlockSize))
+		counterIV := make([]byte, aes.BlockSize)
+		counter.FillBytes(counterIV)
+		block, _ := aes.NewCipher(key)
+		data := make([]byte, end-start+1)
+		for i := range data {
+			data[i] = plainAt(start + int64(i))
+		}
+		cipher.NewCTR(block, counterIV).XORKeyStream(data, data)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, int64(fileSize)))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(data)
+	}))
+	defer downloadServer.Close()
+
+	infoServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(BucketFileInfo{
+			Bucket: TestBucket1,
+			Index:  testIndex,
+			Size:   fileSize,
+			ID:     testFileUUID,
+			Shards: []ShardInfo{{Index: 0, URL: downloadServer.URL + "/shard"}},
+		})
+	}))
+	defer infoServer.Close()
+
+	cfg := newTestConfig(infoServer.URL)
+
+	testCases := []struct {
+		name       string
+		start, end int64 // end -1 for an open range
+	}{
+		{"just past 4GiB", 4<<30 + 3, 4<<30 + 40},
+		{"past 2^32 blocks", 1<<36 + 17, 1<<36 + 100},
+		{"open range at the end", fileSize - 37, -1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rangeValue := fmt.Sprintf("bytes=%d-", tc.start)
+			end := tc.end
+			if end >= 0 {
+				rangeValue += fmt.Sprint(end)
+			} else {
+				end = fileSize - 1
+			}
+
+			stream, err := DownloadFileStream(context.Background(), cfg, testFileUUID, crypto.EncryptVersionAES, rangeValue)
+			if err != nil {
+				t.Fatalf("DownloadFileStream failed: %v", err)
+			}
+			defer stream.Close()
+			got, err := io.ReadAll(stream)
+			if err != nil {
+				t.Fatalf("failed to read stream: %v", err)
+			}
+
+			want := make([]byte, end-tc.start+1)
+			for i := range want {
+				want[i] = plainAt(tc.start + int64(i))
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("decrypted range does not match the plaintext")
+			}
+		})
+	}
+}
+
 // TestDownloadFile_HTTPErrors : HTTP error codes
 func TestDownloadFile_HTTPErrors(t *testing.T) {
 	testCases := []struct {
@@ -386,14 +480,14 @@ func TestDownloadFile_HTTPErrors(t *testing.T) {
 			defer infoServer.Close()
 
 			cfg := &config.Config{
-				Mnemonic:        TestMnemonic,
+				Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 				Bucket:          TestBucket1,
 				BasicAuthHeader: TestBasicAuth,
 				HTTPClient:      &http.Client{},
 				Endpoints:       endpoints.NewConfig(infoServer.URL),
 			}
 
-			err := DownloadFile(context.Background(), cfg, testFileUUID, t.TempDir()+"/test.dat")
+			err := DownloadFile(context.Background(), cfg, testFileUUID, crypto.EncryptVersionAES, t.TempDir()+"/test.dat")
 
 			if err == nil {
 				t.Fatal("expected error, got nil")
@@ -443,7 +537,7 @@ func TestDownloadFileStream_HashMismatch(t *testing.T) {
 	defer infoServer.Close()
 
 	cfg := &config.Config{
-		Mnemonic:           TestMnemonic,
+		Mnemonic:           config.NewSecureBytes([]byte(TestMnemonic)),
 		Bucket:             TestBucket1,
 		BasicAuthHeader:    TestBasicAuth,
 		HTTPClient:         &http.Client{},
@@ -451,7 +545,7 @@ func TestDownloadFileStream_HashMismatch(t *testing.T) {
 		SkipHashValidation: false,
 	}
 
-	stream, err := DownloadFileStream(context.Background(), cfg, testFileUUID)
+	stream, err := DownloadFileStream(context.Background(), cfg, testFileUUID, crypto.EncryptVersionAES)
 	if err != nil {
 		t.Fatalf("DownloadFileStream failed: %v", err)
 	}
@@ -495,7 +589,7 @@ func TestDownloadFile_EmptyFile(t *testing.T) {
 	defer infoServer.Close()
 
 	cfg := &config.Config{
-		Mnemonic:           TestMnemonic,
+		Mnemonic:           config.NewSecureBytes([]byte(TestMnemonic)),
 		Bucket:             TestBucket1,
 		BasicAuthHeader:    TestBasicAuth,
 		HTTPClient:         &http.Client{},
@@ -504,7 +598,7 @@ func TestDownloadFile_EmptyFile(t *testing.T) {
 	}
 
 	tmpFile := t.TempDir() + "/empty.dat"
-	err := DownloadFile(context.Background(), cfg, testFileUUID, tmpFile)
+	err := DownloadFile(context.Background(), cfg, testFileUUID, crypto.EncryptVersionAES, tmpFile)
 	if err != nil {
 		t.Fatalf("DownloadFile failed for empty file: %v", err)
 	}
@@ -589,6 +683,33 @@ func TestGetBucketFileInfo(t *testing.T) {
 		}
 	})
 
+	t.Run("retries transient errors", func(t *testing.T) {
+		var attempts atomic.Int32
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if attempts.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			json.NewEncoder(w).Encode(BucketFileInfo{ID: TestFileID})
+		}))
+		defer mockServer.Close()
+
+		cfg := newTestConfigWithSetup(mockServer.URL, func(c *config.Config) {
+			c.RetryPolicy = &config.RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}
+		})
+
+		info, err := GetBucketFileInfo(context.Background(), cfg, TestBucket1, TestFileID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if info.ID != TestFileID {
+			t.Errorf("expected ID %s, got %s", TestFileID, info.ID)
+		}
+		if attempts.Load() != 3 {
+			t.Errorf("expected 3 attempts, got %d", attempts.Load())
+		}
+	})
+
 	t.Run("error - invalid JSON", func(t *testing.T) {
 		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusOK)
@@ -663,10 +784,10 @@ func TestDownloadFile(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(infoServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
-		err = DownloadFile(context.Background(), cfg, TestFileID, destPath)
+		err = DownloadFile(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES, destPath)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -698,13 +819,13 @@ func TestDownloadFile(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(mockServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
 		tmpDir := t.TempDir()
 		destPath := filepath.Join(tmpDir, "downloaded-file")
 
-		err := DownloadFile(context.Background(), cfg, TestFileID, destPath)
+		err := DownloadFile(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES, destPath)
 		if err == nil {
 			t.Fatal("expected error for no shards, got nil")
 		}
@@ -736,13 +857,13 @@ func TestDownloadFile(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(mockServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
 		tmpDir := t.TempDir()
 		destPath := filepath.Join(tmpDir, "downloaded-file")
 
-		err := DownloadFile(context.Background(), cfg, TestFileID, destPath)
+		err := DownloadFile(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES, destPath)
 		if err == nil {
 			t.Fatal("expected error for shard download failure, got nil")
 		}
@@ -775,13 +896,13 @@ func TestDownloadFile(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(infoServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
 		tmpDir := t.TempDir()
 		destPath := filepath.Join(tmpDir, "downloaded-file")
 
-		err := DownloadFile(context.Background(), cfg, TestFileID, destPath)
+		err := DownloadFile(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES, destPath)
 		if err == nil {
 			t.Fatal("expected error for non-2xx status, got nil")
 		}
@@ -827,13 +948,13 @@ func TestDownloadFile(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(infoServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
 		// Use an invalid path (directory that doesn't exist)
 		destPath := "/nonexistent/directory/that/does/not/exist/file.txt"
 
-		err := DownloadFile(context.Background(), cfg, TestFileID, destPath)
+		err := DownloadFile(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES, destPath)
 		if err == nil {
 			t.Fatal("expected error for invalid destination path, got nil")
 		}
@@ -854,13 +975,13 @@ func TestDownloadFile(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(infoServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
 		tmpDir := t.TempDir()
 		destPath := filepath.Join(tmpDir, "downloaded-file")
 
-		err := DownloadFile(context.Background(), cfg, TestFileID, destPath)
+		err := DownloadFile(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES, destPath)
 		if err == nil {
 			t.Fatal("expected error when get bucket file info fails, got nil")
 		}
@@ -888,13 +1009,13 @@ func TestDownloadFile(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(infoServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
 		tmpDir := t.TempDir()
 		destPath := filepath.Join(tmpDir, "downloaded-file")
 
-		err := DownloadFile(context.Background(), cfg, TestFileID, destPath)
+		err := DownloadFile(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES, destPath)
 		if err == nil {
 			t.Fatal("expected error when generate file key fails, got nil")
 		}
@@ -998,6 +1119,63 @@ func TestGetStartByteAndEndByte(t *testing.T) {
 	}
 }
 
+func TestRangeBody(t *testing.T) {
+	data := []byte("0123456789abcdefghijklmnopqrstuvwxyz") // 36 bytes
+
+	testCases := []struct {
+		name         string
+		rangeHeader  string
+		status       int
+		contentRange string
+		body         []byte
+		want         []byte
+	}{
+		{"exact range", "bytes=10-19", http.StatusPartialContent, "bytes 10-19/36", data[10:20], data[10:20]},
+		{"open range", "bytes=30-", http.StatusPartialContent, "bytes 30-35/36", data[30:], data[30:]},
+		{"unknown total", "bytes=10-19", http.StatusPartialContent, "bytes 10-19/*", data[10:20], data[10:20]},
+		{"end cut short by the shard", "bytes=30-99", http.StatusPartialContent, "bytes 30-35/36", data[30:], data[30:]},
+		{"wider range", "bytes=10-19", http.StatusPartialContent, "bytes 5-24/36", data[5:25], data[10:20]},
+		{"range ignored", "bytes=10-19", http.StatusOK, "", data, data[10:20]},
+		{"range ignored, open range", "bytes=30-", http.StatusOK, "", data, data[30:]},
+		{"later start", "bytes=10-19", http.StatusPartialContent, "bytes 16-19/36", data[16:20], nil},
+		{"earlier end", "bytes=10-19", http.StatusPartialContent, "bytes 10-15/36", data[10:16], nil},
+		{"missing Content-Range", "bytes=10-19", http.StatusPartialContent, "", data[10:20], nil},
+		{"invalid Content-Range", "bytes=10-19", http.StatusPartialContent, "bytes 19-10/36", data[10:20], nil},
+		{"no content", "bytes=10-19", http.StatusNoContent, "", nil, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			if tc.contentRange != "" {
+				rec.Header().Set("Content-Range", tc.contentRange)
+			}
+			rec.Header().Set("Content-Length", fmt.Sprint(len(tc.body)))
+			rec.WriteHeader(tc.status)
+			rec.Write(tc.body)
+			resp := rec.Result()
+
+			body, err := rangeBody(resp, tc.rangeHeader)
+			if tc.want == nil {
+				if !errors.Is(err, sdkerrors.ErrRangeNotSatisfied) {
+					t.Fatalf("expected ErrRangeNotSatisfied, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("failed to read body: %v", err)
+			}
+			if !bytes.Equal(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
 func TestAddToIV(t *testing.T) {
 	t.Run("increment by one block", func(t *testing.T) {
 		iv := make([]byte, 16)
@@ -1091,10 +1269,10 @@ func TestDownloadFileStream(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(infoServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
-		readCloser, err := DownloadFileStream(context.Background(), cfg, TestFileID)
+		readCloser, err := DownloadFileStream(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES)
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1155,10 +1333,10 @@ func TestDownloadFileStream(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(infoServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
-		readCloser, err := DownloadFileStream(context.Background(), cfg, TestFileID, "bytes=16-47")
+		readCloser, err := DownloadFileStream(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES, "bytes=16-47")
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1195,10 +1373,10 @@ func TestDownloadFileStream(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(infoServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
-		_, err := DownloadFileStream(context.Background(), cfg, TestFileID, "invalid-range")
+		_, err := DownloadFileStream(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES, "invalid-range")
 		if err == nil {
 			t.Fatal("expected error for invalid range, got nil")
 		}
@@ -1224,10 +1402,10 @@ func TestDownloadFileStream(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(infoServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
-		_, err := DownloadFileStream(context.Background(), cfg, TestFileID)
+		_, err := DownloadFileStream(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES)
 		if err == nil {
 			t.Fatal("expected error for no shards, got nil")
 		}
@@ -1248,10 +1426,10 @@ func TestDownloadFileStream(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(infoServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
-		_, err := DownloadFileStream(context.Background(), cfg, TestFileID)
+		_, err := DownloadFileStream(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES)
 		if err == nil {
 			t.Fatal("expected error when get bucket file info fails, got nil")
 		}
@@ -1279,10 +1457,10 @@ func TestDownloadFileStream(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(infoServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
-		_, err := DownloadFileStream(context.Background(), cfg, TestFileID)
+		_, err := DownloadFileStream(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES)
 		if err == nil {
 			t.Fatal("expected error when generate file key fails, got nil")
 		}
@@ -1310,10 +1488,10 @@ func TestDownloadFileStream(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(infoServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
-		_, err := DownloadFileStream(context.Background(), cfg, TestFileID)
+		_, err := DownloadFileStream(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES)
 		if err == nil {
 			t.Fatal("expected error when shard download fails, got nil")
 		}
@@ -1347,10 +1525,10 @@ func TestDownloadFileStream(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(infoServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
-		_, err := DownloadFileStream(context.Background(), cfg, TestFileID)
+		_, err := DownloadFileStream(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES)
 		if err == nil {
 			t.Fatal("expected error when shard download returns 404, got nil")
 		}
@@ -1387,10 +1565,10 @@ func TestDownloadFileStream(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(infoServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
-		_, err := DownloadFileStream(context.Background(), cfg, TestFileID)
+		_, err := DownloadFileStream(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES)
 		if err == nil {
 			t.Fatal("expected error when decrypt reader fails, got nil")
 		}
@@ -1450,12 +1628,12 @@ func TestDownloadFileStream(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(infoServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
 		// Request unaligned range: 20-63 (20 % 16 = 4, not aligned)
 		// Should trigger recursive call with adjusted range 16-63
-		readCloser, err := DownloadFileStream(context.Background(), cfg, TestFileID, "bytes=20-63")
+		readCloser, err := DownloadFileStream(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES, "bytes=20-63")
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1528,12 +1706,12 @@ func TestDownloadFileStream(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(infoServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
 		// Request unaligned open-ended range: bytes=50- (50 % 16 = 2, not aligned)
 		// Should trigger recursive call with adjusted range 48-
-		readCloser, err := DownloadFileStream(context.Background(), cfg, TestFileID, "bytes=50-")
+		readCloser, err := DownloadFileStream(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES, "bytes=50-")
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -1602,11 +1780,11 @@ func TestDownloadFileStream(t *testing.T) {
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(infoServer.URL),
 			Bucket:          TestBucket6,
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		}
 
 		// Request unaligned range that will trigger recursive call and fail during discard
-		_, err = DownloadFileStream(context.Background(), cfg, TestFileID, "bytes=21-")
+		_, err = DownloadFileStream(context.Background(), cfg, TestFileID, crypto.EncryptVersionAES, "bytes=21-")
 		if err == nil {
 			t.Fatal("expected error during offset discard, got nil")
 		}
@@ -1639,7 +1817,7 @@ func TestDownloadFileStream(t *testing.T) {
 		defer infoServer.Close()
 
 		cfg := &config.Config{
-			Mnemonic:           TestMnemonic,
+			Mnemonic:           config.NewSecureBytes([]byte(TestMnemonic)),
 			Bucket:             TestBucket1,
 			BasicAuthHeader:    TestBasicAuth,
 			HTTPClient:         &http.Client{},
@@ -1647,7 +1825,7 @@ func TestDownloadFileStream(t *testing.T) {
 			SkipHashValidation: false,
 		}
 
-		stream, err := DownloadFileStream(context.Background(), cfg, testFileUUID)
+		stream, err := DownloadFileStream(context.Background(), cfg, testFileUUID, crypto.EncryptVersionAES)
 		if err != nil {
 			t.Fatalf("DownloadFileStream failed for empty file: %v", err)
 		}
@@ -1691,14 +1869,14 @@ func TestDownloadFileStream(t *testing.T) {
 		defer infoServer.Close()
 
 		cfg := &config.Config{
-			Mnemonic:        TestMnemonic,
+			Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 			Bucket:          TestBucket1,
 			BasicAuthHeader: TestBasicAuth,
 			HTTPClient:      &http.Client{},
 			Endpoints:       endpoints.NewConfig(infoServer.URL),
 		}
 
-		stream, err := DownloadFileStream(context.Background(), cfg, testFileUUID, "bytes=0-99")
+		stream, err := DownloadFileStream(context.Background(), cfg, testFileUUID, crypto.EncryptVersionAES, "bytes=0-99")
 		if err != nil {
 			t.Fatalf("DownloadFileStream with range failed for empty file: %v", err)
 		}
@@ -21,6 +21,7 @@ type Shard struct {
 type CompletedPart struct {
 	PartNumber int    `json:"PartNumber"`
 	ETag       string `json:"ETag"`
+	SHA256     string `json:"sha256,omitempty"` // Hex SHA-256 of the encrypted part, set by ChunkUploadSession
 }
 
 // MultipartShard represents a shard with multipart upload metadata
@@ -123,3 +124,39 @@ func FinishMultipartUpload(ctx context.Context, cfg *config.Config, bucketID, in
 	}
 	return &result, nil
 }
+
+// AbortMultipartUpload asks the network to discard the parts of an
+// unfinished multipart upload so they no longer count against storage or
+// block a new upload. The abort route is not part of the documented network
+// API and may be missing from a gateway, so callers treat it as best-effort:
+// if it fails, the parts stay until the unfinished upload expires
+func AbortMultipartUpload(ctx context.Context, cfg *config.Config, bucketID, uploadId, uuid string) error {
+	url := cfg.Endpoints.Network().AbortUpload(bucketID)
+	payload := map[string]string{
+		"uuid":     uuid,
+		"UploadId": uploadId,
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal abort multipart upload request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("failed to create abort multipart upload request: %w", err)
+	}
+	req.Header.Set("Authorization", cfg.BasicAuthHeader)
+	req.Header.Set("internxt-version", "1.0")
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute abort multipart upload request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.NewHTTPError(resp, "abort multipart upload")
+	}
+	return nil
+}
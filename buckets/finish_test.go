@@ -576,3 +576,34 @@ func TestFinishMultipartUploadEmptyParts(t *testing.T) {
 		t.Error("expected error for empty parts, got nil")
 	}
 }
+
+// TestAbortMultipartUpload tests the abort request and its error handling
+func TestAbortMultipartUpload(t *testing.T) {
+	var capturedPath string
+	var capturedPayload map[string]string
+	status := http.StatusOK
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		capturedPath = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&capturedPayload)
+		w.WriteHeader(status)
+	}))
+	defer mockServer.Close()
+
+	cfg := newTestConfig(mockServer.URL)
+
+	if err := AbortMultipartUpload(context.Background(), cfg, "bucket-123", "upload-xyz", "uuid-123"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if capturedPath != "/network/v2/buckets/bucket-123/files/abort" {
+		t.Errorf("unexpected path %q", capturedPath)
+	}
+	if capturedPayload["UploadId"] != "upload-xyz" || capturedPayload["uuid"] != "uuid-123" {
+		t.Errorf("unexpected payload %v", capturedPayload)
+	}
+
+	status = http.StatusNotFound
+	if err := AbortMultipartUpload(context.Background(), cfg, "bucket-123", "upload-xyz", "uuid-123"); err == nil {
+		t.Error("expected error for 404 response, got nil")
+	}
+}
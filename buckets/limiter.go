@@ -0,0 +1,102 @@
+package buckets
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// adaptiveLimiter bounds the number of parts uploaded at once. When adaptive,
+// the limit grows by one after each part that keeps up with the recent
+// per-part throughput and halves on a transient error (AIMD), staying
+// between 1 and ceiling; otherwise it stays at ceiling.
+type adaptiveLimiter struct {
+	mu       sync.Mutex
+	changed  chan struct{} // Closed and replaced whenever a slot may have become free
+	limit    int
+	inFlight int
+	ceiling  int
+	adaptive bool
+	rate     float64 // Moving average of per-part throughput in bytes per second
+}
+
+// slowdownRatio is how far below the average a part's throughput may fall
+// before the limit stops growing.
+const slowdownRatio = 0.8
+
+func newAdaptiveLimiter(ceiling int, adaptive bool) *adaptiveLimiter {
+	ceiling = max(ceiling, 1)
+	limit := ceiling
+	if adaptive {
+		limit = max(ceiling/2, 1)
+	}
+	return &adaptiveLimiter{
+		changed:  make(chan struct{}),
+		limit:    limit,
+		ceiling:  ceiling,
+		adaptive: adaptive,
+	}
+}
+
+// acquire waits for a free slot or until ctx is done.
+func (l *adaptiveLimiter) acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.inFlight < l.limit {
+			l.inFlight++
+			l.mu.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release frees a slot taken by acquire.
+func (l *adaptiveLimiter) release() {
+	l.mu.Lock()
+	l.inFlight--
+	l.notify()
+	l.mu.Unlock()
+}
+
+// observe records one transfer of size bytes that took d, and adjusts the
+// limit. It returns the new and the previous limit.
+func (l *adaptiveLimiter) observe(size int, d time.Duration, err error) (limit, prev int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prev = l.limit
+	if !l.adaptive {
+		return l.limit, prev
+	}
+
+	if err != nil {
+		if isTransientError(err) {
+			l.limit = max(l.limit/2, 1)
+		}
+		return l.limit, prev
+	}
+
+	rate := float64(size) / max(d.Seconds(), 1e-6)
+	switch {
+	case l.rate == 0:
+		l.rate = rate
+	case rate >= l.rate*slowdownRatio && l.limit < l.ceiling:
+		l.limit++
+		l.notify()
+	}
+	l.rate = 0.8*l.rate + 0.2*rate
+	return l.limit, prev
+}
+
+// notify wakes the goroutines waiting in acquire. l.mu must be held.
+func (l *adaptiveLimiter) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
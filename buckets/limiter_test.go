@@ -0,0 +1,82 @@
+package buckets
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
+)
+
+// TestAdaptiveLimiterAIMD tests that the limit grows while parts keep their
+// throughput, halves on a transient error and stays within [1, ceiling]
+func TestAdaptiveLimiterAIMD(t *testing.T) {
+	l := newAdaptiveLimiter(8, true)
+	if l.limit != 4 {
+		t.Fatalf("expected an initial limit of 4, got %d", l.limit)
+	}
+
+	for range 10 {
+		l.observe(1<<20, time.Second, nil)
+	}
+	if l.limit != 8 {
+		t.Errorf("expected the limit to grow to the ceiling of 8, got %d", l.limit)
+	}
+
+	if limit, _ := l.observe(1<<10, time.Second, nil); limit != 8 {
+		t.Errorf("expected a slow part to hold the limit, got %d", limit)
+	}
+
+	throttled := sdkerrors.NewHTTPError(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{}, Body: http.NoBody}, "upload part")
+	if limit, prev := l.observe(0, time.Second, throttled); limit != 4 || prev != 8 {
+		t.Errorf("expected a 429 to halve the limit from 8 to 4, got %d from %d", limit, prev)
+	}
+	for range 5 {
+		l.observe(0, time.Second, throttled)
+	}
+	if l.limit != 1 {
+		t.Errorf("expected the limit to stop at 1, got %d", l.limit)
+	}
+
+	if limit, _ := l.observe(0, time.Second, errors.New("bad request")); limit != 1 {
+		t.Errorf("expected a permanent error to leave the limit alone, got %d", limit)
+	}
+}
+
+// TestAdaptiveLimiterFixed tests that a non-adaptive limiter keeps its ceiling
+func TestAdaptiveLimiterFixed(t *testing.T) {
+	l := newAdaptiveLimiter(6, false)
+	throttled := sdkerrors.NewHTTPError(&http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{}, Body: http.NoBody}, "upload part")
+	if limit, _ := l.observe(0, time.Second, throttled); limit != 6 {
+		t.Errorf("expected the limit to stay at 6, got %d", limit)
+	}
+}
+
+// TestAdaptiveLimiterAcquire tests that acquire blocks at the limit, wakes on
+// release and honours context cancellation
+func TestAdaptiveLimiterAcquire(t *testing.T) {
+	l := newAdaptiveLimiter(1, false)
+	if err := l.acquire(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := l.acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded at the limit, got %v", err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- l.acquire(context.Background()) }()
+	l.release()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("acquire did not return after release")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -41,13 +42,77 @@ type CreateMetaResponse struct {
 	Created        string      `json:"created"`
 }
 
-// CreateMetaFile creates file metadata in Drive for a file in the given folder.
+// CreateMetaFile creates file metadata in Drive for a file in the given folder,
+// in the workspace's drive when cfg.WorkspaceID is set.
 func CreateMetaFile(ctx context.Context, cfg *config.Config, name, bucketID string, fileID *string, encryptVersion, folderUuid, plainName, fileType string, size int64, modTime time.Time) (*CreateMetaResponse, error) {
 	if err := consistency.AwaitFolder(ctx, folderUuid); err != nil {
 		return nil, err
 	}
 
+	var result *CreateMetaResponse
+	err := cfg.Retry().Do(ctx, isTransientError, func() (err error) {
+		result, err = createMetaFile(ctx, cfg, name, bucketID, fileID, encryptVersion, folderUuid, plainName, fileType, size, modTime)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.PollConsistency {
+		consistency.TrackFileProbe(result.UUID, existsProbe(cfg, cfg.Endpoints.Drive().Files().Meta(result.UUID)))
+		if fileID != nil {
+			networkID := *fileID
+			consistency.TrackFileProbe(networkID, func(ctx context.Context) (bool, error) {
+				_, err := getBucketFileInfo(ctx, cfg, bucketID, networkID)
+				return probeResult(err)
+			})
+		}
+	} else {
+		consistency.TrackFile(result.UUID)
+		if fileID != nil {
+			consistency.TrackFile(*fileID)
+		}
+	}
+	consistency.TrackFolderContents(folderUuid)
+	cfg.ListingCache.InvalidateFolder(folderUuid)
+	return result, nil
+}
+
+// existsProbe reports a Drive object as visible once a GET of url succeeds.
+func existsProbe(cfg *config.Config, url string) consistency.Probe {
+	return func(ctx context.Context) (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return false, fmt.Errorf("failed to create probe request: %w", err)
+		}
+		cfg.AuthorizeRequest(req)
+		resp, err := cfg.HTTPClient.Do(req)
+		if err != nil {
+			return false, fmt.Errorf("failed to execute probe request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return probeResult(errors.NewHTTPError(resp, "probe"))
+		}
+		return true, nil
+	}
+}
+
+// probeResult maps a lookup error to a consistency.Probe result: not found
+// means "not visible yet" rather than a failure.
+func probeResult(err error) (bool, error) {
+	if stderrors.Is(err, errors.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func createMetaFile(ctx context.Context, cfg *config.Config, name, bucketID string, fileID *string, encryptVersion, folderUuid, plainName, fileType string, size int64, modTime time.Time) (*CreateMetaResponse, error) {
 	url := cfg.Endpoints.Drive().Files().Create()
+	if cfg.WorkspaceID != "" {
+		url = cfg.Endpoints.Drive().Workspaces().Files(cfg.WorkspaceID)
+	}
 	reqBody := CreateMetaRequest{
 		Name:             name,
 		Bucket:           bucketID,
@@ -69,8 +134,7 @@ func CreateMetaFile(ctx context.Context, cfg *config.Config, name, bucketID stri
 	if err != nil {
 		return nil, fmt.Errorf("failed to create meta request: %w", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+cfg.Token)
-	req.Header.Set("internxt-version", "v1.0.436")
+	cfg.AuthorizeRequest(req)
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 	resp, err := cfg.HTTPClient.Do(req)
 	if err != nil {
@@ -3,25 +3,48 @@ package buckets
 import (
 	"bytes"
 	"context"
+	"crypto/aes"
 	"crypto/cipher"
+	"crypto/md5"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
+	stderrors "errors"
 	"fmt"
 	"io"
+	"net"
+	"net/url"
+	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/crypto"
+	"github.com/internxt/rclone-adapter/errors"
 )
 
-// chunkBufferPool reuses memory buffers for chunk encryption to reduce GC pressure
-// Uses sync.Pool without pre-allocation - buffers are only created when actually needed
-var chunkBufferPool = sync.Pool{
-	New: func() any {
-		// Return nil - allocate on-demand
-		return nil
-	},
+// chunkBufferPool reuses chunk-sized buffers across parts and transfers to
+// reduce GC pressure. Buffers are only created when actually needed, see
+// getChunkBuffer and putChunkBuffer
+var chunkBufferPool sync.Pool
+
+// getChunkBuffer returns a buffer of length size, reusing a pooled one when
+// it is large enough
+func getChunkBuffer(size int64) *[]byte {
+	if bufPtr, ok := chunkBufferPool.Get().(*[]byte); ok && int64(cap(*bufPtr)) >= size {
+		*bufPtr = (*bufPtr)[:size]
+		return bufPtr
+	}
+	buf := make([]byte, size)
+	return &buf
+}
+
+// putChunkBuffer returns a buffer from getChunkBuffer to the pool. The caller
+// must not use it afterwards
+func putChunkBuffer(bufPtr *[]byte) {
+	chunkBufferPool.Put(bufPtr)
 }
 
 // multipartUploadState holds the state for a single multipart upload session
@@ -31,14 +54,16 @@ type multipartUploadState struct {
 	encIndex       string
 	fileKey        []byte
 	iv             []byte
-	cipher         cipher.Stream
-	totalSize      int64
+	fileCipher     crypto.FileCipher
+	cipher         cipher.Stream // 03-aes only, the stream at offset 0; other versions encrypt the reader as a whole
+	totalSize      int64         // Stored size of the encrypted file
 	chunkSize      int64
 	numParts       int64
 	startResp      *StartUploadResp
 	maxConcurrency int
-	uploadId       string
-	uuid           string
+	retryBudget    *atomic.Int64 // Retries left for all parts, nil if cfg.MultipartRetryBudget is unset
+	limiter        *adaptiveLimiter
+	urls           presignedURLs
 }
 
 // encryptedChunk represents a chunk that has been encrypted and is ready for upload
@@ -47,6 +72,8 @@ type encryptedChunk struct {
 	data       []byte
 	err        error
 	bufferRefs []*[]byte
+	ready      chan struct{} // Closed once data is encrypted, nil if it already is
+	readErr    *error        // Set before ready is closed if reading the chunk at its offset failed
 }
 
 // uploadResult holds the result of a single chunk upload
@@ -58,6 +85,10 @@ type uploadResult struct {
 
 // newMultipartUploadState initializes encryption parameters and cipher for multipart upload
 func newMultipartUploadState(cfg *config.Config, plainSize int64) (*multipartUploadState, error) {
+	fc, err := fileCipher(cfg)
+	if err != nil {
+		return nil, err
+	}
 	var ph [32]byte
 	if _, err := rand.Read(ph[:]); err != nil {
 		return nil, fmt.Errorf("cannot generate random index: %w", err)
@@ -65,18 +96,34 @@ func newMultipartUploadState(cfg *config.Config, plainSize int64) (*multipartUpl
 
 	plainIndex := hex.EncodeToString(ph[:])
 
-	fileKey, iv, err := GenerateFileKey(cfg.Mnemonic, cfg.Bucket, plainIndex)
+	fileKey, iv, err := cfg.FileKey(plainIndex)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate file key: %w", err)
 	}
 
-	cipherStream, err := NewAES256CTRCipher(fileKey, iv)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	var cipherStream cipher.Stream
+	if fc.Version() == crypto.EncryptVersionAES {
+		cipherStream, err = crypto.NewAES256CTRCipher(fileKey, iv)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher: %w", err)
+		}
 	}
+	totalSize := fc.EncryptedSize(plainSize)
 
-	chunkSize := int64(config.DefaultChunkSize)
-	numParts := (plainSize + chunkSize - 1) / chunkSize
+	chunkSize := cfg.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = config.DefaultChunkSize
+	}
+	maxConcurrency := cfg.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = config.DefaultMaxConcurrency
+	}
+	numParts := (totalSize + chunkSize - 1) / chunkSize
+	var retryBudget *atomic.Int64
+	if cfg.MultipartRetryBudget > 0 {
+		retryBudget = new(atomic.Int64)
+		retryBudget.Store(int64(cfg.MultipartRetryBudget))
+	}
 
 	return &multipartUploadState{
 		cfg:            cfg,
@@ -84,11 +131,14 @@ func newMultipartUploadState(cfg *config.Config, plainSize int64) (*multipartUpl
 		encIndex:       plainIndex,
 		fileKey:        fileKey,
 		iv:             iv,
+		fileCipher:     fc,
 		cipher:         cipherStream,
-		totalSize:      plainSize,
+		totalSize:      totalSize,
 		chunkSize:      chunkSize,
 		numParts:       numParts,
-		maxConcurrency: config.DefaultMaxConcurrency,
+		maxConcurrency: maxConcurrency,
+		retryBudget:    retryBudget,
+		limiter:        newAdaptiveLimiter(maxConcurrency, cfg.AdaptiveConcurrency),
 	}, nil
 }
 
@@ -111,48 +161,101 @@ func (s *multipartUploadState) executeMultipartUpload(ctx context.Context, reade
 		return nil, fmt.Errorf("expected %d URLs, got %d", s.numParts, len(uploadInfo.URLs))
 	}
 
-	s.uploadId = uploadInfo.UploadId
-	s.uuid = uploadInfo.UUID
+	s.urls.start = restartUpload(s.cfg, specs, int(s.numParts))
+	defer crypto.Wipe(s.fileKey, s.iv)
+	s.cfg.Log().DebugContext(ctx, "multipart upload started", "upload_id", uploadInfo.UploadId, "parts", s.numParts, "part_size", s.chunkSize, "size", s.totalSize)
+
+	if s.cipher == nil {
+		reader, err = s.fileCipher.EncryptReader(reader, s.fileKey, s.iv)
+		if err != nil {
+			s.abort(ctx)
+			return nil, fmt.Errorf("failed to create encrypt reader: %w", err)
+		}
+	}
 
 	completedParts, overallHash, err := s.encryptAndUploadPipelined(ctx, reader)
 	if err != nil {
+		s.abort(ctx)
 		return nil, fmt.Errorf("failed to encrypt and upload chunks: %w", err)
 	}
 
+	upload := s.urls.current(&s.startResp.Uploads[0])
 	return &MultipartShard{
-		UUID:     s.uuid,
+		UUID:     upload.UUID,
 		Hash:     overallHash,
-		UploadId: s.uploadId,
+		UploadId: upload.UploadId,
 		Parts:    completedParts,
 	}, nil
 }
 
+// abort discards the parts uploaded so far. It runs even when ctx has been
+// cancelled, and a failure is only logged since the upload already failed.
+func (s *multipartUploadState) abort(ctx context.Context) {
+	upload := s.urls.current(&s.startResp.Uploads[0])
+	if err := AbortMultipartUpload(context.WithoutCancel(ctx), s.cfg, s.cfg.Bucket, upload.UploadId, upload.UUID); err != nil {
+		s.cfg.Log().Warn("failed to abort multipart upload", "upload_id", upload.UploadId, "error", err)
+	}
+}
+
 // encryptAndUploadPipelined encrypts chunks and uploads them concurrently
-func (s *multipartUploadState) encryptAndUploadPipelined(ctx context.Context, reader io.Reader) ([]CompletedPart, string, error) {
+func (s *multipartUploadState) encryptAndUploadPipelined(parent context.Context, reader io.Reader) ([]CompletedPart, string, error) {
+	// ctx is cancelled on the first failed part, so in-flight transfers
+	// stop as soon as the upload can no longer succeed
+	ctx, cancel := context.WithCancelCause(parent)
+	defer cancel(nil)
+
 	chunkChan := make(chan encryptedChunk, s.maxConcurrency)
 
 	var uploadWg sync.WaitGroup
 
 	results := make(chan uploadResult, s.numParts)
 
-	semaphore := make(chan struct{}, s.maxConcurrency)
-
 	// Compute hash: RIPEMD-160(SHA-256(encrypted_data)) - matches web client
 	overallHasher := sha256.New()
 	var hashMutex sync.Mutex
 	var encryptErr error
 
+	// buffered bounds the parts read but not yet uploaded to one more than
+	// the uploads that can run at once, so a slow network holds back reading
+	// and encryption instead of the whole file piling up in memory.
+	buffered := make(chan struct{}, s.maxConcurrency+1)
+	release := func(bufs ...*[]byte) {
+		for _, bufPtr := range bufs {
+			putChunkBuffer(bufPtr)
+		}
+		<-buffered
+	}
+
+	// CTR parts are encrypted concurrently, each from the IV advanced to its
+	// offset, and hashed in order as they are handed to the uploaders.
+	encryptSemaphore := make(chan struct{}, runtime.NumCPU())
+
+	// A CTR source that is an io.ReaderAt, such as a local file, is also read
+	// concurrently, each part at its offset from the reader's current position.
+	var readerAt io.ReaderAt
+	var readerBase int64
+	if ra, ok := reader.(io.ReaderAt); ok && s.cipher != nil {
+		readerAt = ra
+		if seeker, ok := reader.(io.Seeker); ok {
+			pos, err := seeker.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to get reader position: %w", err)
+			}
+			readerBase = pos
+		}
+	}
+
 	// Start encryption goroutine
 	go func() {
 		defer close(chunkChan)
 
 		for i := int64(0); i < s.numParts; i++ {
 			select {
+			case buffered <- struct{}{}:
 			case <-ctx.Done():
 				encryptErr = ctx.Err()
 				chunkChan <- encryptedChunk{index: int(i), err: encryptErr}
 				return
-			default:
 			}
 
 			chunkSize := s.chunkSize
@@ -160,82 +263,108 @@ func (s *multipartUploadState) encryptAndUploadPipelined(ctx context.Context, re
 				chunkSize = s.totalSize - (i * s.chunkSize)
 			}
 
-			// Get buffers from pool or allocate at exact size needed
-			var plainBufPtr, encryptedBufPtr *[]byte
-
-			if poolBuf := chunkBufferPool.Get(); poolBuf != nil {
-				plainBufPtr = poolBuf.(*[]byte)
-				// Resize if buffer is too small
-				if int64(cap(*plainBufPtr)) < chunkSize {
-					buf := make([]byte, chunkSize)
-					plainBufPtr = &buf
+			plainBufPtr := getChunkBuffer(chunkSize)
+			encryptedBufPtr := getChunkBuffer(chunkSize)
+
+			plainChunk := *plainBufPtr
+			if readerAt == nil {
+				n, err := io.ReadFull(reader, plainChunk)
+				if err != nil && err != io.ErrUnexpectedEOF {
+					release(plainBufPtr, encryptedBufPtr)
+					encryptErr = fmt.Errorf("failed to read chunk %d: %w", i, err)
+					chunkChan <- encryptedChunk{index: int(i), err: encryptErr}
+					return
 				}
-			} else {
-				buf := make([]byte, chunkSize)
-				plainBufPtr = &buf
+				plainChunk = plainChunk[:n]
 			}
 
-			if poolBuf := chunkBufferPool.Get(); poolBuf != nil {
-				encryptedBufPtr = poolBuf.(*[]byte)
-				if int64(cap(*encryptedBufPtr)) < chunkSize {
-					buf := make([]byte, chunkSize)
-					encryptedBufPtr = &buf
+			encryptedData := (*encryptedBufPtr)[:len(plainChunk)]
+			var ready chan struct{}
+			var readErr *error
+			if s.cipher != nil {
+				var err error
+				stream := s.cipher
+				if i > 0 {
+					if stream, err = s.cipherAtOffset(i * s.chunkSize); err != nil {
+						release(plainBufPtr, encryptedBufPtr)
+						encryptErr = fmt.Errorf("failed to create cipher for chunk %d: %w", i, err)
+						chunkChan <- encryptedChunk{index: int(i), err: encryptErr}
+						return
+					}
 				}
+				ready = make(chan struct{})
+				readErr = new(error)
+				encryptSemaphore <- struct{}{}
+				go func(offset int64) {
+					defer func() { <-encryptSemaphore }()
+					defer close(ready)
+					if readerAt != nil {
+						if n, err := readerAt.ReadAt(plainChunk, readerBase+offset); n < len(plainChunk) {
+							if err == io.EOF {
+								err = io.ErrUnexpectedEOF
+							}
+							*readErr = fmt.Errorf("failed to read chunk %d: %w", offset/s.chunkSize, err)
+							return
+						}
+					}
+					stream.XORKeyStream(encryptedData, plainChunk)
+				}(i * s.chunkSize)
 			} else {
-				buf := make([]byte, chunkSize)
-				encryptedBufPtr = &buf
-			}
-
-			plainChunk := (*plainBufPtr)[:chunkSize]
-			n, err := io.ReadFull(reader, plainChunk)
-			if err != nil && err != io.ErrUnexpectedEOF {
-				chunkBufferPool.Put(plainBufPtr)
-				chunkBufferPool.Put(encryptedBufPtr)
-				encryptErr = fmt.Errorf("failed to read chunk %d: %w", i, err)
-				chunkChan <- encryptedChunk{index: int(i), err: encryptErr}
-				return
+				copy(encryptedData, plainChunk) // Read from an encrypting reader
 			}
-			plainChunk = plainChunk[:n]
-
-			encryptedData := (*encryptedBufPtr)[:len(plainChunk)]
-			s.cipher.XORKeyStream(encryptedData, plainChunk)
-
-			overallHasher.Write(encryptedData)
 
 			chunkChan <- encryptedChunk{
 				index:      int(i),
 				data:       encryptedData,
 				err:        nil,
 				bufferRefs: []*[]byte{plainBufPtr, encryptedBufPtr},
+				ready:      ready,
+				readErr:    readErr,
 			}
 		}
 	}()
 
 	// Start upload workers
 	for chunk := range chunkChan {
+		if chunk.ready != nil {
+			<-chunk.ready
+		}
+		if chunk.readErr != nil && *chunk.readErr != nil {
+			release(chunk.bufferRefs...)
+			chunk.err = *chunk.readErr
+		}
 		if chunk.err != nil {
+			cancel(chunk.err)
 			for remaining := range chunkChan {
-				for _, bufPtr := range remaining.bufferRefs {
-					chunkBufferPool.Put(bufPtr)
+				if remaining.ready != nil {
+					<-remaining.ready
+				}
+				if remaining.bufferRefs != nil {
+					release(remaining.bufferRefs...)
 				}
 			}
-			return nil, "", chunk.err
+			uploadWg.Wait()
+			return nil, "", context.Cause(ctx)
 		}
 
+		overallHasher.Write(chunk.data)
+
 		uploadWg.Add(1)
 		go func(ch encryptedChunk) {
 			defer uploadWg.Done()
 
-			defer func() {
-				for _, bufPtr := range ch.bufferRefs {
-					chunkBufferPool.Put(bufPtr)
-				}
-			}()
+			defer release(ch.bufferRefs...)
 
-			semaphore <- struct{}{}
-			defer func() { <-semaphore }()
+			if err := s.limiter.acquire(ctx); err != nil {
+				results <- uploadResult{index: ch.index, err: err}
+				return
+			}
+			defer s.limiter.release()
 
 			etag, err := s.uploadChunkWithRetry(ctx, ch.index, ch.data)
+			if err != nil {
+				cancel(err)
+			}
 
 			results <- uploadResult{
 				index: ch.index,
@@ -251,14 +380,7 @@ func (s *multipartUploadState) encryptAndUploadPipelined(ctx context.Context, re
 	}()
 
 	parts := make([]CompletedPart, s.numParts)
-	var firstError error
-
-	resultsCollected := 0
 	for result := range results {
-		resultsCollected++
-		if result.err != nil && firstError == nil {
-			firstError = result.err
-		}
 		if result.err == nil {
 			parts[result.index] = CompletedPart{
 				PartNumber: result.index + 1,
@@ -267,85 +389,128 @@ func (s *multipartUploadState) encryptAndUploadPipelined(ctx context.Context, re
 		}
 	}
 
-	if firstError != nil {
-		return nil, "", firstError
+	// The cause is the first failed part, or the caller's ctx.Err()
+	if err := context.Cause(ctx); err != nil {
+		return nil, "", err
 	}
 
 	hashMutex.Lock()
 	// Compute RIPEMD-160(SHA-256) to match web client
 	sha256Result := overallHasher.Sum(nil)
-	overallHash := ComputeFileHash(sha256Result)
+	overallHash := crypto.ComputeFileHash(sha256Result)
 	hashMutex.Unlock()
 
 	return parts, overallHash, nil
 }
 
-// uploadChunkWithRetry uploads a single chunk with exponential backoff retry
+// cipherAtOffset returns the 03-aes stream positioned at byteOffset.
+func (s *multipartUploadState) cipherAtOffset(byteOffset int64) (cipher.Stream, error) {
+	stream, err := crypto.NewAES256CTRCipher(s.fileKey, crypto.AddToIV(s.iv, byteOffset/aes.BlockSize))
+	if err != nil {
+		return nil, err
+	}
+	if partial := byteOffset % aes.BlockSize; partial > 0 {
+		throwaway := make([]byte, partial)
+		stream.XORKeyStream(throwaway, throwaway)
+	}
+	return stream, nil
+}
+
+// uploadChunkWithRetry uploads a single chunk, retrying according to the
+// config's RetryPolicy
 func (s *multipartUploadState) uploadChunkWithRetry(ctx context.Context, partIndex int, encryptedData []byte) (string, error) {
-	const maxRetries = 3
-	const baseDelay = 1 * time.Second
-
-	uploadURL := s.startResp.Uploads[0].URLs[partIndex]
-
-	var lastErr error
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		// Check context cancellation before retry
-		select {
-		case <-ctx.Done():
-			return "", ctx.Err()
-		default:
-		}
+	policy := s.cfg.Retry()
 
-		if attempt > 0 {
-			delay := baseDelay * time.Duration(1<<uint(attempt-1))
-			timer := time.NewTimer(delay)
-			select {
-			case <-timer.C:
-			case <-ctx.Done():
-				timer.Stop()
-				return "", ctx.Err()
+	var partMD5 []byte
+	if s.cfg.VerifyPartETags {
+		sum := md5.Sum(encryptedData)
+		partMD5 = sum[:]
+	}
+
+	var etag string
+	attempt := 0
+	budgetSpent := false
+	retryable := func(err error) bool {
+		if !isRetryableError(err) {
+			return false
+		}
+		// Do asks after the last attempt too; only a retry spends budget
+		if attempt >= policy.Attempts() {
+			return true
+		}
+		if s.retryBudget != nil && s.retryBudget.Add(-1) < 0 {
+			budgetSpent = true
+			return false
+		}
+		return true
+	}
+	err := policy.Do(ctx, retryable, func() error {
+		attempt++
+		s.cfg.Log().DebugContext(ctx, "uploading part", "part", partIndex+1, "size", len(encryptedData), "attempt", attempt)
+		start := time.Now()
+		result, err := s.urls.transfer(ctx, s.cfg, &s.startResp.Uploads[0], partIndex, bytes.NewReader(encryptedData), int64(len(encryptedData)))
+		if limit, prev := s.limiter.observe(len(encryptedData), time.Since(start), err); limit != prev {
+			s.cfg.Log().DebugContext(ctx, "part concurrency changed", "limit", limit, "previous", prev)
+		}
+		if err != nil {
+			s.cfg.Log().DebugContext(ctx, "part upload failed", "part", partIndex+1, "attempt", attempt, "error", err)
+			return err
+		}
+		if partMD5 != nil {
+			if err := checkPartETag(partIndex, result.ETag, partMD5); err != nil {
+				return err
 			}
 		}
-
-		result, err := Transfer(ctx, s.cfg, uploadURL, bytes.NewReader(encryptedData), int64(len(encryptedData)))
-		if err == nil {
-			return result.ETag, nil
+		etag = result.ETag
+		return nil
+	})
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
 		}
-
-		lastErr = err
-		if !isRetryableError(err) {
-			break
+		if budgetSpent {
+			return "", fmt.Errorf("chunk %d upload failed, retry budget of %d exhausted: %w", partIndex+1, s.cfg.MultipartRetryBudget, err)
 		}
+		return "", fmt.Errorf("chunk %d upload failed after %d attempts: %w", partIndex+1, attempt, err)
 	}
+	return etag, nil
+}
 
-	return "", fmt.Errorf("chunk %d upload failed after %d retries: %w", partIndex+1, maxRetries, lastErr)
+// checkPartETag returns an error wrapping crypto.ErrHashMismatch unless etag
+// is the hex MD5 of the part's encrypted bytes
+func checkPartETag(partIndex int, etag string, partMD5 []byte) error {
+	if want := hex.EncodeToString(partMD5); !strings.EqualFold(strings.Trim(etag, `"`), want) {
+		return fmt.Errorf("%w: part %d ETag %q, expected MD5 %s", crypto.ErrHashMismatch, partIndex+1, etag, want)
+	}
+	return nil
 }
 
-// isRetryableError determines if an error should be retried
+// isRetryableError reports whether a failed part upload may succeed when sent
+// again: a transient error (see isTransientError) or a network timeout. Parts
+// that fail their ETag check and hosts with an open circuit are not retried.
 func isRetryableError(err error) bool {
-	if err == nil {
+	if stderrors.Is(err, errors.ErrCircuitOpen) || stderrors.Is(err, crypto.ErrHashMismatch) {
 		return false
 	}
-
-	errStr := err.Error()
-
-	if contains(errStr, "400") || contains(errStr, "401") || contains(errStr, "403") || contains(errStr, "404") {
-		return false
+	if isTransientError(err) {
+		return true
 	}
-
-	return true
-}
-
-// contains checks if a string contains a substring
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(s) > len(substr) && (s[:len(substr)] == substr || s[len(s)-len(substr):] == substr || containsMiddle(s, substr)))
+	var netErr net.Error
+	return stderrors.As(err, &netErr) && netErr.Timeout() &&
+		!stderrors.Is(err, context.Canceled) && !stderrors.Is(err, context.DeadlineExceeded)
 }
 
-func containsMiddle(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
-		}
+// isTransientError reports whether a failed request may succeed when sent
+// again: transport failures, and HTTP errors with a 408, 429 or 5xx status.
+// Cancellation and malformed responses are never retried.
+func isTransientError(err error) bool {
+	if err == nil || stderrors.Is(err, context.Canceled) || stderrors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var httpErr *errors.HTTPError
+	if stderrors.As(err, &httpErr) {
+		return httpErr.Temporary()
 	}
-	return false
+	var urlErr *url.Error
+	return stderrors.As(err, &urlErr)
 }
@@ -3,19 +3,29 @@ package buckets
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/crypto"
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
 )
 
 // TestNewMultipartUploadState tests the initialization of multipart upload state
@@ -96,6 +106,29 @@ func TestNewMultipartUploadState(t *testing.T) {
 	}
 }
 
+// TestNewMultipartUploadStateCustomConfig verifies that chunk size and
+// concurrency come from the config when set
+func TestNewMultipartUploadStateCustomConfig(t *testing.T) {
+	cfg := newTestConfigWithBucket(TestBucket6)
+	cfg.ChunkSize = 10 * 1024 * 1024
+	cfg.MaxConcurrency = 2
+
+	state, err := newMultipartUploadState(cfg, 100*1024*1024)
+	if err != nil {
+		t.Fatalf("newMultipartUploadState failed: %v", err)
+	}
+
+	if state.chunkSize != cfg.ChunkSize {
+		t.Errorf("expected chunk size %d, got %d", cfg.ChunkSize, state.chunkSize)
+	}
+	if state.numParts != 10 {
+		t.Errorf("expected 10 parts, got %d", state.numParts)
+	}
+	if state.maxConcurrency != 2 {
+		t.Errorf("expected max concurrency 2, got %d", state.maxConcurrency)
+	}
+}
+
 // TestEncryptedChunkPipeline tests the encryption pipeline
 func TestEncryptedChunkPipeline(t *testing.T) {
 	cfg := newTestConfigWithBucket(TestBucket6)
@@ -155,8 +188,6 @@ func TestEncryptedChunkPipeline(t *testing.T) {
 			},
 		},
 	}
-	state.uuid = "test-uuid"
-	state.uploadId = "test-upload-id"
 
 	reader := bytes.NewReader(testData)
 	parts, overallHash, err := state.encryptAndUploadPipelined(context.Background(), reader)
@@ -196,11 +227,69 @@ func TestEncryptedChunkPipeline(t *testing.T) {
 	}
 }
 
+// TestParallelChunkEncryption verifies that parts encrypted concurrently,
+// at offsets that are not block aligned, match the sequential CTR stream
+func TestParallelChunkEncryption(t *testing.T) {
+	cfg := newTestConfigWithBucket(TestBucket6)
+	cfg.ChunkSize = 1000
+	cfg.MaxConcurrency = 4
+
+	testData := bytes.Repeat([]byte("0123456789abcdef"), 1000)
+	state, err := newMultipartUploadState(cfg, int64(len(testData)))
+	if err != nil {
+		t.Fatalf("newMultipartUploadState failed: %v", err)
+	}
+
+	parts := make([][]byte, state.numParts)
+	var partsMutex sync.Mutex
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var index int
+		fmt.Sscanf(r.URL.Query().Get("part"), "%d", &index)
+		data, _ := io.ReadAll(r.Body)
+		partsMutex.Lock()
+		parts[index] = data
+		partsMutex.Unlock()
+		w.Header().Set("ETag", fmt.Sprintf("\"etag-%d\"", index))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	urls := make([]string, state.numParts)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("%s/?part=%d", mockServer.URL, i)
+	}
+	state.startResp = &StartUploadResp{Uploads: []UploadPart{{UUID: "uuid", UploadId: "upload-id", URLs: urls}}}
+
+	_, overallHash, err := state.encryptAndUploadPipelined(context.Background(), bytes.NewReader(testData))
+	if err != nil {
+		t.Fatalf("encryptAndUploadPipelined failed: %v", err)
+	}
+
+	stream, err := crypto.NewAES256CTRCipher(state.fileKey, state.iv)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := make([]byte, len(testData))
+	stream.XORKeyStream(want, testData)
+	if got := bytes.Join(parts, nil); !bytes.Equal(got, want) {
+		t.Error("parallel ciphertext differs from the sequential cipher")
+	}
+	sum := sha256.Sum256(want)
+	if wantHash := crypto.ComputeFileHash(sum[:]); overallHash != wantHash {
+		t.Errorf("expected hash %s, got %s", wantHash, overallHash)
+	}
+}
+
 // TestRetryableErrorDetection tests the retry logic for different error types
 func TestRetryableErrorDetection(t *testing.T) {
+	httpErr := func(code int, body string) error {
+		return sdkerrors.NewHTTPError(&http.Response{StatusCode: code, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}, "transfer")
+	}
+	transportErr := &url.Error{Op: "Put", URL: "https://s3.example.com/part", Err: errors.New("connection reset by peer")}
+
 	testCases := []struct {
-		name       string
-		err        error
+		name        string
+		err         error
 		shouldRetry bool
 	}{
 		{
@@ -210,48 +299,63 @@ func TestRetryableErrorDetection(t *testing.T) {
 		},
 		{
 			name:        "400 error should not retry",
-			err:         fmt.Errorf("bad request: 400"),
+			err:         httpErr(http.StatusBadRequest, "bad request"),
 			shouldRetry: false,
 		},
 		{
 			name:        "401 error should not retry",
-			err:         fmt.Errorf("unauthorized: 401"),
+			err:         httpErr(http.StatusUnauthorized, "unauthorized"),
 			shouldRetry: false,
 		},
 		{
 			name:        "403 error should not retry",
-			err:         fmt.Errorf("forbidden: 403"),
+			err:         httpErr(http.StatusForbidden, "forbidden"),
 			shouldRetry: false,
 		},
 		{
 			name:        "404 error should not retry",
-			err:         fmt.Errorf("not found: 404"),
+			err:         httpErr(http.StatusNotFound, "not found"),
 			shouldRetry: false,
 		},
+		{
+			name:        "429 error should retry",
+			err:         httpErr(http.StatusTooManyRequests, "slow down"),
+			shouldRetry: true,
+		},
 		{
 			name:        "500 error should retry",
-			err:         fmt.Errorf("internal server error: 500"),
+			err:         fmt.Errorf("chunk 2: %w", httpErr(http.StatusInternalServerError, "internal error")),
 			shouldRetry: true,
 		},
 		{
-			name:        "502 error should retry",
-			err:         fmt.Errorf("bad gateway: 502"),
+			name:        "503 error mentioning 404 in its body should retry",
+			err:         httpErr(http.StatusServiceUnavailable, "upload of report-404.pdf unavailable"),
 			shouldRetry: true,
 		},
 		{
-			name:        "503 error should retry",
-			err:         fmt.Errorf("service unavailable: 503"),
+			name:        "transport error should retry",
+			err:         fmt.Errorf("failed to execute transfer request: %w", transportErr),
 			shouldRetry: true,
 		},
 		{
 			name:        "network timeout should retry",
-			err:         fmt.Errorf("connection timeout"),
+			err:         &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded},
 			shouldRetry: true,
 		},
 		{
-			name:        "generic error should retry",
-			err:         fmt.Errorf("some random error"),
-			shouldRetry: true,
+			name:        "generic error mentioning 500 should not retry",
+			err:         fmt.Errorf("failed to read 500.bin"),
+			shouldRetry: false,
+		},
+		{
+			name:        "cancellation should not retry",
+			err:         &url.Error{Op: "Put", URL: "https://s3.example.com/part", Err: context.Canceled},
+			shouldRetry: false,
+		},
+		{
+			name:        "ETag mismatch should not retry",
+			err:         fmt.Errorf("%w: part 1", crypto.ErrHashMismatch),
+			shouldRetry: false,
 		},
 	}
 
@@ -268,6 +372,8 @@ func TestRetryableErrorDetection(t *testing.T) {
 // TestChunkRetryLogic tests that failed uploads are retried
 func TestChunkRetryLogic(t *testing.T) {
 	cfg := newTestConfigWithBucket(TestBucket6)
+	var logs bytes.Buffer
+	cfg.Logger = slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
 
 	state, err := newMultipartUploadState(cfg, 100*1024*1024)
 	if err != nil {
@@ -310,6 +416,138 @@ func TestChunkRetryLogic(t *testing.T) {
 	if attemptCount.Load() != 3 {
 		t.Errorf("expected 3 attempts, got %d", attemptCount.Load())
 	}
+
+	for _, want := range []string{`msg="uploading part" part=1 size=9 attempt=3`, `msg="part upload failed" part=1 attempt=2`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("expected the debug log to contain %s, got:\n%s", want, logs.String())
+		}
+	}
+}
+
+// TestChunkRetryBudget tests that parts stop retrying once the retry budget
+// shared by the upload is spent
+func TestChunkRetryBudget(t *testing.T) {
+	cfg := newTestConfigWithBucket(TestBucket6)
+	cfg.RetryPolicy = &config.RetryPolicy{MaxRetries: 5, BaseDelay: time.Millisecond}
+	cfg.MultipartRetryBudget = 1
+
+	state, err := newMultipartUploadState(cfg, 100*1024*1024)
+	if err != nil {
+		t.Fatalf("newMultipartUploadState failed: %v", err)
+	}
+
+	var attemptCount atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attemptCount.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer mockServer.Close()
+
+	state.startResp = &StartUploadResp{
+		Uploads: []UploadPart{
+			{URLs: []string{mockServer.URL, mockServer.URL}},
+		},
+	}
+
+	_, err = state.uploadChunkWithRetry(context.Background(), 0, []byte("test data"))
+	if err == nil || !strings.Contains(err.Error(), "retry budget of 1 exhausted") {
+		t.Fatalf("expected retry budget error, got %v", err)
+	}
+	if attemptCount.Load() != 2 {
+		t.Errorf("expected 2 attempts for the first part, got %d", attemptCount.Load())
+	}
+
+	attemptCount.Store(0)
+	if _, err = state.uploadChunkWithRetry(context.Background(), 1, []byte("test data")); err == nil {
+		t.Fatal("expected error for the second part, got nil")
+	}
+	if attemptCount.Load() != 1 {
+		t.Errorf("expected no retries once the budget is spent, got %d attempts", attemptCount.Load())
+	}
+}
+
+// TestChunkRetryBudgetLastAttempt tests that a part failing its last attempt
+// does not spend budget on a retry that never happens
+func TestChunkRetryBudgetLastAttempt(t *testing.T) {
+	cfg := newTestConfigWithBucket(TestBucket6)
+	cfg.RetryPolicy = &config.RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond}
+	cfg.MultipartRetryBudget = 2
+
+	state, err := newMultipartUploadState(cfg, 100*1024*1024)
+	if err != nil {
+		t.Fatalf("newMultipartUploadState failed: %v", err)
+	}
+
+	var attemptCount atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attemptCount.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer mockServer.Close()
+
+	state.startResp = &StartUploadResp{
+		Uploads: []UploadPart{
+			{URLs: []string{mockServer.URL, mockServer.URL}},
+		},
+	}
+
+	for part := range 2 {
+		attemptCount.Store(0)
+		_, err = state.uploadChunkWithRetry(context.Background(), part, []byte("test data"))
+		if err == nil || !strings.Contains(err.Error(), "after 2 attempts") {
+			t.Errorf("part %d: expected the retries to run out, got %v", part, err)
+		}
+		if attemptCount.Load() != 2 {
+			t.Errorf("part %d: expected 2 attempts, got %d", part, attemptCount.Load())
+		}
+	}
+}
+
+// TestChunkETagVerification tests that VerifyPartETags accepts an MD5 ETag
+// and fails a mismatching one without retrying
+func TestChunkETagVerification(t *testing.T) {
+	cfg := newTestConfigWithBucket(TestBucket6)
+	cfg.VerifyPartETags = true
+
+	state, err := newMultipartUploadState(cfg, 100*1024*1024)
+	if err != nil {
+		t.Fatalf("newMultipartUploadState failed: %v", err)
+	}
+
+	testData := []byte("test data")
+	sum := md5.Sum(testData)
+	goodETag := hex.EncodeToString(sum[:])
+
+	var attemptCount atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attemptCount.Add(1)
+		if r.URL.Path == "/good" {
+			w.Header().Set("ETag", `"`+goodETag+`"`)
+		} else {
+			w.Header().Set("ETag", `"0123456789abcdef0123456789abcdef"`)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer mockServer.Close()
+
+	state.startResp = &StartUploadResp{
+		Uploads: []UploadPart{
+			{URLs: []string{mockServer.URL + "/good", mockServer.URL + "/bad"}},
+		},
+	}
+
+	if _, err := state.uploadChunkWithRetry(context.Background(), 0, testData); err != nil {
+		t.Fatalf("expected matching ETag to pass, got %v", err)
+	}
+
+	attemptCount.Store(0)
+	_, err = state.uploadChunkWithRetry(context.Background(), 1, testData)
+	if !errors.Is(err, crypto.ErrHashMismatch) {
+		t.Fatalf("expected crypto.ErrHashMismatch, got %v", err)
+	}
+	if attemptCount.Load() != 1 {
+		t.Errorf("expected a mismatch not to be retried, got %d attempts", attemptCount.Load())
+	}
 }
 
 // TestChunkRetryExhaustion tests that non-retryable errors fail immediately
@@ -352,33 +590,6 @@ func TestChunkRetryExhaustion(t *testing.T) {
 	}
 }
 
-func TestContainsHelper(t *testing.T) {
-	testCases := []struct {
-		str      string
-		substr   string
-		expected bool
-	}{
-		{"hello world", "world", true},
-		{"hello world", "hello", true},
-		{"hello world", "lo wo", true},
-		{"hello world", "xyz", false},
-		{"", "test", false},
-		{"test", "", true},
-		{"", "", true},
-		{"status: 404", "404", true},
-		{"error 500 occurred", "500", true},
-	}
-
-	for _, tc := range testCases {
-		t.Run(fmt.Sprintf("'%s' contains '%s'", tc.str, tc.substr), func(t *testing.T) {
-			result := contains(tc.str, tc.substr)
-			if result != tc.expected {
-				t.Errorf("contains('%s', '%s') = %v, expected %v", tc.str, tc.substr, result, tc.expected)
-			}
-		})
-	}
-}
-
 // TestMultipartUploadContextCancellation tests context cancellation during upload
 func TestMultipartUploadContextCancellation(t *testing.T) {
 	cfg := newTestConfigWithBucket(TestBucket1)
@@ -429,6 +640,203 @@ func TestMultipartUploadContextCancellation(t *testing.T) {
 	}
 }
 
+// TestEncryptAndUploadPipelinedReaderAt tests that a source read in parallel
+// at part offsets uploads the same bytes as a sequential reader, starting from
+// the reader's current position
+func TestEncryptAndUploadPipelinedReaderAt(t *testing.T) {
+	cfg := newTestConfigWithBucket(TestBucket6)
+	cfg.ChunkSize = 1000
+
+	testData := make([]byte, 4500)
+	for i := range testData {
+		testData[i] = byte(i % 251)
+	}
+	prefixed := append([]byte("skipped"), testData...)
+
+	state, err := newMultipartUploadState(cfg, int64(len(testData)))
+	if err != nil {
+		t.Fatalf("newMultipartUploadState failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	uploaded := map[string][]byte{}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		uploaded[r.URL.Path] = body
+		mu.Unlock()
+		w.Header().Set("ETag", `"etag"`)
+	}))
+	defer mockServer.Close()
+
+	urls := make([]string, state.numParts)
+	for i := range urls {
+		urls[i] = fmt.Sprintf("%s/%d", mockServer.URL, i)
+	}
+	state.startResp = &StartUploadResp{Uploads: []UploadPart{{UUID: "uuid", UploadId: "upload-id", URLs: urls}}}
+
+	// io.MultiReader hides io.ReaderAt, forcing the sequential path
+	_, seqHash, err := state.encryptAndUploadPipelined(context.Background(), io.MultiReader(bytes.NewReader(testData)))
+	if err != nil {
+		t.Fatalf("sequential upload failed: %v", err)
+	}
+	sequential := uploaded
+	uploaded = map[string][]byte{}
+	state.cipher, _ = state.cipherAtOffset(0) // Part 0 consumes the stream
+
+	reader := bytes.NewReader(prefixed)
+	reader.Seek(int64(len("skipped")), io.SeekStart)
+	_, parHash, err := state.encryptAndUploadPipelined(context.Background(), reader)
+	if err != nil {
+		t.Fatalf("ReaderAt upload failed: %v", err)
+	}
+
+	if seqHash != parHash {
+		t.Errorf("expected hash %s, got %s", seqHash, parHash)
+	}
+	for path, want := range sequential {
+		if !bytes.Equal(uploaded[path], want) {
+			t.Errorf("part %s differs between sequential and ReaderAt uploads", path)
+		}
+	}
+
+	state.cipher, _ = state.cipherAtOffset(0)
+	_, _, err = state.encryptAndUploadPipelined(context.Background(), bytes.NewReader(testData[:2500]))
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected io.ErrUnexpectedEOF for a short ReaderAt, got %v", err)
+	}
+}
+
+// TestEncryptAndUploadPipelinedCancelsInFlight tests that cancelling the
+// caller's context stops in-flight part uploads and returns ctx.Err()
+func TestEncryptAndUploadPipelinedCancelsInFlight(t *testing.T) {
+	cfg := newTestConfigWithBucket(TestBucket2)
+
+	testData := make([]byte, config.DefaultChunkSize*2)
+	state, _ := newMultipartUploadState(cfg, int64(len(testData)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var started sync.Once
+	release := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started.Do(cancel)
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer mockServer.Close()
+	defer close(release)
+
+	urls := make([]string, state.numParts)
+	for i := range urls {
+		urls[i] = mockServer.URL
+	}
+	state.startResp = &StartUploadResp{
+		Uploads: []UploadPart{{UUID: "uuid", UploadId: "upload-id", URLs: urls}},
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := state.encryptAndUploadPipelined(ctx, bytes.NewReader(testData))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("upload did not return after the context was cancelled")
+	}
+}
+
+// TestEncryptAndUploadPipelinedBackpressure tests that parts are not read
+// further ahead of the uploads than one part while all uploads are stalled
+func TestEncryptAndUploadPipelinedBackpressure(t *testing.T) {
+	cfg := newTestConfigWithBucket(TestBucket2)
+	cfg.ChunkSize = 1000
+	cfg.MaxConcurrency = 2
+
+	testData := make([]byte, 10000)
+	state, err := newMultipartUploadState(cfg, int64(len(testData)))
+	if err != nil {
+		t.Fatalf("newMultipartUploadState failed: %v", err)
+	}
+
+	var inFlight atomic.Int32
+	release := make(chan struct{})
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		inFlight.Add(1)
+		<-release
+		w.Header().Set("ETag", `"etag"`)
+	}))
+	defer mockServer.Close()
+
+	urls := make([]string, state.numParts)
+	for i := range urls {
+		urls[i] = mockServer.URL
+	}
+	state.startResp = &StartUploadResp{Uploads: []UploadPart{{UUID: "uuid", UploadId: "upload-id", URLs: urls}}}
+
+	src := &countingReader{r: bytes.NewReader(testData)}
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := state.encryptAndUploadPipelined(context.Background(), src)
+		done <- err
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for inFlight.Load() < int32(cfg.MaxConcurrency) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(100 * time.Millisecond) // Let the reader run ahead if it would
+	if read, limit := src.n.Load(), int64(cfg.MaxConcurrency+1)*cfg.ChunkSize; read > limit {
+		t.Errorf("expected at most %d bytes read while uploads stall, got %d", limit, read)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	if read := src.n.Load(); read != int64(len(testData)) {
+		t.Errorf("expected all %d bytes read, got %d", len(testData), read)
+	}
+}
+
+// countingReader counts the bytes read from r, hiding any io.ReaderAt.
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// TestChunkBufferPool tests that pooled buffers are resized to the requested
+// length and never handed out too small
+func TestChunkBufferPool(t *testing.T) {
+	small := getChunkBuffer(10)
+	if len(*small) != 10 {
+		t.Fatalf("expected length 10, got %d", len(*small))
+	}
+	putChunkBuffer(small)
+
+	large := getChunkBuffer(1000)
+	if len(*large) != 1000 {
+		t.Fatalf("expected length 1000 after pooling a smaller buffer, got %d", len(*large))
+	}
+	putChunkBuffer(large)
+
+	if again := getChunkBuffer(500); len(*again) != 500 || cap(*again) < 500 {
+		t.Errorf("expected length 500, got %d (cap %d)", len(*again), cap(*again))
+	}
+}
+
 // TestEncryptAndUploadPipelinedError tests error handling in the pipeline
 func TestEncryptAndUploadPipelinedError(t *testing.T) {
 	cfg := newTestConfigWithBucket(TestBucket2)
@@ -457,8 +865,6 @@ func TestEncryptAndUploadPipelinedError(t *testing.T) {
 			URLs:     urls,
 		}},
 	}
-	state.uuid = "uuid"
-	state.uploadId = "upload-id"
 
 	reader := bytes.NewReader(testData)
 	_, _, err := state.encryptAndUploadPipelined(context.Background(), reader)
@@ -468,6 +874,46 @@ func TestEncryptAndUploadPipelinedError(t *testing.T) {
 	}
 }
 
+// TestExecuteMultipartUploadAbortsOnFailure tests that a failed part upload
+// aborts the upload session
+func TestExecuteMultipartUploadAbortsOnFailure(t *testing.T) {
+	cfg := newTestConfigWithBucket(TestBucket2)
+
+	testData := make([]byte, config.DefaultChunkSize*2)
+	state, _ := newMultipartUploadState(cfg, int64(len(testData)))
+
+	var abortPayload map[string]string
+	var serverURL string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/files/start"):
+			urls := make([]string, state.numParts)
+			for i := range urls {
+				urls[i] = serverURL + "/upload"
+			}
+			json.NewEncoder(w).Encode(StartUploadResp{
+				Uploads: []UploadPart{{UUID: "uuid", UploadId: "upload-id", URLs: urls}},
+			})
+		case strings.HasSuffix(r.URL.Path, "/files/abort"):
+			json.NewDecoder(r.Body).Decode(&abortPayload)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer mockServer.Close()
+	serverURL = mockServer.URL
+
+	setEndpoints(cfg, serverURL)
+
+	_, err := state.executeMultipartUpload(context.Background(), bytes.NewReader(testData))
+	if err == nil {
+		t.Fatal("expected error for failed uploads, got nil")
+	}
+	if abortPayload["UploadId"] != "upload-id" || abortPayload["uuid"] != "uuid" {
+		t.Errorf("expected the upload to be aborted, got abort payload %v", abortPayload)
+	}
+}
+
 // TestExecuteMultipartUploadWrongURLCount tests handling of incorrect URL count
 func TestExecuteMultipartUploadWrongURLCount(t *testing.T) {
 	cfg := newTestConfigWithBucket(TestBucket3)
@@ -0,0 +1,186 @@
+package buckets
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/errors"
+)
+
+// urlRefreshMargin is how long before their expiry presigned part URLs are
+// refreshed, so that a part started just in time does not fail mid-transfer.
+const urlRefreshMargin = 2 * time.Minute
+
+// ExpiresAt returns when the presigned upload URLs of p stop being accepted,
+// read from their X-Amz-Date and X-Amz-Expires (or Expires) query parameters.
+// The zero time means the URLs do not say.
+func (p UploadPart) ExpiresAt() time.Time {
+	rawURL := p.URL
+	if len(p.URLs) > 0 {
+		rawURL = p.URLs[0]
+	}
+	return presignedExpiry(rawURL)
+}
+
+func presignedExpiry(rawURL string) time.Time {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return time.Time{}
+	}
+	q := u.Query()
+	if date, expires := q.Get("X-Amz-Date"), q.Get("X-Amz-Expires"); date != "" && expires != "" {
+		signed, err := time.Parse("20060102T150405Z", date)
+		secs, serr := strconv.ParseInt(expires, 10, 64)
+		if err != nil || serr != nil {
+			return time.Time{}
+		}
+		return signed.Add(time.Duration(secs) * time.Second)
+	}
+	if expires := q.Get("Expires"); expires != "" {
+		if secs, err := strconv.ParseInt(expires, 10, 64); err == nil {
+			return time.Unix(secs, 0)
+		}
+	}
+	return time.Time{}
+}
+
+// ErrUploadURLsExpired is returned when the presigned URLs of an upload
+// expire after some of its parts were stored. The network only hands out
+// URLs when an upload starts, so the whole upload has to be started again.
+var ErrUploadURLsExpired = stderrors.New("upload URLs expired after parts were stored")
+
+// restartUpload returns a function that starts the upload of parts again,
+// through StartUpload when numParts is 1 and StartUploadMultipart otherwise,
+// for presignedURLs.start.
+func restartUpload(cfg *config.Config, parts []UploadPartSpec, numParts int) func(context.Context) (*UploadPart, error) {
+	return func(ctx context.Context) (*UploadPart, error) {
+		var resp *StartUploadResp
+		var err error
+		if numParts <= 1 {
+			resp, err = StartUpload(ctx, cfg, cfg.Bucket, parts)
+		} else {
+			resp, err = StartUploadMultipart(ctx, cfg, cfg.Bucket, parts, numParts)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Uploads) != 1 {
+			return nil, fmt.Errorf("expected 1 upload entry, got %d", len(resp.Uploads))
+		}
+		upload := &resp.Uploads[0]
+		if len(upload.URLs) == 0 && upload.URL != "" {
+			upload.URLs = []string{upload.URL}
+		}
+		return upload, nil
+	}
+}
+
+// presignedURLs hands out the part URLs of an upload and renews them
+// shortly before they expire or when the storage rejects one with 403. The
+// network has no call to renew the URLs of an upload in progress, so they are
+// renewed by starting the upload again, under a new UUID and upload ID, as
+// long as none of its parts has been stored. After that an expired URL fails
+// with ErrUploadURLsExpired.
+type presignedURLs struct {
+	mu     sync.Mutex
+	gen    int                                        // Incremented on each refresh, so concurrent parts refresh once
+	stored bool                                       // A part was stored under the current upload, which can no longer be restarted
+	start  func(context.Context) (*UploadPart, error) // Starts the upload again, see restartUpload; nil if it cannot be
+}
+
+// url returns the URL of part i of upload and the generation it belongs to.
+func (p *presignedURLs) url(ctx context.Context, cfg *config.Config, upload *UploadPart, i int) (string, int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if exp := presignedExpiry(upload.URLs[i]); !exp.IsZero() && time.Until(exp) < urlRefreshMargin && !p.stored && p.start != nil {
+		if err := p.refreshLocked(ctx, cfg, upload); err != nil {
+			return "", 0, err
+		}
+	}
+	return upload.URLs[i], p.gen, nil
+}
+
+// refresh replaces the URLs of upload unless another part already did since
+// generation gen was handed out.
+func (p *presignedURLs) refresh(ctx context.Context, cfg *config.Config, upload *UploadPart, gen int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.gen != gen {
+		return nil
+	}
+	return p.refreshLocked(ctx, cfg, upload)
+}
+
+func (p *presignedURLs) refreshLocked(ctx context.Context, cfg *config.Config, upload *UploadPart) error {
+	if p.stored || p.start == nil {
+		return ErrUploadURLsExpired
+	}
+	restarted, err := p.start(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to restart upload for new URLs: %w", err)
+	}
+	if len(restarted.URLs) != len(upload.URLs) {
+		return fmt.Errorf("failed to restart upload for new URLs: expected %d URLs, got %d", len(upload.URLs), len(restarted.URLs))
+	}
+	cfg.Log().DebugContext(ctx, "restarted upload for new URLs", "upload_id", restarted.UploadId, "replaced", upload.UploadId, "expires", restarted.ExpiresAt())
+	*upload = *restarted
+	p.gen++
+	return nil
+}
+
+// transfer uploads part i of upload, refreshing the URLs and trying once more
+// if the storage answers 403, which is how it rejects an expired URL. A part
+// that was sent while another part restarted the upload is sent again, since
+// it went to the discarded one.
+func (p *presignedURLs) transfer(ctx context.Context, cfg *config.Config, upload *UploadPart, i int, data io.ReadSeeker, size int64) (*TransferResult, error) {
+	start, err := data.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	refreshed := false
+	for {
+		uploadURL, gen, err := p.url(ctx, cfg, upload, i)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := data.Seek(start, io.SeekStart); err != nil {
+			return nil, err
+		}
+		result, err := Transfer(ctx, cfg, uploadURL, data, size)
+		var httpErr *errors.HTTPError
+		if err != nil && !refreshed && stderrors.As(err, &httpErr) && httpErr.StatusCode() == http.StatusForbidden {
+			if rerr := p.refresh(ctx, cfg, upload, gen); rerr != nil {
+				return nil, stderrors.Join(err, rerr)
+			}
+			refreshed = true
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		p.mu.Lock()
+		current := p.gen == gen
+		p.stored = p.stored || current
+		p.mu.Unlock()
+		if current {
+			return result, nil
+		}
+	}
+}
+
+// current returns a copy of upload, whose UUID and upload ID change when it
+// is restarted for new URLs.
+func (p *presignedURLs) current(upload *UploadPart) UploadPart {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return *upload
+}
@@ -0,0 +1,145 @@
+package buckets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUploadPartExpiresAt(t *testing.T) {
+	testCases := []struct {
+		name string
+		url  string
+		want time.Time
+	}{
+		{"sigv4", "https://s3.example.com/b/k?X-Amz-Date=20260101T120000Z&X-Amz-Expires=3600&X-Amz-Signature=abc", time.Date(2026, 1, 1, 13, 0, 0, 0, time.UTC)},
+		{"sigv2", "https://s3.example.com/b/k?Expires=1767272400&Signature=abc", time.Unix(1767272400, 0)},
+		{"unsigned", "https://s3.example.com/b/k", time.Time{}},
+		{"malformed date", "https://s3.example.com/b/k?X-Amz-Date=yesterday&X-Amz-Expires=3600", time.Time{}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := UploadPart{URLs: []string{tc.url}}.ExpiresAt()
+			if !got.Equal(tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+// presignServer serves part uploads under /part, rejecting URLs of the
+// first generation with 403, and restarts the upload under /files/start
+type presignServer struct {
+	*httptest.Server
+	refreshes atomic.Int32
+	expiresAt string // X-Amz-Date of the URLs handed out by the refresh
+}
+
+func newPresignServer(t *testing.T) *presignServer {
+	p := &presignServer{expiresAt: time.Now().UTC().Format("20060102T150405Z")}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/files/start"):
+			p.refreshes.Add(1)
+			var payload startUploadReq
+			json.NewDecoder(r.Body).Decode(&payload)
+			if len(payload.Uploads) != 1 || payload.Uploads[0].Size != 8 || r.URL.Query().Get("multiparts") != "2" {
+				t.Errorf("unexpected start request %v %s", payload, r.URL.RawQuery)
+			}
+			url := p.URL + "/part?gen=2&X-Amz-Date=" + p.expiresAt + "&X-Amz-Expires=3600"
+			json.NewEncoder(w).Encode(StartUploadResp{Uploads: []UploadPart{{UUID: "uuid-2", UploadId: "upload-id-2", URLs: []string{url, url}}}})
+		case r.URL.Query().Get("gen") == "2":
+			w.Header().Set("ETag", `"fresh"`)
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("Request has expired"))
+		}
+	}))
+	return p
+}
+
+// TestPresignedURLsRefreshOn403 tests that a part rejected with 403 is sent
+// again to the URL of a restarted upload, and that concurrent parts share one
+// restart
+func TestPresignedURLsRefreshOn403(t *testing.T) {
+	server := newPresignServer(t)
+	defer server.Close()
+	cfg := newTestConfig(server.URL)
+
+	upload := &UploadPart{UUID: "uuid", UploadId: "upload-id", URLs: []string{server.URL + "/part", server.URL + "/part"}}
+	urls := presignedURLs{start: restartUpload(cfg, []UploadPartSpec{{Index: 0, Size: 8}}, 2)}
+
+	result, err := urls.transfer(context.Background(), cfg, upload, 0, bytes.NewReader([]byte("data")), 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ETag != "fresh" {
+		t.Errorf("expected ETag from the refreshed URL, got %q", result.ETag)
+	}
+	if current := urls.current(upload); current.UUID != "uuid-2" || current.UploadId != "upload-id-2" {
+		t.Errorf("expected the restarted upload, got %+v", current)
+	}
+
+	if err := urls.refresh(context.Background(), cfg, upload, 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if server.refreshes.Load() != 1 {
+		t.Errorf("expected a stale generation not to refresh again, got %d refreshes", server.refreshes.Load())
+	}
+}
+
+// TestPresignedURLsRefreshBeforeExpiry tests that URLs about to expire are
+// refreshed before they are used
+func TestPresignedURLsRefreshBeforeExpiry(t *testing.T) {
+	server := newPresignServer(t)
+	defer server.Close()
+	server.expiresAt = time.Now().UTC().Add(time.Hour).Format("20060102T150405Z")
+	cfg := newTestConfig(server.URL)
+
+	soon := time.Now().UTC().Add(-59 * time.Minute).Format("20060102T150405Z")
+	stale := server.URL + "/part?X-Amz-Date=" + soon + "&X-Amz-Expires=3600"
+	upload := &UploadPart{UUID: "uuid", UploadId: "upload-id", URLs: []string{stale, stale}}
+	urls := presignedURLs{start: restartUpload(cfg, []UploadPartSpec{{Index: 0, Size: 8}}, 2)}
+
+	got, _, err := urls.url(context.Background(), cfg, upload, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(got, "gen=2") || server.refreshes.Load() != 1 {
+		t.Errorf("expected a refreshed URL, got %q after %d refreshes", got, server.refreshes.Load())
+	}
+
+	if _, _, err := urls.url(context.Background(), cfg, upload, 0); err != nil || server.refreshes.Load() != 1 {
+		t.Errorf("expected fresh URLs to be reused, got %v after %d refreshes", err, server.refreshes.Load())
+	}
+}
+
+// TestPresignedURLsExpiredAfterStored tests that URLs are not renewed once a
+// part was stored, since that would restart the upload without it
+func TestPresignedURLsExpiredAfterStored(t *testing.T) {
+	server := newPresignServer(t)
+	defer server.Close()
+	cfg := newTestConfig(server.URL)
+
+	fresh := server.URL + "/part?gen=2"
+	upload := &UploadPart{UUID: "uuid", UploadId: "upload-id", URLs: []string{fresh, server.URL + "/part"}}
+	urls := presignedURLs{start: restartUpload(cfg, []UploadPartSpec{{Index: 0, Size: 8}}, 2)}
+
+	if _, err := urls.transfer(context.Background(), cfg, upload, 0, bytes.NewReader([]byte("data")), 4); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err := urls.transfer(context.Background(), cfg, upload, 1, bytes.NewReader([]byte("data")), 4)
+	if !errors.Is(err, ErrUploadURLsExpired) {
+		t.Errorf("expected ErrUploadURLsExpired, got %v", err)
+	}
+	if server.refreshes.Load() != 0 || urls.current(upload).UploadId != "upload-id" {
+		t.Errorf("expected the upload not to be restarted, got %d restarts", server.refreshes.Load())
+	}
+}
@@ -0,0 +1,60 @@
+package buckets
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/internxt/rclone-adapter/internal/spool"
+)
+
+// replayBuffer keeps the encrypted data of a single-part upload so that a
+// failed transfer can be sent again: up to memLimit bytes in memory and the
+// rest in a temporary file. The data is already encrypted, so the file is
+// not encrypted again.
+type replayBuffer struct {
+	mem  []byte
+	file *spool.File
+	size int64
+}
+
+// newReplayBuffer reads size bytes from r.
+func newReplayBuffer(r io.Reader, size, memLimit int64) (*replayBuffer, error) {
+	b := &replayBuffer{mem: make([]byte, min(size, memLimit)), size: size}
+	if _, err := io.ReadFull(r, b.mem); err != nil {
+		return nil, fmt.Errorf("failed to buffer upload data: %w", err)
+	}
+	if size <= memLimit {
+		return b, nil
+	}
+
+	file, err := spool.Create("", "internxt-upload-*", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload buffer file: %w", err)
+	}
+	b.file = file
+	if _, err := io.CopyN(file, r, size-memLimit); err != nil {
+		b.Close()
+		return nil, fmt.Errorf("failed to buffer upload data: %w", err)
+	}
+	return b, nil
+}
+
+// reader returns a reader of the whole data, from the start.
+func (b *replayBuffer) reader() io.Reader {
+	if b.file == nil {
+		return bytes.NewReader(b.mem)
+	}
+	rest := io.NewSectionReader(b.file, 0, b.size-int64(len(b.mem)))
+	return io.MultiReader(bytes.NewReader(b.mem), rest)
+}
+
+// Close removes the temporary file.
+func (b *replayBuffer) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	b.file.Close()
+	return os.Remove(b.file.Name())
+}
@@ -0,0 +1,164 @@
+package buckets
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/crypto"
+)
+
+// shardPart is the part of a shard a download reads.
+type shardPart struct {
+	shard      ShardInfo
+	rangeValue string // Range header, empty for the whole shard
+}
+
+// shardParts maps the encrypted bytes from encStart to encEnd (-1 for the end
+// of the file) onto the shards of info, in index order. Legacy files are
+// split into several shards of one encrypted stream; a range over those needs
+// their sizes. Without ranged, all shards are read whole.
+func shardParts(info *BucketFileInfo, encStart, encEnd int64, ranged bool) ([]shardPart, error) {
+	shards := slices.SortedFunc(slices.Values(info.Shards), func(a, b ShardInfo) int { return a.Index - b.Index })
+	if !ranged {
+		parts := make([]shardPart, len(shards))
+		for i, shard := range shards {
+			parts[i] = shardPart{shard: shard}
+		}
+		return parts, nil
+	}
+	if len(shards) == 1 {
+		rangeValue := fmt.Sprintf("bytes=%d-", encStart)
+		if encEnd >= 0 {
+			rangeValue += fmt.Sprint(encEnd)
+		}
+		return []shardPart{{shard: shards[0], rangeValue: rangeValue}}, nil
+	}
+
+	var parts []shardPart
+	var off int64 // Offset of shard in the encrypted file
+	for _, shard := range shards {
+		if shard.Size <= 0 {
+			return nil, fmt.Errorf("cannot read a range of %d shards without their sizes", len(shards))
+		}
+		first, last := max(encStart, off), off+shard.Size-1
+		if encEnd >= 0 {
+			last = min(last, encEnd)
+		}
+		if first <= last {
+			part := shardPart{shard: shard}
+			if first > off || last < off+shard.Size-1 {
+				part.rangeValue = fmt.Sprintf("bytes=%d-%d", first-off, last-off)
+			}
+			parts = append(parts, part)
+		}
+		off += shard.Size
+	}
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("range starting at %d is beyond the %d stored bytes", encStart, off)
+	}
+	return parts, nil
+}
+
+// shardReader reads parts of the shards of a file one after another as a
+// single stream, so the cipher stream continues across shard boundaries.
+// Each shard is only opened once the previous one is exhausted. With
+// validate, shards read whole are checked against their hash; Close reports
+// the first mismatch after draining the rest of the stream.
+type shardReader struct {
+	ctx      context.Context
+	cfg      *config.Config
+	fileID   string
+	kind     string
+	parts    []shardPart
+	validate bool
+
+	cur      int
+	body     io.ReadCloser
+	hasher   *crypto.ShardHasher
+	mismatch error
+}
+
+func newShardReader(ctx context.Context, cfg *config.Config, fileID, kind string, parts []shardPart, validate bool) *shardReader {
+	return &shardReader{ctx: ctx, cfg: cfg, fileID: fileID, kind: kind, parts: parts, validate: validate}
+}
+
+// open opens the current part unless it is open already.
+func (r *shardReader) open() error {
+	if r.body != nil || r.cur >= len(r.parts) {
+		return nil
+	}
+	part := r.parts[r.cur]
+	resp, err := openShard(r.ctx, r.cfg, part.shard.URL, part.rangeValue, r.kind)
+	if err != nil {
+		return err
+	}
+	r.body = resp.Body
+	r.hasher = nil
+	if r.validate && part.rangeValue == "" {
+		r.hasher = crypto.NewShardHasher()
+	}
+	return nil
+}
+
+func (r *shardReader) Read(p []byte) (int, error) {
+	for r.cur < len(r.parts) {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+		n, err := r.body.Read(p)
+		if r.hasher != nil {
+			r.hasher.Write(p[:n])
+		}
+		if err == io.EOF {
+			r.next()
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+	return 0, io.EOF
+}
+
+// next closes the current part, checking its hash, and moves to the next.
+func (r *shardReader) next() {
+	r.body.Close()
+	r.body = nil
+	shard := r.parts[r.cur].shard
+	if r.hasher != nil && r.mismatch == nil {
+		if computedHash := r.hasher.Sum(); computedHash != shard.Hash {
+			which := ""
+			if len(r.parts) > 1 {
+				which = fmt.Sprintf(" shard %d", shard.Index)
+			}
+			r.mismatch = fmt.Errorf("%w for file %s%s: expected %s, got %s",
+				crypto.ErrHashMismatch, r.fileID, which, shard.Hash, computedHash)
+		}
+	}
+	r.cur++
+}
+
+// Close closes the open shard. With validation it first reads the rest of
+// the stream, so that every shard is checked, and returns any mismatch.
+func (r *shardReader) Close() error {
+	if r.validate && r.cur < len(r.parts) {
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			r.closeBody()
+			return fmt.Errorf("failed to drain remaining stream data: %w", err)
+		}
+	}
+	r.closeBody()
+	return r.mismatch
+}
+
+func (r *shardReader) closeBody() {
+	if r.body != nil {
+		r.body.Close()
+		r.body = nil
+	}
+	r.cur = len(r.parts)
+}
@@ -0,0 +1,267 @@
+package buckets
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/crypto"
+	"github.com/internxt/rclone-adapter/endpoints"
+)
+
+// multiShardServer serves a file whose encrypted data is split into shards
+// of the given sizes. The file info lists the shards out of index order.
+type multiShardServer struct {
+	plain   []byte
+	shards  [][]byte
+	info    BucketFileInfo
+	cfg     *config.Config
+	mu      sync.Mutex
+	ranges  []string // "shard:Range" for every shard request
+	corrupt int      // Shard served with a flipped byte, -1 for none
+
+	ignoreRange bool // Serve whole shards with 200, like storage without range support
+}
+
+func newMultiShardServer(t *testing.T, plain []byte, sizes []int, withSizes bool) *multiShardServer {
+	t.Helper()
+	key, iv, err := GenerateFileKey(TestMnemonic, TestBucket1, testIndex)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	encReader, err := EncryptReader(bytes.NewReader(plain), key, iv)
+	if err != nil {
+		t.Fatalf("failed to create encrypt reader: %v", err)
+	}
+	encData, err := io.ReadAll(encReader)
+	if err != nil {
+		t.Fatalf("failed to read encrypted data: %v", err)
+	}
+
+	s := &multiShardServer{plain: plain, corrupt: -1}
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	for i, size := range sizes {
+		data := encData[:size]
+		encData = encData[size:]
+		s.shards = append(s.shards, data)
+		sum := sha256.Sum256(data)
+		shard := ShardInfo{Index: i, Hash: ComputeFileHash(sum[:]), URL: fmt.Sprintf("%s/shard/%d", srv.URL, i)}
+		if withSizes {
+			shard.Size = int64(size)
+		}
+		s.info.Shards = append([]ShardInfo{shard}, s.info.Shards...)
+	}
+	s.info.Bucket = TestBucket1
+	s.info.Index = testIndex
+	s.info.Size = int64(len(plain))
+	s.info.ID = testFileUUID
+
+	mux.HandleFunc("/shard/{i}", func(w http.ResponseWriter, r *http.Request) {
+		var i int
+		fmt.Sscan(r.PathValue("i"), &i)
+		s.mu.Lock()
+		s.ranges = append(s.ranges, fmt.Sprintf("%d:%s", i, r.Header.Get("Range")))
+		s.mu.Unlock()
+
+		data := bytes.Clone(s.shards[i])
+		if i == s.corrupt {
+			data[0] ^= 0xff
+		}
+		if rng := r.Header.Get("Range"); rng != "" && !s.ignoreRange {
+			start, end, err := getStartByteAndEndByte(rng)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if end < 0 || end >= len(data) {
+				end = len(data) - 1
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[start : end+1])
+			return
+		}
+		w.Write(data)
+	})
+	mux.HandleFunc("/network/buckets/", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(s.info)
+	})
+
+	s.cfg = &config.Config{
+		Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
+		Bucket:          TestBucket1,
+		BasicAuthHeader: TestBasicAuth,
+		HTTPClient:      &http.Client{},
+		Endpoints:       endpoints.NewConfig(srv.URL),
+	}
+	return s
+}
+
+func TestDownloadFileStreamMultiShard(t *testing.T) {
+	plain := []byte(strings.Repeat("0123456789abcdefghijklmnopqrstuvwxyz", 10)) // 360 bytes
+	s := newMultiShardServer(t, plain, []int{100, 150, 110}, true)
+
+	stream, err := DownloadFileStream(context.Background(), s.cfg, testFileUUID, crypto.EncryptVersionAES)
+	if err != nil {
+		t.Fatalf("DownloadFileStream failed: %v", err)
+	}
+	got, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatalf("failed to read stream: %v", err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Errorf("Close() failed with valid hashes: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Errorf("content mismatch:\nwant: %s\ngot:  %s", plain, got)
+	}
+	if want := []string{"0:", "1:", "2:"}; !slices.Equal(s.ranges, want) {
+		t.Errorf("shard requests = %q, want %q", s.ranges, want)
+	}
+}
+
+func TestDownloadFileStreamMultiShardRange(t *testing.T) {
+	plain := []byte(strings.Repeat("0123456789abcdefghijklmnopqrstuvwxyz", 10))
+
+	tests := []struct {
+		name       string
+		rangeValue string
+		want       []byte
+		requests   []string
+	}{
+		{"within one shard", "bytes=120-139", plain[120:140], []string{"1:bytes=12-39"}},
+		{"block across a boundary", "bytes=100-119", plain[100:120], []string{"0:bytes=96-99", "1:bytes=0-19"}},
+		{"across a boundary", "bytes=90-205", plain[90:206], []string{"0:bytes=80-99", "1:bytes=0-105"}},
+		{"across all shards", "bytes=50-300", plain[50:301], []string{"0:bytes=48-99", "1:", "2:bytes=0-50"}},
+		{"open ended", "bytes=256-", plain[256:], []string{"2:bytes=6-109"}},
+		{"whole last shard", "bytes=250-", plain[250:], []string{"1:bytes=140-149", "2:"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newMultiShardServer(t, plain, []int{100, 150, 110}, true)
+
+			stream, err := DownloadFileStream(context.Background(), s.cfg, testFileUUID, crypto.EncryptVersionAES, tt.rangeValue)
+			if err != nil {
+				t.Fatalf("DownloadFileStream failed: %v", err)
+			}
+			got, err := io.ReadAll(stream)
+			if err != nil {
+				t.Fatalf("failed to read stream: %v", err)
+			}
+			if err := stream.Close(); err != nil {
+				t.Errorf("Close() failed: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("content mismatch:\nwant: %s\ngot:  %s", tt.want, got)
+			}
+			if !slices.Equal(s.ranges, tt.requests) {
+				t.Errorf("shard requests = %q, want %q", s.ranges, tt.requests)
+			}
+		})
+	}
+}
+
+func TestDownloadFileStreamMultiShardRangeIgnored(t *testing.T) {
+	plain := []byte(strings.Repeat("0123456789abcdefghijklmnopqrstuvwxyz", 10))
+	s := newMultiShardServer(t, plain, []int{100, 150, 110}, true)
+	s.ignoreRange = true
+
+	stream, err := DownloadFileStream(context.Background(), s.cfg, testFileUUID, crypto.EncryptVersionAES, "bytes=90-300")
+	if err != nil {
+		t.Fatalf("DownloadFileStream failed: %v", err)
+	}
+	defer stream.Close()
+	got, err := io.ReadAll(stream)
+	if err != nil {
+		t.Fatalf("failed to read stream: %v", err)
+	}
+	if !bytes.Equal(got, plain[90:301]) {
+		t.Errorf("content mismatch:\nwant: %s\ngot:  %s", plain[90:301], got)
+	}
+}
+
+func TestDownloadFileStreamMultiShardRangeWithoutSizes(t *testing.T) {
+	plain := []byte(strings.Repeat("x", 300))
+	s := newMultiShardServer(t, plain, []int{100, 100, 100}, false)
+
+	_, err := DownloadFileStream(context.Background(), s.cfg, testFileUUID, crypto.EncryptVersionAES, "bytes=10-20")
+	if err == nil || !strings.Contains(err.Error(), "without their sizes") {
+		t.Fatalf("expected error about missing shard sizes, got %v", err)
+	}
+	if len(s.ranges) != 0 {
+		t.Errorf("expected no shard requests, got %q", s.ranges)
+	}
+}
+
+func TestDownloadFileStreamMultiShardHashMismatch(t *testing.T) {
+	plain := []byte(strings.Repeat("0123456789", 30))
+	s := newMultiShardServer(t, plain, []int{100, 100, 100}, false)
+	s.corrupt = 1
+
+	stream, err := DownloadFileStream(context.Background(), s.cfg, testFileUUID, crypto.EncryptVersionAES)
+	if err != nil {
+		t.Fatalf("DownloadFileStream failed: %v", err)
+	}
+	// Stop early: Close still checks the remaining shards.
+	if _, err := io.ReadFull(stream, make([]byte, 10)); err != nil {
+		t.Fatalf("failed to read stream: %v", err)
+	}
+	err = stream.Close()
+	if !errors.Is(err, crypto.ErrHashMismatch) {
+		t.Fatalf("expected ErrHashMismatch, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "shard 1") {
+		t.Errorf("expected error to name shard 1, got %v", err)
+	}
+}
+
+func TestDownloadFileMultiShard(t *testing.T) {
+	plain := []byte(strings.Repeat("0123456789abcdefghijklmnopqrstuvwxyz", 10))
+
+	t.Run("valid", func(t *testing.T) {
+		s := newMultiShardServer(t, plain, []int{200, 60, 100}, false)
+		dest := filepath.Join(t.TempDir(), "out")
+		if err := DownloadFile(context.Background(), s.cfg, testFileUUID, crypto.EncryptVersionAES, dest); err != nil {
+			t.Fatalf("DownloadFile failed: %v", err)
+		}
+		got, err := os.ReadFile(dest)
+		if err != nil {
+			t.Fatalf("failed to read downloaded file: %v", err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("content mismatch:\nwant: %s\ngot:  %s", plain, got)
+		}
+	})
+
+	t.Run("hash mismatch", func(t *testing.T) {
+		s := newMultiShardServer(t, plain, []int{200, 60, 100}, false)
+		s.corrupt = 2
+		dest := filepath.Join(t.TempDir(), "out")
+		err := DownloadFile(context.Background(), s.cfg, testFileUUID, crypto.EncryptVersionAES, dest)
+		if !errors.Is(err, crypto.ErrHashMismatch) {
+			t.Fatalf("expected ErrHashMismatch, got %v", err)
+		}
+		if !strings.Contains(err.Error(), "shard 2") {
+			t.Errorf("expected error to name shard 2, got %v", err)
+		}
+		if _, err := os.Stat(dest); !os.IsNotExist(err) {
+			t.Errorf("expected corrupted file to be removed, stat error: %v", err)
+		}
+	})
+}
@@ -36,61 +36,34 @@ type StartUploadResp struct {
 
 // StartUpload reserves all parts at once
 func StartUpload(ctx context.Context, cfg *config.Config, bucketID string, parts []UploadPartSpec) (*StartUploadResp, error) {
-	url := cfg.Endpoints.Network().StartUpload(bucketID)
-	url += fmt.Sprintf("?multiparts=%d", len(parts))
-	reqBody := startUploadReq{Uploads: parts}
-	b, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(b))
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Authorization", cfg.BasicAuthHeader)
-	req.Header.Set("internxt-version", "1.0")
-	req.Header.Set("Content-Type", "application/json; charset=utf-8")
-
-	if cfg.BasicAuthHeader != "" {
-	}
-
-	resp, err := cfg.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, errors.NewHTTPError(resp, "start upload")
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	var result StartUploadResp
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
-	}
-
-	return &result, nil
+	return startUpload(ctx, cfg, bucketID, parts, len(parts), "start upload")
 }
 
 // StartUploadMultipart starts a multipart upload session with explicit part count
 func StartUploadMultipart(ctx context.Context, cfg *config.Config, bucketID string, parts []UploadPartSpec, numParts int) (*StartUploadResp, error) {
+	return startUpload(ctx, cfg, bucketID, parts, numParts, "start multipart upload")
+}
+
+// maxErrorBodySnippet is how much of an unexpected response body is quoted
+// in an error.
+const maxErrorBodySnippet = 200
+
+// startUpload reserves parts with numParts presigned URLs each. A non-2xx
+// response fails with *errors.HTTPError carrying its body, and a 2xx one that
+// is not the expected JSON (such as an HTML page from a proxy) with an error
+// quoting the start of it.
+func startUpload(ctx context.Context, cfg *config.Config, bucketID string, parts []UploadPartSpec, numParts int, op string) (*StartUploadResp, error) {
 	url := cfg.Endpoints.Network().StartUpload(bucketID)
 	url += fmt.Sprintf("?multiparts=%d", numParts)
 	reqBody := startUploadReq{Uploads: parts}
 	b, err := json.Marshal(reqBody)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to marshal %s request: %w", op, err)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(b))
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to create %s request: %w", op, err)
 	}
 	req.Header.Set("Authorization", cfg.BasicAuthHeader)
 	req.Header.Set("internxt-version", "1.0")
@@ -98,12 +71,12 @@ func StartUploadMultipart(ctx context.Context, cfg *config.Config, bucketID stri
 
 	resp, err := cfg.HTTPClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
+		return nil, fmt.Errorf("failed to execute %s request: %w", op, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, errors.NewHTTPError(resp, "start multipart upload")
+		return nil, errors.NewHTTPError(resp, op)
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -113,7 +86,8 @@ func StartUploadMultipart(ctx context.Context, cfg *config.Config, bucketID stri
 
 	var result StartUploadResp
 	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		snippet := body[:min(len(body), maxErrorBodySnippet)]
+		return nil, fmt.Errorf("failed to unmarshal %s response (status %d, %s, body %q): %w", op, resp.StatusCode, resp.Header.Get("Content-Type"), snippet, err)
 	}
 
 	return &result, nil
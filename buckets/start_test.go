@@ -3,12 +3,14 @@ package buckets
 import (
 	"context"
 	"encoding/json"
+	stderrors "errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/errors"
 )
 
 // TestStartUploadMultipart tests the multipart upload start functionality
@@ -291,7 +293,7 @@ func TestStartUpload(t *testing.T) {
 			},
 			mockStatusCode: http.StatusInternalServerError,
 			expectError:    true,
-			errorContains:  "failed",
+			errorContains:  "error message (status 500",
 		},
 		{
 			name: "unauthorized - 401",
@@ -493,8 +495,32 @@ func TestStartUploadInvalidJSONResponse(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected error for invalid JSON response, got nil")
 	}
-	if !strings.Contains(err.Error(), "failed to unmarshal") {
-		t.Errorf("expected error to contain 'failed to unmarshal', got: %v", err)
+	if !strings.Contains(err.Error(), "failed to unmarshal") || !strings.Contains(err.Error(), "invalid json {{{") {
+		t.Errorf("expected error to contain 'failed to unmarshal' and the body, got: %v", err)
+	}
+}
+
+// TestStartUploadHTMLErrorPage tests that an HTML error page from a proxy
+// fails with an HTTPError holding the page instead of a JSON decode error
+func TestStartUploadHTMLErrorPage(t *testing.T) {
+	page := "<html><body><h1>502 Bad Gateway</h1></body></html>"
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(page))
+	}))
+	defer mockServer.Close()
+
+	cfg := newTestConfig(mockServer.URL)
+	specs := []UploadPartSpec{{Index: 0, Size: 1024}}
+
+	_, err := StartUpload(context.Background(), cfg, TestBucket1, specs)
+	var httpErr *errors.HTTPError
+	if !stderrors.As(err, &httpErr) {
+		t.Fatalf("expected *errors.HTTPError, got %v", err)
+	}
+	if httpErr.StatusCode() != http.StatusBadGateway || string(httpErr.Body) != page {
+		t.Errorf("expected status 502 with the page body, got %d %q", httpErr.StatusCode(), httpErr.Body)
 	}
 }
 
@@ -9,7 +9,7 @@ import (
 // The HTTPClient is properly configured with the centralized header transport.
 func newTestConfig(mockServerURL string) *config.Config {
 	cfg := &config.Config{
-		Mnemonic:        TestMnemonic,
+		Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		Bucket:          TestBucket1,
 		Token:           TestToken,
 		BasicAuthHeader: TestBasicAuth,
@@ -24,7 +24,7 @@ func newTestConfig(mockServerURL string) *config.Config {
 // Call setEndpoints() if you need to set endpoints later.
 func newTestConfigWithBucket(bucket string) *config.Config {
 	cfg := &config.Config{
-		Mnemonic: TestMnemonic,
+		Mnemonic: config.NewSecureBytes([]byte(TestMnemonic)),
 		Bucket:   bucket,
 	}
 	cfg.ApplyDefaults()
@@ -51,7 +51,7 @@ func setEndpoints(cfg *config.Config, mockServerURL string) {
 // Useful for tests that need to modify the config before defaults are applied.
 func newTestConfigWithSetup(mockServerURL string, setup func(*config.Config)) *config.Config {
 	cfg := &config.Config{
-		Mnemonic:        TestMnemonic,
+		Mnemonic:        config.NewSecureBytes([]byte(TestMnemonic)),
 		Bucket:          TestBucket1,
 		Token:           TestToken,
 		BasicAuthHeader: TestBasicAuth,
@@ -25,7 +25,7 @@ func Transfer(ctx context.Context, cfg *config.Config, uploadURL string, r io.Re
 	req.Header.Set("Content-Type", "application/octet-stream")
 	req.ContentLength = size
 
-	resp, err := cfg.HTTPClient.Do(req)
+	resp, err := doShardRequest(cfg, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute transfer request: %w", err)
 	}
@@ -42,3 +42,25 @@ func Transfer(ctx context.Context, cfg *config.Config, uploadURL string, r io.Re
 
 	return &TransferResult{ETag: etag}, nil
 }
+
+// doShardRequest sends a request to a shard-transfer host through
+// cfg.CircuitBreaker: it fails fast with *errors.CircuitOpenError while the
+// host's circuit is open, and counts network errors and 5xx responses as
+// failures.
+func doShardRequest(cfg *config.Config, req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+	if err := cfg.CircuitBreaker.Allow(host); err != nil {
+		return nil, err
+	}
+
+	resp, err := cfg.HTTPClient.Do(req)
+	switch {
+	case err != nil && req.Context().Err() != nil:
+		cfg.CircuitBreaker.Abandon(host)
+	case err != nil:
+		cfg.CircuitBreaker.Record(host, true)
+	default:
+		cfg.CircuitBreaker.Record(host, resp.StatusCode >= 500)
+	}
+	return resp, err
+}
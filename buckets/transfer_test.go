@@ -5,11 +5,17 @@ import (
 	"context"
 	"crypto/sha1"
 	"encoding/hex"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/internxt/rclone-adapter/config"
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
 )
 
 func TestTransfer(t *testing.T) {
@@ -139,3 +145,33 @@ func TestTransfer(t *testing.T) {
 		}
 	})
 }
+
+func TestTransferCircuitBreaker(t *testing.T) {
+	var requests atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer mockServer.Close()
+
+	cfg := newTestConfigWithSetup(mockServer.URL, func(c *config.Config) {
+		c.CircuitBreaker = config.NewCircuitBreaker(2, time.Minute)
+	})
+
+	for range 2 {
+		if _, err := Transfer(context.Background(), cfg, mockServer.URL+"/upload", strings.NewReader("x"), 1); err == nil {
+			t.Fatal("expected transfer error")
+		}
+	}
+
+	_, err := Transfer(context.Background(), cfg, mockServer.URL+"/upload", strings.NewReader("x"), 1)
+	if !errors.Is(err, sdkerrors.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("expected the open circuit to skip the request, got %d requests", requests.Load())
+	}
+	if isRetryableError(err) || isTransientError(err) {
+		t.Error("expected open circuit errors not to be retried")
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"time"
 
 	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/crypto"
 	"github.com/internxt/rclone-adapter/errors"
 	"github.com/internxt/rclone-adapter/thumbnails"
 )
@@ -33,20 +34,34 @@ func WaitForPendingThumbnails() {
 	thumbnailWG.Wait()
 }
 
+// fileCipher returns the cipher of new uploads, chosen by cfg.EncryptVersion.
+func fileCipher(cfg *config.Config) (crypto.FileCipher, error) {
+	fc, err := crypto.CipherFor(cfg.EncryptVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select file cipher: %w", err)
+	}
+	return fc, nil
+}
+
 // encryptionSetup handles the encryption preparation for an upload.
 // Returns the encrypted reader with hash computation, the sha256 hasher, and the encryption index.
 func encryptionSetup(in io.Reader, cfg *config.Config) (io.Reader, hash.Hash, string, error) {
+	fc, err := fileCipher(cfg)
+	if err != nil {
+		return nil, nil, "", err
+	}
 	var ph [32]byte
 	if _, err := rand.Read(ph[:]); err != nil {
 		return nil, nil, "", fmt.Errorf("cannot generate random index: %w", err)
 	}
 	plainIndex := hex.EncodeToString(ph[:])
-	fileKey, iv, err := GenerateFileKey(cfg.Mnemonic, cfg.Bucket, plainIndex)
+	fileKey, iv, err := cfg.FileKey(plainIndex)
 	if err != nil {
 		return nil, nil, "", fmt.Errorf("failed to generate file key: %w", err)
 	}
+	defer crypto.Wipe(fileKey, iv) // The cipher keeps its own copy
 
-	encReader, err := EncryptReader(in, fileKey, iv)
+	encReader, err := fc.EncryptReader(in, fileKey, iv)
 	if err != nil {
 		return nil, nil, "", fmt.Errorf("failed to create encrypt reader: %w", err)
 	}
@@ -60,8 +75,13 @@ func encryptionSetup(in io.Reader, cfg *config.Config) (io.Reader, hash.Hash, st
 }
 
 // uploadEncryptedData handles the network upload flow: StartUpload → Transfer → FinishUpload.
-// Returns the network file ID.
+// size is the plaintext size. Returns the network file ID.
 func uploadEncryptedData(ctx context.Context, cfg *config.Config, encryptedReader io.Reader, sha256Hasher hash.Hash, encIndex string, size int64) (string, error) {
+	fc, err := fileCipher(cfg)
+	if err != nil {
+		return "", err
+	}
+	size = fc.EncryptedSize(size)
 	specs := []UploadPartSpec{{Index: 0, Size: size}}
 	startResp, err := StartUpload(ctx, cfg, cfg.Bucket, specs)
 	if err != nil {
@@ -83,7 +103,7 @@ func uploadEncryptedData(ctx context.Context, cfg *config.Config, encryptedReade
 	}
 
 	sha256Result := sha256Hasher.Sum(nil)
-	partHash := ComputeFileHash(sha256Result)
+	partHash := crypto.ComputeFileHash(sha256Result)
 
 	finishResp, err := FinishUpload(ctx, cfg, cfg.Bucket, encIndex, []Shard{{Hash: partHash, UUID: part.UUID}})
 	if err != nil {
@@ -93,6 +113,16 @@ func uploadEncryptedData(ctx context.Context, cfg *config.Config, encryptedReade
 	return finishResp.ID, nil
 }
 
+// UploadData encrypts size bytes from in with cfg.Mnemonic and stores them in
+// cfg.Bucket without creating a Drive file. Returns the network file ID.
+func UploadData(ctx context.Context, cfg *config.Config, in io.Reader, size int64) (string, error) {
+	encryptedReader, sha256Hasher, encIndex, err := encryptionSetup(in, cfg)
+	if err != nil {
+		return "", err
+	}
+	return uploadEncryptedData(ctx, cfg, encryptedReader, sha256Hasher, encIndex, size)
+}
+
 func UploadFile(ctx context.Context, cfg *config.Config, filePath, targetFolderUUID string, modTime time.Time) (*CreateMetaResponse, error) {
 	f, err := os.Open(filePath)
 	if err != nil {
@@ -105,6 +135,13 @@ func UploadFile(ctx context.Context, cfg *config.Config, filePath, targetFolderU
 		return nil, fmt.Errorf("failed to stat file %s: %w", filePath, err)
 	}
 	plainSize := fileInfo.Size()
+	if err := checkQuota(ctx, cfg, plainSize); err != nil {
+		return nil, err
+	}
+	fc, err := fileCipher(cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	// Setup encryption
 	encryptedReader, sha256Hasher, encIndex, err := encryptionSetup(f, cfg)
@@ -122,7 +159,7 @@ func UploadFile(ctx context.Context, cfg *config.Config, filePath, targetFolderU
 	base := filepath.Base(filePath)
 	name := strings.TrimSuffix(base, filepath.Ext(base))
 	ext := strings.TrimPrefix(filepath.Ext(base), ".")
-	meta, err := CreateMetaFile(ctx, cfg, name, cfg.Bucket, &fileID, "03-aes", targetFolderUUID, name, ext, plainSize, modTime)
+	meta, err := CreateMetaFile(ctx, cfg, name, cfg.Bucket, &fileID, fc.Version(), targetFolderUUID, name, ext, plainSize, modTime)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create file metadata: %w", err)
 	}
@@ -131,20 +168,42 @@ func UploadFile(ctx context.Context, cfg *config.Config, filePath, targetFolderU
 
 // UploadFileStream uploads data from the provided io.Reader into Internxt,
 // encrypting it on the fly and creating the metadata file in the target folder.
-// It returns the CreateMetaResponse of the created file entry.
+// It returns the CreateMetaResponse of the created file entry. A failed
+// transfer is only retried with cfg.ReplayBufferSize set.
 func UploadFileStream(ctx context.Context, cfg *config.Config, targetFolderUUID, fileName string, in io.Reader, plainSize int64, modTime time.Time) (*CreateMetaResponse, error) {
+	// Handle unknown size by buffering entire stream, so the quota
+	// check below sees the real size
+	if plainSize < 0 {
+		cfg.Log().DebugContext(ctx, "unknown stream size, buffering entire stream")
+		data, err := io.ReadAll(in)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stream (unknown size): %w", err)
+		}
+		plainSize = int64(len(data))
+		in = bytes.NewReader(data)
+	}
+	if err := checkQuota(ctx, cfg, plainSize); err != nil {
+		return nil, err
+	}
+	fc, err := fileCipher(cfg)
+	if err != nil {
+		return nil, err
+	}
 	var ph [32]byte
 	if _, err := rand.Read(ph[:]); err != nil {
 		return nil, fmt.Errorf("cannot generate random index: %w", err)
 	}
 	plainIndex := hex.EncodeToString(ph[:])
 
-	fileKey, iv, err := GenerateFileKey(cfg.Mnemonic, cfg.Bucket, plainIndex)
+	fileKey, iv, err := cfg.FileKey(plainIndex)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate file key: %w", err)
 	}
+	defer crypto.Wipe(fileKey, iv)
+
+	encSize := fc.EncryptedSize(plainSize)
 
-	encReader, err := EncryptReader(in, fileKey, iv)
+	encReader, err := fc.EncryptReader(in, fileKey, iv)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create encrypt reader: %w", err)
 	}
@@ -153,19 +212,19 @@ func UploadFileStream(ctx context.Context, cfg *config.Config, targetFolderUUID,
 	sha256Hasher := sha256.New()
 	r := io.TeeReader(encReader, sha256Hasher)
 
-	// Handle unknown size by buffering entire stream
+	// With cfg.ReplayBufferSize keep all the data, so a failed transfer can be
+	// retried. Otherwise pre-read a buffer to reduce transfer startup latency
+	// Use 5MB or file size, whichever is smaller
+	var replay *replayBuffer
 	var preBuf []byte
-	if plainSize < 0 {
-		fmt.Printf("[DEBUG] UploadFileStream: Unknown size, buffering entire stream...\n")
-		preBuf, err = io.ReadAll(r)
+	if cfg.ReplayBufferSize > 0 {
+		replay, err = newReplayBuffer(r, encSize, cfg.ReplayBufferSize)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read stream (unknown size): %w", err)
+			return nil, err
 		}
-		plainSize = int64(len(preBuf))
+		defer replay.Close()
 	} else {
-		// Pre-read a buffer to reduce transfer startup latency
-		// Use 5MB or file size, whichever is smaller
-		bufSize := min(plainSize, int64(5*1024*1024))
+		bufSize := min(encSize, int64(5*1024*1024))
 		preBuf = make([]byte, bufSize)
 		preReadN, preReadErr := io.ReadFull(r, preBuf)
 		if preReadErr != nil && preReadErr != io.ErrUnexpectedEOF && preReadErr != io.EOF {
@@ -179,7 +238,7 @@ func UploadFileStream(ctx context.Context, cfg *config.Config, targetFolderUUID,
 		err  error
 	}
 	startChan := make(chan startResult, 1)
-	specs := []UploadPartSpec{{Index: 0, Size: plainSize}}
+	specs := []UploadPartSpec{{Index: 0, Size: encSize}}
 
 	go func() {
 		resp, err := StartUpload(ctx, cfg, cfg.Bucket, specs)
@@ -203,16 +262,27 @@ func UploadFileStream(ctx context.Context, cfg *config.Config, targetFolderUUID,
 		uploadURL = part.URLs[0]
 	}
 
-	// Transfer using pre-buffered data + remaining stream
-	multiReader := io.MultiReader(bytes.NewReader(preBuf), r)
-	if _, err := Transfer(ctx, cfg, uploadURL, multiReader, plainSize); err != nil {
+	if replay != nil {
+		err = cfg.Retry().Do(ctx, isRetryableError, func() error {
+			_, err := Transfer(ctx, cfg, uploadURL, replay.reader(), encSize)
+			if err != nil {
+				cfg.Log().DebugContext(ctx, "single-part transfer failed", "error", err)
+			}
+			return err
+		})
+	} else {
+		// Transfer using pre-buffered data + remaining stream
+		multiReader := io.MultiReader(bytes.NewReader(preBuf), r)
+		_, err = Transfer(ctx, cfg, uploadURL, multiReader, encSize)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("failed to transfer file data: %w", err)
 	}
 
 	encIndex := hex.EncodeToString(ph[:])
 	// Compute RIPEMD-160(SHA-256) to match web client
 	sha256Result := sha256Hasher.Sum(nil)
-	partHash := ComputeFileHash(sha256Result)
+	partHash := crypto.ComputeFileHash(sha256Result)
 	finishResp, err := FinishUpload(ctx, cfg, cfg.Bucket, encIndex, []Shard{{Hash: partHash, UUID: part.UUID}})
 	if err != nil {
 		return nil, fmt.Errorf("failed to finish upload: %w", err)
@@ -221,7 +291,7 @@ func UploadFileStream(ctx context.Context, cfg *config.Config, targetFolderUUID,
 	base := filepath.Base(fileName)
 	name := strings.TrimSuffix(base, filepath.Ext(base))
 	ext := strings.TrimPrefix(filepath.Ext(base), ".")
-	meta, err := CreateMetaFile(ctx, cfg, name, cfg.Bucket, &finishResp.ID, "03-aes", targetFolderUUID, name, ext, plainSize, modTime)
+	meta, err := CreateMetaFile(ctx, cfg, name, cfg.Bucket, &finishResp.ID, fc.Version(), targetFolderUUID, name, ext, plainSize, modTime)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create file metadata: %w", err)
 	}
@@ -229,8 +299,20 @@ func UploadFileStream(ctx context.Context, cfg *config.Config, targetFolderUUID,
 }
 
 // UploadFileStreamMultipart uploads data from an io.Reader using multipart upload.
-// This is intended for large files (>100MB) and splits the file into multiple chunks
+// This is intended for large files (>100MB) and splits the file into multiple chunks.
+// The whole upload fails with context.DeadlineExceeded once cfg.MultipartTimeout passes.
+// With 03-aes, an in that implements io.ReaderAt (such as an *os.File) has its parts read
+// in parallel from its current position, which is left unchanged
 func UploadFileStreamMultipart(ctx context.Context, cfg *config.Config, targetFolderUUID, fileName string, in io.Reader, plainSize int64, modTime time.Time) (*CreateMetaResponse, error) {
+	if cfg.MultipartTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.MultipartTimeout)
+		defer cancel()
+	}
+
+	if err := checkQuota(ctx, cfg, plainSize); err != nil {
+		return nil, err
+	}
 	state, err := newMultipartUploadState(cfg, plainSize)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize multipart upload state: %w", err)
@@ -243,13 +325,14 @@ func UploadFileStreamMultipart(ctx context.Context, cfg *config.Config, targetFo
 
 	finishResp, err := FinishMultipartUpload(ctx, cfg, cfg.Bucket, state.encIndex, *shard)
 	if err != nil {
+		state.abort(ctx)
 		return nil, fmt.Errorf("failed to finish multipart upload: %w", err)
 	}
 
 	base := filepath.Base(fileName)
 	name := strings.TrimSuffix(base, filepath.Ext(base))
 	ext := strings.TrimPrefix(filepath.Ext(base), ".")
-	meta, err := CreateMetaFile(ctx, cfg, name, cfg.Bucket, &finishResp.ID, "03-aes", targetFolderUUID, name, ext, plainSize, modTime)
+	meta, err := CreateMetaFile(ctx, cfg, name, cfg.Bucket, &finishResp.ID, state.fileCipher.Version(), targetFolderUUID, name, ext, plainSize, modTime)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create file metadata: %w", err)
 	}
@@ -279,11 +362,19 @@ func UploadFileStreamAuto(ctx context.Context, cfg *config.Config, targetFolderU
 		in = bytes.NewReader(bufferedData)
 	}
 
+	if err := checkUploadSize(cfg, plainSize); err != nil {
+		return nil, err
+	}
+
 	if plainSize == 0 {
+		fc, err := fileCipher(cfg)
+		if err != nil {
+			return nil, err
+		}
 		base := filepath.Base(fileName)
 		name := strings.TrimSuffix(base, filepath.Ext(base))
 		ext := strings.TrimPrefix(filepath.Ext(base), ".")
-		meta, err := CreateMetaFile(ctx, cfg, name, cfg.Bucket, nil, "03-aes", targetFolderUUID, name, ext, 0, modTime)
+		meta, err := CreateMetaFile(ctx, cfg, name, cfg.Bucket, nil, fc.Version(), targetFolderUUID, name, ext, 0, modTime)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create empty file metadata: %w", err)
 		}
@@ -299,9 +390,14 @@ func UploadFileStreamAuto(ctx context.Context, cfg *config.Config, targetFolderU
 		capturedReader = io.TeeReader(in, capturedData)
 	}
 
+	multipartMinSize := cfg.MultipartMinSize
+	if multipartMinSize <= 0 {
+		multipartMinSize = config.DefaultMultipartMinSize
+	}
+
 	var meta *CreateMetaResponse
 	var err error
-	if plainSize >= config.DefaultMultipartMinSize {
+	if plainSize >= multipartMinSize {
 		meta, err = UploadFileStreamMultipart(ctx, cfg, targetFolderUUID, fileName, capturedReader, plainSize, modTime)
 	} else {
 		meta, err = UploadFileStream(ctx, cfg, targetFolderUUID, fileName, capturedReader, plainSize, modTime)
@@ -319,6 +415,50 @@ func UploadFileStreamAuto(ctx context.Context, cfg *config.Config, targetFolderU
 	return meta, nil
 }
 
+// checkUploadSize fails with errors.ErrFileTooLarge when a file of plainSize
+// bytes exceeds cfg.MaxUploadSize or its encrypted form would need more than
+// config.MaxMultipartParts parts, before any data is transferred.
+func checkUploadSize(cfg *config.Config, plainSize int64) error {
+	if cfg.MaxUploadSize > 0 && plainSize > cfg.MaxUploadSize {
+		return fmt.Errorf("file of %d bytes exceeds the %d byte upload limit: %w", plainSize, cfg.MaxUploadSize, errors.ErrFileTooLarge)
+	}
+	fc, err := fileCipher(cfg)
+	if err != nil {
+		return err
+	}
+	chunkSize := cfg.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = config.DefaultChunkSize
+	}
+	encSize := fc.EncryptedSize(plainSize)
+	if parts := (encSize + chunkSize - 1) / chunkSize; parts > config.MaxMultipartParts {
+		return fmt.Errorf("file of %d bytes (%d encrypted) needs %d parts of %d bytes, more than the %d allowed: %w", plainSize, encSize, parts, chunkSize, config.MaxMultipartParts, errors.ErrFileTooLarge)
+	}
+	return nil
+}
+
+// checkQuota runs cfg.QuotaCheck, if set, for an upload of plainSize bytes.
+func checkQuota(ctx context.Context, cfg *config.Config, plainSize int64) error {
+	if cfg.QuotaCheck == nil || plainSize <= 0 {
+		return nil
+	}
+	return cfg.QuotaCheck(ctx, plainSize)
+}
+
+// GenerateAndUploadThumbnail generates a thumbnail of sourceData, encrypts
+// and uploads it to the bucket and registers it for the file, retrying
+// transient failures, as UploadFile does in the background for supported
+// images. An empty fileType is detected from sourceData.
+func GenerateAndUploadThumbnail(ctx context.Context, cfg *config.Config, fileUUID, fileType string, sourceData []byte) error {
+	if fileType == "" {
+		fileType = thumbnails.DetectFormat(sourceData)
+	}
+	if !thumbnails.IsSupportedFormat(fileType) {
+		return fmt.Errorf("unsupported thumbnail format: %q", fileType)
+	}
+	return uploadThumbnailWithRetry(ctx, cfg, fileUUID, fileType, sourceData)
+}
+
 // uploadThumbnailAsync handles thumbnail upload in a background goroutine
 func uploadThumbnailAsync(ctx context.Context, cfg *config.Config, fileUUID, fileType string, originalData []byte) {
 	defer thumbnailWG.Done()
@@ -329,7 +469,7 @@ func uploadThumbnailAsync(ctx context.Context, cfg *config.Config, fileUUID, fil
 	bgCtx := context.Background()
 
 	if err := uploadThumbnailWithRetry(bgCtx, cfg, fileUUID, fileType, originalData); err != nil {
-		fmt.Printf("[WARN] Thumbnail upload failed for %s after retries: %v\n", fileUUID, err)
+		cfg.Log().Warn("thumbnail upload failed after retries", "file_uuid", fileUUID, "error", err)
 	}
 }
 
@@ -373,12 +513,16 @@ func uploadThumbnailWithRetry(ctx context.Context, cfg *config.Config, fileUUID,
 
 // uploadThumbnail generates and uploads a thumbnail for the given file
 func uploadThumbnail(ctx context.Context, cfg *config.Config, fileUUID, fileType string, originalData []byte) error {
+	fc, err := fileCipher(cfg)
+	if err != nil {
+		return err
+	}
 	thumbReader, thumbSize, thumbCfg, err := thumbnails.GenerateAndPrepare(fileType, originalData)
 	if err != nil {
 		return fmt.Errorf("failed to generate thumbnail: %w", err)
 	}
 
-	fmt.Printf("[DEBUG] Uploading thumbnail for file %s\n", fileUUID)
+	cfg.Log().DebugContext(ctx, "uploading thumbnail", "file_uuid", fileUUID)
 
 	encryptedReader, sha256Hasher, encIndex, err := encryptionSetup(thumbReader, cfg)
 	if err != nil {
@@ -394,7 +538,7 @@ func uploadThumbnail(ctx context.Context, cfg *config.Config, fileUUID, fileType
 		fileUUID,
 		cfg.Bucket,
 		fileID,
-		"03-aes",
+		fc.Version(),
 		thumbSize,
 		thumbCfg,
 	)
@@ -419,7 +563,7 @@ func createThumbnailAPI(ctx context.Context, cfg *config.Config, req thumbnails.
 	if err != nil {
 		return fmt.Errorf("failed to create thumbnail request: %w", err)
 	}
-	httpReq.Header.Set("Authorization", "Bearer "+cfg.Token)
+	cfg.AuthorizeRequest(httpReq)
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	resp, err := cfg.HTTPClient.Do(httpReq)
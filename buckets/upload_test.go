@@ -4,16 +4,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/crypto"
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
+	"github.com/internxt/rclone-adapter/thumbnails"
 )
 
 // mockMultiEndpointServer is an alias for MockMultiEndpointServer
@@ -319,6 +325,57 @@ func TestUploadFileStream(t *testing.T) {
 	}
 }
 
+// TestUploadFileStreamReplay tests that with ReplayBufferSize a failed
+// single-part transfer is sent again with the same data
+func TestUploadFileStreamReplay(t *testing.T) {
+	content := bytes.Repeat([]byte("replay "), 100)
+	for _, tc := range []struct {
+		name       string
+		bufferSize int64
+	}{
+		{"in memory", 1 << 20},
+		{"spilled to file", 64},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var bodies [][]byte
+			mockServer := newMockMultiEndpointServer()
+			defer mockServer.Close()
+			mockServer.startHandler = func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(StartUploadResp{
+					Uploads: []UploadPart{{UUID: "uuid", URL: mockServer.URL() + "/upload/replay"}},
+				})
+			}
+			mockServer.transferHandler = func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				bodies = append(bodies, body)
+				if len(bodies) == 1 {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Header().Set("ETag", "\"etag\"")
+			}
+			mockServer.finishHandler = func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(FinishUploadResp{ID: "file-id"})
+			}
+			mockServer.createMetaHandler = func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(CreateMetaResponse{UUID: "uuid", FileID: "file-id"})
+			}
+
+			cfg := newTestConfigWithSetup(mockServer.URL(), func(c *config.Config) {
+				c.ReplayBufferSize = tc.bufferSize
+				c.RetryPolicy = &config.RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}
+			})
+			_, err := UploadFileStream(context.Background(), cfg, TestFolderUUID, "replay.dat", bytes.NewReader(content), int64(len(content)), time.Now())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(bodies) != 2 || len(bodies[0]) != len(content) || !bytes.Equal(bodies[0], bodies[1]) {
+				t.Errorf("expected the same data to be sent twice, got %d transfers", len(bodies))
+			}
+		})
+	}
+}
+
 // TestUploadFileStreamMultipart tests multipart upload functionality
 func TestUploadFileStreamMultipart(t *testing.T) {
 	// Create content larger than chunk size to trigger multipart
@@ -460,6 +517,44 @@ func TestUploadFileStreamMultipart(t *testing.T) {
 	}
 }
 
+// TestUploadFileStreamMultipartTimeout tests that MultipartTimeout bounds a
+// multipart upload whose parts never complete
+func TestUploadFileStreamMultipartTimeout(t *testing.T) {
+	content := make([]byte, config.DefaultChunkSize+1000)
+
+	mockServer := newMockMultiEndpointServer()
+	defer mockServer.Close()
+	release := make(chan struct{})
+	defer close(release)
+
+	mockServer.multipartStartHandler = func(w http.ResponseWriter, r *http.Request) {
+		urls := []string{mockServer.URL() + "/upload/multipart", mockServer.URL() + "/upload/multipart"}
+		json.NewEncoder(w).Encode(StartUploadResp{
+			Uploads: []UploadPart{{UUID: "uuid", UploadId: "upload-id", URLs: urls}},
+		})
+	}
+	mockServer.transferHandler = func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}
+
+	cfg := newTestConfigWithSetup(mockServer.URL(), func(c *config.Config) {
+		c.Bucket = TestBucket3
+		c.MultipartTimeout = 200 * time.Millisecond
+	})
+
+	start := time.Now()
+	_, err := UploadFileStreamMultipart(context.Background(), cfg, TestFolderUUID, "slow.bin", bytes.NewReader(content), int64(len(content)), time.Now())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("upload took %v after a 200ms deadline", elapsed)
+	}
+}
+
 // TestUploadFileStreamAuto tests automatic routing between single-part and multipart uploads
 func TestUploadFileStreamAuto(t *testing.T) {
 	testCases := []struct {
@@ -553,6 +648,124 @@ func TestUploadFileStreamAuto(t *testing.T) {
 	}
 }
 
+// TestUploadFileStreamAutoCustomThreshold verifies that MultipartMinSize
+// from the config decides between single-part and multipart upload
+func TestUploadFileStreamAutoCustomThreshold(t *testing.T) {
+	mockServer := newMockMultiEndpointServer()
+	defer mockServer.Close()
+	mockServer.SetupMultipartUploadMock()
+
+	var multiparts string
+	multipartStart := mockServer.multipartStartHandler
+	mockServer.multipartStartHandler = func(w http.ResponseWriter, r *http.Request) {
+		multiparts = r.URL.Query().Get("multiparts")
+		multipartStart(w, r)
+	}
+
+	cfg := newTestConfigWithSetup(mockServer.URL(), func(c *config.Config) {
+		c.ChunkSize = 1024
+		c.MultipartMinSize = 2048
+	})
+
+	content := make([]byte, 4096)
+	_, err := UploadFileStreamAuto(context.Background(), cfg, TestFolderUUID, "small.dat", bytes.NewReader(content), int64(len(content)), time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if multiparts != "4" {
+		t.Errorf("expected multipart upload with 4 parts, got multiparts=%q", multiparts)
+	}
+}
+
+func TestUploadFileStreamAutoTooLarge(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(c *config.Config)
+		size  int64
+	}{
+		{"over plan limit", func(c *config.Config) { c.MaxUploadSize = 100 }, 101},
+		{"too many parts", func(c *config.Config) { c.ChunkSize = 1 }, config.MaxMultipartParts + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer mockServer.Close()
+
+			cfg := newTestConfigWithSetup(mockServer.URL, tt.setup)
+			_, err := UploadFileStreamAuto(context.Background(), cfg, TestFolderUUID, "big.dat", bytes.NewReader(make([]byte, tt.size)), tt.size, time.Now())
+			if !errors.Is(err, sdkerrors.ErrFileTooLarge) {
+				t.Errorf("expected ErrFileTooLarge, got %v", err)
+			}
+			if requests != 0 {
+				t.Errorf("expected no requests, got %d", requests)
+			}
+		})
+	}
+}
+
+func TestCheckUploadSizeEncrypted(t *testing.T) {
+	// The parts are cut from the encrypted stream, so the GCM tags can push a
+	// file that fits in config.MaxMultipartParts plaintext chunks over it
+	plainSize := int64(config.MaxMultipartParts) * 16
+	cfg := newTestConfigWithSetup("http://localhost", func(c *config.Config) {
+		c.ChunkSize = 16
+	})
+	if err := checkUploadSize(cfg, plainSize); err != nil {
+		t.Errorf("%s: unexpected error: %v", crypto.EncryptVersionAES, err)
+	}
+	cfg.EncryptVersion = crypto.EncryptVersionAESGCM
+	if err := checkUploadSize(cfg, plainSize); !errors.Is(err, sdkerrors.ErrFileTooLarge) {
+		t.Errorf("%s: expected ErrFileTooLarge, got %v", crypto.EncryptVersionAESGCM, err)
+	}
+}
+
+func TestUploadQuotaCheck(t *testing.T) {
+	var requests int
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer mockServer.Close()
+
+	var checked []int64
+	cfg := newTestConfigWithSetup(mockServer.URL, func(c *config.Config) {
+		c.ChunkSize = 1024
+		c.MinChunkSize = 100
+		c.MultipartMinSize = 2048
+		c.QuotaCheck = func(ctx context.Context, size int64) error {
+			checked = append(checked, size)
+			return &sdkerrors.QuotaExceededError{Size: size, Available: 10}
+		}
+	})
+	ctx := context.Background()
+
+	for _, size := range []int64{100, 4096} {
+		_, err := UploadFileStreamAuto(ctx, cfg, TestFolderUUID, "file.dat", bytes.NewReader(make([]byte, size)), size, time.Now())
+		if !errors.Is(err, sdkerrors.ErrQuotaExceeded) {
+			t.Errorf("%d bytes: expected ErrQuotaExceeded, got %v", size, err)
+		}
+	}
+	if _, err := NewChunkUploadSession(ctx, cfg, 4096, 1024); !errors.Is(err, sdkerrors.ErrQuotaExceeded) {
+		t.Errorf("NewChunkUploadSession: expected ErrQuotaExceeded, got %v", err)
+	}
+	if _, err := UploadFileStream(ctx, cfg, TestFolderUUID, "file.dat", bytes.NewReader(make([]byte, 300)), -1, time.Now()); !errors.Is(err, sdkerrors.ErrQuotaExceeded) {
+		t.Errorf("unknown size: expected ErrQuotaExceeded, got %v", err)
+	}
+
+	if want := []int64{100, 4096, 4096, 300}; !slices.Equal(checked, want) {
+		t.Errorf("expected quota checks for %v, got %v", want, checked)
+	}
+	if requests != 0 {
+		t.Errorf("expected no requests, got %d", requests)
+	}
+}
+
 // TestUploadFileInvalidMnemonic tests that invalid mnemonic still generates keys
 // (BIP39 doesn't validate mnemonic strength, just uses it as entropy)
 func TestUploadFileInvalidMnemonic(t *testing.T) {
@@ -561,7 +774,7 @@ func TestUploadFileInvalidMnemonic(t *testing.T) {
 	os.WriteFile(testFilePath, []byte("test"), 0644)
 
 	cfg := newTestConfigWithSetup("http://localhost", func(c *config.Config) {
-		c.Mnemonic = "invalid mnemonic phrase that is not standard"
+		c.SetMnemonic("invalid mnemonic phrase that is not standard")
 		c.Bucket = TestBucket5
 	})
 
@@ -661,7 +874,7 @@ func TestEncryptionSetup(t *testing.T) {
 
 	t.Run("successful encryption setup", func(t *testing.T) {
 		cfg := &config.Config{
-			Mnemonic: TestMnemonic,
+			Mnemonic: config.NewSecureBytes([]byte(TestMnemonic)),
 			Bucket:   TestBucket7,
 		}
 
@@ -691,7 +904,7 @@ func TestEncryptionSetup(t *testing.T) {
 
 	t.Run("error - invalid mnemonic", func(t *testing.T) {
 		cfg := &config.Config{
-			Mnemonic: "invalid mnemonic that is not a valid BIP39 phrase",
+			Mnemonic: config.NewSecureBytes([]byte("invalid mnemonic that is not a valid BIP39 phrase")),
 			Bucket:   TestBucket7,
 		}
 
@@ -709,7 +922,7 @@ func TestEncryptionSetup(t *testing.T) {
 
 	t.Run("error - invalid bucket ID", func(t *testing.T) {
 		cfg := &config.Config{
-			Mnemonic: TestMnemonic,
+			Mnemonic: config.NewSecureBytes([]byte(TestMnemonic)),
 			Bucket:   "invalid-bucket-id-not-hex",
 		}
 
@@ -1235,6 +1448,46 @@ func TestWaitForPendingThumbnails(t *testing.T) {
 	})
 }
 
+func TestGenerateAndUploadThumbnail(t *testing.T) {
+	mockServer := newMockMultiEndpointServer()
+	defer mockServer.Close()
+
+	var registered thumbnails.CreateThumbnailRequest
+	mockServer.startHandler = func(w http.ResponseWriter, r *http.Request) {
+		resp := StartUploadResp{
+			Uploads: []UploadPart{
+				{UUID: TestThumbUUID, URL: mockServer.URL() + TestThumbPath},
+			},
+		}
+		json.NewEncoder(w).Encode(resp)
+	}
+	mockServer.transferHandler = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", TestThumbETag)
+		w.WriteHeader(http.StatusOK)
+	}
+	mockServer.finishHandler = func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(FinishUploadResp{ID: TestThumbFileID})
+	}
+	mockServer.thumbnailHandler = func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&registered)
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	cfg := newTestConfigWithSetup(mockServer.URL(), nil)
+
+	if err := GenerateAndUploadThumbnail(context.Background(), cfg, TestThumbFileUUID, "", TestValidPNG); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if registered.FileUUID != TestThumbFileUUID || registered.BucketFile != TestThumbFileID {
+		t.Errorf("unexpected thumbnail registration: %+v", registered)
+	}
+
+	err := GenerateAndUploadThumbnail(context.Background(), cfg, TestThumbFileUUID, "", []byte("plain text"))
+	if err == nil || !strings.Contains(err.Error(), "unsupported thumbnail format") {
+		t.Errorf("expected unsupported format error, got %v", err)
+	}
+}
+
 // TestUploadFileStreamAuto_EmptyFile tests that empty files skip S3 upload and only create metadata
 func TestUploadFileStreamAuto_EmptyFile(t *testing.T) {
 	mockServer := newMockMultiEndpointServer()
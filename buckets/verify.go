@@ -0,0 +1,37 @@
+package buckets
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/crypto"
+)
+
+// VerifyFile downloads the encrypted shard of the network file fileID and
+// checks it against the hash the network stored on upload, without
+// decrypting it. A corrupt file fails with an error wrapping
+// crypto.ErrHashMismatch.
+func VerifyFile(ctx context.Context, cfg *config.Config, fileID string) error {
+	info, err := GetBucketFileInfo(ctx, cfg, cfg.Bucket, fileID)
+	if err != nil {
+		return fmt.Errorf("failed to get bucket file info: %w", err)
+	}
+	if info.Size == 0 {
+		return nil
+	}
+	if len(info.Shards) == 0 {
+		return fmt.Errorf("no shards found for file %s", fileID)
+	}
+	shard := info.Shards[0]
+
+	resp, err := openShard(ctx, cfg, shard.URL, "", "verify")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := crypto.VerifyShard(resp.Body, shard.Hash); err != nil {
+		return fmt.Errorf("failed to verify file %s: %w", fileID, err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Package cache pre-populates the SDK caches configured on a config.Config,
+// so that tools such as mounts and file browsers get fast first navigation.
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/folders"
+)
+
+// ErrNoListingCache is returned by WarmTree when cfg has no ListingCache.
+var ErrNoListingCache = errors.New("listing cache not configured")
+
+// WarmTree lists the folders and files of rootUUID and of its subfolders down
+// to depth levels below it (0 lists rootUUID only, negative means the whole
+// subtree), filling cfg.ListingCache, which folders.ResolvePath also reads.
+// Up to cfg.MaxConcurrency folders are listed at a time. The first listing
+// error stops the warm-up and is returned.
+func WarmTree(ctx context.Context, cfg *config.Config, rootUUID string, depth int) error {
+	if cfg.ListingCache == nil {
+		return ErrNoListingCache
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	semaphore := make(chan struct{}, max(cfg.MaxConcurrency, 1))
+
+	var warm func(uuid string, level int)
+	warm = func(uuid string, level int) {
+		defer wg.Done()
+
+		semaphore <- struct{}{}
+		subfolders, err := warmFolder(ctx, cfg, uuid)
+		<-semaphore
+		if err != nil {
+			errOnce.Do(func() {
+				firstErr = err
+				cancel()
+			})
+			return
+		}
+
+		if depth >= 0 && level >= depth {
+			return
+		}
+		for _, f := range subfolders {
+			wg.Add(1)
+			go warm(f.UUID, level+1)
+		}
+	}
+
+	wg.Add(1)
+	go warm(rootUUID, 0)
+	wg.Wait()
+
+	return firstErr
+}
+
+// warmFolder lists the folders and files of uuid and returns its subfolders.
+func warmFolder(ctx context.Context, cfg *config.Config, uuid string) ([]folders.Folder, error) {
+	subfolders, err := folders.ListAllFolders(ctx, cfg, uuid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to warm folder %s: %w", uuid, err)
+	}
+	if _, err := folders.ListAllFiles(ctx, cfg, uuid); err != nil {
+		return nil, fmt.Errorf("failed to warm folder %s: %w", uuid, err)
+	}
+	return subfolders, nil
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/internxt/rclone-adapter/config"
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
+	"github.com/internxt/rclone-adapter/folders"
+)
+
+var tree = map[string][]string{
+	"root": {"a", "b"},
+	"a":    {"c"},
+}
+
+func newTreeServer(listed *sync.Map) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		uuid, kind := parts[len(parts)-2], parts[len(parts)-1]
+		if uuid == "broken" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if kind == "files" {
+			json.NewEncoder(w).Encode(map[string][]folders.File{"files": {}})
+			return
+		}
+		listed.Store(uuid, true)
+		var children []folders.Folder
+		for _, child := range tree[uuid] {
+			children = append(children, folders.Folder{UUID: child, PlainName: child})
+		}
+		json.NewEncoder(w).Encode(map[string][]folders.Folder{"folders": children})
+	}))
+}
+
+func TestWarmTree(t *testing.T) {
+	tests := []struct {
+		name  string
+		depth int
+		want  []string
+	}{
+		{"root only", 0, []string{"root"}},
+		{"one level", 1, []string{"root", "a", "b"}},
+		{"whole tree", -1, []string{"root", "a", "b", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var listed sync.Map
+			mockServer := newTreeServer(&listed)
+			defer mockServer.Close()
+
+			cfg := newTestConfig(mockServer.URL)
+			cfg.ListingCache = config.NewListingCache(time.Hour)
+			if err := WarmTree(context.Background(), cfg, "root", tt.depth); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			count := 0
+			listed.Range(func(any, any) bool { count++; return true })
+			if count != len(tt.want) {
+				t.Errorf("expected %d folders listed, got %d", len(tt.want), count)
+			}
+			for _, uuid := range tt.want {
+				if _, ok := listed.Load(uuid); !ok {
+					t.Errorf("expected %s to be listed", uuid)
+				}
+				listed.Delete(uuid)
+				if _, err := folders.ListAllFolders(context.Background(), cfg, uuid); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if _, ok := listed.Load(uuid); ok {
+					t.Errorf("expected listing of %s to be served from the cache", uuid)
+				}
+			}
+		})
+	}
+}
+
+func TestWarmTreeErrors(t *testing.T) {
+	var listed sync.Map
+	mockServer := newTreeServer(&listed)
+	defer mockServer.Close()
+
+	cfg := newTestConfig(mockServer.URL)
+	if err := WarmTree(context.Background(), cfg, "root", -1); !errors.Is(err, ErrNoListingCache) {
+		t.Errorf("expected ErrNoListingCache, got %v", err)
+	}
+
+	cfg.ListingCache = config.NewListingCache(time.Hour)
+	if err := WarmTree(context.Background(), cfg, "broken", -1); !errors.Is(err, sdkerrors.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package changes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/internxt/rclone-adapter/folders"
+)
+
+func TestSince(t *testing.T) {
+	t0 := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Minute)
+
+	var updatedAt []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/folders") {
+			updatedAt = append(updatedAt, r.URL.Query().Get("updatedAt"))
+			json.NewEncoder(w).Encode([]folders.Folder{
+				{UUID: "new-folder", ParentUUID: "root", CreatedAt: t1, UpdatedAt: t1, Status: "EXISTS"},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode([]folders.File{
+			{UUID: "changed-file", FolderUUID: "root", CreatedAt: t0.Add(-time.Hour), UpdatedAt: t0.Add(time.Second), Status: "EXISTS"},
+			{UUID: "deleted-file", FolderUUID: "root", CreatedAt: t0.Add(-time.Hour), UpdatedAt: t1, Status: "DELETED"},
+		})
+	}))
+	defer mockServer.Close()
+	cfg := newTestConfig(mockServer.URL)
+
+	changes, err := Since(context.Background(), cfg, CursorAt(t0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(changes.Created) != 1 || changes.Created[0].UUID != "new-folder" {
+		t.Errorf("unexpected created %+v", changes.Created)
+	}
+	if len(changes.Updated) != 1 || changes.Updated[0].UUID != "changed-file" {
+		t.Errorf("unexpected updated %+v", changes.Updated)
+	}
+	if len(changes.Deleted) != 1 || changes.Deleted[0].UUID != "deleted-file" {
+		t.Errorf("unexpected deleted %+v", changes.Deleted)
+	}
+	if updatedAt[0] != t0.Format(time.RFC3339Nano) {
+		t.Errorf("expected listing from the cursor time, got %q", updatedAt[0])
+	}
+
+	// The continuation cursor neither lists from before the newest change
+	// nor reports the changes made at its time again.
+	again, err := Since(context.Background(), cfg, changes.Cursor)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := len(again.Created) + len(again.Updated) + len(again.Deleted); n != 0 {
+		t.Errorf("expected no changes, got %+v", again)
+	}
+	if updatedAt[1] != t1.Format(time.RFC3339Nano) {
+		t.Errorf("expected listing from the newest change, got %q", updatedAt[1])
+	}
+
+	if _, err := Since(context.Background(), cfg, "not a cursor!"); err == nil {
+		t.Error("expected an error for an invalid cursor")
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/internxt/rclone-adapter/auth"
+	"github.com/internxt/rclone-adapter/backend"
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/crypto"
+	"github.com/internxt/rclone-adapter/send"
+	"github.com/internxt/rclone-adapter/sharing"
+)
+
+// EnvPassword is read by login before falling back to standard input.
+const EnvPassword = "INTERNXT_PASSWORD"
+
+func runLogin(e *env, args []string) error {
+	flags := e.newFlagSet("login")
+	tfa := flags.String("tfa", "", "current two-factor authentication code")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+	email := flags.Arg(0)
+
+	password := config.NewSecureBytes([]byte(os.Getenv(EnvPassword)))
+	if password.Len() == 0 {
+		fmt.Fprint(e.stderr, "Password: ")
+		line, err := bufio.NewReader(e.stdin).ReadBytes('\n')
+		if err != nil && len(line) == 0 {
+			return fmt.Errorf("failed to read password: %w", err)
+		}
+		password = config.NewSecureBytes(bytes.TrimRight(line, "\r\n"))
+		crypto.Wipe(line)
+	}
+	defer password.Wipe()
+
+	base, err := config.FromEnv()
+	if err != nil {
+		return err
+	}
+	cfg, err := auth.LoginWithPasswordTFA(e.ctx, base, email, string(password.Bytes()), *tfa)
+	if stderrors.Is(err, auth.ErrTFARequired) {
+		return fmt.Errorf("%w: pass the code from your authenticator app with -tfa", err)
+	}
+	if err != nil {
+		return err
+	}
+	if err := saveSession(e.dir, cfg); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "Logged in as %s\n", email)
+	return nil
+}
+
+func runLs(e *env, args []string) error {
+	flags := e.newFlagSet("ls")
+	long := flags.Bool("l", false, "show sizes and modification times")
+	if err := flags.Parse(args); err != nil || flags.NArg() > 1 {
+		return errUsage
+	}
+
+	entries, err := e.fs.List(e.ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := path.Base(entry.Remote())
+		if _, ok := entry.(*backend.Directory); ok {
+			name += "/"
+		}
+		if *long {
+			fmt.Fprintf(e.stdout, "%12d  %s  %s\n", entry.Size(), entry.ModTime().Local().Format(time.DateTime), name)
+		} else {
+			fmt.Fprintln(e.stdout, name)
+		}
+	}
+	return nil
+}
+
+func runMkdir(e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	return e.fs.Mkdir(e.ctx, args[0])
+}
+
+// runUpload uploads a local file. A remote path ending in "/" or naming a
+// directory receives the file under its local name.
+func runUpload(e *env, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errUsage
+	}
+	local := args[0]
+	remote := filepath.Base(local)
+	if len(args) == 2 {
+		remote = args[1]
+		if strings.HasSuffix(remote, "/") {
+			remote += filepath.Base(local)
+		} else if entry, err := e.fs.Stat(e.ctx, remote); err == nil {
+			if _, ok := entry.(*backend.Directory); ok {
+				remote = path.Join(remote, filepath.Base(local))
+			}
+		}
+	}
+
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("failed to upload %q: %w", local, backend.ErrIsDir)
+	}
+	obj, err := e.fs.Put(e.ctx, remote, f, info.Size(), info.ModTime())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "%s\t%s\n", obj.Remote(), obj.UUID())
+	return nil
+}
+
+// runDownload downloads a file to the local path, or to the current
+// directory under its remote name. The file appears only once complete and
+// keeps its remote modification time.
+func runDownload(e *env, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errUsage
+	}
+	obj, err := e.fs.NewObject(e.ctx, args[0])
+	if err != nil {
+		return err
+	}
+	local := path.Base(obj.Remote())
+	if len(args) == 2 {
+		local = args[1]
+		if info, err := os.Stat(local); err == nil && info.IsDir() {
+			local = filepath.Join(local, path.Base(obj.Remote()))
+		}
+	}
+
+	rc, err := obj.Open(e.ctx)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(local), ".internxt-download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.ReadFrom(rc); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to download %q: %w", obj.Remote(), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), time.Time{}, obj.ModTime()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), local)
+}
+
+// runRm removes a file or an empty directory, or with -r a directory and
+// everything below it.
+func runRm(e *env, args []string) error {
+	flags := e.newFlagSet("rm")
+	recursive := flags.Bool("r", false, "remove directories and their contents")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+	remote := flags.Arg(0)
+	if path.Clean("/"+remote) == "/" {
+		return stderrors.New("refusing to remove the root folder")
+	}
+
+	entry, err := e.fs.Stat(e.ctx, remote)
+	if err != nil {
+		return err
+	}
+	switch entry := entry.(type) {
+	case *backend.Object:
+		return entry.Remove(e.ctx)
+	case *backend.Directory:
+		if *recursive {
+			return e.fs.Purge(e.ctx, remote)
+		}
+		return e.fs.Rmdir(e.ctx, remote)
+	}
+	return nil
+}
+
+// runScrub checks the stored contents of a file, or of every file below a
+// directory, against their network hashes and lists the corrupt ones.
+func runScrub(e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	entry, err := e.fs.Stat(e.ctx, args[0])
+	if err != nil {
+		return err
+	}
+	var checked, corrupt int
+	var scrub func(entry backend.Entry) error
+	scrub = func(entry backend.Entry) error {
+		switch entry := entry.(type) {
+		case *backend.Object:
+			checked++
+			err := entry.Verify(e.ctx)
+			if stderrors.Is(err, crypto.ErrHashMismatch) {
+				corrupt++
+				fmt.Fprintf(e.stdout, "corrupt: %s\n", entry.Remote())
+				return nil
+			}
+			return err
+		case *backend.Directory:
+			entries, err := e.fs.List(e.ctx, entry.Remote())
+			if err != nil {
+				return err
+			}
+			for _, child := range entries {
+				if err := scrub(child); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := scrub(entry); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "%d files checked, %d corrupt\n", checked, corrupt)
+	if corrupt > 0 {
+		return fmt.Errorf("%d corrupt files", corrupt)
+	}
+	return nil
+}
+
+// runCleanup removes the files left behind by uploads and moves that were
+// interrupted while replacing an existing file.
+func runCleanup(e *env, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	return e.fs.Cleanup(e.ctx)
+}
+
+// runShare prints a public link to a file or folder, creating it unless the
+// item is shared already.
+func runShare(e *env, args []string) error {
+	flags := e.newFlagSet("share")
+	viewOnly := flags.Bool("view", false, "let visitors preview but not download")
+	password := flags.String("password", "", "password visitors must enter")
+	expire := flags.Duration("expire", 0, "disable the link after this long")
+	maxDownloads := flags.Int("max-downloads", 0, "disable the link after this many downloads")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 1 {
+		return errUsage
+	}
+	opts := &sharing.LinkOptions{Password: *password, MaxDownloads: *maxDownloads}
+	if *viewOnly {
+		opts.Permission = sharing.PermissionView
+	}
+	if *expire > 0 {
+		opts.ExpiresAt = time.Now().Add(*expire)
+	}
+
+	entry, err := e.fs.Stat(e.ctx, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	var link *sharing.Link
+	switch entry := entry.(type) {
+	case *backend.Object:
+		link, err = sharing.CreateFileLink(e.ctx, e.cfg, entry.UUID(), opts)
+	case *backend.Directory:
+		link, err = sharing.CreateFolderLink(e.ctx, e.cfg, entry.ID(), opts)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(e.stdout, link.URL)
+	return nil
+}
+
+// runSend uploads local files to a new Send link and prints its URL.
+func runSend(e *env, args []string) error {
+	flags := e.newFlagSet("send")
+	title := flags.String("title", "", "title shown to receivers")
+	message := flags.String("message", "", "message shown to receivers")
+	to := flags.String("to", "", "comma-separated emails to send the link to")
+	expire := flags.Duration("expire", 0, "disable the link after this long (default 14 days)")
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		return errUsage
+	}
+	opts := &send.Options{Title: *title, Message: *message}
+	if *to != "" {
+		opts.Receivers = strings.Split(*to, ",")
+	}
+	if *expire > 0 {
+		opts.ExpiresAt = time.Now().Add(*expire)
+	}
+
+	var files []send.File
+	for _, local := range flags.Args() {
+		f, err := os.Open(local)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return fmt.Errorf("failed to send %q: %w", local, backend.ErrIsDir)
+		}
+		files = append(files, send.File{Name: filepath.Base(local), Size: info.Size(), Content: f})
+	}
+	link, err := send.Upload(e.ctx, e.cfg, files, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(e.stdout, link.URL)
+	return nil
+}
+
+// runReceive downloads the files of a Send link into the local directory,
+// or the current one. No login is needed.
+func runReceive(e *env, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return errUsage
+	}
+	dir := "."
+	if len(args) == 2 {
+		dir = args[1]
+	}
+	cfg, err := config.FromEnv()
+	if err != nil {
+		return err
+	}
+	transfer, err := send.Fetch(e.ctx, cfg, args[0])
+	if err != nil {
+		return err
+	}
+	for i := range transfer.Items {
+		item := &transfer.Items[i]
+		if err := receiveItem(e, transfer, item, filepath.Join(dir, filepath.Base(item.Name))); err != nil {
+			return err
+		}
+		fmt.Fprintln(e.stdout, item.Name)
+	}
+	return nil
+}
+
+// receiveItem downloads item to local; the file appears only once complete.
+func receiveItem(e *env, transfer *send.Transfer, item *send.Item, local string) error {
+	rc, err := transfer.Open(e.ctx, item)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(local), ".internxt-download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.ReadFrom(rc); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to download %q: %w", item.Name, err)
+	}
+	if err := rc.Close(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to download %q: %w", item.Name, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), local)
+}
+
+func runUsage(e *env, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	about, err := e.fs.About(e.ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stdout, "Used:  %s\nFree:  %s\nTotal: %s\n", formatSize(about.Used), formatSize(about.Free), formatSize(about.Total))
+	return nil
+}
+
+// formatSize formats n bytes with binary units.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,165 @@
+// Command internxt is a command-line client for Internxt Drive built on this
+// module. It logs in once and saves the session, then lists, creates,
+// uploads, downloads, removes, shares, scrubs and cleans up files by path,
+// and sends local files through expiring Send links:
+//
+//	internxt login user@example.com
+//	internxt mkdir photos/2024
+//	internxt upload beach.jpg photos/2024/
+//	internxt ls -l photos/2024
+//	internxt download photos/2024/beach.jpg
+//	internxt share photos/2024
+//	internxt send -to friend@example.com beach.jpg sunset.jpg
+//	internxt receive https://send.internxt.com/download/...
+//	internxt scrub photos
+//	internxt cleanup
+//	internxt rm -r photos/2024
+//	internxt usage
+//
+// The password is read from INTERNXT_PASSWORD, or from the first line of
+// standard input. The other INTERNXT_* variables read by config.FromEnv are
+// honored; INTERNXT_TOKEN bypasses the saved session.
+package main
+
+import (
+	"context"
+	stderrors "errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+
+	"github.com/internxt/rclone-adapter/backend"
+	"github.com/internxt/rclone-adapter/config"
+)
+
+// errUsage reports invalid arguments; the command's usage is printed.
+var errUsage = stderrors.New("invalid arguments")
+
+// env is what a command runs with.
+type env struct {
+	ctx    context.Context
+	dir    string // Directory of the session and state files
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	cfg    *config.Config // Logged-in config, nil for login and receive
+	fs     *backend.Fs    // Drive root, nil for login and receive
+}
+
+type command struct {
+	usage string
+	run   func(e *env, args []string) error
+}
+
+var commands = map[string]command{
+	"login":    {"login [-tfa code] <email>", runLogin},
+	"ls":       {"ls [-l] [path]", runLs},
+	"mkdir":    {"mkdir <path>", runMkdir},
+	"upload":   {"upload <local file> [remote path]", runUpload},
+	"download": {"download <remote path> [local path]", runDownload},
+	"rm":       {"rm [-r] <path>", runRm},
+	"scrub":    {"scrub <path>", runScrub},
+	"cleanup":  {"cleanup", runCleanup},
+	"share":    {"share [-view] [-password p] [-expire 24h] [-max-downloads n] <path>", runShare},
+	"send":     {"send [-title t] [-message m] [-to emails] [-expire 24h] <local file>...", runSend},
+	"receive":  {"receive <link> [local dir]", runReceive},
+	"usage":    {"usage", runUsage},
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run executes the command line args and returns the exit status: 0 on
+// success, 1 on failure and 2 on invalid arguments.
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("internxt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dir := flags.String("config", defaultConfigDir(), "directory of the session and state files")
+	flags.Usage = func() { printUsage(stderr, flags) }
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	name := flags.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "internxt: unknown command %q\n", name)
+		flags.Usage()
+		return 2
+	}
+
+	e := &env{ctx: ctx, dir: *dir, stdin: stdin, stdout: stdout, stderr: stderr}
+	err := e.exec(name, cmd, flags.Args()[1:])
+	if stderrors.Is(err, errUsage) {
+		fmt.Fprintf(stderr, "usage: internxt %s\n", cmd.usage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "internxt %s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// exec runs cmd, loading the session first for everything but login and
+// receive, and saving the consistency state and any refreshed token afterwards.
+func (e *env) exec(name string, cmd command, args []string) error {
+	if name == "login" || name == "receive" {
+		return cmd.run(e, args)
+	}
+
+	cfg, err := loadConfig(e.dir)
+	if err != nil {
+		return err
+	}
+	if err := cfg.LoadState(); err != nil {
+		return err
+	}
+	token := cfg.CurrentToken()
+	e.cfg, e.fs = cfg, backend.NewFs(cfg, "")
+
+	err = cmd.run(e, args)
+	if serr := cfg.SaveState(); serr != nil && err == nil {
+		err = serr
+	}
+	if cfg.TokenRefresher != nil && cfg.CurrentToken() != token {
+		if serr := saveSession(e.dir, cfg); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+func printUsage(w io.Writer, flags *flag.FlagSet) {
+	fmt.Fprintln(w, "usage: internxt [-config dir] <command> [arguments]")
+	fmt.Fprintln(w, "\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n", commands[name].usage)
+	}
+	fmt.Fprintln(w, "\nflags:")
+	flags.PrintDefaults()
+}
+
+// newFlagSet returns the flag set of a command. Its parse errors are printed
+// without the flag defaults; run follows them with the command's usage.
+func (e *env) newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(e.stderr)
+	flags.Usage = func() {}
+	return flags
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/internal/fakedrive"
+)
+
+// newTestSession starts a fake drive and saves a session for it in a new
+// config directory, as login would.
+func newTestSession(t *testing.T) (*fakedrive.Server, string) {
+	server := fakedrive.New()
+	t.Cleanup(server.Close)
+	for _, key := range []string{config.EnvToken, config.EnvMnemonic, config.EnvBucket, config.EnvRootFolderID,
+		config.EnvBasicAuthHeader, config.EnvDriveURL, config.EnvNetworkURL, config.EnvWorkspaceID} {
+		t.Setenv(key, "")
+	}
+	t.Setenv(config.EnvGatewayURL, server.URL)
+
+	dir := t.TempDir()
+	if err := saveSession(dir, server.Config()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return server, dir
+}
+
+func runCommand(t *testing.T, dir string, args ...string) (string, string, int) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(context.Background(), append([]string{"-config", dir}, args...), strings.NewReader(""), &stdout, &stderr)
+	return stdout.String(), stderr.String(), code
+}
+
+func TestCommands(t *testing.T) {
+	server, dir := newTestSession(t)
+	local := t.TempDir()
+	modTime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	src := filepath.Join(local, "report.txt")
+	if err := os.WriteFile(src, []byte("quarterly numbers"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(src, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, stderr, code := runCommand(t, dir, "mkdir", "docs/2024"); code != 0 {
+		t.Fatalf("mkdir failed: %s", stderr)
+	}
+	if _, stderr, code := runCommand(t, dir, "upload", src, "docs/2024"); code != 0 {
+		t.Fatalf("upload failed: %s", stderr)
+	}
+	if _, _, ok := server.Lookup("docs/2024/report.txt"); !ok {
+		t.Fatal("expected upload into the named directory")
+	}
+
+	stdout, stderr, code := runCommand(t, dir, "ls", "docs")
+	if code != 0 || stdout != "2024/\n" {
+		t.Errorf("ls: got %q, %q, %d", stdout, stderr, code)
+	}
+	stdout, _, _ = runCommand(t, dir, "ls", "-l", "docs/2024")
+	if !strings.Contains(stdout, "17  ") || !strings.HasSuffix(stdout, "  report.txt\n") {
+		t.Errorf("ls -l: got %q", stdout)
+	}
+
+	dst := filepath.Join(local, "copy.txt")
+	if _, stderr, code := runCommand(t, dir, "download", "docs/2024/report.txt", dst); code != 0 {
+		t.Fatalf("download failed: %s", stderr)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "quarterly numbers" {
+		t.Errorf("downloaded %q", data)
+	}
+	if info, err := os.Stat(dst); err != nil || !info.ModTime().Equal(modTime) {
+		t.Errorf("expected the remote modification time, got %v, %v", info, err)
+	}
+
+	if _, stderr, code := runCommand(t, dir, "rm", "docs"); code != 1 || !strings.Contains(stderr, "not empty") {
+		t.Errorf("rm of a non-empty directory: got %q, %d", stderr, code)
+	}
+	if _, stderr, code := runCommand(t, dir, "rm", "-r", "docs"); code != 0 {
+		t.Fatalf("rm -r failed: %s", stderr)
+	}
+	if _, _, ok := server.Lookup("docs"); ok {
+		t.Error("expected docs to be removed")
+	}
+
+	server.SetLimit(2 << 30)
+	stdout, _, _ = runCommand(t, dir, "usage")
+	if !strings.Contains(stdout, "Total: 2.0 GiB") {
+		t.Errorf("usage: got %q", stdout)
+	}
+}
+
+func TestScrub(t *testing.T) {
+	server, dir := newTestSession(t)
+	photos := server.AddFolder(fakedrive.RootUUID, "photos")
+	server.AddFile(photos, "a.jpg", []byte("first photo"), time.Now())
+	b := server.AddFile(server.AddFolder(photos, "2024"), "b.jpg", []byte("second photo"), time.Now())
+	server.AddFile(fakedrive.RootUUID, "empty.txt", nil, time.Now())
+
+	stdout, stderr, code := runCommand(t, dir, "scrub", "/")
+	if code != 0 || stdout != "3 files checked, 0 corrupt\n" {
+		t.Errorf("scrub: got %q, %q, %d", stdout, stderr, code)
+	}
+
+	server.Corrupt(b)
+	stdout, stderr, code = runCommand(t, dir, "scrub", "photos")
+	if code != 1 || stdout != "corrupt: photos/2024/b.jpg\n2 files checked, 1 corrupt\n" || !strings.Contains(stderr, "1 corrupt files") {
+		t.Errorf("scrub: got %q, %q, %d", stdout, stderr, code)
+	}
+	if _, _, code := runCommand(t, dir, "scrub", "photos/a.jpg"); code != 0 {
+		t.Error("expected a.jpg to verify")
+	}
+}
+
+func TestUsageErrors(t *testing.T) {
+	_, dir := newTestSession(t)
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"no command", nil, 2},
+		{"unknown command", []string{"cp"}, 2},
+		{"missing argument", []string{"mkdir"}, 2},
+		{"unknown flag", []string{"ls", "-x"}, 2},
+		{"share without path", []string{"share", "-view"}, 2},
+		{"send without files", []string{"send", "-title", "t"}, 2},
+		{"receive without link", []string{"receive"}, 2},
+		{"receive invalid link", []string{"receive", "https://send.internxt.com/"}, 1},
+		{"root removal", []string{"rm", "-r", "/"}, 1},
+		{"missing file", []string{"download", "missing.txt"}, 1},
+		{"scrub without path", []string{"scrub"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, stderr, code := runCommand(t, dir, tt.args...); code != tt.code {
+				t.Errorf("expected exit status %d, got %d: %s", tt.code, code, stderr)
+			}
+		})
+	}
+}
+
+func TestNotLoggedIn(t *testing.T) {
+	newTestSession(t)
+	_, stderr, code := runCommand(t, t.TempDir(), "ls")
+	if code != 1 || !strings.Contains(stderr, "not logged in") {
+		t.Errorf("got %q, %d", stderr, code)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1536, "1.5 KiB"},
+		{5 << 30, "5.0 GiB"},
+	}
+	for _, tt := range tests {
+		if got := formatSize(tt.n); got != tt.want {
+			t.Errorf("formatSize(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
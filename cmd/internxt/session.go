@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/internxt/rclone-adapter/auth"
+	"github.com/internxt/rclone-adapter/config"
+)
+
+// session is what login persists for the other commands.
+type session struct {
+	Token           string `json:"token"`
+	RootFolderID    string `json:"root_folder_id"`
+	Bucket          string `json:"bucket"`
+	Mnemonic        string `json:"mnemonic"`
+	BasicAuthHeader string `json:"basic_auth_header"`
+	PrivateKey      string `json:"private_key,omitempty"`
+}
+
+// defaultConfigDir returns the directory holding the session and state
+// files: $XDG_CONFIG_HOME/internxt or its platform equivalent.
+func defaultConfigDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ".internxt"
+	}
+	return filepath.Join(dir, "internxt")
+}
+
+func sessionPath(dir string) string { return filepath.Join(dir, "session.json") }
+
+// loadConfig builds the config for a command from the INTERNXT_*
+// environment variables, filling the account fields from the session saved
+// by login unless INTERNXT_TOKEN is set.
+func loadConfig(dir string) (*config.Config, error) {
+	cfg, err := config.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	cfg.StatePath = filepath.Join(dir, "state.json")
+	if cfg.Token != "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(sessionPath(dir))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("not logged in: run \"internxt login\" or set %s", config.EnvToken)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session file: %w", err)
+	}
+	var s session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse session file: %w", err)
+	}
+	cfg.Token = s.Token
+	cfg.TokenRefresher = auth.TokenRefresher
+	if cfg.RootFolderID == "" {
+		cfg.RootFolderID = s.RootFolderID
+	}
+	if cfg.Bucket == "" {
+		cfg.Bucket = s.Bucket
+	}
+	if cfg.Mnemonic.Len() == 0 {
+		cfg.SetMnemonic(s.Mnemonic)
+	}
+	if cfg.BasicAuthHeader == "" {
+		cfg.BasicAuthHeader = s.BasicAuthHeader
+	}
+	cfg.PrivateKey = s.PrivateKey
+	return cfg, nil
+}
+
+// saveSession writes the account fields of cfg to the session file,
+// readable only by the current user.
+func saveSession(dir string, cfg *config.Config) error {
+	data, err := json.MarshalIndent(session{
+		Token:           cfg.CurrentToken(),
+		RootFolderID:    cfg.RootFolderID,
+		Bucket:          cfg.Bucket,
+		Mnemonic:        cfg.MnemonicString(),
+		BasicAuthHeader: cfg.BasicAuthHeader,
+		PrivateKey:      cfg.PrivateKey,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal session: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".session-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary session file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), sessionPath(dir)); err != nil {
+		return fmt.Errorf("failed to replace session file: %w", err)
+	}
+	return nil
+}
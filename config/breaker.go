@@ -0,0 +1,104 @@
+package config
+
+import (
+	"sync"
+	"time"
+
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
+)
+
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+// CircuitBreaker tracks consecutive failures per host. Once a host reaches
+// Threshold failures in a row its circuit opens and Allow fails fast for
+// Cooldown; after that a single request is let through, closing the circuit
+// on success or reopening it on failure.
+type CircuitBreaker struct {
+	Threshold int
+	Cooldown  time.Duration
+
+	mu    sync.Mutex
+	hosts map[string]*breakerHost
+}
+
+type breakerHost struct {
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// NewCircuitBreaker creates a CircuitBreaker; non-positive arguments use
+// DefaultBreakerThreshold and DefaultBreakerCooldown.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = DefaultBreakerThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+	return &CircuitBreaker{Threshold: threshold, Cooldown: cooldown, hosts: make(map[string]*breakerHost)}
+}
+
+// Allow returns a *errors.CircuitOpenError if requests to host should not be
+// sent. A nil breaker allows everything.
+func (b *CircuitBreaker) Allow(host string) error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	h := b.hosts[host]
+	if h == nil || h.failures < b.Threshold {
+		return nil
+	}
+	if time.Now().Before(h.openUntil) || h.probing {
+		return &sdkerrors.CircuitOpenError{Host: host, Until: h.openUntil}
+	}
+	h.probing = true
+	return nil
+}
+
+// Record reports the outcome of a request to host allowed by Allow.
+func (b *CircuitBreaker) Record(host string, failed bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.hosts == nil {
+		b.hosts = make(map[string]*breakerHost)
+	}
+	h := b.hosts[host]
+	if h == nil {
+		h = &breakerHost{}
+		b.hosts[host] = h
+	}
+	h.probing = false
+	if !failed {
+		h.failures = 0
+		return
+	}
+	h.failures++
+	if h.failures >= b.Threshold {
+		h.openUntil = time.Now().Add(b.Cooldown)
+	}
+}
+
+// Abandon releases a request allowed by Allow whose outcome is unknown, e.g.
+// because it was cancelled, without counting it either way.
+func (b *CircuitBreaker) Abandon(host string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if h := b.hosts[host]; h != nil {
+		h.probing = false
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := NewCircuitBreaker(3, 50*time.Millisecond)
+	const host = "shards.example.com"
+
+	for range 2 {
+		if err := b.Allow(host); err != nil {
+			t.Fatalf("expected closed circuit, got %v", err)
+		}
+		b.Record(host, true)
+	}
+	b.Record(host, false)
+	for range 2 {
+		b.Record(host, true)
+	}
+	if err := b.Allow(host); err != nil {
+		t.Fatalf("expected success to reset the failure count, got %v", err)
+	}
+	b.Record(host, true)
+
+	err := b.Allow(host)
+	var openErr *sdkerrors.CircuitOpenError
+	if !errors.As(err, &openErr) || !errors.Is(err, sdkerrors.ErrCircuitOpen) {
+		t.Fatalf("expected CircuitOpenError after 3 failures, got %v", err)
+	}
+	if openErr.Host != host || openErr.RetryAfter() <= 0 {
+		t.Errorf("unexpected error details: %+v", openErr)
+	}
+	if err := b.Allow("other.example.com"); err != nil {
+		t.Errorf("expected other hosts to be unaffected, got %v", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err := b.Allow(host); err != nil {
+		t.Fatalf("expected a probe after cooldown, got %v", err)
+	}
+	if err := b.Allow(host); err == nil {
+		t.Fatal("expected only one concurrent probe")
+	}
+	b.Record(host, true)
+	if err := b.Allow(host); err == nil {
+		t.Fatal("expected failed probe to reopen the circuit")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err := b.Allow(host); err != nil {
+		t.Fatalf("expected a probe after cooldown, got %v", err)
+	}
+	b.Record(host, false)
+	if err := b.Allow(host); err != nil {
+		t.Errorf("expected successful probe to close the circuit, got %v", err)
+	}
+
+	var nilBreaker *CircuitBreaker
+	if err := nilBreaker.Allow(host); err != nil {
+		t.Errorf("expected nil breaker to allow, got %v", err)
+	}
+	nilBreaker.Record(host, true)
+}
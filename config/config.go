@@ -1,11 +1,16 @@
 package config
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
+	"net/url"
 	"time"
 
+	"github.com/internxt/rclone-adapter/crypto"
 	"github.com/internxt/rclone-adapter/endpoints"
 )
 
@@ -14,20 +19,57 @@ const (
 	DefaultMultipartMinSize = 100 * 1024 * 1024
 	DefaultMaxConcurrency   = 6
 	MaxThumbnailSourceSize  = 50 * 1024 * 1024
+	MaxMultipartParts       = 10000
+	MinChunkSize            = 5 * 1024 * 1024        // Smallest part but the last the storage accepts
+	MaxChunkSize            = 5 * 1024 * 1024 * 1024 // Largest part the storage accepts
 	ClientName              = "rclone-adapter"
+	ClientVersion           = "v1.0.436"
 )
 
 type Config struct {
-	Token              string            `json:"token,omitempty"`
-	RootFolderID       string            `json:"root_folder_id,omitempty"`
-	Bucket             string            `json:"bucket,omitempty"`
-	Mnemonic           string            `json:"mnemonic,omitempty"`
-	BasicAuthHeader    string            `json:"basic_auth_header,omitempty"`
-	HTTPClient         *http.Client      `json:"-"` // Centralized HTTP client with proper timeouts
-	Endpoints          *endpoints.Config `json:"-"` // Centralized API endpoint management
-	SkipHashValidation bool              `json:"skip_hash_validation,omitempty"`
+	Token                string            `json:"token,omitempty"`
+	RootFolderID         string            `json:"root_folder_id,omitempty"`
+	Bucket               string            `json:"bucket,omitempty"`
+	Mnemonic             *SecureBytes      `json:"mnemonic,omitempty"` // Derives the file keys, see SetMnemonic and MnemonicString
+	BasicAuthHeader      string            `json:"basic_auth_header,omitempty"`
+	HTTPClient           *http.Client      `json:"-"` // Centralized HTTP client with proper timeouts
+	Endpoints            *endpoints.Config `json:"-"` // Centralized API endpoint management
+	SkipHashValidation   bool              `json:"skip_hash_validation,omitempty"`
+	ChunkSize            int64             `json:"chunk_size,omitempty"`             // Multipart part size in bytes (default DefaultChunkSize)
+	MinChunkSize         int64             `json:"min_chunk_size,omitempty"`         // Smallest part size NewChunkUploadSession accepts for multipart uploads (default MinChunkSize)
+	MaxConcurrency       int               `json:"max_concurrency,omitempty"`        // Parallel part uploads per file (default DefaultMaxConcurrency)
+	AdaptiveConcurrency  bool              `json:"adaptive_concurrency,omitempty"`   // Tune parallel part uploads between 1 and MaxConcurrency from their throughput and errors
+	MultipartMinSize     int64             `json:"multipart_min_size,omitempty"`     // Files this large or larger use multipart upload (default DefaultMultipartMinSize)
+	MultipartTimeout     time.Duration     `json:"multipart_timeout,omitempty"`      // Deadline for a whole multipart upload, from start to finish (0 = none)
+	MultipartRetryBudget int               `json:"multipart_retry_budget,omitempty"` // Retries shared by all parts of a multipart upload, on top of RetryPolicy's per-part limit (0 = unlimited)
+	VerifyPartETags      bool              `json:"verify_part_etags,omitempty"`      // Fail a multipart part whose ETag is not the MD5 of its encrypted bytes; only for storage that returns MD5 ETags
+	ReplayBufferSize     int64             `json:"replay_buffer_size,omitempty"`     // Keep the encrypted data of single-part uploads, this many bytes in memory and the rest in a temp file, to retry failed transfers (0 = stream once)
+	MaxUploadSize        int64             `json:"max_upload_size,omitempty"`        // Largest file UploadFileStreamAuto accepts, see users.ApplyUploadLimits (0 = no limit)
+	QuotaCheck           QuotaCheckFunc    `json:"-"`                                // Called with the size of each upload before any data is transferred, see users.ApplyQuotaCheck (nil = no check)
+	EncryptVersion       string            `json:"encrypt_version,omitempty"`        // Scheme of new uploads, "03-aes", "04-aes-gcm" where the backend accepts it, or one added by crypto.RegisterCipher (empty = "03-aes")
+	KeyDeriver           crypto.KeyDeriver `json:"-"`                                // Derives file keys (nil = crypto.DefaultKeyDeriver, from Mnemonic)
+	ClientName           string            `json:"client_name,omitempty"`            // Sent as internxt-client (default ClientName)
+	ClientVersion        string            `json:"client_version,omitempty"`         // Sent as internxt-version unless a request sets its own (default ClientVersion)
+	ProxyURL             string            `json:"proxy_url,omitempty"`              // http(s):// or socks5:// proxy for the default HTTPClient; empty uses HTTP(S)_PROXY/NO_PROXY
+	WorkspaceID          string            `json:"workspace_id,omitempty"`           // Scopes Drive requests to a workspace, see AuthorizeRequest
+	ResourcesToken       string            `json:"-"`                                // Grants Drive requests access to items shared with the account, see AuthorizeRequest
+	PrivateKey           string            `json:"private_key,omitempty"`            // Armored OpenPGP private key, unwraps the keys of items shared with the account
+	TokenRefresher       TokenRefreshFunc  `json:"-"`                                // Called by the default HTTPClient to renew an expired token
+	TokenRefreshLeeway   time.Duration     `json:"-"`                                // Renew the token this long before its JWT expiry (0 = only on 401)
+	CredentialStore      CredentialStore   `json:"-"`                                // Where refreshed tokens are persisted, see LoadCredentials/SaveCredentials
+	RetryPolicy          *RetryPolicy      `json:"retry_policy,omitempty"`           // Retries for part uploads, shard downloads and metadata calls (nil = DefaultRetryPolicy)
+	RetryRequests        bool              `json:"retry_requests,omitempty"`         // Also retry every idempotent request of the default HTTPClient on transient failures
+	PollConsistency      bool              `json:"poll_consistency,omitempty"`       // Confirm new files and folders are visible by polling instead of waiting a fixed window
+	ListingCache         *ListingCache     `json:"-"`                                // Optional cache of folder listings, invalidated by mutations made through the SDK
+	StatePath            string            `json:"state_path,omitempty"`             // File where LoadState/SaveState persist the consistency gate and ListingCache between runs
+	CircuitBreaker       *CircuitBreaker   `json:"-"`                                // Fails shard transfers fast while their host keeps failing (default NewCircuitBreaker(0, 0))
+	Logger               *slog.Logger      `json:"-"`                                // Debug and warning output from all packages; nil discards it
 }
 
+// QuotaCheckFunc reports whether an upload of size plaintext bytes fits in
+// the account's storage, failing with errors.ErrQuotaExceeded if not.
+type QuotaCheckFunc func(ctx context.Context, size int64) error
+
 func NewDefaultToken(token string) *Config {
 	cfg := &Config{
 		Token: token,
@@ -40,28 +82,69 @@ func NewDefaultToken(token string) *Config {
 // This is useful for test configurations to ensure they have properly configured HTTPClient with custom transport.
 func (c *Config) ApplyDefaults() {
 	if c.HTTPClient == nil {
-		c.HTTPClient = newHTTPClient()
+		proxy := http.ProxyFromEnvironment
+		if c.ProxyURL != "" {
+			proxy = fixedProxy(c.ProxyURL)
+		}
+		c.HTTPClient = newHTTPClient(proxy)
+		c.HTTPClient.Transport = &configTransport{cfg: c, base: c.HTTPClient.Transport}
 	}
 	if c.Endpoints == nil {
 		c.Endpoints = endpoints.Default()
 	}
+	if c.CircuitBreaker == nil {
+		c.CircuitBreaker = NewCircuitBreaker(0, 0)
+	}
+	if c.ClientName == "" {
+		c.ClientName = ClientName
+	}
+	if c.ClientVersion == "" {
+		c.ClientVersion = ClientVersion
+	}
+	if c.ChunkSize <= 0 {
+		c.ChunkSize = DefaultChunkSize
+	}
+	if c.MaxConcurrency <= 0 {
+		c.MaxConcurrency = DefaultMaxConcurrency
+	}
+	if c.MultipartMinSize <= 0 {
+		c.MultipartMinSize = DefaultMultipartMinSize
+	}
+}
+
+// Clone returns a shallow copy of c. When c uses the default HTTP client, the
+// copy gets its own client sharing the same connection pool, so that token
+// refreshes update the copy rather than the original.
+func (c *Config) Clone() *Config {
+	out := *c
+	out.Token = c.CurrentToken()
+	if c.Mnemonic != nil {
+		out.Mnemonic = NewSecureBytes(c.Mnemonic.Bytes()) // Wiping either config leaves the other usable
+	}
+	if c.HTTPClient != nil {
+		if rt, ok := c.HTTPClient.Transport.(*configTransport); ok && rt.cfg == c {
+			client := *c.HTTPClient
+			client.Transport = &configTransport{cfg: &out, base: rt.base}
+			out.HTTPClient = &client
+		}
+	}
+	return &out
 }
 
-// clientHeaderTransport wraps http.RoundTripper to automatically add the internxt-client header
-type clientHeaderTransport struct {
+// securityTransport wraps http.RoundTripper to refuse sending Basic Auth
+// credentials over plain HTTP
+type securityTransport struct {
 	base http.RoundTripper
 }
 
-func (t *clientHeaderTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+func (t *securityTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 	if err := t.validateSecurity(req); err != nil {
 		return nil, err
 	}
-
-	req.Header.Set("internxt-client", ClientName)
 	return t.base.RoundTrip(req)
 }
 
-func (t *clientHeaderTransport) validateSecurity(req *http.Request) error {
+func (t *securityTransport) validateSecurity(req *http.Request) error {
 	_, _, isBasic := req.BasicAuth()
 	if !isBasic {
 		return nil
@@ -78,8 +161,29 @@ func isLoopback(host string) bool {
 	return host == "localhost" || host == "127.0.0.1" || host == "::1"
 }
 
-// newHTTPClient: properly configured HTTP client with sensible timeouts
-func newHTTPClient() *http.Client {
+// fixedProxy routes every request through proxyURL. Supported schemes are
+// http, https, socks5 and socks5h. An invalid URL makes requests fail with
+// the parse error rather than silently bypassing the proxy.
+func fixedProxy(proxyURL string) func(*http.Request) (*url.URL, error) {
+	u, err := url.Parse(proxyURL)
+	if err == nil {
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			err = fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+		}
+	}
+	return func(*http.Request) (*url.URL, error) {
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		return u, nil
+	}
+}
+
+// newHTTPClient: properly configured HTTP client with sensible timeouts,
+// sending requests through the given proxy function
+func newHTTPClient(proxy func(*http.Request) (*url.URL, error)) *http.Client {
 	baseTransport := &http.Transport{
 		MaxIdleConns:        100,
 		MaxIdleConnsPerHost: 10,
@@ -92,6 +196,7 @@ func newHTTPClient() *http.Client {
 		TLSHandshakeTimeout:   10 * time.Second,
 		ResponseHeaderTimeout: 20 * time.Second,
 		ExpectContinueTimeout: 1 * time.Second,
+		Proxy:                 proxy,
 		DisableKeepAlives:     false,
 		DisableCompression:    false,
 		ForceAttemptHTTP2:     true,
@@ -99,6 +204,6 @@ func newHTTPClient() *http.Client {
 
 	return &http.Client{
 		Timeout:   5 * time.Minute,
-		Transport: &clientHeaderTransport{base: baseTransport},
+		Transport: &securityTransport{base: baseTransport},
 	}
 }
@@ -1,7 +1,12 @@
 package config
 
 import (
+	"bytes"
+	"context"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -35,6 +40,24 @@ func TestApplyDefaults(t *testing.T) {
 		if cfg.Endpoints == nil {
 			t.Error("expected Endpoints to be initialized, got nil")
 		}
+		if cfg.ChunkSize != DefaultChunkSize {
+			t.Errorf("expected ChunkSize %d, got %d", DefaultChunkSize, cfg.ChunkSize)
+		}
+		if cfg.MaxConcurrency != DefaultMaxConcurrency {
+			t.Errorf("expected MaxConcurrency %d, got %d", DefaultMaxConcurrency, cfg.MaxConcurrency)
+		}
+		if cfg.MultipartMinSize != DefaultMultipartMinSize {
+			t.Errorf("expected MultipartMinSize %d, got %d", DefaultMultipartMinSize, cfg.MultipartMinSize)
+		}
+	})
+
+	t.Run("preserves transfer tuning", func(t *testing.T) {
+		cfg := &Config{ChunkSize: 1024, MaxConcurrency: 2, MultipartMinSize: 4096}
+		cfg.ApplyDefaults()
+
+		if cfg.ChunkSize != 1024 || cfg.MaxConcurrency != 2 || cfg.MultipartMinSize != 4096 {
+			t.Errorf("expected custom values to be preserved, got %d/%d/%d", cfg.ChunkSize, cfg.MaxConcurrency, cfg.MultipartMinSize)
+		}
 	})
 
 	t.Run("preserves existing values", func(t *testing.T) {
@@ -57,7 +80,7 @@ func TestApplyDefaults(t *testing.T) {
 }
 
 func TestNewHTTPClient(t *testing.T) {
-	client := newHTTPClient()
+	client := newHTTPClient(http.ProxyFromEnvironment)
 
 	if client == nil {
 		t.Fatal("expected HTTPClient to be created, got nil")
@@ -71,10 +94,10 @@ func TestNewHTTPClient(t *testing.T) {
 		t.Fatal("expected Transport to be set, got nil")
 	}
 
-	// Transport is wrapped in clientHeaderTransport, so unwrap it
-	headerTransport, ok := client.Transport.(*clientHeaderTransport)
+	// Transport is wrapped in securityTransport, so unwrap it
+	headerTransport, ok := client.Transport.(*securityTransport)
 	if !ok {
-		t.Fatalf("expected Transport to be *clientHeaderTransport, got %T", client.Transport)
+		t.Fatalf("expected Transport to be *securityTransport, got %T", client.Transport)
 	}
 
 	transport, ok := headerTransport.base.(*http.Transport)
@@ -116,4 +139,52 @@ func TestNewHTTPClient(t *testing.T) {
 	if transport.DialContext == nil {
 		t.Error("expected DialContext to be set, got nil")
 	}
+	if transport.Proxy == nil {
+		t.Error("expected Proxy to be set, got nil")
+	}
+}
+
+func TestProxyConfiguration(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://gateway.internxt.com/drive/files", nil)
+
+	proxyOf := func(cfg *Config) func(*http.Request) (*url.URL, error) {
+		cfg.ApplyDefaults()
+		return cfg.HTTPClient.Transport.(*configTransport).base.(*securityTransport).base.(*http.Transport).Proxy
+	}
+
+	t.Run("explicit socks5", func(t *testing.T) {
+		t.Setenv("HTTPS_PROXY", "http://env-proxy:3128")
+
+		got, err := proxyOf(&Config{ProxyURL: "socks5://127.0.0.1:1080"})(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.String() != "socks5://127.0.0.1:1080" {
+			t.Errorf("expected explicit proxy to win, got %v", got)
+		}
+	})
+
+	t.Run("unsupported scheme", func(t *testing.T) {
+		_, err := proxyOf(&Config{ProxyURL: "ftp://proxy:21"})(req)
+		if err == nil {
+			t.Error("expected error for unsupported proxy scheme")
+		}
+	})
+}
+
+func TestLog(t *testing.T) {
+	var nilCfg *Config
+	if nilCfg.Log() == nil {
+		t.Fatal("expected discard logger for nil config")
+	}
+	if (&Config{}).Log().Enabled(context.Background(), slog.LevelError) {
+		t.Error("expected default logger to discard output")
+	}
+
+	var buf bytes.Buffer
+	cfg := &Config{Logger: slog.New(slog.NewTextHandler(&buf, nil))}
+	cfg.Log().Warn("hello", "k", "v")
+	if !strings.Contains(buf.String(), "msg=hello k=v") {
+		t.Errorf("expected configured logger to be used, got %q", buf.String())
+	}
 }
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Credentials are the secrets a Config needs to talk to Internxt.
+type Credentials struct {
+	Token           string `json:"token,omitempty"`
+	Mnemonic        string `json:"mnemonic,omitempty"`
+	BasicAuthHeader string `json:"basic_auth_header,omitempty"`
+}
+
+// CredentialStore persists Credentials between runs. Implementations must be
+// safe for concurrent use.
+type CredentialStore interface {
+	// Get returns the stored credentials, or zero Credentials if nothing was stored yet.
+	Get() (Credentials, error)
+	// Store replaces the stored credentials.
+	Store(creds Credentials) error
+}
+
+// MemoryStore is a CredentialStore that keeps credentials in memory.
+type MemoryStore struct {
+	mu    sync.Mutex
+	creds Credentials
+}
+
+func (m *MemoryStore) Get() (Credentials, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.creds, nil
+}
+
+func (m *MemoryStore) Store(creds Credentials) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.creds = creds
+	return nil
+}
+
+// FileStore is a CredentialStore backed by a JSON file readable only by the
+// current user. Writes go through a temporary file so a crash never leaves a
+// truncated file behind.
+type FileStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// NewFileStore returns a FileStore writing to path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{Path: path}
+}
+
+func (f *FileStore) Get() (Credentials, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var creds Credentials
+	data, err := os.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return creds, nil
+	}
+	if err != nil {
+		return creds, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return creds, fmt.Errorf("failed to parse credentials file: %w", err)
+	}
+	return creds, nil
+}
+
+func (f *FileStore) Store(creds Credentials) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+
+	dir := filepath.Dir(f.Path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create credentials directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".credentials-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary credentials file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.Path); err != nil {
+		return fmt.Errorf("failed to replace credentials file: %w", err)
+	}
+	return nil
+}
+
+// LoadCredentials hydrates the Config from its CredentialStore. Fields the
+// store has no value for are left unchanged.
+func (c *Config) LoadCredentials() error {
+	if c.CredentialStore == nil {
+		return nil
+	}
+	creds, err := c.CredentialStore.Get()
+	if err != nil {
+		return err
+	}
+	if creds.Token != "" {
+		c.SetToken(creds.Token)
+	}
+	if creds.Mnemonic != "" {
+		c.SetMnemonic(creds.Mnemonic)
+	}
+	if creds.BasicAuthHeader != "" {
+		c.BasicAuthHeader = creds.BasicAuthHeader
+	}
+	return nil
+}
+
+// SaveCredentials persists the Config's current secrets to its CredentialStore.
+func (c *Config) SaveCredentials() error {
+	if c.CredentialStore == nil {
+		return nil
+	}
+	return c.CredentialStore.Store(Credentials{
+		Token:           c.CurrentToken(),
+		Mnemonic:        c.MnemonicString(),
+		BasicAuthHeader: c.BasicAuthHeader,
+	})
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/internxt/rclone-adapter/endpoints"
+)
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "credentials.json")
+	store := NewFileStore(path)
+
+	creds, err := store.Get()
+	if err != nil {
+		t.Fatalf("unexpected error reading missing file: %v", err)
+	}
+	if creds != (Credentials{}) {
+		t.Errorf("expected empty credentials, got %+v", creds)
+	}
+
+	want := Credentials{Token: "token", Mnemonic: "mnemonic", BasicAuthHeader: "Basic abc"}
+	if err := store.Store(want); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected credentials file to exist: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("expected file mode 0600, got %o", perm)
+	}
+
+	got, err := NewFileStore(path).Get()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestFileStoreCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	os.WriteFile(path, []byte("not json"), 0o600)
+
+	if _, err := NewFileStore(path).Get(); err == nil {
+		t.Error("expected error for corrupt credentials file")
+	}
+}
+
+func TestLoadAndSaveCredentials(t *testing.T) {
+	store := &MemoryStore{}
+	store.Store(Credentials{Token: "stored-token", Mnemonic: "stored-mnemonic"})
+
+	cfg := &Config{BasicAuthHeader: "Basic existing", CredentialStore: store}
+	if err := cfg.LoadCredentials(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Token != "stored-token" || cfg.MnemonicString() != "stored-mnemonic" {
+		t.Errorf("expected config to be hydrated, got token=%q mnemonic=%q", cfg.Token, cfg.MnemonicString())
+	}
+	if cfg.BasicAuthHeader != "Basic existing" {
+		t.Errorf("expected unset store fields to be left alone, got %q", cfg.BasicAuthHeader)
+	}
+
+	cfg.SetToken("new-token")
+	if err := cfg.SaveCredentials(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, _ := store.Get()
+	want := Credentials{Token: "new-token", Mnemonic: "stored-mnemonic", BasicAuthHeader: "Basic existing"}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestRefreshedTokenIsPersisted(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	store := &MemoryStore{}
+	cfg := &Config{
+		Token:           "expired-token",
+		Endpoints:       endpoints.NewConfig(server.URL),
+		CredentialStore: store,
+		TokenRefresher: func(ctx context.Context, cfg *Config) (string, error) {
+			return "fresh-token", nil
+		},
+	}
+	cfg.ApplyDefaults()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Authorization", "Bearer "+cfg.CurrentToken())
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	got, _ := store.Get()
+	if got.Token != "fresh-token" {
+		t.Errorf("expected refreshed token to be persisted, got %q", got.Token)
+	}
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/internxt/rclone-adapter/endpoints"
+)
+
+// Environment variables read by FromEnv
+const (
+	EnvToken              = "INTERNXT_TOKEN"
+	EnvMnemonic           = "INTERNXT_MNEMONIC"
+	EnvBucket             = "INTERNXT_BUCKET"
+	EnvRootFolderID       = "INTERNXT_ROOT_FOLDER_ID"
+	EnvBasicAuthHeader    = "INTERNXT_BASIC_AUTH_HEADER"
+	EnvGatewayURL         = "INTERNXT_GATEWAY_URL"
+	EnvDriveURL           = "INTERNXT_DRIVE_URL"
+	EnvNetworkURL         = "INTERNXT_NETWORK_URL"
+	EnvRegion             = "INTERNXT_REGION"
+	EnvWorkspaceID        = "INTERNXT_WORKSPACE_ID"
+	EnvProxyURL           = "INTERNXT_PROXY_URL"
+	EnvSkipHashValidation = "INTERNXT_SKIP_HASH_VALIDATION"
+)
+
+// FromEnv builds a Config from INTERNXT_* environment variables and applies
+// defaults for everything that is not set. INTERNXT_GATEWAY_URL overrides the
+// production gateway, and INTERNXT_DRIVE_URL / INTERNXT_NETWORK_URL override
+// the drive and network API roots individually. INTERNXT_REGION selects the
+// network gateway of one of the Endpoints.Regions, see SelectRegion.
+func FromEnv() (*Config, error) {
+	cfg := &Config{
+		Token:           os.Getenv(EnvToken),
+		Bucket:          os.Getenv(EnvBucket),
+		RootFolderID:    os.Getenv(EnvRootFolderID),
+		BasicAuthHeader: os.Getenv(EnvBasicAuthHeader),
+		WorkspaceID:     os.Getenv(EnvWorkspaceID),
+		ProxyURL:        os.Getenv(EnvProxyURL),
+	}
+	if v := os.Getenv(EnvMnemonic); v != "" {
+		cfg.SetMnemonic(v)
+	}
+
+	if v := os.Getenv(EnvGatewayURL); v != "" {
+		cfg.Endpoints = endpoints.NewConfig(v)
+	}
+	driveURL, networkURL := os.Getenv(EnvDriveURL), os.Getenv(EnvNetworkURL)
+	if driveURL != "" || networkURL != "" {
+		if cfg.Endpoints == nil {
+			cfg.Endpoints = endpoints.Default()
+		}
+		cfg.Endpoints.DriveBaseURL = strings.TrimSuffix(driveURL, "/")
+		cfg.Endpoints.NetworkBaseURL = strings.TrimSuffix(networkURL, "/")
+	}
+	if v := os.Getenv(EnvRegion); v != "" {
+		if cfg.Endpoints == nil {
+			cfg.Endpoints = endpoints.Default()
+		}
+		cfg.Endpoints.Region = v
+	}
+
+	if v := os.Getenv(EnvSkipHashValidation); v != "" {
+		skip, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", EnvSkipHashValidation, err)
+		}
+		cfg.SkipHashValidation = skip
+	}
+
+	cfg.ApplyDefaults()
+	return cfg, nil
+}
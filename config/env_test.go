@@ -0,0 +1,109 @@
+package config
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFromEnv(t *testing.T) {
+	t.Run("reads all variables", func(t *testing.T) {
+		t.Setenv(EnvToken, "env-token")
+		t.Setenv(EnvMnemonic, "env mnemonic")
+		t.Setenv(EnvBucket, "env-bucket")
+		t.Setenv(EnvRootFolderID, "env-root")
+		t.Setenv(EnvBasicAuthHeader, "Basic env")
+		t.Setenv(EnvGatewayURL, "https://gateway.example.com/")
+		t.Setenv(EnvWorkspaceID, "env-workspace")
+		t.Setenv(EnvSkipHashValidation, "true")
+
+		cfg, err := FromEnv()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if cfg.Token != "env-token" {
+			t.Errorf("expected Token env-token, got %s", cfg.Token)
+		}
+		if cfg.MnemonicString() != "env mnemonic" {
+			t.Errorf("expected Mnemonic from env, got %s", cfg.MnemonicString())
+		}
+		if cfg.Bucket != "env-bucket" {
+			t.Errorf("expected Bucket env-bucket, got %s", cfg.Bucket)
+		}
+		if cfg.RootFolderID != "env-root" {
+			t.Errorf("expected RootFolderID env-root, got %s", cfg.RootFolderID)
+		}
+		if cfg.BasicAuthHeader != "Basic env" {
+			t.Errorf("expected BasicAuthHeader from env, got %s", cfg.BasicAuthHeader)
+		}
+		if cfg.WorkspaceID != "env-workspace" {
+			t.Errorf("expected WorkspaceID env-workspace, got %s", cfg.WorkspaceID)
+		}
+		if !cfg.SkipHashValidation {
+			t.Error("expected SkipHashValidation true")
+		}
+		if got := cfg.Endpoints.Drive().Auth().Login(); got != "https://gateway.example.com/drive/auth/login" {
+			t.Errorf("expected custom gateway, got %s", got)
+		}
+		if cfg.HTTPClient == nil {
+			t.Error("expected HTTPClient to be initialized")
+		}
+	})
+
+	t.Run("defaults when unset", func(t *testing.T) {
+		t.Setenv(EnvGatewayURL, "")
+		t.Setenv(EnvSkipHashValidation, "")
+
+		cfg, err := FromEnv()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Endpoints.BaseURL != "https://gateway.internxt.com" {
+			t.Errorf("expected default gateway, got %s", cfg.Endpoints.BaseURL)
+		}
+		if cfg.SkipHashValidation {
+			t.Error("expected SkipHashValidation false")
+		}
+	})
+
+	t.Run("per-service URLs", func(t *testing.T) {
+		t.Setenv(EnvGatewayURL, "")
+		t.Setenv(EnvDriveURL, "https://drive.example.com/api/")
+		t.Setenv(EnvNetworkURL, "https://bridge.example.com")
+
+		cfg, err := FromEnv()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got := cfg.Endpoints.Drive().Auth().Login(); got != "https://drive.example.com/api/auth/login" {
+			t.Errorf("expected drive override, got %s", got)
+		}
+		if got := cfg.Endpoints.Network().StartUpload("b"); got != "https://bridge.example.com/v2/buckets/b/files/start" {
+			t.Errorf("expected network override, got %s", got)
+		}
+	})
+
+	t.Run("region", func(t *testing.T) {
+		t.Setenv(EnvGatewayURL, "")
+		t.Setenv(EnvRegion, "eu")
+
+		cfg, err := FromEnv()
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Endpoints.Region != "eu" {
+			t.Errorf("expected region eu, got %q", cfg.Endpoints.Region)
+		}
+		if region, err := cfg.SelectRegion(context.Background(), "eu", "us"); err != nil || region != "eu" {
+			t.Errorf("expected the region from the environment to be kept, got %q, %v", region, err)
+		}
+	})
+
+	t.Run("invalid bool", func(t *testing.T) {
+		t.Setenv(EnvSkipHashValidation, "maybe")
+
+		if _, err := FromEnv(); err == nil {
+			t.Error("expected error for invalid boolean")
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+// makeJWT builds an unsigned JWT carrying the given claims JSON.
+func makeJWT(claims string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + enc.EncodeToString([]byte(claims)) + ".sig"
+}
+
+func TestTokenExpiry(t *testing.T) {
+	testCases := []struct {
+		name        string
+		token       string
+		want        time.Time
+		expectError bool
+	}{
+		{
+			name:  "integer exp",
+			token: makeJWT(`{"exp":1700000000}`),
+			want:  time.Unix(1700000000, 0),
+		},
+		{
+			name:  "fractional exp",
+			token: makeJWT(`{"exp":1700000000.5,"email":"user@example.com"}`),
+			want:  time.Unix(1700000000, 0),
+		},
+		{
+			name:        "missing exp",
+			token:       makeJWT(`{"email":"user@example.com"}`),
+			expectError: true,
+		},
+		{
+			name:        "not a JWT",
+			token:       "opaque-token",
+			expectError: true,
+		},
+		{
+			name:        "invalid payload",
+			token:       "a.!!!.c",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := TokenExpiry(tc.token)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equal(tc.want) {
+				t.Errorf("TokenExpiry() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultListingCacheTTL is used by NewListingCache for non-positive TTLs.
+const DefaultListingCacheTTL = time.Minute
+
+// DefaultNegativeLookupTTL is how long NewListingCache remembers names that
+// were not found.
+const DefaultNegativeLookupTTL = 5 * time.Second
+
+// ListingCache keeps folder listings in memory for TTL. Mutations made
+// through this SDK invalidate the affected listings: the folder an item was
+// listed in (InvalidateItem) or the folder whose contents changed
+// (InvalidateFolder). Changes made by other clients are only picked up once
+// the TTL expires. It also remembers names recently looked up and not found
+// for NegativeTTL (see PutMissing), dropped together with the folder's
+// listings. All methods are safe on a nil cache, which caches nothing.
+type ListingCache struct {
+	TTL         time.Duration
+	NegativeTTL time.Duration
+
+	mu       sync.Mutex
+	folders  map[string]map[string]listingEntry // folder UUID -> listing key -> entry
+	parentOf map[string]string                  // item UUID -> folder UUID it was listed in
+	missing  map[string]map[string]time.Time    // folder UUID -> name -> expiry
+}
+
+type listingEntry struct {
+	value   any
+	expires time.Time
+}
+
+// NewListingCache creates a ListingCache with the given TTL.
+func NewListingCache(ttl time.Duration) *ListingCache {
+	if ttl <= 0 {
+		ttl = DefaultListingCacheTTL
+	}
+	return &ListingCache{TTL: ttl, NegativeTTL: DefaultNegativeLookupTTL}
+}
+
+// Get returns the listing of folderUUID stored under key, if not expired.
+func (c *ListingCache) Get(folderUUID, key string) (any, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.folders[folderUUID][key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(e.expires) {
+		delete(c.folders[folderUUID], key)
+		return nil, false
+	}
+	return e.value, true
+}
+
+// Put stores a listing of folderUUID under key. children are the UUIDs of
+// the listed items, used to find the listing when one of them changes.
+func (c *ListingCache) Put(folderUUID, key string, value any, children []string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.folders == nil {
+		c.folders = make(map[string]map[string]listingEntry)
+		c.parentOf = make(map[string]string)
+	}
+	if c.folders[folderUUID] == nil {
+		c.folders[folderUUID] = make(map[string]listingEntry)
+	}
+	c.folders[folderUUID][key] = listingEntry{value: value, expires: time.Now().Add(c.TTL)}
+	for _, child := range children {
+		c.parentOf[child] = folderUUID
+	}
+}
+
+// InvalidateFolder drops every cached listing of folderUUID.
+func (c *ListingCache) InvalidateFolder(folderUUID string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.folders, folderUUID)
+	delete(c.missing, folderUUID)
+}
+
+// InvalidateItem drops the listings that contain the item and, if the item
+// is a folder, its own listings.
+func (c *ListingCache) InvalidateItem(uuid string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if parent, ok := c.parentOf[uuid]; ok {
+		delete(c.folders, parent)
+		delete(c.missing, parent)
+		delete(c.parentOf, uuid)
+	}
+	delete(c.folders, uuid)
+	delete(c.missing, uuid)
+}
+
+// PutMissing records that folderUUID has no item called name.
+func (c *ListingCache) PutMissing(folderUUID, name string) {
+	if c == nil || c.NegativeTTL <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.missing == nil {
+		c.missing = make(map[string]map[string]time.Time)
+	}
+	if c.missing[folderUUID] == nil {
+		c.missing[folderUUID] = make(map[string]time.Time)
+	}
+	c.missing[folderUUID][name] = time.Now().Add(c.NegativeTTL)
+}
+
+// IsMissing reports whether name was recently recorded as missing from
+// folderUUID by PutMissing.
+func (c *ListingCache) IsMissing(folderUUID, name string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires, ok := c.missing[folderUUID][name]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expires) {
+		delete(c.missing[folderUUID], name)
+		return false
+	}
+	return true
+}
+
+// listingCacheState is the persisted form of a ListingCache.
+type listingCacheState struct {
+	Listings map[string]map[string]listingStateEntry `json:"listings,omitempty"`
+	ParentOf map[string]string                       `json:"parent_of,omitempty"`
+	Missing  map[string]map[string]time.Time         `json:"missing,omitempty"`
+}
+
+type listingStateEntry struct {
+	Value   json.RawMessage `json:"value"`
+	Expires time.Time       `json:"expires"`
+}
+
+// MarshalJSON encodes the unexpired entries of the cache. Listings are
+// restored by UnmarshalJSON as json.RawMessage values.
+func (c *ListingCache) MarshalJSON() ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	state := listingCacheState{
+		Listings: make(map[string]map[string]listingStateEntry),
+		ParentOf: c.parentOf,
+		Missing:  make(map[string]map[string]time.Time),
+	}
+	for folder, listings := range c.folders {
+		for key, e := range listings {
+			if now.After(e.expires) {
+				continue
+			}
+			value, err := json.Marshal(e.value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal listing of %s: %w", folder, err)
+			}
+			if state.Listings[folder] == nil {
+				state.Listings[folder] = make(map[string]listingStateEntry)
+			}
+			state.Listings[folder][key] = listingStateEntry{Value: value, Expires: e.expires}
+		}
+	}
+	for folder, names := range c.missing {
+		for name, expires := range names {
+			if now.After(expires) {
+				continue
+			}
+			if state.Missing[folder] == nil {
+				state.Missing[folder] = make(map[string]time.Time)
+			}
+			state.Missing[folder][name] = expires
+		}
+	}
+	return json.Marshal(state)
+}
+
+// UnmarshalJSON replaces the cache contents with entries encoded by
+// MarshalJSON, keeping their original expiry.
+func (c *ListingCache) UnmarshalJSON(data []byte) error {
+	var state listingCacheState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.folders = make(map[string]map[string]listingEntry)
+	for folder, listings := range state.Listings {
+		c.folders[folder] = make(map[string]listingEntry)
+		for key, e := range listings {
+			c.folders[folder][key] = listingEntry{value: e.Value, expires: e.Expires}
+		}
+	}
+	c.parentOf = state.ParentOf
+	if c.parentOf == nil {
+		c.parentOf = make(map[string]string)
+	}
+	c.missing = state.Missing
+	return nil
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListingCache(t *testing.T) {
+	c := NewListingCache(time.Hour)
+	c.Put("parent", "folders", "listing", []string{"child-1", "child-2"})
+	c.Put("child-1", "files", "child listing", nil)
+
+	if v, ok := c.Get("parent", "folders"); !ok || v != "listing" {
+		t.Fatalf("expected cached listing, got %v, %v", v, ok)
+	}
+	if _, ok := c.Get("parent", "files"); ok {
+		t.Error("expected miss for another key")
+	}
+
+	c.InvalidateItem("child-1")
+	if _, ok := c.Get("parent", "folders"); ok {
+		t.Error("expected InvalidateItem to drop the parent's listing")
+	}
+	if _, ok := c.Get("child-1", "files"); ok {
+		t.Error("expected InvalidateItem to drop the item's own listing")
+	}
+
+	c.Put("parent", "folders", "listing", nil)
+	c.InvalidateFolder("parent")
+	if _, ok := c.Get("parent", "folders"); ok {
+		t.Error("expected InvalidateFolder to drop the listing")
+	}
+
+	expiring := NewListingCache(10 * time.Millisecond)
+	expiring.Put("parent", "folders", "listing", nil)
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := expiring.Get("parent", "folders"); ok {
+		t.Error("expected listing to expire after the TTL")
+	}
+
+	var nilCache *ListingCache
+	nilCache.Put("parent", "folders", "listing", []string{"child"})
+	nilCache.InvalidateItem("child")
+	nilCache.InvalidateFolder("parent")
+	if _, ok := nilCache.Get("parent", "folders"); ok {
+		t.Error("expected nil cache to cache nothing")
+	}
+}
+
+func TestListingCacheMissing(t *testing.T) {
+	c := NewListingCache(time.Hour)
+	c.PutMissing("parent", "a.txt")
+	if !c.IsMissing("parent", "a.txt") || c.IsMissing("parent", "b.txt") {
+		t.Fatal("expected only a.txt to be recorded as missing")
+	}
+
+	c.InvalidateFolder("parent")
+	if c.IsMissing("parent", "a.txt") {
+		t.Error("expected InvalidateFolder to drop missing names")
+	}
+
+	c.Put("parent", "files", "listing", []string{"child"})
+	c.PutMissing("parent", "a.txt")
+	c.InvalidateItem("child")
+	if c.IsMissing("parent", "a.txt") {
+		t.Error("expected InvalidateItem to drop missing names of the parent")
+	}
+
+	c.NegativeTTL = 10 * time.Millisecond
+	c.PutMissing("parent", "a.txt")
+	time.Sleep(20 * time.Millisecond)
+	if c.IsMissing("parent", "a.txt") {
+		t.Error("expected missing name to expire after NegativeTTL")
+	}
+}
@@ -0,0 +1,16 @@
+package config
+
+import "log/slog"
+
+// discardLogger is used when no Logger is configured, so library consumers
+// get no output unless they opt in.
+var discardLogger = slog.New(slog.DiscardHandler)
+
+// Log returns the configured Logger, or a logger that discards everything.
+// Safe to call on a nil Config.
+func (c *Config) Log() *slog.Logger {
+	if c == nil || c.Logger == nil {
+		return discardLogger
+	}
+	return c.Logger
+}
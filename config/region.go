@@ -0,0 +1,109 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// regionProbeTimeout bounds how long a single region is probed.
+const regionProbeTimeout = 5 * time.Second
+
+// SelectRegion selects the network gateway that shard transfers of c.Bucket
+// go through and returns its region name. regions are the regions known to
+// serve the bucket, from its settings or the user's, and must be configured
+// in Endpoints.Regions. A region already set in Endpoints.Region, such as
+// from INTERNXT_REGION, is kept. Otherwise the only region given is selected,
+// and of several, the one whose gateway answers fastest: latency only breaks
+// ties, it never moves the bucket to a region it is not known to be served
+// from. Any HTTP response counts as reachable.
+//
+// The selection replaces c.Endpoints with a copy, leaving configs that share
+// the old one untouched. Like the other fields of c, it must not be changed
+// while c is in use, so call SelectRegion before making requests.
+func (c *Config) SelectRegion(ctx context.Context, regions ...string) (string, error) {
+	if c.Endpoints == nil || len(c.Endpoints.Regions) == 0 {
+		return "", errors.New("no regions configured")
+	}
+	if region := c.Endpoints.Region; region != "" {
+		if _, ok := c.Endpoints.Regions[region]; !ok {
+			return "", fmt.Errorf("region %q is not configured", region)
+		}
+		return region, nil
+	}
+	if len(regions) == 0 {
+		return "", errors.New("no region known to serve the bucket")
+	}
+
+	names := slices.Clone(regions)
+	sort.Strings(names)
+	names = slices.Compact(names)
+	for _, name := range names {
+		if _, ok := c.Endpoints.Regions[name]; !ok {
+			return "", fmt.Errorf("region %q is not configured", name)
+		}
+	}
+
+	best := 0
+	if len(names) > 1 {
+		rtts := make([]time.Duration, len(names))
+		var wg sync.WaitGroup
+		for i, name := range names {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rtts[i] = c.probeRegion(ctx, c.Endpoints.Regions[name])
+			}()
+		}
+		wg.Wait()
+
+		best = -1
+		for i, rtt := range rtts {
+			if rtt >= 0 && (best < 0 || rtt < rtts[best]) {
+				best = i
+			}
+		}
+		if best < 0 {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+			return "", fmt.Errorf("no region reachable out of %d", len(names))
+		}
+	}
+
+	selected := *c.Endpoints
+	selected.Regions = maps.Clone(c.Endpoints.Regions)
+	selected.Region = names[best]
+	c.Endpoints = &selected
+	return names[best], nil
+}
+
+// probeRegion returns the round-trip time of a HEAD request to baseURL, or
+// -1 if the gateway could not be reached.
+func (c *Config) probeRegion(ctx context.Context, baseURL string) time.Duration {
+	ctx, cancel := context.WithTimeout(ctx, regionProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, baseURL, nil)
+	if err != nil {
+		return -1
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return -1
+	}
+	resp.Body.Close()
+	return time.Since(start)
+}
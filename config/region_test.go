@@ -0,0 +1,99 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/internxt/rclone-adapter/endpoints"
+)
+
+func TestSelectRegion(t *testing.T) {
+	var probes atomic.Int32
+	newGateway := func(delay time.Duration) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			probes.Add(1)
+			time.Sleep(delay)
+			w.WriteHeader(http.StatusNotFound)
+		}))
+	}
+
+	fast := newGateway(0)
+	defer fast.Close()
+	slow := newGateway(200 * time.Millisecond)
+	defer slow.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	regions := map[string]string{"eu": slow.URL, "us": fast.URL, "ap": down.URL}
+
+	t.Run("breaks ties between the bucket's regions by latency", func(t *testing.T) {
+		cfg := &Config{Endpoints: &endpoints.Config{BaseURL: "https://gateway.example.com", Regions: regions}}
+		cfg.ApplyDefaults()
+		shared := cfg.Clone()
+
+		region, err := cfg.SelectRegion(context.Background(), "eu", "us", "ap")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if region != "us" || cfg.Endpoints.Region != "us" {
+			t.Errorf("expected region us, got %s (config %s)", region, cfg.Endpoints.Region)
+		}
+		if shared.Endpoints.Region != "" {
+			t.Errorf("expected a clone sharing the endpoints to keep no region, got %s", shared.Endpoints.Region)
+		}
+		if got := cfg.Endpoints.Network().StartUpload("b"); got != fast.URL+"/v2/buckets/b/files/start" {
+			t.Errorf("expected network endpoints to use the selected region, got %s", got)
+		}
+	})
+
+	t.Run("keeps the bucket in its only region", func(t *testing.T) {
+		cfg := &Config{Endpoints: &endpoints.Config{Regions: regions}}
+		cfg.ApplyDefaults()
+		probes.Store(0)
+
+		if region, err := cfg.SelectRegion(context.Background(), "eu"); err != nil || region != "eu" {
+			t.Errorf("expected region eu, got %q, %v", region, err)
+		}
+		if probes.Load() != 0 {
+			t.Errorf("expected no probes for a single region, got %d", probes.Load())
+		}
+	})
+
+	t.Run("keeps a region set by the user", func(t *testing.T) {
+		cfg := &Config{Endpoints: &endpoints.Config{Region: "eu", Regions: regions}}
+		cfg.ApplyDefaults()
+		if region, err := cfg.SelectRegion(context.Background(), "eu", "us"); err != nil || region != "eu" {
+			t.Errorf("expected region eu, got %q, %v", region, err)
+		}
+	})
+
+	t.Run("rejects unknown regions", func(t *testing.T) {
+		cfg := &Config{Endpoints: &endpoints.Config{Regions: regions}}
+		cfg.ApplyDefaults()
+		if _, err := cfg.SelectRegion(context.Background(), "us", "sa"); err == nil {
+			t.Error("expected error for a region that is not configured")
+		}
+		if _, err := cfg.SelectRegion(context.Background()); err == nil {
+			t.Error("expected error without the bucket's regions")
+		}
+	})
+
+	t.Run("no regions configured", func(t *testing.T) {
+		cfg := &Config{Endpoints: endpoints.NewConfig("https://gateway.example.com")}
+		cfg.ApplyDefaults()
+		if _, err := cfg.SelectRegion(context.Background(), "eu"); err == nil {
+			t.Error("expected error without regions")
+		}
+	})
+
+	t.Run("all regions unreachable", func(t *testing.T) {
+		cfg := &Config{Endpoints: &endpoints.Config{Regions: map[string]string{"ap": down.URL, "sa": down.URL}}}
+		cfg.ApplyDefaults()
+		if _, err := cfg.SelectRegion(context.Background(), "ap", "sa"); err == nil {
+			t.Error("expected error when no region is reachable")
+		}
+	})
+}
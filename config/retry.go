@@ -0,0 +1,91 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how transient failures are retried by multipart part
+// uploads, shard downloads and metadata calls.
+type RetryPolicy struct {
+	MaxRetries int           `json:"max_retries"` // Attempts after the first one; 0 disables retries
+	BaseDelay  time.Duration `json:"base_delay"`  // Delay before the first retry, doubled on each subsequent one
+	MaxDelay   time.Duration `json:"max_delay"`   // Upper bound for a single delay (0 = unbounded)
+	Jitter     float64       `json:"jitter"`      // Fraction of each delay that is randomized, between 0 and 1
+}
+
+// DefaultRetryPolicy is used when Config.RetryPolicy is nil.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: 2,
+		BaseDelay:  1 * time.Second,
+		MaxDelay:   30 * time.Second,
+		Jitter:     0.2,
+	}
+}
+
+// Retry returns the effective retry policy for c.
+func (c *Config) Retry() RetryPolicy {
+	if c == nil || c.RetryPolicy == nil {
+		return DefaultRetryPolicy()
+	}
+	return *c.RetryPolicy
+}
+
+// Attempts returns the total number of attempts, including the first one.
+func (p RetryPolicy) Attempts() int {
+	return max(p.MaxRetries, 0) + 1
+}
+
+// Backoff returns the delay before the given retry (1 for the first retry).
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	if retry < 1 || p.BaseDelay <= 0 {
+		return 0
+	}
+	delay := p.BaseDelay
+	for i := 1; i < retry && (p.MaxDelay <= 0 || delay < p.MaxDelay) && delay <= math.MaxInt64/2; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if jitter := min(max(p.Jitter, 0), 1); jitter > 0 {
+		spread := time.Duration(float64(delay) * jitter)
+		delay += time.Duration(rand.Int64N(int64(2*spread)+1)) - spread
+	}
+	return delay
+}
+
+// Do calls fn until it succeeds, returns an error that retryable rejects, the
+// policy is exhausted or ctx is done, and returns the last error. Errors that
+// carry a RetryAfter hint (such as errors.HTTPError), also when wrapped, wait
+// at least that long.
+func (p RetryPolicy) Do(ctx context.Context, retryable func(error) bool, fn func() error) error {
+	var err error
+	for attempt := range p.Attempts() {
+		if attempt > 0 {
+			delay := p.Backoff(attempt)
+			var ra interface{ RetryAfter() time.Duration }
+			if errors.As(err, &ra) {
+				delay = max(delay, ra.RetryAfter())
+			}
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+		} else if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		if err = fn(); err == nil || !retryable(err) {
+			return err
+		}
+	}
+	return err
+}
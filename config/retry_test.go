@@ -0,0 +1,123 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	tests := []struct {
+		retry int
+		want  time.Duration
+	}{
+		{0, 0},
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{100, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := p.Backoff(tt.retry); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.retry, got, tt.want)
+		}
+	}
+
+	p.Jitter = 0.5
+	for range 100 {
+		if got := p.Backoff(2); got < time.Second || got > 3*time.Second {
+			t.Fatalf("jittered Backoff(2) = %v, want within [1s, 3s]", got)
+		}
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	errTransient := errors.New("transient")
+	errFatal := errors.New("fatal")
+	retryable := func(err error) bool { return errors.Is(err, errTransient) }
+	fast := RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond}
+
+	tests := []struct {
+		name      string
+		policy    RetryPolicy
+		results   []error
+		wantErr   error
+		wantCalls int
+	}{
+		{"success first try", fast, []error{nil}, nil, 1},
+		{"success after retries", fast, []error{errTransient, errTransient, nil}, nil, 3},
+		{"non-retryable stops", fast, []error{errFatal}, errFatal, 1},
+		{"exhausted", fast, []error{errTransient, errTransient, errTransient, errTransient}, errTransient, 4},
+		{"retries disabled", RetryPolicy{}, []error{errTransient}, errTransient, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := tt.policy.Do(context.Background(), retryable, func() error {
+				err := tt.results[calls]
+				calls++
+				return err
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected error %v, got %v", tt.wantErr, err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("expected %d calls, got %d", tt.wantCalls, calls)
+			}
+		})
+	}
+
+	t.Run("context cancelled during backoff", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		err := RetryPolicy{MaxRetries: 3, BaseDelay: time.Hour}.Do(ctx, retryable, func() error {
+			calls++
+			cancel()
+			return errTransient
+		})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+		if calls != 1 {
+			t.Errorf("expected 1 call, got %d", calls)
+		}
+	})
+}
+
+func TestRetryPolicyDoWrappedRetryAfter(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"60"}}, Body: http.NoBody}
+	rateLimited := fmt.Errorf("failed to upload part: %w", sdkerrors.NewHTTPError(resp, "upload part"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	calls := 0
+	err := RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond}.Do(ctx, func(error) bool { return true }, func() error {
+		calls++
+		return rateLimited
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected to wait for Retry-After until the deadline, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected 1 call before Retry-After, got %d", calls)
+	}
+}
+
+func TestRetryDefaults(t *testing.T) {
+	if got := (&Config{}).Retry(); got != DefaultRetryPolicy() {
+		t.Errorf("expected default policy, got %+v", got)
+	}
+	custom := &RetryPolicy{MaxRetries: 7}
+	if got := (&Config{RetryPolicy: custom}).Retry(); got != *custom {
+		t.Errorf("expected custom policy, got %+v", got)
+	}
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strconv"
+
+	"github.com/internxt/rclone-adapter/crypto"
+)
+
+// SecureBytes holds a secret, such as a password read from a terminal, as
+// bytes that Wipe can zero, unlike a Go string. It formats and logs as
+// "REDACTED". The zero value and nil hold nothing.
+type SecureBytes struct {
+	b []byte
+}
+
+// NewSecureBytes copies b into a SecureBytes. The caller should wipe b.
+func NewSecureBytes(b []byte) *SecureBytes {
+	return &SecureBytes{b: append([]byte(nil), b...)}
+}
+
+// Bytes returns the secret. The slice is wiped together with s.
+func (s *SecureBytes) Bytes() []byte {
+	if s == nil {
+		return nil
+	}
+	return s.b
+}
+
+// Len returns the length of the secret.
+func (s *SecureBytes) Len() int {
+	return len(s.Bytes())
+}
+
+// Wipe zeroes the secret and empties s.
+func (s *SecureBytes) Wipe() {
+	if s == nil {
+		return
+	}
+	crypto.Wipe(s.b)
+	s.b = nil
+}
+
+// String implements fmt.Stringer without revealing the secret.
+func (s *SecureBytes) String() string {
+	if s.Len() == 0 {
+		return ""
+	}
+	return redactedValue
+}
+
+// GoString implements fmt.GoStringer without revealing the secret.
+func (s *SecureBytes) GoString() string {
+	return "config.SecureBytes(" + strconv.Quote(s.String()) + ")"
+}
+
+// MarshalJSON encodes s as "REDACTED", or "" when empty.
+func (s *SecureBytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// LogValue implements slog.LogValuer without revealing the secret.
+func (s *SecureBytes) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+// UnmarshalJSON decodes a JSON string into s.
+func (s *SecureBytes) UnmarshalJSON(data []byte) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	s.Wipe()
+	s.b = []byte(v)
+	return nil
+}
+
+// SetMnemonic stores a copy of mnemonic in c.Mnemonic. The previous
+// SecureBytes is replaced, not wiped, since struct copies of c may share it.
+func (c *Config) SetMnemonic(mnemonic string) {
+	c.Mnemonic = NewSecureBytes([]byte(mnemonic))
+}
+
+// MnemonicString returns c.Mnemonic as a string, or "" if it is unset, for
+// the crypto functions that take one. Unlike c.Mnemonic the string cannot
+// be wiped, so callers should not keep it.
+func (c *Config) MnemonicString() string {
+	return string(c.Mnemonic.Bytes())
+}
+
+// Wipe clears the secrets of c: it zeroes the mnemonic, empties the token,
+// private key and basic auth header, and drops the bucket keys derived from
+// the mnemonic and cached by the crypto package. Strings cannot be zeroed in
+// Go, so the old token, key and header stay in memory until collected.
+// Struct copies of c share its mnemonic and lose it too; Clone does not.
+// c cannot reach the account afterwards.
+func (c *Config) Wipe() {
+	c.SetToken("")
+	c.Mnemonic.Wipe()
+	c.Mnemonic = nil
+	c.PrivateKey = ""
+	c.BasicAuthHeader = ""
+	crypto.ClearKeyCache()
+}
+
+// FileKey derives the key and IV of the file with the given index in
+// c.Bucket with c.KeyDeriver, or crypto.DefaultKeyDeriver if it is nil.
+func (c *Config) FileKey(indexHex string) (key, iv []byte, err error) {
+	kd := c.KeyDeriver
+	if kd == nil {
+		kd = crypto.DefaultKeyDeriver
+	}
+	return kd.FileKey(c.MnemonicString(), c.Bucket, indexHex)
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSecureBytes(t *testing.T) {
+	src := []byte("hunter2")
+	s := NewSecureBytes(src)
+	secret := s.Bytes()
+	if !bytes.Equal(secret, src) {
+		t.Fatalf("expected the secret, got %q", secret)
+	}
+
+	var logged bytes.Buffer
+	slog.New(slog.NewTextHandler(&logged, nil)).Info("login", "password", s)
+	encoded, _ := json.Marshal(struct{ Password *SecureBytes }{s})
+	for _, out := range []string{s.String(), fmt.Sprintf("%v %+v %#v %s", s, s, s, s), logged.String(), string(encoded)} {
+		if strings.Contains(out, "hunter2") || !strings.Contains(out, redactedValue) {
+			t.Errorf("expected the secret to be redacted, got %s", out)
+		}
+	}
+
+	s.Wipe()
+	if !bytes.Equal(secret, make([]byte, len(src))) || s.Len() != 0 || s.String() != "" {
+		t.Errorf("expected the secret to be wiped, got %q", secret)
+	}
+	var empty *SecureBytes
+	empty.Wipe()
+	if empty.Len() != 0 {
+		t.Error("expected a nil SecureBytes to be empty")
+	}
+
+	var cfg Config
+	if err := json.Unmarshal([]byte(`{"mnemonic":"hunter2"}`), &cfg); err != nil || cfg.MnemonicString() != "hunter2" {
+		t.Errorf("expected the mnemonic to be decoded, got %q, %v", cfg.MnemonicString(), err)
+	}
+}
+
+func TestConfigWipe(t *testing.T) {
+	cfg := &Config{Token: "token", PrivateKey: "key", BasicAuthHeader: "Basic x", Bucket: "bucket-id"}
+	cfg.SetMnemonic("mnemonic")
+	clone := cfg.Clone()
+	mnemonic := cfg.Mnemonic.Bytes()
+	cfg.Wipe()
+	if !bytes.Equal(mnemonic, make([]byte, len(mnemonic))) {
+		t.Errorf("expected the mnemonic bytes to be zeroed, got %q", mnemonic)
+	}
+	if clone.MnemonicString() != "mnemonic" {
+		t.Errorf("expected the clone to keep its mnemonic, got %q", clone.MnemonicString())
+	}
+	if cfg.CurrentToken() != "" || cfg.MnemonicString() != "" || cfg.PrivateKey != "" || cfg.BasicAuthHeader != "" {
+		t.Errorf("expected secrets to be cleared, got %+v", cfg)
+	}
+	if cfg.Bucket != "bucket-id" {
+		t.Error("expected non-secret fields to be kept")
+	}
+}
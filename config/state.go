@@ -0,0 +1,81 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/internxt/rclone-adapter/consistency"
+)
+
+// persistedState is the content of the file at Config.StatePath.
+type persistedState struct {
+	Consistency  consistency.State `json:"consistency"`
+	ListingCache *ListingCache     `json:"listing_cache,omitempty"`
+}
+
+// LoadState restores the consistency gate and, when set, the ListingCache
+// from StatePath, so that short-lived processes benefit from the state built
+// by previous runs. A missing file is not an error.
+func (c *Config) LoadState() error {
+	if c.StatePath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.StatePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	state := persistedState{ListingCache: c.ListingCache}
+	if c.ListingCache == nil {
+		// Decode and discard the listings.
+		state.ListingCache = &ListingCache{}
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to parse state file: %w", err)
+	}
+	consistency.Restore(state.Consistency)
+	return nil
+}
+
+// SaveState writes the consistency gate and the ListingCache to StatePath,
+// through a temporary file so a crash never leaves a truncated file behind.
+func (c *Config) SaveState() error {
+	if c.StatePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(persistedState{
+		Consistency:  consistency.Snapshot(),
+		ListingCache: c.ListingCache,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	dir := filepath.Dir(c.StatePath)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".state-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.StatePath); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	return nil
+}
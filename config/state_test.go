@@ -0,0 +1,55 @@
+package config
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/internxt/rclone-adapter/consistency"
+)
+
+func TestSaveLoadState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "state.json")
+
+	cfg := &Config{StatePath: path, ListingCache: NewListingCache(time.Hour)}
+	if err := cfg.LoadState(); err != nil {
+		t.Fatalf("expected missing state file to be ignored, got %v", err)
+	}
+
+	cfg.ListingCache.Put("parent", "folders", []string{"a", "b"}, []string{"child"})
+	cfg.ListingCache.PutMissing("parent", "gone.txt")
+	consistency.TrackFolder("state-folder")
+	if err := cfg.SaveState(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded := &Config{StatePath: path, ListingCache: NewListingCache(time.Hour)}
+	if err := loaded.LoadState(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	v, ok := loaded.ListingCache.Get("parent", "folders")
+	if !ok {
+		t.Fatal("expected listing to be restored")
+	}
+	var listing []string
+	if err := json.Unmarshal(v.(json.RawMessage), &listing); err != nil || len(listing) != 2 {
+		t.Errorf("unexpected restored listing %s: %v", v, err)
+	}
+	if !loaded.ListingCache.IsMissing("parent", "gone.txt") {
+		t.Error("expected missing name to be restored")
+	}
+	loaded.ListingCache.InvalidateItem("child")
+	if _, ok := loaded.ListingCache.Get("parent", "folders"); ok {
+		t.Error("expected restored parent links to invalidate the listing")
+	}
+	if _, ok := consistency.Snapshot().Folders["state-folder"]; !ok {
+		t.Error("expected consistency entry to be kept")
+	}
+
+	noCache := &Config{StatePath: path}
+	if err := noCache.LoadState(); err != nil {
+		t.Errorf("expected state to load without a ListingCache, got %v", err)
+	}
+}
@@ -0,0 +1,265 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
+)
+
+// TokenRefreshFunc obtains a fresh bearer token for cfg. It is called by the
+// default HTTP client when a request authenticated with the current token is
+// rejected with 401. auth.TokenRefresher is the standard implementation.
+type TokenRefreshFunc func(ctx context.Context, cfg *Config) (string, error)
+
+// tokenMu guards Config.Token so the refresh transport can swap it while
+// other goroutines are building requests.
+var tokenMu sync.RWMutex
+
+// CurrentToken returns the bearer token, safe for use concurrently with a refresh.
+func (c *Config) CurrentToken() string {
+	tokenMu.RLock()
+	defer tokenMu.RUnlock()
+	return c.Token
+}
+
+// SetToken atomically replaces the bearer token.
+func (c *Config) SetToken(token string) {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	c.Token = token
+}
+
+// WorkspaceHeader scopes Drive API requests to a workspace.
+const WorkspaceHeader = "x-internxt-workspace"
+
+// ResourcesTokenHeader carries Config.ResourcesToken.
+const ResourcesTokenHeader = "internxt-resources-token"
+
+// AuthorizeRequest authenticates a Drive API request with the bearer token,
+// scopes it to WorkspaceID when set and adds ResourcesToken when set. Doing
+// so where requests are built keeps the scoping with any HTTPClient.
+func (c *Config) AuthorizeRequest(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+c.CurrentToken())
+	if c.WorkspaceID != "" {
+		req.Header.Set(WorkspaceHeader, c.WorkspaceID)
+	}
+	if c.ResourcesToken != "" {
+		req.Header.Set(ResourcesTokenHeader, c.ResourcesToken)
+	}
+}
+
+// configTransport applies per-Config behaviour to requests sent by the default
+// HTTP client. It is the single place where client identification headers are
+// set: internxt-client is always ClientName, internxt-version is
+// ClientVersion unless the request carries its own (network API calls pin
+// the API version), and X-Request-Id is generated unless already present.
+// The token of bearer-authenticated (Drive API) requests is kept fresh: when TokenRefreshLeeway
+// is set, requests are sent with a renewed token if the current one expires
+// within the leeway, and when the server answers 401 to a request carrying the
+// current token, the token is refreshed and the request replayed once.
+// With RetryRequests set, idempotent requests are also retried on transient
+// failures, see send.
+type configTransport struct {
+	cfg  *Config
+	base http.RoundTripper
+
+	refreshMu sync.Mutex
+}
+
+func (t *configTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("internxt-client", valueOr(t.cfg.ClientName, ClientName))
+	if req.Header.Get("internxt-version") == "" {
+		req.Header.Set("internxt-version", valueOr(t.cfg.ClientVersion, ClientVersion))
+	}
+	if req.Header.Get(RequestIDHeader) == "" {
+		id := RequestIDFromContext(req.Context())
+		if id == "" {
+			id = NewRequestID()
+		}
+		req.Header.Set(RequestIDHeader, id)
+	}
+
+	if !t.managesToken(req) {
+		return t.send(req)
+	}
+
+	if t.cfg.TokenRefreshLeeway > 0 {
+		sentToken := bearerToken(req)
+		if exp, err := TokenExpiry(sentToken); err == nil && time.Until(exp) < t.cfg.TokenRefreshLeeway {
+			if token, err := t.refresh(req.Context(), sentToken); err == nil && token != sentToken {
+				req = req.Clone(req.Context())
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+	}
+
+	resp, err := t.send(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !canReplay(req) {
+		return resp, err
+	}
+
+	sentToken := bearerToken(req)
+	token, refreshErr := t.refresh(req.Context(), sentToken)
+	if refreshErr != nil || token == sentToken {
+		return resp, nil
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return resp, nil
+		}
+		retry.Body = body
+	}
+	retry.Header.Set("Authorization", "Bearer "+token)
+
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	return t.send(retry)
+}
+
+// send forwards req to the base transport. When RetryRequests is set and req
+// is idempotent and replayable, network errors and 408/429/5xx responses are
+// retried following the RetryPolicy, waiting at least as long as the
+// response's Retry-After or X-RateLimit-Reset asks for.
+func (t *configTransport) send(req *http.Request) (*http.Response, error) {
+	if !t.cfg.RetryRequests || !isIdempotent(req.Method) || !canReplay(req) {
+		return t.roundTrip(req)
+	}
+
+	policy := t.cfg.Retry()
+	for retry := 0; ; retry++ {
+		attempt := req
+		if retry > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attempt = req.Clone(req.Context())
+			attempt.Body = body
+		}
+
+		resp, err := t.roundTrip(attempt)
+		if retry >= policy.MaxRetries || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		delay := policy.Backoff(retry + 1)
+		if err != nil {
+			var netErr net.Error
+			if !errors.As(err, &netErr) {
+				return nil, err
+			}
+		} else {
+			httpErr := &sdkerrors.HTTPError{Response: resp}
+			if !httpErr.Temporary() {
+				return resp, nil
+			}
+			delay = max(delay, httpErr.RetryAfter())
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// roundTrip sends req through the base transport and logs the outcome with
+// the request id and, when the server returns one, its own request id.
+func (t *configTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	log := t.cfg.Log()
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+
+	attrs := []any{
+		"method", req.Method,
+		"host", req.URL.Host,
+		"path", req.URL.Path,
+		"request_id", req.Header.Get(RequestIDHeader),
+		"duration", time.Since(start),
+	}
+	if err != nil {
+		log.DebugContext(req.Context(), "http request failed", append(attrs, "error", err)...)
+		return nil, err
+	}
+	attrs = append(attrs, "status", resp.StatusCode)
+	if id := resp.Header.Get(RequestIDHeader); id != "" && id != req.Header.Get(RequestIDHeader) {
+		attrs = append(attrs, "server_request_id", id)
+	}
+	log.DebugContext(req.Context(), "http request", attrs...)
+	return resp, nil
+}
+
+// isIdempotent reports whether requests with method can be safely repeated.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// managesToken reports whether req is a bearer-authenticated request whose
+// token the transport may renew. The refresh call itself is never touched.
+func (t *configTransport) managesToken(req *http.Request) bool {
+	if t.cfg.TokenRefresher == nil {
+		return false
+	}
+	if !strings.HasPrefix(req.Header.Get("Authorization"), "Bearer ") {
+		return false
+	}
+	return t.cfg.Endpoints == nil || req.URL.String() != t.cfg.Endpoints.Drive().Users().Refresh()
+}
+
+// canReplay reports whether the body of req can be sent again.
+func canReplay(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func valueOr(v, fallback string) string {
+	if v == "" {
+		return fallback
+	}
+	return v
+}
+
+func bearerToken(req *http.Request) string {
+	return strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+}
+
+// refresh obtains a new token unless another request already replaced
+// sentToken while we were waiting, in which case the current token is reused.
+func (t *configTransport) refresh(ctx context.Context, sentToken string) (string, error) {
+	t.refreshMu.Lock()
+	defer t.refreshMu.Unlock()
+
+	if current := t.cfg.CurrentToken(); current != sentToken {
+		return current, nil
+	}
+
+	token, err := t.cfg.TokenRefresher(ctx, t.cfg)
+	if err != nil {
+		return "", err
+	}
+	t.cfg.SetToken(token)
+	// The request can proceed with the new token even if it couldn't be
+	// persisted; the next refresh will try again.
+	_ = t.cfg.SaveCredentials()
+	return token, nil
+}
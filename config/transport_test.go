@@ -0,0 +1,333 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/internxt/rclone-adapter/endpoints"
+)
+
+func TestTokenRefreshTransport(t *testing.T) {
+	var refreshes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	cfg := &Config{
+		Token:     "expired-token",
+		Endpoints: endpoints.NewConfig(server.URL),
+		TokenRefresher: func(ctx context.Context, cfg *Config) (string, error) {
+			refreshes.Add(1)
+			return "fresh-token", nil
+		},
+	}
+	cfg.ApplyDefaults()
+
+	req, _ := http.NewRequest(http.MethodPost, server.URL+"/drive/files", bytes.NewReader([]byte("payload")))
+	req.Header.Set("Authorization", "Bearer "+cfg.CurrentToken())
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected replayed request to succeed, got status %d", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "payload" {
+		t.Errorf("expected replayed body %q, got %q", "payload", body)
+	}
+	if cfg.CurrentToken() != "fresh-token" {
+		t.Errorf("expected token to be swapped, got %s", cfg.CurrentToken())
+	}
+	if refreshes.Load() != 1 {
+		t.Errorf("expected 1 refresh, got %d", refreshes.Load())
+	}
+
+	// A request that was built with the old token while the refresh was in
+	// flight reuses the new token instead of refreshing again.
+	req, _ = http.NewRequest(http.MethodGet, server.URL+"/drive/files", nil)
+	req.Header.Set("Authorization", "Bearer expired-token")
+	resp, err = cfg.HTTPClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", resp.StatusCode)
+	}
+	if refreshes.Load() != 1 {
+		t.Errorf("expected no additional refresh, got %d", refreshes.Load())
+	}
+}
+
+func TestTokenRefreshTransportWithoutRefresher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	cfg := &Config{Token: "expired-token", Endpoints: endpoints.NewConfig(server.URL)}
+	cfg.ApplyDefaults()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Authorization", "Bearer expired-token")
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 to be passed through, got %d", resp.StatusCode)
+	}
+}
+
+func TestCloneRebindsRefreshTransport(t *testing.T) {
+	cfg := &Config{Token: "token"}
+	cfg.ApplyDefaults()
+
+	clone := cfg.Clone()
+	clone.SetToken("other-token")
+
+	if cfg.CurrentToken() != "token" {
+		t.Errorf("expected original token to be unchanged, got %s", cfg.CurrentToken())
+	}
+	rt, ok := clone.HTTPClient.Transport.(*configTransport)
+	if !ok {
+		t.Fatalf("expected *configTransport, got %T", clone.HTTPClient.Transport)
+	}
+	if rt.cfg != clone {
+		t.Error("expected clone's transport to refresh the clone")
+	}
+	if rt.base != cfg.HTTPClient.Transport.(*configTransport).base {
+		t.Error("expected clone to share the underlying transport")
+	}
+}
+
+func TestTokenRefreshTransportLeeway(t *testing.T) {
+	expiring := makeJWT(fmt.Sprintf(`{"exp":%d}`, time.Now().Add(2*time.Minute).Unix()))
+	fresh := makeJWT(fmt.Sprintf(`{"exp":%d}`, time.Now().Add(time.Hour).Unix()))
+
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	var refreshes int
+	cfg := &Config{
+		Token:              expiring,
+		Endpoints:          endpoints.NewConfig(server.URL),
+		TokenRefreshLeeway: 5 * time.Minute,
+		TokenRefresher: func(ctx context.Context, cfg *Config) (string, error) {
+			refreshes++
+			return fresh, nil
+		},
+	}
+	cfg.ApplyDefaults()
+
+	for range 2 {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		req.Header.Set("Authorization", "Bearer "+cfg.CurrentToken())
+		resp, err := cfg.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	if refreshes != 1 {
+		t.Errorf("expected 1 proactive refresh, got %d", refreshes)
+	}
+	for i, auth := range seen {
+		if auth != "Bearer "+fresh {
+			t.Errorf("request %d sent with stale token", i)
+		}
+	}
+}
+
+func TestClientIdentificationHeaders(t *testing.T) {
+	tests := []struct {
+		name          string
+		clientName    string
+		clientVersion string
+		reqVersion    string
+		wantClient    string
+		wantVersion   string
+	}{
+		{"defaults", "", "", "", ClientName, ClientVersion},
+		{"overrides", "my-app", "2.3.4", "", "my-app", "2.3.4"},
+		{"request pins version", "my-app", "2.3.4", "1.0", "my-app", "1.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotClient, gotVersion string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotClient = r.Header.Get("internxt-client")
+				gotVersion = r.Header.Get("internxt-version")
+			}))
+			defer server.Close()
+
+			cfg := &Config{ClientName: tt.clientName, ClientVersion: tt.clientVersion}
+			cfg.ApplyDefaults()
+
+			req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+			if tt.reqVersion != "" {
+				req.Header.Set("internxt-version", tt.reqVersion)
+			}
+			resp, err := cfg.HTTPClient.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp.Body.Close()
+
+			if gotClient != tt.wantClient {
+				t.Errorf("expected internxt-client %q, got %q", tt.wantClient, gotClient)
+			}
+			if gotVersion != tt.wantVersion {
+				t.Errorf("expected internxt-version %q, got %q", tt.wantVersion, gotVersion)
+			}
+		})
+	}
+}
+
+func TestAuthorizeRequestCustomClient(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	cfg := &Config{Token: "user-token", WorkspaceID: "ws-1", ResourcesToken: "shared-token", HTTPClient: &http.Client{}}
+	cfg.ApplyDefaults()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	cfg.AuthorizeRequest(req)
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	if got.Get("Authorization") != "Bearer user-token" {
+		t.Errorf("expected bearer token, got %q", got.Get("Authorization"))
+	}
+	if got.Get(WorkspaceHeader) != "ws-1" {
+		t.Errorf("expected workspace header ws-1, got %q", got.Get(WorkspaceHeader))
+	}
+	if got.Get(ResourcesTokenHeader) != "shared-token" {
+		t.Errorf("expected resources token, got %q", got.Get(ResourcesTokenHeader))
+	}
+}
+
+func TestRetryRequests(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		enabled      bool
+		failures     int32
+		status       int
+		wantAttempts int32
+		wantStatus   int
+	}{
+		{"retries idempotent request", http.MethodPut, true, 2, http.StatusServiceUnavailable, 3, http.StatusOK},
+		{"honors max retries", http.MethodGet, true, 5, http.StatusTooManyRequests, 3, http.StatusTooManyRequests},
+		{"does not retry POST", http.MethodPost, true, 1, http.StatusServiceUnavailable, 1, http.StatusServiceUnavailable},
+		{"does not retry client errors", http.MethodGet, true, 1, http.StatusNotFound, 1, http.StatusNotFound},
+		{"disabled by default", http.MethodGet, false, 1, http.StatusServiceUnavailable, 1, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if r.Method == http.MethodPut && string(body) != "payload" {
+					t.Errorf("expected replayed body %q, got %q", "payload", body)
+				}
+				if attempts.Add(1) <= tt.failures {
+					w.Header().Set("Retry-After", "0")
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			cfg := &Config{
+				RetryRequests: tt.enabled,
+				RetryPolicy:   &RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond},
+			}
+			cfg.ApplyDefaults()
+
+			req, _ := http.NewRequest(tt.method, server.URL, bytes.NewReader([]byte("payload")))
+			resp, err := cfg.HTTPClient.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if attempts.Load() != tt.wantAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.wantAttempts, attempts.Load())
+			}
+		})
+	}
+}
+
+func TestRequestIDs(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get(RequestIDHeader))
+		w.Header().Set(RequestIDHeader, "server-side-id")
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	cfg := &Config{Logger: slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))}
+	cfg.ApplyDefaults()
+
+	send := func(ctx context.Context) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		resp, err := cfg.HTTPClient.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	send(context.Background())
+	send(context.Background())
+	send(WithRequestID(context.Background(), "caller-id"))
+
+	if len(got) != 3 || got[0] == "" || got[0] == got[1] {
+		t.Errorf("expected a distinct generated id per request, got %q", got)
+	}
+	if got[2] != "caller-id" {
+		t.Errorf("expected id from context, got %q", got[2])
+	}
+	if !strings.Contains(logs.String(), "request_id="+got[0]) || !strings.Contains(logs.String(), "server_request_id=server-side-id") {
+		t.Errorf("expected log lines with request ids, got %s", logs.String())
+	}
+}
@@ -1,9 +1,11 @@
 // Package consistency provides a gate to handle eventual consistency
-// when creating folders. After a folder is created on the server, it
-// may not be immediately visible to other API endpoints. TrackFolder
-// records the creation time, and AwaitFolder blocks only for the
-// remaining window before the folder is expected to be consistent.
-// Entries self-evict via time.AfterFunc, keeping memory bounded.
+// after mutations. After a folder or file is created, renamed or moved on
+// the server, it may not be immediately visible to other API endpoints.
+// The Track functions record the mutation time, and the matching Await
+// functions block only for the remaining window before the change is
+// expected to be consistent. Objects tracked with a Probe are instead
+// polled until the server reports them visible. Entries self-evict via time.AfterFunc,
+// keeping memory bounded.
 // This aims to prevent this issue: https://inxt.atlassian.net/browse/PB-1446
 package consistency
 
@@ -13,33 +15,118 @@ import (
 	"time"
 )
 
-var recentFolders sync.Map
+var (
+	recentFolders        sync.Map
+	recentFiles          sync.Map
+	recentFolderContents sync.Map
+)
 
 const window = 500 * time.Millisecond
 
-// TrackFolder records that a folder was just created. The entry
-// self-deletes after the consistency window elapses.
+// Polling parameters for entries tracked with a Probe.
+const (
+	pollTimeout      = 10 * time.Second
+	pollInitialDelay = 50 * time.Millisecond
+	pollMaxDelay     = time.Second
+)
+
+// Probe asks the server whether a tracked object is visible yet.
+type Probe func(ctx context.Context) (visible bool, err error)
+
+// probeEntry is stored instead of the tracking time for objects tracked with
+// a Probe.
+type probeEntry struct {
+	at    time.Time
+	probe Probe
+}
+
+// TrackFolder records that a folder was just created, renamed or moved.
+// The entry self-deletes after the consistency window elapses.
 func TrackFolder(uuid string) {
-	recentFolders.Store(uuid, time.Now())
-	time.AfterFunc(window, func() {
-		recentFolders.Delete(uuid)
-	})
+	track(&recentFolders, uuid)
 }
 
 // AwaitFolder blocks until the consistency window has elapsed for a
 // recently created folder. Returns immediately for unknown or already
 // consistent folders.
 func AwaitFolder(ctx context.Context, folderUUID string) error {
-	v, ok := recentFolders.Load(folderUUID)
+	return await(ctx, &recentFolders, folderUUID)
+}
+
+// TrackFolderProbe is like TrackFolder, but AwaitFolder polls probe with a
+// short backoff until the folder is visible instead of sleeping for a fixed
+// window, giving up silently after a deadline.
+func TrackFolderProbe(uuid string, probe Probe) {
+	trackProbe(&recentFolders, uuid, probe)
+}
+
+// TrackFile records that a file was just created, renamed or moved. Files
+// can be tracked by their Drive UUID and by their network file ID.
+func TrackFile(id string) {
+	track(&recentFiles, id)
+}
+
+// TrackFileProbe is like TrackFile, but AwaitFile polls probe until the
+// file is visible, see TrackFolderProbe.
+func TrackFileProbe(id string, probe Probe) {
+	trackProbe(&recentFiles, id, probe)
+}
+
+// AwaitFile blocks until the consistency window has elapsed for a recently
+// tracked file.
+func AwaitFile(ctx context.Context, id string) error {
+	return await(ctx, &recentFiles, id)
+}
+
+// TrackFolderContents records that a file or folder was just added to, or
+// moved into, the given folder, so listings of it may be stale.
+func TrackFolderContents(folderUUID string) {
+	track(&recentFolderContents, folderUUID)
+}
+
+// AwaitFolderContents blocks until a listing of folderUUID is expected to
+// reflect recent changes, including the folder's own creation.
+func AwaitFolderContents(ctx context.Context, folderUUID string) error {
+	if err := AwaitFolder(ctx, folderUUID); err != nil {
+		return err
+	}
+	return await(ctx, &recentFolderContents, folderUUID)
+}
+
+func track(m *sync.Map, key string) {
+	if key == "" {
+		return
+	}
+	now := time.Now()
+	m.Store(key, now)
+	time.AfterFunc(window, func() {
+		m.CompareAndDelete(key, now)
+	})
+}
+
+func trackProbe(m *sync.Map, key string, probe Probe) {
+	if key == "" {
+		return
+	}
+	e := &probeEntry{at: time.Now(), probe: probe}
+	m.Store(key, e)
+	time.AfterFunc(pollTimeout, func() {
+		m.CompareAndDelete(key, e)
+	})
+}
+
+func await(ctx context.Context, m *sync.Map, key string) error {
+	v, ok := m.Load(key)
 	if !ok {
 		return nil
 	}
-
+	if e, ok := v.(*probeEntry); ok {
+		return poll(ctx, m, key, e)
+	}
 	remaining := window - time.Since(v.(time.Time))
 	if remaining <= 0 {
 		return nil
 	}
-
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
@@ -47,3 +134,87 @@ func AwaitFolder(ctx context.Context, folderUUID string) error {
 		return nil
 	}
 }
+
+// poll calls the entry's probe until the object is visible or the polling
+// deadline passes. Probe errors are treated as "not visible yet".
+func poll(ctx context.Context, m *sync.Map, key string, e *probeEntry) error {
+	deadline := e.at.Add(pollTimeout)
+	delay := pollInitialDelay
+	for {
+		if visible, err := e.probe(ctx); err == nil && visible {
+			m.CompareAndDelete(key, e)
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(min(delay, remaining))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		delay = min(delay*2, pollMaxDelay)
+	}
+}
+
+// State is a snapshot of the tracked entries, keyed by ID with the time each
+// was tracked, that can be persisted so a later process honours the gate for
+// mutations made by an earlier one. See Snapshot and Restore.
+type State struct {
+	Folders        map[string]time.Time `json:"folders,omitempty"`
+	Files          map[string]time.Time `json:"files,omitempty"`
+	FolderContents map[string]time.Time `json:"folder_contents,omitempty"`
+}
+
+// Snapshot returns the currently tracked entries.
+func Snapshot() State {
+	return State{
+		Folders:        snapshot(&recentFolders),
+		Files:          snapshot(&recentFiles),
+		FolderContents: snapshot(&recentFolderContents),
+	}
+}
+
+// Restore tracks the entries of s whose consistency window hasn't elapsed
+// yet, keeping their original tracking time. Entries tracked with a Probe
+// are restored as plain window entries. Existing entries are kept.
+func Restore(s State) {
+	restore(&recentFolders, s.Folders)
+	restore(&recentFiles, s.Files)
+	restore(&recentFolderContents, s.FolderContents)
+}
+
+func snapshot(m *sync.Map) map[string]time.Time {
+	out := make(map[string]time.Time)
+	m.Range(func(key, v any) bool {
+		if e, ok := v.(*probeEntry); ok {
+			out[key.(string)] = e.at
+		} else {
+			out[key.(string)] = v.(time.Time)
+		}
+		return true
+	})
+	return out
+}
+
+func restore(m *sync.Map, entries map[string]time.Time) {
+	for key, at := range entries {
+		remaining := window - time.Since(at)
+		if key == "" || remaining <= 0 {
+			continue
+		}
+		if _, loaded := m.LoadOrStore(key, at); loaded {
+			continue
+		}
+		time.AfterFunc(remaining, func() {
+			m.CompareAndDelete(key, at)
+		})
+	}
+}
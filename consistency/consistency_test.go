@@ -78,3 +78,131 @@ func TestAwaitFolder(t *testing.T) {
 		}
 	})
 }
+
+func TestTrackFileAndFolderContents(t *testing.T) {
+	tests := []struct {
+		name  string
+		track func()
+		await func(ctx context.Context) error
+	}{
+		{"file", func() { TrackFile("tracked-file") }, func(ctx context.Context) error { return AwaitFile(ctx, "tracked-file") }},
+		{"folder contents", func() { TrackFolderContents("changed-folder") }, func(ctx context.Context) error { return AwaitFolderContents(ctx, "changed-folder") }},
+		{"contents of new folder", func() { TrackFolder("new-folder") }, func(ctx context.Context) error { return AwaitFolderContents(ctx, "new-folder") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.track()
+			start := time.Now()
+			if err := tt.await(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if time.Since(start) < 400*time.Millisecond {
+				t.Error("expected await to block for a recent change")
+			}
+
+			start = time.Now()
+			if err := tt.await(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if time.Since(start) > 10*time.Millisecond {
+				t.Error("expected immediate return once the window has elapsed")
+			}
+		})
+	}
+
+	t.Run("empty key is ignored", func(t *testing.T) {
+		TrackFile("")
+		if _, ok := recentFiles.Load(""); ok {
+			t.Error("expected empty id not to be tracked")
+		}
+	})
+}
+
+func TestTrackProbe(t *testing.T) {
+	t.Run("returns as soon as the probe reports visible", func(t *testing.T) {
+		calls := 0
+		TrackFolderProbe("probed-folder", func(ctx context.Context) (bool, error) {
+			calls++
+			return calls >= 3, nil
+		})
+
+		start := time.Now()
+		if err := AwaitFolder(context.Background(), "probed-folder"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 3 {
+			t.Errorf("expected 3 probe calls, got %d", calls)
+		}
+		if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+			t.Errorf("expected polling to finish quickly, took %v", elapsed)
+		}
+
+		if err := AwaitFolder(context.Background(), "probed-folder"); err != nil || calls != 3 {
+			t.Errorf("expected a confirmed folder not to be probed again (calls=%d, err=%v)", calls, err)
+		}
+	})
+
+	t.Run("probe errors keep polling", func(t *testing.T) {
+		calls := 0
+		TrackFileProbe("probed-file", func(ctx context.Context) (bool, error) {
+			calls++
+			if calls == 1 {
+				return false, context.DeadlineExceeded
+			}
+			return true, nil
+		})
+		if err := AwaitFile(context.Background(), "probed-file"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if calls != 2 {
+			t.Errorf("expected 2 probe calls, got %d", calls)
+		}
+	})
+
+	t.Run("respects context cancellation", func(t *testing.T) {
+		TrackFileProbe("never-visible", func(ctx context.Context) (bool, error) { return false, nil })
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		if err := AwaitFile(ctx, "never-visible"); err == nil {
+			t.Fatal("expected context error")
+		}
+	})
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	TrackFile("snapshot-file")
+	TrackFolderProbe("snapshot-probe", func(context.Context) (bool, error) { return true, nil })
+
+	s := Snapshot()
+	if _, ok := s.Files["snapshot-file"]; !ok {
+		t.Fatal("expected tracked file in snapshot")
+	}
+	if _, ok := s.Folders["snapshot-probe"]; !ok {
+		t.Fatal("expected probe-tracked folder in snapshot")
+	}
+
+	restored := State{
+		Folders: map[string]time.Time{
+			"restored-folder": time.Now().Add(-window / 2),
+			"expired-folder":  time.Now().Add(-2 * window),
+		},
+	}
+	Restore(restored)
+
+	if _, ok := recentFolders.Load("expired-folder"); ok {
+		t.Error("expected entries past the window not to be restored")
+	}
+	start := time.Now()
+	if err := AwaitFolder(context.Background(), "restored-folder"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < window/4 || elapsed > window {
+		t.Errorf("expected to wait for the remaining window, waited %v", elapsed)
+	}
+
+	time.Sleep(window)
+	if _, ok := recentFolders.Load("restored-folder"); ok {
+		t.Error("expected restored entry to be evicted after its window")
+	}
+}
@@ -0,0 +1,19 @@
+// Package crypto implements the encryption schemes shared by Internxt
+// clients, with no dependency on the rest of this module:
+//
+//   - file contents: AES-256-CTR with per-file keys derived from the user's
+//     mnemonic, the bucket ID and a random index (GenerateFileKey,
+//     EncryptReader, DecryptReader), and the network's
+//     RIPEMD-160(SHA-256) content hash (ComputeFileHash). Each encryptVersion
+//     of stored files has a FileCipher (CipherFor), so 04-aes-gcm files
+//     are written as authenticated chunks and 03-aes files still read.
+//     Other ciphers plug in with RegisterCipher, and other key derivations
+//     through a KeyDeriver;
+//   - text: the legacy CryptoJS-compatible format of the drive API
+//     (EncryptText, DecryptText) and the web app's AES-GCM format for share
+//     keys (EncryptTextGCM, DecryptTextGCM);
+//   - BIP39 mnemonics, which every key derives from (ValidateMnemonic,
+//     GenerateMnemonic);
+//   - OpenPGP messages exchanged with other users' keys
+//     (EncryptWithPublicKey, DecryptWithPrivateKey).
+package crypto
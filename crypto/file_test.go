@@ -0,0 +1,119 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"testing"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestFileKey(t *testing.T) {
+	index := "0123456789abcdef00000123456789abcdef00000000123456789abcdef0000000"
+	key, iv, err := GenerateFileKey(testMnemonic, "0123456789abcdef0000", index)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := hex.EncodeToString(key), "c80d66e4ffe8b8c9cf9ec65723b97e9b1736d16f18332c15e806e05d277a3e2e"; got != want {
+		t.Errorf("expected key %s, got %s", want, got)
+	}
+	if got, want := hex.EncodeToString(iv), index[:32]; got != want {
+		t.Errorf("expected iv %s, got %s", want, got)
+	}
+
+	if _, _, err := GenerateFileKey("not a mnemonic", "0123456789abcdef0000", index); err == nil {
+		t.Error("expected an error for an invalid mnemonic")
+	}
+}
+
+func TestEncryptDecryptReader(t *testing.T) {
+	key := bytes.Repeat([]byte{0x11}, 32)
+	iv := bytes.Repeat([]byte{0x22}, 16)
+	plain := bytes.Repeat([]byte("internxt"), 100)
+
+	enc, err := EncryptReader(bytes.NewReader(plain), key, iv)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cipherText, _ := io.ReadAll(enc)
+	if bytes.Equal(cipherText, plain) {
+		t.Fatal("expected the data to be encrypted")
+	}
+
+	// Decrypting from the second block on needs the IV advanced by one.
+	dec, err := DecryptReader(bytes.NewReader(cipherText[16:]), key, AddToIV(iv, 1))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, _ := io.ReadAll(dec)
+	if !bytes.Equal(got, plain[16:]) {
+		t.Error("expected the decrypted data to match")
+	}
+}
+
+func TestAddToIV(t *testing.T) {
+	testCases := []struct {
+		name string
+		iv   string
+		n    int64
+		want string
+	}{
+		{"zero", "00000000000000000000000000000000", 0, "00000000000000000000000000000000"},
+		{"carry across all bytes", "00ffffffffffffffffffffffffffffff", 1, "01000000000000000000000000000000"},
+		{"carry into the high half", "0000000000000001ffffffffffffffff", 2, "00000000000000020000000000000001"},
+		{"beyond 2^32 blocks", "22222222222222222222222222222222", 1 << 40, "22222222222222222222232222222222"},
+		{"largest offset", "00000000000000000000000000000001", 1<<63 - 1, "00000000000000008000000000000000"},
+		{"wraps at 2^128", "ffffffffffffffffffffffffffffffff", 1, "00000000000000000000000000000000"},
+		{"negative", "00000000000000010000000000000000", -1, "0000000000000000ffffffffffffffff"},
+		{"short iv", "ff", 1, "00000000000000000000000000000100"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			iv, _ := hex.DecodeString(tc.iv)
+			orig := bytes.Clone(iv)
+			if got := hex.EncodeToString(AddToIV(iv, tc.n)); got != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, got)
+			}
+			if !bytes.Equal(iv, orig) {
+				t.Error("expected iv to be unchanged")
+			}
+		})
+	}
+}
+
+// TestAddToIVMatchesCTR tests that a stream started at AddToIV(iv, n)
+// continues the keystream of iv at block n, also across the 2^64 carry and
+// the 2^128 wrap-around that cipher.NewCTR performs
+func TestAddToIVMatchesCTR(t *testing.T) {
+	key := bytes.Repeat([]byte{0x11}, 32)
+	for _, ivHex := range []string{"0000000000000000fffffffffffffffd", "fffffffffffffffffffffffffffffffe"} {
+		iv, _ := hex.DecodeString(ivHex)
+		plain := make([]byte, 8*16)
+		stream, _ := NewAES256CTRCipher(key, iv)
+		full := make([]byte, len(plain))
+		stream.XORKeyStream(full, plain)
+
+		for n := range int64(8) {
+			stream, _ := NewAES256CTRCipher(key, AddToIV(iv, n))
+			part := make([]byte, len(plain)-int(n)*16)
+			stream.XORKeyStream(part, plain[n*16:])
+			if !bytes.Equal(part, full[n*16:]) {
+				t.Errorf("iv %s: keystream at block %d does not match", ivHex, n)
+			}
+		}
+	}
+}
+
+func TestComputeFileHash(t *testing.T) {
+	data := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef, 0x00, 0x00}
+	want := "30899ccba67493659474c5397a3e860cd45a670c"
+	sum := sha256.Sum256(data)
+	if got := ComputeFileHash(sum[:]); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if got, err := CalculateFileHash(bytes.NewReader(data)); err != nil || got != want {
+		t.Errorf("expected %s, got %s, %v", want, got, err)
+	}
+}
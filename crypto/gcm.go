@@ -0,0 +1,75 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Parameters of the web app's text encryption (aes.encrypt in @internxt/lib).
+const (
+	gcmSaltSize   = 64
+	gcmIVSize     = 16
+	gcmTagSize    = 16
+	gcmIterations = 2145
+)
+
+// EncryptTextGCM encrypts plainText with AES-256-GCM under a key derived
+// from password with PBKDF2-SHA512, the scheme the web app uses for share
+// keys. The result is base64(salt | iv | tag | ciphertext).
+func EncryptTextGCM(plainText, password string) (string, error) {
+	buf := make([]byte, gcmSaltSize+gcmIVSize, gcmSaltSize+gcmIVSize+gcmTagSize+len(plainText))
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate salt and iv: %w", err)
+	}
+	gcm, err := newGCM(password, buf[:gcmSaltSize])
+	if err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nil, buf[gcmSaltSize:], []byte(plainText), nil)
+	tag := sealed[len(sealed)-gcmTagSize:]
+	buf = append(buf, tag...)
+	buf = append(buf, sealed[:len(sealed)-gcmTagSize]...)
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// DecryptTextGCM decrypts the output of EncryptTextGCM.
+func DecryptTextGCM(encrypted, password string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode base64: %w", err)
+	}
+	if len(data) < gcmSaltSize+gcmIVSize+gcmTagSize {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+	salt, iv := data[:gcmSaltSize], data[gcmSaltSize:gcmSaltSize+gcmIVSize]
+	tag, cipherText := data[gcmSaltSize+gcmIVSize:gcmSaltSize+gcmIVSize+gcmTagSize], data[gcmSaltSize+gcmIVSize+gcmTagSize:]
+
+	gcm, err := newGCM(password, salt)
+	if err != nil {
+		return "", err
+	}
+	plainText, err := gcm.Open(nil, iv, append(append([]byte(nil), cipherText...), tag...), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return string(plainText), nil
+}
+
+func newGCM(password string, salt []byte) (cipher.AEAD, error) {
+	key := pbkdf2.Key([]byte(password), salt, gcmIterations, 32, sha512.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, gcmIVSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcm: %w", err)
+	}
+	return gcm, nil
+}
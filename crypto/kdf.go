@@ -0,0 +1,21 @@
+package crypto
+
+// KeyDeriver derives the key and IV of a file from the random index stored
+// with it. Implementations may ignore the mnemonic, e.g. to keep keys in
+// hardware. The returned slices belong to the caller, which wipes them once
+// done.
+type KeyDeriver interface {
+	FileKey(mnemonic, bucketID, indexHex string) (key, iv []byte, err error)
+}
+
+// KeyDeriverFunc adapts a function to a KeyDeriver.
+type KeyDeriverFunc func(mnemonic, bucketID, indexHex string) (key, iv []byte, err error)
+
+// FileKey calls f.
+func (f KeyDeriverFunc) FileKey(mnemonic, bucketID, indexHex string) ([]byte, []byte, error) {
+	return f(mnemonic, bucketID, indexHex)
+}
+
+// DefaultKeyDeriver derives keys from the BIP39 mnemonic like every
+// Internxt client, see GenerateFileKey.
+var DefaultKeyDeriver KeyDeriver = KeyDeriverFunc(GenerateFileKey)
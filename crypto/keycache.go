@@ -0,0 +1,59 @@
+package crypto
+
+import "sync"
+
+// maxCachedBucketKeys bounds bucketKeys; the cache is emptied when full.
+// A process normally works with one or two accounts.
+const maxCachedBucketKeys = 64
+
+type bucketKeyID struct {
+	mnemonic, bucketID string
+}
+
+// bucketKeys caches GenerateFileBucketKey, whose BIP39 seed derivation
+// (PBKDF2 with 2048 rounds) would otherwise run for every file.
+var bucketKeys = struct {
+	sync.Mutex
+	m map[bucketKeyID][]byte
+}{m: map[bucketKeyID][]byte{}}
+
+func cachedBucketKey(mnemonic, bucketID string) ([]byte, bool) {
+	bucketKeys.Lock()
+	defer bucketKeys.Unlock()
+	key, ok := bucketKeys.m[bucketKeyID{mnemonic, bucketID}]
+	if !ok {
+		return nil, false
+	}
+	return append([]byte(nil), key...), true
+}
+
+func cacheBucketKey(mnemonic, bucketID string, key []byte) {
+	bucketKeys.Lock()
+	defer bucketKeys.Unlock()
+	if len(bucketKeys.m) >= maxCachedBucketKeys {
+		wipeBucketKeys()
+	}
+	bucketKeys.m[bucketKeyID{mnemonic, bucketID}] = append([]byte(nil), key...)
+}
+
+// ClearKeyCache wipes the bucket keys cached by GenerateFileKey, e.g. on
+// logout so no key material of the account stays in memory.
+func ClearKeyCache() {
+	bucketKeys.Lock()
+	defer bucketKeys.Unlock()
+	wipeBucketKeys()
+}
+
+func wipeBucketKeys() {
+	for _, key := range bucketKeys.m {
+		Wipe(key)
+	}
+	clear(bucketKeys.m)
+}
+
+// Wipe zeroes bufs, e.g. keys once a cipher holds its own copy of them.
+func Wipe(bufs ...[]byte) {
+	for _, b := range bufs {
+		clear(b)
+	}
+}
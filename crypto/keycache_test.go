@@ -0,0 +1,41 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBucketKeyCache(t *testing.T) {
+	ClearKeyCache()
+	const bucketID = "0123456789abcdef0000"
+
+	key, err := GenerateFileBucketKey(testMnemonic, bucketID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := cachedBucketKey(testMnemonic, bucketID); !ok {
+		t.Fatal("expected the key to be cached")
+	}
+
+	// Callers own the returned slice.
+	key[0] ^= 0xff
+	again, err := GenerateFileBucketKey(testMnemonic, bucketID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if again[0] == key[0] || !bytes.Equal(again[1:], key[1:]) {
+		t.Error("expected the cached key to be unchanged")
+	}
+
+	if _, err := GenerateFileBucketKey("not a mnemonic", bucketID); err == nil {
+		t.Error("expected an error for an invalid mnemonic")
+	}
+	if _, ok := cachedBucketKey("not a mnemonic", bucketID); ok {
+		t.Error("expected failed derivations not to be cached")
+	}
+
+	ClearKeyCache()
+	if _, ok := cachedBucketKey(testMnemonic, bucketID); ok {
+		t.Error("expected the cache to be empty")
+	}
+}
@@ -0,0 +1,46 @@
+package crypto
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tyler-smith/go-bip39"
+)
+
+// ValidateMnemonic reports why m is not a BIP39 mnemonic of the English
+// wordlist, or nil if it is one. Keys derived from anything else would not
+// decrypt files written by other clients.
+func ValidateMnemonic(m string) error {
+	words := strings.Fields(m)
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+	default:
+		return fmt.Errorf("mnemonic has %d words, expected 12, 15, 18, 21 or 24", len(words))
+	}
+	for i, w := range words {
+		if _, ok := bip39.GetWordIndex(w); !ok {
+			return fmt.Errorf("word %d of the mnemonic is not in the BIP39 wordlist", i+1)
+		}
+	}
+	if strings.Join(words, " ") != m {
+		return fmt.Errorf("mnemonic words must be separated by single spaces")
+	}
+	if !bip39.IsMnemonicValid(m) {
+		return fmt.Errorf("mnemonic checksum mismatch")
+	}
+	return nil
+}
+
+// GenerateMnemonic returns a new random 24-word mnemonic, as the web client
+// creates for new accounts.
+func GenerateMnemonic() (string, error) {
+	entropy, err := bip39.NewEntropy(256)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate entropy: %w", err)
+	}
+	m, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate mnemonic: %w", err)
+	}
+	return m, nil
+}
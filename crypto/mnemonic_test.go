@@ -0,0 +1,53 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateMnemonic(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mnemonic string
+		wantErr  string
+	}{
+		{name: "valid", mnemonic: testMnemonic},
+		{name: "empty", mnemonic: "", wantErr: "mnemonic has 0 words"},
+		{name: "too short", mnemonic: "abandon abandon about", wantErr: "mnemonic has 3 words"},
+		{name: "unknown word", mnemonic: strings.Replace(testMnemonic, "about", "abcdef", 1), wantErr: "word 12 of the mnemonic"},
+		{name: "bad checksum", mnemonic: strings.Replace(testMnemonic, "about", "abandon", 1), wantErr: "checksum mismatch"},
+		{name: "extra spaces", mnemonic: testMnemonic + " ", wantErr: "single spaces"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateMnemonic(tc.mnemonic)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestGenerateMnemonic(t *testing.T) {
+	m1, err := GenerateMnemonic()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m2, _ := GenerateMnemonic()
+	if err := ValidateMnemonic(m1); err != nil {
+		t.Errorf("generated mnemonic is invalid: %v", err)
+	}
+	if n := len(strings.Fields(m1)); n != 24 {
+		t.Errorf("expected 24 words, got %d", n)
+	}
+	if m1 == m2 {
+		t.Error("expected different mnemonics")
+	}
+}
@@ -0,0 +1,77 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	_ "golang.org/x/crypto/ripemd160" // Assumed by OpenPGP for keys without hash preferences
+)
+
+// DecryptWithPrivateKey decrypts a message the web app encrypted for a user,
+// such as the key of an item shared with them: an armored OpenPGP message,
+// base64-encoded. privateKey is the user's armored OpenPGP private key.
+func DecryptWithPrivateKey(encryptedBase64, privateKey string) (string, error) {
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(privateKey))
+	if err != nil {
+		return "", fmt.Errorf("failed to read private key: %w", err)
+	}
+	armored, err := base64.StdEncoding.DecodeString(encryptedBase64)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode base64: %w", err)
+	}
+	block, err := armor.Decode(bytes.NewReader(armored))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode armored message: %w", err)
+	}
+	md, err := openpgp.ReadMessage(block.Body, keyring, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt message: %w", err)
+	}
+	plainText, err := io.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt message: %w", err)
+	}
+	return string(plainText), nil
+}
+
+// EncryptWithPublicKey encrypts message for the owner of publicKey, in the
+// format DecryptWithPrivateKey reads. publicKey is an armored OpenPGP public
+// key, optionally base64-encoded as the API returns it.
+func EncryptWithPublicKey(message, publicKey string) (string, error) {
+	if !strings.HasPrefix(strings.TrimSpace(publicKey), "-----BEGIN") {
+		decoded, err := base64.StdEncoding.DecodeString(publicKey)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode public key: %w", err)
+		}
+		publicKey = string(decoded)
+	}
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(publicKey))
+	if err != nil {
+		return "", fmt.Errorf("failed to read public key: %w", err)
+	}
+
+	var armored bytes.Buffer
+	aw, err := armor.Encode(&armored, "PGP MESSAGE", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt message: %w", err)
+	}
+	pw, err := openpgp.Encrypt(aw, keyring, nil, nil, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt message: %w", err)
+	}
+	if _, err := io.WriteString(pw, message); err != nil {
+		return "", fmt.Errorf("failed to encrypt message: %w", err)
+	}
+	if err := pw.Close(); err != nil {
+		return "", fmt.Errorf("failed to encrypt message: %w", err)
+	}
+	if err := aw.Close(); err != nil {
+		return "", fmt.Errorf("failed to encrypt message: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(armored.Bytes()), nil
+}
@@ -0,0 +1,53 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// ErrHashMismatch is wrapped by errors of stored data that does not match
+// its network hash.
+var ErrHashMismatch = errors.New("hash mismatch")
+
+// ShardHasher computes the network hash, RIPEMD-160(SHA-256), of the
+// encrypted bytes written to it.
+type ShardHasher struct {
+	sha hash.Hash
+}
+
+// NewShardHasher returns an empty ShardHasher.
+func NewShardHasher() *ShardHasher {
+	return &ShardHasher{sha: sha256.New()}
+}
+
+// Write adds p to the hashed data. It never fails.
+func (h *ShardHasher) Write(p []byte) (int, error) {
+	return h.sha.Write(p)
+}
+
+// Sum returns the hex-encoded hash of the data written so far.
+func (h *ShardHasher) Sum() string {
+	return ComputeFileHash(h.sha.Sum(nil))
+}
+
+// Verify returns an error wrapping ErrHashMismatch unless the data written
+// so far hashes to expectedHash.
+func (h *ShardHasher) Verify(expectedHash string) error {
+	if got := h.Sum(); got != expectedHash {
+		return fmt.Errorf("%w: expected %s, got %s", ErrHashMismatch, expectedHash, got)
+	}
+	return nil
+}
+
+// VerifyShard reads the encrypted shard r to the end and checks it against
+// its network hash, without holding it in memory.
+func VerifyShard(r io.Reader, expectedHash string) error {
+	h := NewShardHasher()
+	if _, err := io.Copy(h, r); err != nil {
+		return fmt.Errorf("failed to read shard: %w", err)
+	}
+	return h.Verify(expectedHash)
+}
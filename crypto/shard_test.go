@@ -0,0 +1,31 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"testing/iotest"
+)
+
+func TestVerifyShard(t *testing.T) {
+	data := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef, 0x00, 0x00}
+	const hash = "30899ccba67493659474c5397a3e860cd45a670c"
+
+	if err := VerifyShard(bytes.NewReader(data), hash); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := VerifyShard(bytes.NewReader(data[1:]), hash); !errors.Is(err, ErrHashMismatch) {
+		t.Errorf("expected a hash mismatch, got %v", err)
+	}
+	readErr := errors.New("connection reset")
+	if err := VerifyShard(iotest.ErrReader(readErr), hash); !errors.Is(err, readErr) || errors.Is(err, ErrHashMismatch) {
+		t.Errorf("expected the read error, got %v", err)
+	}
+
+	h := NewShardHasher()
+	h.Write(data[:4])
+	h.Write(data[4:])
+	if h.Sum() != hash || h.Verify(hash) != nil {
+		t.Errorf("expected %s, got %s", hash, h.Sum())
+	}
+}
@@ -0,0 +1,254 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Encryption versions of file contents, as stored in the encryptVersion
+// field of Drive files.
+const (
+	EncryptVersionAES    = "03-aes"     // AES-256-CTR, unauthenticated
+	EncryptVersionAESGCM = "04-aes-gcm" // AES-256-GCM in GCMChunkSize chunks
+)
+
+// GCMChunkSize is the plaintext size of each chunk of 04-aes-gcm content.
+// Every chunk is stored followed by its 16-byte tag.
+const GCMChunkSize = 64 * 1024
+
+// FileCipher encrypts and decrypts file contents for one encryption
+// version. Keys and IVs come from GenerateFileKey.
+type FileCipher interface {
+	// Version is the encryptVersion stored with files written by the cipher.
+	Version() string
+	// EncryptedSize is the stored size of plainSize bytes of content.
+	EncryptedSize(plainSize int64) int64
+	EncryptReader(src io.Reader, key, iv []byte) (io.Reader, error)
+	// EncryptedRange maps the plaintext bytes start to end, inclusive, to
+	// the stored bytes to fetch and the decrypted bytes to skip from there.
+	// An end of -1 stands for the end of the file, and is returned as such.
+	EncryptedRange(start, end int64) (encStart, encEnd, skip int64)
+	// DecryptReaderAt decrypts src, the stored content from encStart on,
+	// where encStart was returned by EncryptedRange or is 0. Ciphers that
+	// authenticate the end of the content fail once src ends before it,
+	// so a range is read only up to its length.
+	DecryptReaderAt(src io.Reader, key, iv []byte, encStart int64) (io.Reader, error)
+}
+
+var (
+	ciphersMu sync.RWMutex
+	ciphers   = map[string]FileCipher{}
+)
+
+// RegisterCipher makes c available to CipherFor under c.Version(), so files
+// can be written and read with another algorithm. It panics if the version
+// is empty or already has a cipher.
+func RegisterCipher(c FileCipher) {
+	version := c.Version()
+	ciphersMu.Lock()
+	defer ciphersMu.Unlock()
+	if _, err := builtinCipher(version); err == nil || ciphers[version] != nil {
+		panic(fmt.Sprintf("crypto: cipher for encryption version %q registered twice", version))
+	}
+	ciphers[version] = c
+}
+
+// CipherFor returns the cipher of an encryption version. The empty version,
+// left by older clients, is 03-aes.
+func CipherFor(version string) (FileCipher, error) {
+	if c, err := builtinCipher(version); err == nil {
+		return c, nil
+	}
+	ciphersMu.RLock()
+	defer ciphersMu.RUnlock()
+	if c, ok := ciphers[version]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("unsupported encryption version %q", version)
+}
+
+func builtinCipher(version string) (FileCipher, error) {
+	switch version {
+	case "", EncryptVersionAES:
+		return ctrCipher{}, nil
+	case EncryptVersionAESGCM:
+		return gcmCipher{}, nil
+	}
+	return nil, fmt.Errorf("unsupported encryption version %q", version)
+}
+
+// ctrCipher is 03-aes: the whole file is one AES-256-CTR stream.
+type ctrCipher struct{}
+
+func (ctrCipher) Version() string { return EncryptVersionAES }
+
+func (ctrCipher) EncryptedSize(plainSize int64) int64 { return plainSize }
+
+func (ctrCipher) EncryptReader(src io.Reader, key, iv []byte) (io.Reader, error) {
+	return EncryptReader(src, key, iv)
+}
+
+func (ctrCipher) EncryptedRange(start, end int64) (int64, int64, int64) {
+	skip := start % aes.BlockSize
+	return start - skip, end, skip
+}
+
+func (ctrCipher) DecryptReaderAt(src io.Reader, key, iv []byte, encStart int64) (io.Reader, error) {
+	if encStart%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("offset %d is not block aligned", encStart)
+	}
+	return DecryptReader(src, key, AddToIV(iv, encStart/aes.BlockSize))
+}
+
+// gcmCipher is 04-aes-gcm: chunk i is sealed with AES-256-GCM under the
+// nonce iv[:8] | uint32(i), and the last chunk with gcmLastChunkAD, so
+// chunks can neither be altered, reordered nor dropped from the end.
+type gcmCipher struct{}
+
+// gcmLastChunkAD is the additional data of the last chunk of a file.
+var gcmLastChunkAD = []byte{1}
+
+const (
+	gcmNonceSize   = 12
+	gcmStoredChunk = GCMChunkSize + gcmTagSize
+)
+
+func (gcmCipher) Version() string { return EncryptVersionAESGCM }
+
+// EncryptedSize counts a chunk for empty content too: the last chunk is
+// always sealed, so an empty file cannot pass for a truncated one.
+func (gcmCipher) EncryptedSize(plainSize int64) int64 {
+	chunks := max((plainSize+GCMChunkSize-1)/GCMChunkSize, 1)
+	return plainSize + chunks*gcmTagSize
+}
+
+func (gcmCipher) EncryptReader(src io.Reader, key, iv []byte) (io.Reader, error) {
+	return newGCMChunkReader(src, key, iv, 0, true)
+}
+
+func (gcmCipher) EncryptedRange(start, end int64) (int64, int64, int64) {
+	encStart := start / GCMChunkSize * gcmStoredChunk
+	if end < 0 {
+		return encStart, -1, start % GCMChunkSize
+	}
+	return encStart, (end/GCMChunkSize+1)*gcmStoredChunk - 1, start % GCMChunkSize
+}
+
+// DecryptReaderAt fails with io.ErrUnexpectedEOF once src ends before the
+// last chunk, after returning the chunks it holds, so readers of a range
+// ending mid-file stop before reaching the error.
+func (gcmCipher) DecryptReaderAt(src io.Reader, key, iv []byte, encStart int64) (io.Reader, error) {
+	if encStart%gcmStoredChunk != 0 {
+		return nil, fmt.Errorf("offset %d is not chunk aligned", encStart)
+	}
+	return newGCMChunkReader(src, key, iv, uint32(encStart/gcmStoredChunk), false)
+}
+
+// gcmChunkReader seals or opens src chunk by chunk. It reads a byte past
+// each chunk to tell whether it is the last.
+type gcmChunkReader struct {
+	src     io.Reader
+	aead    cipher.AEAD
+	nonce   [gcmNonceSize]byte
+	counter uint32
+	seal    bool
+	in, buf []byte
+	ahead   int    // Bytes of the next chunk already in in
+	out     []byte // Processed bytes not read yet
+	err     error
+}
+
+func newGCMChunkReader(src io.Reader, key, iv []byte, counter uint32, seal bool) (*gcmChunkReader, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	if len(iv) < 8 {
+		return nil, fmt.Errorf("iv of %d bytes is too short", len(iv))
+	}
+	r := &gcmChunkReader{
+		src:     src,
+		aead:    aead,
+		counter: counter,
+		seal:    seal,
+		in:      make([]byte, gcmStoredChunk+1),
+		buf:     make([]byte, 0, gcmStoredChunk),
+	}
+	copy(r.nonce[:8], iv)
+	return r, nil
+}
+
+func (r *gcmChunkReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.next()
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// next processes the next chunk of src into r.out.
+func (r *gcmChunkReader) next() {
+	size := GCMChunkSize
+	if !r.seal {
+		size = gcmStoredChunk
+	}
+	n, err := io.ReadFull(r.src, r.in[r.ahead:size+1])
+	n += r.ahead
+	r.ahead = 0
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		r.err = io.EOF
+		if n == 0 && r.counter > 0 {
+			return // Past the last chunk
+		}
+		if n == 0 && !r.seal {
+			r.err = fmt.Errorf("content is empty, without the last chunk: %w", io.ErrUnexpectedEOF)
+			return
+		}
+		// Empty content is sealed as an empty last chunk
+	case err != nil:
+		r.err = err
+		return
+	}
+	last := n <= size
+	chunk := r.in[:min(n, size)]
+
+	binary.BigEndian.PutUint32(r.nonce[8:], r.counter)
+	var ad []byte
+	if last {
+		ad = gcmLastChunkAD
+	}
+	if r.seal {
+		r.out = r.aead.Seal(r.buf[:0], r.nonce[:], chunk, ad)
+	} else {
+		out, err := r.aead.Open(r.buf[:0], r.nonce[:], chunk, ad)
+		if err != nil && last {
+			// src was cut short after a chunk that is not the last
+			if out, err = r.aead.Open(r.buf[:0], r.nonce[:], chunk, nil); err == nil {
+				r.err = fmt.Errorf("content ends at chunk %d, before the last chunk: %w", r.counter, io.ErrUnexpectedEOF)
+			}
+		}
+		if err != nil {
+			r.out, r.err = nil, fmt.Errorf("failed to authenticate chunk %d: %w", r.counter, err)
+			return
+		}
+		r.out = out
+	}
+	if !last {
+		r.in[0] = r.in[size]
+		r.ahead = 1
+	}
+	r.counter++
+}
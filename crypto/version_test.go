@@ -0,0 +1,173 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCipherFor(t *testing.T) {
+	testCases := []struct {
+		version string
+		want    string
+		wantErr bool
+	}{
+		{version: "", want: EncryptVersionAES},
+		{version: EncryptVersionAES, want: EncryptVersionAES},
+		{version: EncryptVersionAESGCM, want: EncryptVersionAESGCM},
+		{version: "02-aes", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		fc, err := CipherFor(tc.version)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%q: expected an error", tc.version)
+			}
+			continue
+		}
+		if err != nil || fc.Version() != tc.want {
+			t.Errorf("%q: expected %s, got %v", tc.version, tc.want, err)
+		}
+	}
+}
+
+func TestFileCipherRoundTrip(t *testing.T) {
+	key := bytes.Repeat([]byte{0x11}, 32)
+	iv := bytes.Repeat([]byte{0x22}, 16)
+
+	for _, version := range []string{EncryptVersionAES, EncryptVersionAESGCM} {
+		fc, _ := CipherFor(version)
+		for _, size := range []int{0, 1, GCMChunkSize - 1, GCMChunkSize, 2*GCMChunkSize + 100} {
+			plain := bytes.Repeat([]byte("internxt"), size/8+1)[:size]
+			enc, err := fc.EncryptReader(bytes.NewReader(plain), key, iv)
+			if err != nil {
+				t.Fatalf("%s/%d: unexpected error: %v", version, size, err)
+			}
+			stored, _ := io.ReadAll(enc)
+			if int64(len(stored)) != fc.EncryptedSize(int64(size)) {
+				t.Errorf("%s/%d: expected %d stored bytes, got %d", version, size, fc.EncryptedSize(int64(size)), len(stored))
+			}
+
+			ranges := [][2]int64{{0, -1}, {5, 20}, {GCMChunkSize - 3, GCMChunkSize + 3}, {int64(size) / 2, -1}}
+			for _, r := range ranges {
+				if r[0] > int64(size) || r[1] >= int64(size) {
+					continue
+				}
+				encStart, encEnd, skip := fc.EncryptedRange(r[0], r[1])
+				src := stored[encStart:]
+				if encEnd >= 0 && encEnd < int64(len(stored)) {
+					src = stored[encStart : encEnd+1]
+				}
+				dec, err := fc.DecryptReaderAt(bytes.NewReader(src), key, iv, encStart)
+				if err != nil {
+					t.Fatalf("%s/%d %v: unexpected error: %v", version, size, r, err)
+				}
+				want := plain[r[0]:]
+				if r[1] >= 0 {
+					// A range ending mid-file is read up to its end only
+					want = plain[r[0] : r[1]+1]
+					dec = io.LimitReader(dec, skip+int64(len(want)))
+				}
+				got, err := io.ReadAll(dec)
+				if err != nil {
+					t.Fatalf("%s/%d %v: unexpected error: %v", version, size, r, err)
+				}
+				if got = got[skip:]; r[1] >= 0 {
+					got = got[:len(want)]
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("%s/%d %v: decrypted data mismatch", version, size, r)
+				}
+			}
+		}
+	}
+}
+
+func TestGCMTamperDetection(t *testing.T) {
+	key := bytes.Repeat([]byte{0x11}, 32)
+	iv := bytes.Repeat([]byte{0x22}, 16)
+	fc, _ := CipherFor(EncryptVersionAESGCM)
+	enc, _ := fc.EncryptReader(bytes.NewReader(make([]byte, 2*GCMChunkSize)), key, iv)
+	stored, _ := io.ReadAll(enc)
+
+	flipped := bytes.Clone(stored)
+	flipped[GCMChunkSize+gcmTagSize+10] ^= 1
+	swapped := append(bytes.Clone(stored[gcmStoredChunk:]), stored[:gcmStoredChunk]...)
+
+	for name, data := range map[string][]byte{"flipped bit": flipped, "swapped chunks": swapped, "truncated tag": stored[:len(stored)-1]} {
+		dec, _ := fc.DecryptReaderAt(bytes.NewReader(data), key, iv, 0)
+		if _, err := io.ReadAll(dec); err == nil || !strings.Contains(err.Error(), "failed to authenticate chunk") {
+			t.Errorf("%s: expected an authentication error, got %v", name, err)
+		}
+	}
+}
+
+func TestGCMTruncationDetection(t *testing.T) {
+	key := bytes.Repeat([]byte{0x11}, 32)
+	iv := bytes.Repeat([]byte{0x22}, 16)
+	fc, _ := CipherFor(EncryptVersionAESGCM)
+
+	for _, size := range []int{2 * GCMChunkSize, 2*GCMChunkSize + 100} {
+		plain := bytes.Repeat([]byte{0x33}, size)
+		enc, _ := fc.EncryptReader(bytes.NewReader(plain), key, iv)
+		stored, _ := io.ReadAll(enc)
+
+		dec, _ := fc.DecryptReaderAt(bytes.NewReader(stored), key, iv, 0)
+		if got, err := io.ReadAll(dec); err != nil || !bytes.Equal(got, plain) {
+			t.Fatalf("%d: expected the whole content, got %d bytes, %v", size, len(got), err)
+		}
+
+		// Cut at a chunk boundary, every chunk left authenticates
+		dec, _ = fc.DecryptReaderAt(bytes.NewReader(stored[:gcmStoredChunk]), key, iv, 0)
+		got, err := io.ReadAll(dec)
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%d: expected io.ErrUnexpectedEOF, got %v", size, err)
+		}
+		if !bytes.Equal(got, plain[:GCMChunkSize]) {
+			t.Errorf("%d: expected the chunk before the cut, got %d bytes", size, len(got))
+		}
+	}
+
+	// Empty content is stored as an empty last chunk, so an object cut to
+	// nothing does not pass for an empty file
+	enc, _ := fc.EncryptReader(bytes.NewReader(nil), key, iv)
+	stored, _ := io.ReadAll(enc)
+	if int64(len(stored)) != fc.EncryptedSize(0) || len(stored) != gcmTagSize {
+		t.Fatalf("expected an empty file to be stored in %d bytes, got %d", gcmTagSize, len(stored))
+	}
+	dec, _ := fc.DecryptReaderAt(bytes.NewReader(stored), key, iv, 0)
+	if got, err := io.ReadAll(dec); err != nil || len(got) != 0 {
+		t.Errorf("expected empty content, got %d bytes, %v", len(got), err)
+	}
+	dec, _ = fc.DecryptReaderAt(bytes.NewReader(nil), key, iv, 0)
+	if _, err := io.ReadAll(dec); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected io.ErrUnexpectedEOF for an empty stored object, got %v", err)
+	}
+}
+
+// testCipher is 03-aes under another version, to test RegisterCipher.
+type testCipher struct{ ctrCipher }
+
+func (testCipher) Version() string { return "99-test" }
+
+func TestRegisterCipher(t *testing.T) {
+	RegisterCipher(testCipher{})
+	fc, err := CipherFor("99-test")
+	if err != nil || fc.Version() != "99-test" {
+		t.Fatalf("expected the registered cipher, got %v", err)
+	}
+
+	for _, c := range []FileCipher{testCipher{}, ctrCipher{}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected registering %s again to panic", c.Version())
+				}
+			}()
+			RegisterCipher(c)
+		}()
+	}
+}
@@ -1,19 +1,39 @@
 package endpoints
 
 import (
+	"maps"
 	"net/url"
 	"strings"
 )
 
-// Config holds the base URL configuration for all API endpoints
+// Config holds the base URL configuration for all API endpoints.
+// DriveBaseURL and NetworkBaseURL, when set, override the drive and network
+// API roots derived from BaseURL (BaseURL + "/drive" and BaseURL + "/network"),
+// for setups that serve them from different hosts.
+//
+// Regions maps region names (e.g. "eu", "us") to network API roots; when
+// Region names one of them and NetworkBaseURL is unset, shard transfers go
+// through that region's gateway.
 type Config struct {
-	BaseURL string
+	BaseURL        string
+	DriveBaseURL   string
+	NetworkBaseURL string
+	Region         string
+	Regions        map[string]string
+}
+
+// DefaultRegions maps the regions of the production network gateways to
+// their API roots. Only the gateway the production network is known to run is
+// listed; add others to Config.Regions. Default sets Regions to a copy of it.
+var DefaultRegions = map[string]string{
+	"eu": "https://gateway.internxt.com/network",
 }
 
 // Default returns the production endpoints configuration
 func Default() *Config {
 	return &Config{
 		BaseURL: "https://gateway.internxt.com",
+		Regions: maps.Clone(DefaultRegions),
 	}
 }
 
@@ -24,8 +44,21 @@ func NewConfig(baseURL string) *Config {
 	}
 }
 
+// NewServiceConfig creates an endpoints config with independent drive and
+// network API roots, e.g. "https://drive.example.com/api" and
+// "https://bridge.example.com".
+func NewServiceConfig(driveBaseURL, networkBaseURL string) *Config {
+	return &Config{
+		DriveBaseURL:   strings.TrimSuffix(driveBaseURL, "/"),
+		NetworkBaseURL: strings.TrimSuffix(networkBaseURL, "/"),
+	}
+}
+
 // driveURL returns the base drive API URL
 func (c *Config) driveURL() string {
+	if c.DriveBaseURL != "" {
+		return c.DriveBaseURL
+	}
 	u, _ := url.JoinPath(c.BaseURL, "/drive")
 	return u
 }
@@ -36,6 +69,12 @@ func (c *Config) Drive() *DriveEndpoints {
 }
 
 func (c *Config) networkURL() string {
+	if c.NetworkBaseURL != "" {
+		return c.NetworkBaseURL
+	}
+	if u, ok := c.Regions[c.Region]; ok && c.Region != "" {
+		return strings.TrimSuffix(u, "/")
+	}
 	u, _ := url.JoinPath(c.BaseURL, "/network")
 	return u
 }
@@ -74,6 +113,24 @@ func (d *DriveEndpoints) Users() *UserEndpoints {
 	return &UserEndpoints{base: base}
 }
 
+// Workspaces returns workspace-related endpoints
+func (d *DriveEndpoints) Workspaces() *WorkspaceEndpoints {
+	base, _ := url.JoinPath(d.base, "/workspaces")
+	return &WorkspaceEndpoints{base: base}
+}
+
+// Sharings returns sharing-related endpoints
+func (d *DriveEndpoints) Sharings() *SharingEndpoints {
+	base, _ := url.JoinPath(d.base, "/sharings")
+	return &SharingEndpoints{base: base}
+}
+
+// Sends returns endpoints of Send links
+func (d *DriveEndpoints) Sends() *SendEndpoints {
+	base, _ := url.JoinPath(d.base, "/links")
+	return &SendEndpoints{base: base}
+}
+
 // AuthEndpoints : endpoints under /drive/auth
 type AuthEndpoints struct {
 	base string
@@ -96,6 +153,10 @@ type FileEndpoints struct {
 
 func (f *FileEndpoints) Create() string { return f.base }
 
+// List lists the user's files across folders, filtered by query parameters
+// such as updatedAt and status.
+func (f *FileEndpoints) List() string { return f.base }
+
 func (f *FileEndpoints) Meta(uuid string) string {
 	u, _ := url.JoinPath(f.base, uuid, "/meta")
 	return u
@@ -123,6 +184,10 @@ type FolderEndpoints struct {
 
 func (f *FolderEndpoints) Create() string { return f.base }
 
+// List lists the user's folders across the tree, filtered by query parameters
+// such as updatedAt and status.
+func (f *FolderEndpoints) List() string { return f.base }
+
 func (f *FolderEndpoints) Delete(uuid string) string {
 	u, _ := url.JoinPath(f.base, uuid)
 	return u
@@ -173,6 +238,160 @@ func (u *UserEndpoints) Refresh() string {
 	return path
 }
 
+func (u *UserEndpoints) Tier() string {
+	path, _ := url.JoinPath(u.base, "/tier")
+	return path
+}
+
+func (u *UserEndpoints) Referrals() string {
+	path, _ := url.JoinPath(u.base, "/referrals")
+	return path
+}
+
+func (u *UserEndpoints) PublicKey(email string) string {
+	path, _ := url.JoinPath(u.base, "/public-key", email)
+	return path
+}
+
+// WorkspaceEndpoints : endpoints under /drive/workspaces
+type WorkspaceEndpoints struct {
+	base string
+}
+
+func (w *WorkspaceEndpoints) List() string { return w.base }
+
+func (w *WorkspaceEndpoints) Credentials(workspaceID string) string {
+	u, _ := url.JoinPath(w.base, workspaceID, "/credentials")
+	return u
+}
+
+func (w *WorkspaceEndpoints) Usage(workspaceID string) string {
+	u, _ := url.JoinPath(w.base, workspaceID, "/usage")
+	return u
+}
+
+func (w *WorkspaceEndpoints) Members(workspaceID string) string {
+	u, _ := url.JoinPath(w.base, workspaceID, "/members")
+	return u
+}
+
+// Files creates files in the workspace's drive.
+func (w *WorkspaceEndpoints) Files(workspaceID string) string {
+	u, _ := url.JoinPath(w.base, workspaceID, "/files")
+	return u
+}
+
+// Folders creates folders in the workspace's drive.
+func (w *WorkspaceEndpoints) Folders(workspaceID string) string {
+	u, _ := url.JoinPath(w.base, workspaceID, "/folders")
+	return u
+}
+
+func (w *WorkspaceEndpoints) ContentFolders(workspaceID, parentUUID string) string {
+	u, _ := url.JoinPath(w.base, workspaceID, "/folders", parentUUID, "/folders")
+	return u
+}
+
+func (w *WorkspaceEndpoints) ContentFiles(workspaceID, parentUUID string) string {
+	u, _ := url.JoinPath(w.base, workspaceID, "/folders", parentUUID, "/files")
+	return u
+}
+
+// SharingEndpoints : endpoints under /drive/sharings
+type SharingEndpoints struct {
+	base string
+}
+
+func (s *SharingEndpoints) Create() string { return s.base }
+
+func (s *SharingEndpoints) Files() string {
+	u, _ := url.JoinPath(s.base, "/files")
+	return u
+}
+
+func (s *SharingEndpoints) Folders() string {
+	u, _ := url.JoinPath(s.base, "/folders")
+	return u
+}
+
+func (s *SharingEndpoints) SharedWithMeFiles() string {
+	u, _ := url.JoinPath(s.base, "/shared-with-me/files")
+	return u
+}
+
+func (s *SharingEndpoints) SharedWithMeFolders() string {
+	u, _ := url.JoinPath(s.base, "/shared-with-me/folders")
+	return u
+}
+
+func (s *SharingEndpoints) ItemFolders(sharedFolderID string) string {
+	u, _ := url.JoinPath(s.base, "/items", sharedFolderID, "/folders")
+	return u
+}
+
+func (s *SharingEndpoints) ItemFiles(sharedFolderID string) string {
+	u, _ := url.JoinPath(s.base, "/items", sharedFolderID, "/files")
+	return u
+}
+
+func (s *SharingEndpoints) Sharing(sharingID string) string {
+	u, _ := url.JoinPath(s.base, sharingID)
+	return u
+}
+
+func (s *SharingEndpoints) Password(sharingID string) string {
+	u, _ := url.JoinPath(s.base, sharingID, "/password")
+	return u
+}
+
+// Info describes the sharing of an item, including link usage.
+func (s *SharingEndpoints) Info(itemType, itemID string) string {
+	u, _ := url.JoinPath(s.base, itemType, itemID, "/info")
+	return u
+}
+
+func (s *SharingEndpoints) Roles() string {
+	u, _ := url.JoinPath(s.base, "/roles")
+	return u
+}
+
+// Invites lists the invitations received by the user.
+func (s *SharingEndpoints) Invites() string {
+	u, _ := url.JoinPath(s.base, "/invites")
+	return u
+}
+
+func (s *SharingEndpoints) SendInvite() string {
+	u, _ := url.JoinPath(s.base, "/invites/send")
+	return u
+}
+
+func (s *SharingEndpoints) Invite(inviteID string) string {
+	u, _ := url.JoinPath(s.base, "/invites", inviteID)
+	return u
+}
+
+func (s *SharingEndpoints) AcceptInvite(inviteID string) string {
+	u, _ := url.JoinPath(s.base, "/invites", inviteID, "/accept")
+	return u
+}
+
+// Meta resolves a public sharing from its ID and code, without an account.
+func (s *SharingEndpoints) Meta(sharingID string) string {
+	u, _ := url.JoinPath(s.base, sharingID, "/meta")
+	return u
+}
+
+func (s *SharingEndpoints) PublicItemFolders(sharedFolderID string) string {
+	u, _ := url.JoinPath(s.base, "/public/items", sharedFolderID, "/folders")
+	return u
+}
+
+func (s *SharingEndpoints) PublicItemFiles(sharedFolderID string) string {
+	u, _ := url.JoinPath(s.base, "/public/items", sharedFolderID, "/files")
+	return u
+}
+
 // NetworkEndpoints : endpoints under /buckets and /v2/buckets
 type NetworkEndpoints struct {
 	base string
@@ -192,3 +411,22 @@ func (b *NetworkEndpoints) FinishUpload(bucketID string) string {
 	u, _ := url.JoinPath(b.base, "/v2/buckets", bucketID, "/files/finish")
 	return u
 }
+
+// AbortUpload is not part of the documented network API, see
+// buckets.AbortMultipartUpload
+func (b *NetworkEndpoints) AbortUpload(bucketID string) string {
+	u, _ := url.JoinPath(b.base, "/v2/buckets", bucketID, "/files/abort")
+	return u
+}
+
+// SendEndpoints : endpoints under /drive/links
+type SendEndpoints struct {
+	base string
+}
+
+func (s *SendEndpoints) Create() string { return s.base }
+
+func (s *SendEndpoints) Link(id string) string {
+	u, _ := url.JoinPath(s.base, id)
+	return u
+}
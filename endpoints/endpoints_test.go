@@ -12,19 +12,53 @@ func TestEndpointConstruction(t *testing.T) {
 	}{
 		{"Auth Login", cfg.Drive().Auth().Login(), "https://gateway.internxt.com/drive/auth/login"},
 		{"File Create", cfg.Drive().Files().Create(), "https://gateway.internxt.com/drive/files"},
+		{"File List", cfg.Drive().Files().List(), "https://gateway.internxt.com/drive/files"},
 		{"File Meta", cfg.Drive().Files().Meta("test-uuid"), "https://gateway.internxt.com/drive/files/test-uuid/meta"},
 		{"File Delete", cfg.Drive().Files().Delete("test-uuid"), "https://gateway.internxt.com/drive/files/test-uuid"},
 		{"Folder Create", cfg.Drive().Folders().Create(), "https://gateway.internxt.com/drive/folders"},
+		{"Folder List", cfg.Drive().Folders().List(), "https://gateway.internxt.com/drive/folders"},
 		{"Folder Delete", cfg.Drive().Folders().Delete("test-uuid"), "https://gateway.internxt.com/drive/folders/test-uuid"},
 		{"Folder ContentFolders", cfg.Drive().Folders().ContentFolders("parent-uuid"), "https://gateway.internxt.com/drive/folders/content/parent-uuid/folders"},
 		{"Folder ContentFiles", cfg.Drive().Folders().ContentFiles("parent-uuid"), "https://gateway.internxt.com/drive/folders/content/parent-uuid/files"},
 		{"User Usage", cfg.Drive().Users().Usage(), "https://gateway.internxt.com/drive/users/usage"},
 		{"User Limit", cfg.Drive().Users().Limit(), "https://gateway.internxt.com/drive/users/limit"},
+		{"User Tier", cfg.Drive().Users().Tier(), "https://gateway.internxt.com/drive/users/tier"},
+		{"User Referrals", cfg.Drive().Users().Referrals(), "https://gateway.internxt.com/drive/users/referrals"},
+		{"User Public Key", cfg.Drive().Users().PublicKey("a@example.com"), "https://gateway.internxt.com/drive/users/public-key/a@example.com"},
 		{"Network FileInfo", cfg.Network().FileInfo("bucket-123", "file-456"), "https://gateway.internxt.com/network/buckets/bucket-123/files/file-456/info"},
 		{"Network StartUpload", cfg.Network().StartUpload("bucket-123"), "https://gateway.internxt.com/network/v2/buckets/bucket-123/files/start"},
 		{"Network FinishUpload", cfg.Network().FinishUpload("bucket-123"), "https://gateway.internxt.com/network/v2/buckets/bucket-123/files/finish"},
+		{"Network AbortUpload", cfg.Network().AbortUpload("bucket-123"), "https://gateway.internxt.com/network/v2/buckets/bucket-123/files/abort"},
 		{"File Check Files Existence", cfg.Drive().Folders().CheckFilesExistence("parent-uuid"), "https://gateway.internxt.com/drive/folders/content/parent-uuid/files/existence"},
 		{"File Thumbnail", cfg.Drive().Files().Thumbnail(), "https://gateway.internxt.com/drive/files/thumbnail"},
+		{"Workspaces List", cfg.Drive().Workspaces().List(), "https://gateway.internxt.com/drive/workspaces"},
+		{"Workspace Credentials", cfg.Drive().Workspaces().Credentials("ws-123"), "https://gateway.internxt.com/drive/workspaces/ws-123/credentials"},
+		{"Workspace Usage", cfg.Drive().Workspaces().Usage("ws-123"), "https://gateway.internxt.com/drive/workspaces/ws-123/usage"},
+		{"Workspace Members", cfg.Drive().Workspaces().Members("ws-123"), "https://gateway.internxt.com/drive/workspaces/ws-123/members"},
+		{"Workspace Files", cfg.Drive().Workspaces().Files("ws-123"), "https://gateway.internxt.com/drive/workspaces/ws-123/files"},
+		{"Workspace Folders", cfg.Drive().Workspaces().Folders("ws-123"), "https://gateway.internxt.com/drive/workspaces/ws-123/folders"},
+		{"Workspace Content Folders", cfg.Drive().Workspaces().ContentFolders("ws-123", "f-1"), "https://gateway.internxt.com/drive/workspaces/ws-123/folders/f-1/folders"},
+		{"Workspace Content Files", cfg.Drive().Workspaces().ContentFiles("ws-123", "f-1"), "https://gateway.internxt.com/drive/workspaces/ws-123/folders/f-1/files"},
+		{"Send Create", cfg.Drive().Sends().Create(), "https://gateway.internxt.com/drive/links"},
+		{"Send Link", cfg.Drive().Sends().Link("link-1"), "https://gateway.internxt.com/drive/links/link-1"},
+		{"Sharing Create", cfg.Drive().Sharings().Create(), "https://gateway.internxt.com/drive/sharings"},
+		{"Sharing Info", cfg.Drive().Sharings().Info("file", "item-1"), "https://gateway.internxt.com/drive/sharings/file/item-1/info"},
+		{"Sharing Files", cfg.Drive().Sharings().Files(), "https://gateway.internxt.com/drive/sharings/files"},
+		{"Sharing Folders", cfg.Drive().Sharings().Folders(), "https://gateway.internxt.com/drive/sharings/folders"},
+		{"Sharing Shared With Me Files", cfg.Drive().Sharings().SharedWithMeFiles(), "https://gateway.internxt.com/drive/sharings/shared-with-me/files"},
+		{"Sharing Shared With Me Folders", cfg.Drive().Sharings().SharedWithMeFolders(), "https://gateway.internxt.com/drive/sharings/shared-with-me/folders"},
+		{"Sharing Item Folders", cfg.Drive().Sharings().ItemFolders("f-1"), "https://gateway.internxt.com/drive/sharings/items/f-1/folders"},
+		{"Sharing Item Files", cfg.Drive().Sharings().ItemFiles("f-1"), "https://gateway.internxt.com/drive/sharings/items/f-1/files"},
+		{"Sharing Update", cfg.Drive().Sharings().Sharing("sh-1"), "https://gateway.internxt.com/drive/sharings/sh-1"},
+		{"Sharing Password", cfg.Drive().Sharings().Password("sh-1"), "https://gateway.internxt.com/drive/sharings/sh-1/password"},
+		{"Sharing Roles", cfg.Drive().Sharings().Roles(), "https://gateway.internxt.com/drive/sharings/roles"},
+		{"Sharing Invites", cfg.Drive().Sharings().Invites(), "https://gateway.internxt.com/drive/sharings/invites"},
+		{"Sharing Send Invite", cfg.Drive().Sharings().SendInvite(), "https://gateway.internxt.com/drive/sharings/invites/send"},
+		{"Sharing Invite", cfg.Drive().Sharings().Invite("inv-1"), "https://gateway.internxt.com/drive/sharings/invites/inv-1"},
+		{"Sharing Accept Invite", cfg.Drive().Sharings().AcceptInvite("inv-1"), "https://gateway.internxt.com/drive/sharings/invites/inv-1/accept"},
+		{"Sharing Meta", cfg.Drive().Sharings().Meta("sh-1"), "https://gateway.internxt.com/drive/sharings/sh-1/meta"},
+		{"Sharing Public Item Folders", cfg.Drive().Sharings().PublicItemFolders("f-1"), "https://gateway.internxt.com/drive/sharings/public/items/f-1/folders"},
+		{"Sharing Public Item Files", cfg.Drive().Sharings().PublicItemFiles("f-1"), "https://gateway.internxt.com/drive/sharings/public/items/f-1/files"},
 	}
 
 	for _, tt := range tests {
@@ -35,3 +69,42 @@ func TestEndpointConstruction(t *testing.T) {
 		})
 	}
 }
+
+func TestServiceOverrides(t *testing.T) {
+	tests := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{"Separate Drive", NewServiceConfig("https://drive.example.com/api/", "https://bridge.example.com").Drive().Files().Create(), "https://drive.example.com/api/files"},
+		{"Separate Network", NewServiceConfig("https://drive.example.com/api/", "https://bridge.example.com").Network().FileInfo("b", "f"), "https://bridge.example.com/buckets/b/files/f/info"},
+		{"Drive override only", (&Config{BaseURL: "https://gw.example.com", DriveBaseURL: "https://drive.example.com"}).Drive().Users().Usage(), "https://drive.example.com/users/usage"},
+		{"Region network", (&Config{BaseURL: "https://gw.example.com", Region: "us", Regions: map[string]string{"eu": "https://eu.example.com", "us": "https://us.example.com/"}}).Network().FileInfo("b", "f"), "https://us.example.com/buckets/b/files/f/info"},
+		{"Region keeps drive", (&Config{BaseURL: "https://gw.example.com", Region: "us", Regions: map[string]string{"us": "https://us.example.com"}}).Drive().Files().Create(), "https://gw.example.com/drive/files"},
+		{"Unknown region falls back", (&Config{BaseURL: "https://gw.example.com", Region: "ap", Regions: map[string]string{"us": "https://us.example.com"}}).Network().StartUpload("b"), "https://gw.example.com/network/v2/buckets/b/files/start"},
+		{"Default EU region", (&Config{BaseURL: "https://gateway.internxt.com", Region: "eu", Regions: DefaultRegions}).Network().FileInfo("b", "f"), "https://gateway.internxt.com/network/buckets/b/files/f/info"},
+		{"Network falls back to BaseURL", (&Config{BaseURL: "https://gw.example.com", DriveBaseURL: "https://drive.example.com"}).Network().StartUpload("b"), "https://gw.example.com/network/v2/buckets/b/files/start"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.expected {
+				t.Errorf("%s:\ngot:      %s\nexpected: %s", tt.name, tt.got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDefaultRegions(t *testing.T) {
+	cfg := Default()
+	if len(cfg.Regions) != len(DefaultRegions) || cfg.Regions["eu"] != DefaultRegions["eu"] {
+		t.Fatalf("expected the default regions, got %v", cfg.Regions)
+	}
+	cfg.Regions["eu"] = "https://eu.example.com"
+	if DefaultRegions["eu"] == cfg.Regions["eu"] {
+		t.Error("expected Default to copy DefaultRegions")
+	}
+	if got := cfg.Network().StartUpload("b"); got != "https://gateway.internxt.com/network/v2/buckets/b/files/start" {
+		t.Errorf("expected no region to be selected by default, got %s", got)
+	}
+}
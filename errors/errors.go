@@ -2,6 +2,7 @@ package errors
 
 import (
 	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -9,19 +10,121 @@ import (
 	"time"
 )
 
+// Sentinel errors matched by HTTPError through errors.Is, so callers can
+// check the kind of failure without inspecting status codes or messages.
+var (
+	ErrNotFound      = stderrors.New("not found")
+	ErrUnauthorized  = stderrors.New("unauthorized")
+	ErrQuotaExceeded = stderrors.New("quota exceeded")
+	ErrAlreadyExists = stderrors.New("already exists")
+	ErrRateLimited   = stderrors.New("rate limited")
+	ErrCircuitOpen   = stderrors.New("circuit open")
+	ErrFileTooLarge  = stderrors.New("file too large")
+
+	// ErrRangeNotSatisfied is returned when the storage cannot serve the
+	// requested byte range, or answers with different bytes than asked for.
+	ErrRangeNotSatisfied = stderrors.New("range not satisfied")
+)
+
+// CircuitOpenError is returned without sending the request while a host's
+// circuit breaker is open. It matches ErrCircuitOpen through errors.Is.
+type CircuitOpenError struct {
+	Host  string
+	Until time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit open for %s until %s", e.Host, e.Until.Format(time.RFC3339))
+}
+
+// Is reports whether target is ErrCircuitOpen.
+func (e *CircuitOpenError) Is(target error) bool {
+	return target == ErrCircuitOpen
+}
+
+// RetryAfter returns how long until the circuit allows requests again.
+func (e *CircuitOpenError) RetryAfter() time.Duration {
+	return max(time.Until(e.Until), 0)
+}
+
+// QuotaExceededError is returned before an upload that does not fit in the
+// space left in the storage quota. It matches ErrQuotaExceeded through
+// errors.Is.
+type QuotaExceededError struct {
+	Size      int64 // Bytes to upload
+	Available int64 // Bytes left in the quota
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded: file of %d bytes needs %d bytes more than the %d available", e.Size, e.Shortfall(), e.Available)
+}
+
+// Is reports whether target is ErrQuotaExceeded.
+func (e *QuotaExceededError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// Shortfall returns how many bytes must be freed for the upload to fit.
+func (e *QuotaExceededError) Shortfall() int64 {
+	return e.Size - e.Available
+}
+
 // HTTPError preserves HTTP response details
 type HTTPError struct {
 	Response  *http.Response
 	Operation string
 	Message   string
 	Body      []byte
+	Code      string // Backend error code ("code", else "statusCode"), e.g. CodeMaxSpaceReached
+	RequestID string // Server's X-Request-Id, or the one the request was sent with
 }
 
+// Error codes returned by the backend in HTTPError.Code.
+const (
+	CodeMaxSpaceReached     = "MAX_SPACE_REACHED"
+	CodeFileAlreadyExists   = "FILE_ALREADY_EXISTS"
+	CodeFolderAlreadyExists = "FOLDER_ALREADY_EXISTS"
+	CodeFileNotFound        = "FILE_NOT_FOUND"
+	CodeFolderNotFound      = "FOLDER_NOT_FOUND"
+)
+
 func (e *HTTPError) Error() string {
+	details := fmt.Sprintf("status %d", e.Response.StatusCode)
+	if e.RequestID != "" {
+		details += ", request id " + e.RequestID
+	}
 	if e.Message != "" {
-		return fmt.Sprintf("%s: %s (status %d)", e.Operation, e.Message, e.Response.StatusCode)
+		return fmt.Sprintf("%s: %s (%s)", e.Operation, e.Message, details)
+	}
+	return fmt.Sprintf("%s: %s", e.Operation, details)
+}
+
+// Is reports whether target is the sentinel error matching the backend error
+// code or, failing that, the status code.
+func (e *HTTPError) Is(target error) bool {
+	switch e.Code {
+	case CodeMaxSpaceReached:
+		return target == ErrQuotaExceeded
+	case CodeFileAlreadyExists, CodeFolderAlreadyExists:
+		return target == ErrAlreadyExists
+	case CodeFileNotFound, CodeFolderNotFound:
+		return target == ErrNotFound
 	}
-	return fmt.Sprintf("%s: status %d", e.Operation, e.Response.StatusCode)
+	switch e.Response.StatusCode {
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusUnauthorized:
+		return target == ErrUnauthorized
+	case http.StatusPaymentRequired, http.StatusInsufficientStorage:
+		return target == ErrQuotaExceeded
+	case http.StatusConflict:
+		return target == ErrAlreadyExists
+	case http.StatusTooManyRequests:
+		return target == ErrRateLimited
+	case http.StatusRequestedRangeNotSatisfiable:
+		return target == ErrRangeNotSatisfied
+	}
+	return false
 }
 
 // Temporary implements net.Error interface
@@ -31,7 +134,8 @@ func (e *HTTPError) Temporary() bool {
 }
 
 // RetryAfter returns how long to wait before retrying based on
-// rate limit headers in the response
+// rate limit headers in the response: Retry-After, or X-RateLimit-Reset
+// given either as seconds to wait or as a Unix timestamp
 func (e *HTTPError) RetryAfter() time.Duration {
 	if v := e.Response.Header.Get("Retry-After"); v != "" {
 		if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
@@ -43,9 +147,26 @@ func (e *HTTPError) RetryAfter() time.Duration {
 			}
 		}
 	}
+	if v := e.Response.Header.Get("X-RateLimit-Reset"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			if n < unixTimestampThreshold {
+				return time.Duration(n) * time.Second
+			}
+			if delay := time.Until(time.Unix(n, 0)); delay > 0 {
+				return delay
+			}
+		}
+	}
 	return 0
 }
 
+// requestIDHeader matches config.RequestIDHeader.
+const requestIDHeader = "X-Request-Id"
+
+// unixTimestampThreshold separates X-RateLimit-Reset values that are
+// relative delays in seconds from absolute Unix timestamps.
+const unixTimestampThreshold = 1_000_000_000
+
 // StatusCode returns the HTTP status code
 func (e *HTTPError) StatusCode() int {
 	return e.Response.StatusCode
@@ -59,11 +180,17 @@ func NewHTTPError(resp *http.Response, operation string) error {
 		Response:  resp,
 		Operation: operation,
 		Body:      body,
+		RequestID: resp.Header.Get(requestIDHeader),
+	}
+	if httpErr.RequestID == "" && resp.Request != nil {
+		httpErr.RequestID = resp.Request.Header.Get(requestIDHeader)
 	}
 
 	var backendErr struct {
-		Error   string `json:"error"`
-		Message string `json:"message"`
+		Error      string          `json:"error"`
+		Message    string          `json:"message"`
+		Code       json.RawMessage `json:"code"`
+		StatusCode json.RawMessage `json:"statusCode"`
 	}
 
 	if json.Unmarshal(body, &backendErr) == nil {
@@ -72,9 +199,26 @@ func NewHTTPError(resp *http.Response, operation string) error {
 		} else if backendErr.Error != "" {
 			httpErr.Message = backendErr.Error
 		}
+		httpErr.Code = rawCode(backendErr.Code)
+		if httpErr.Code == "" {
+			httpErr.Code = rawCode(backendErr.StatusCode)
+		}
 	} else {
 		httpErr.Message = string(body)
 	}
 
 	return httpErr
 }
+
+// rawCode returns a JSON string or number as a string, and "" otherwise.
+func rawCode(raw json.RawMessage) string {
+	var str string
+	if json.Unmarshal(raw, &str) == nil {
+		return str
+	}
+	var num json.Number
+	if json.Unmarshal(raw, &num) == nil {
+		return num.String()
+	}
+	return ""
+}
@@ -1,7 +1,12 @@
 package errors
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -112,3 +117,127 @@ func TestTemporary(t *testing.T) {
 		}
 	}
 }
+
+func TestHTTPErrorIs(t *testing.T) {
+	sentinels := []error{ErrNotFound, ErrUnauthorized, ErrQuotaExceeded, ErrAlreadyExists, ErrRateLimited, ErrRangeNotSatisfied}
+
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusPaymentRequired, ErrQuotaExceeded},
+		{http.StatusInsufficientStorage, ErrQuotaExceeded},
+		{http.StatusConflict, ErrAlreadyExists},
+		{http.StatusTooManyRequests, ErrRateLimited},
+		{http.StatusRequestedRangeNotSatisfiable, ErrRangeNotSatisfied},
+		{http.StatusInternalServerError, nil},
+	}
+
+	for _, tt := range tests {
+		var err error = &HTTPError{Response: newHTTPResponse(tt.status, nil), Operation: "op"}
+		err = fmt.Errorf("failed to do op: %w", err)
+
+		for _, sentinel := range sentinels {
+			if got := errors.Is(err, sentinel); got != (sentinel == tt.want) {
+				t.Errorf("status %d: errors.Is(err, %v) = %v", tt.status, sentinel, got)
+			}
+		}
+	}
+}
+
+func TestRetryAfter_RateLimitReset(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		min     time.Duration
+		max     time.Duration
+	}{
+		{"relative seconds", map[string]string{"X-RateLimit-Reset": "12"}, 12 * time.Second, 12 * time.Second},
+		{"unix timestamp", map[string]string{"X-RateLimit-Reset": strconv.FormatInt(time.Now().Add(30*time.Second).Unix(), 10)}, 28 * time.Second, 31 * time.Second},
+		{"past timestamp", map[string]string{"X-RateLimit-Reset": strconv.FormatInt(time.Now().Add(-30*time.Second).Unix(), 10)}, 0, 0},
+		{"Retry-After wins", map[string]string{"Retry-After": "3", "X-RateLimit-Reset": "12"}, 3 * time.Second, 3 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &HTTPError{Response: newHTTPResponse(429, tt.headers)}
+			if got := e.RetryAfter(); got < tt.min || got > tt.max {
+				t.Errorf("RetryAfter() = %v, want within [%v, %v]", got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestNewHTTPErrorRequestID(t *testing.T) {
+	sent, _ := http.NewRequest(http.MethodGet, "https://example.com", nil)
+	sent.Header.Set("X-Request-Id", "client-id")
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantID  string
+		wantErr string
+	}{
+		{"server id", map[string]string{"X-Request-Id": "server-id"}, "server-id", `op: boom (status 500, request id server-id)`},
+		{"falls back to sent id", nil, "client-id", `op: boom (status 500, request id client-id)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := newHTTPResponse(http.StatusInternalServerError, tt.headers)
+			resp.Body = io.NopCloser(strings.NewReader(`{"message":"boom"}`))
+			resp.Request = sent
+
+			err := NewHTTPError(resp, "op").(*HTTPError)
+			if err.RequestID != tt.wantID {
+				t.Errorf("expected RequestID %q, got %q", tt.wantID, err.RequestID)
+			}
+			if err.Error() != tt.wantErr {
+				t.Errorf("expected %q, got %q", tt.wantErr, err.Error())
+			}
+		})
+	}
+
+	plain := &HTTPError{Response: newHTTPResponse(http.StatusNotFound, nil), Operation: "op"}
+	if plain.Error() != "op: status 404" {
+		t.Errorf("expected error without request id unchanged, got %q", plain.Error())
+	}
+}
+
+func TestNewHTTPErrorCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		wantCode string
+		wantMsg  string
+		is       error
+	}{
+		{"string code", 402, `{"code":"MAX_SPACE_REACHED","message":"Max space used"}`, CodeMaxSpaceReached, "Max space used", ErrQuotaExceeded},
+		{"code overrides status", 400, `{"code":"FILE_ALREADY_EXISTS","error":"Bad Request"}`, CodeFileAlreadyExists, "Bad Request", ErrAlreadyExists},
+		{"numeric statusCode", 404, `{"statusCode":404,"message":"Not Found"}`, "404", "Not Found", ErrNotFound},
+		{"no code", 500, `{"message":"boom"}`, "", "boom", nil},
+		{"not json", 500, `gateway timeout`, "", "gateway timeout", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := newHTTPResponse(tt.status, nil)
+			resp.Body = io.NopCloser(strings.NewReader(tt.body))
+
+			err := NewHTTPError(resp, "op")
+			httpErr := err.(*HTTPError)
+			if httpErr.Code != tt.wantCode {
+				t.Errorf("expected Code %q, got %q", tt.wantCode, httpErr.Code)
+			}
+			if httpErr.Message != tt.wantMsg {
+				t.Errorf("expected Message %q, got %q", tt.wantMsg, httpErr.Message)
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("expected errors.Is(err, %v)", tt.is)
+			}
+		})
+	}
+}
@@ -0,0 +1,269 @@
+// Package events turns the Drive "updated since" listings into a stream of
+// create, modify and delete events. A Poller periodically asks the server for
+// the files and folders updated after its cursor and advances the cursor to
+// the newest change seen, so each change is reported once. This is the basis
+// for near-real-time sync and mount poll intervals.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/errors"
+	"github.com/internxt/rclone-adapter/folders"
+)
+
+// DefaultInterval is used by NewPoller for non-positive intervals.
+const DefaultInterval = time.Minute
+
+// pageSize is the number of items requested per page.
+const pageSize = 50
+
+// Kind is the type of change an Event reports.
+type Kind string
+
+const (
+	Created  Kind = "create"
+	Modified Kind = "modify"
+	Deleted  Kind = "delete"
+)
+
+// Event is a change to a file or folder. Exactly one of File and Folder is set.
+type Event struct {
+	Kind       Kind
+	UUID       string
+	ParentUUID string
+	UpdatedAt  time.Time
+	File       *folders.File
+	Folder     *folders.Folder
+}
+
+// IsFolder reports whether the event is about a folder.
+func (e Event) IsFolder() bool {
+	return e.Folder != nil
+}
+
+// Poller polls the Drive API for changes made after its cursor.
+type Poller struct {
+	cfg      *config.Config
+	interval time.Duration
+	since    time.Time
+	seen     map[string]time.Time // items already reported at the cursor time
+}
+
+// NewPoller creates a Poller reporting changes made after since, polling
+// every interval.
+func NewPoller(cfg *config.Config, since time.Time, interval time.Duration) *Poller {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Poller{cfg: cfg, interval: interval, since: since, seen: map[string]time.Time{}}
+}
+
+// Since returns the cursor: the time of the newest change reported so far.
+// It can be persisted and passed to NewPoller to resume after a restart.
+func (p *Poller) Since() time.Time {
+	return p.since
+}
+
+// Cursor is the full position of a Poller: its cursor time and the items
+// already reported at that time. Resuming from a Cursor instead of Since
+// does not report those items again.
+type Cursor struct {
+	Since time.Time            `json:"since"`
+	Seen  map[string]time.Time `json:"seen,omitempty"`
+}
+
+// Cursor returns the position of the poller.
+func (p *Poller) Cursor() Cursor {
+	seen := make(map[string]time.Time, len(p.seen))
+	for uuid, t := range p.seen {
+		seen[uuid] = t
+	}
+	return Cursor{Since: p.since, Seen: seen}
+}
+
+// ResumePoller creates a Poller continuing from cursor, as returned by
+// Poller.Cursor.
+func ResumePoller(cfg *config.Config, cursor Cursor, interval time.Duration) *Poller {
+	p := NewPoller(cfg, cursor.Since, interval)
+	for uuid, t := range cursor.Seen {
+		p.seen[uuid] = t
+	}
+	return p
+}
+
+// Run polls until ctx is done, sending events to out. It returns ctx's error,
+// or the first polling error.
+func (p *Poller) Run(ctx context.Context, out chan<- Event) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		evs, err := p.Poll(ctx)
+		if err != nil {
+			return err
+		}
+		for _, ev := range evs {
+			select {
+			case out <- ev:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Poll fetches the changes made since the previous poll, folders first, and
+// advances the cursor. Listings held in cfg.ListingCache that the changes
+// affect are invalidated.
+func (p *Poller) Poll(ctx context.Context) ([]Event, error) {
+	changedFolders, err := listUpdated[folders.Folder](ctx, p, p.cfg.Endpoints.Drive().Folders().List(), "folders")
+	if err != nil {
+		return nil, err
+	}
+	changedFiles, err := listUpdated[folders.File](ctx, p, p.cfg.Endpoints.Drive().Files().List(), "files")
+	if err != nil {
+		return nil, err
+	}
+
+	var evs []Event
+	for i := range changedFolders {
+		f := &changedFolders[i]
+		evs = append(evs, Event{
+			Kind:       p.kind(f.CreatedAt, f.Status, f.Deleted || f.Removed),
+			UUID:       f.UUID,
+			ParentUUID: f.ParentUUID,
+			UpdatedAt:  f.UpdatedAt,
+			Folder:     f,
+		})
+	}
+	for i := range changedFiles {
+		f := &changedFiles[i]
+		evs = append(evs, Event{
+			Kind:       p.kind(f.CreatedAt, f.Status, f.Deleted || f.Removed),
+			UUID:       f.UUID,
+			ParentUUID: f.FolderUUID,
+			UpdatedAt:  f.UpdatedAt,
+			File:       f,
+		})
+	}
+
+	return p.advance(evs), nil
+}
+
+// kind classifies a change relative to the current cursor.
+func (p *Poller) kind(createdAt time.Time, status string, removed bool) Kind {
+	switch {
+	case removed || status == string(folders.StatusTrashed) || status == string(folders.StatusDeleted):
+		return Deleted
+	case createdAt.After(p.since):
+		return Created
+	default:
+		return Modified
+	}
+}
+
+// advance drops events older than the cursor or already reported, moves the cursor to the newest
+// change and invalidates the affected cached listings.
+func (p *Poller) advance(evs []Event) []Event {
+	newest := p.since
+	out := evs[:0]
+	for _, ev := range evs {
+		if ev.UpdatedAt.Before(p.since) {
+			continue
+		}
+		if seen, ok := p.seen[ev.UUID]; ok && !ev.UpdatedAt.After(seen) {
+			continue
+		}
+		if ev.UpdatedAt.After(newest) {
+			newest = ev.UpdatedAt
+		}
+		out = append(out, ev)
+
+		p.cfg.ListingCache.InvalidateItem(ev.UUID)
+		p.cfg.ListingCache.InvalidateFolder(ev.ParentUUID)
+	}
+
+	// The server returns items updated at or after the cursor, so remember
+	// those at the new cursor time to avoid reporting them twice.
+	if newest.After(p.since) {
+		p.seen = map[string]time.Time{}
+	}
+	for _, ev := range out {
+		if ev.UpdatedAt.Equal(newest) {
+			p.seen[ev.UUID] = ev.UpdatedAt
+		}
+	}
+	p.since = newest
+	return out
+}
+
+// listUpdated fetches every page of items of the given kind updated since
+// the poller's cursor.
+func listUpdated[T any](ctx context.Context, p *Poller, endpoint, kind string) ([]T, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse list updated %s URL: %w", kind, err)
+	}
+
+	var all []T
+	for offset := 0; ; offset += pageSize {
+		q := url.Values{}
+		q.Set("limit", strconv.Itoa(pageSize))
+		q.Set("offset", strconv.Itoa(offset))
+		q.Set("status", string(folders.StatusAll))
+		q.Set("sort", "updatedAt")
+		q.Set("order", "ASC")
+		if !p.since.IsZero() {
+			q.Set("updatedAt", p.since.UTC().Format(time.RFC3339Nano))
+		}
+		u.RawQuery = q.Encode()
+
+		page, err := getPage[T](ctx, p.cfg, u.String(), kind)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if len(page) < pageSize {
+			return all, nil
+		}
+	}
+}
+
+// getPage fetches and decodes one page of updated items.
+func getPage[T any](ctx context.Context, cfg *config.Config, pageURL, kind string) ([]T, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create list updated %s request: %w", kind, err)
+	}
+	cfg.AuthorizeRequest(req)
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute list updated %s request: %w", kind, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.NewHTTPError(resp, "list updated "+kind)
+	}
+
+	var page []T
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("failed to decode list updated %s response: %w", kind, err)
+	}
+	return page, nil
+}
@@ -0,0 +1,121 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/internxt/rclone-adapter/folders"
+)
+
+func TestPoll(t *testing.T) {
+	t0 := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	t1 := t0.Add(time.Minute)
+
+	var updatedAt []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("status") != "ALL" || q.Get("sort") != "updatedAt" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		if strings.HasSuffix(r.URL.Path, "/folders") {
+			updatedAt = append(updatedAt, q.Get("updatedAt"))
+			json.NewEncoder(w).Encode([]folders.Folder{
+				{UUID: "new-folder", ParentUUID: "root", CreatedAt: t1, UpdatedAt: t1, Status: "EXISTS"},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode([]folders.File{
+			{UUID: "changed-file", FolderUUID: "root", CreatedAt: t0.Add(-time.Hour), UpdatedAt: t0.Add(time.Second), Status: "EXISTS"},
+			{UUID: "trashed-file", FolderUUID: "root", CreatedAt: t0.Add(-time.Hour), UpdatedAt: t1, Status: "TRASHED"},
+		})
+	}))
+	defer mockServer.Close()
+
+	p := NewPoller(newTestConfig(mockServer.URL), t0, time.Second)
+
+	evs, err := p.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]Kind{"new-folder": Created, "changed-file": Modified, "trashed-file": Deleted}
+	if len(evs) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), evs)
+	}
+	for _, ev := range evs {
+		if want[ev.UUID] != ev.Kind {
+			t.Errorf("%s: expected %s, got %s", ev.UUID, want[ev.UUID], ev.Kind)
+		}
+		if ev.IsFolder() != (ev.UUID == "new-folder") {
+			t.Errorf("%s: unexpected IsFolder %v", ev.UUID, ev.IsFolder())
+		}
+	}
+	if !p.Since().Equal(t1) {
+		t.Errorf("expected cursor %v, got %v", t1, p.Since())
+	}
+
+	evs, err = p.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(evs) != 0 {
+		t.Errorf("expected changes at the cursor not to be reported again, got %+v", evs)
+	}
+	if updatedAt[1] != t1.Format(time.RFC3339Nano) {
+		t.Errorf("expected second poll from the cursor, got %q", updatedAt[1])
+	}
+}
+
+func TestPollPaginates(t *testing.T) {
+	var offsets []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/folders") {
+			json.NewEncoder(w).Encode([]folders.Folder{})
+			return
+		}
+		offset := r.URL.Query().Get("offset")
+		offsets = append(offsets, offset)
+		n := pageSize
+		if offset != "0" {
+			n = 1
+		}
+		page := make([]folders.File, n)
+		for i := range page {
+			page[i] = folders.File{UUID: offset + "-" + string(rune('a'+i%26)) + string(rune('a'+i/26))}
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer mockServer.Close()
+
+	evs, err := NewPoller(newTestConfig(mockServer.URL), time.Time{}, 0).Poll(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(evs) != pageSize+1 {
+		t.Errorf("expected %d events, got %d", pageSize+1, len(evs))
+	}
+	if strings.Join(offsets, ",") != "0,50" {
+		t.Errorf("expected offsets 0,50, got %v", offsets)
+	}
+}
+
+func TestRun(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/folders") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode([]folders.File{})
+	}))
+	defer mockServer.Close()
+
+	err := NewPoller(newTestConfig(mockServer.URL), time.Time{}, time.Millisecond).Run(context.Background(), make(chan Event))
+	if err == nil || !strings.Contains(err.Error(), "list updated folders") {
+		t.Errorf("expected polling error, got %v", err)
+	}
+}
@@ -67,7 +67,7 @@ type CheckFilesExistenceResponse struct {
 
 // CheckFilesExistence checks if files exist in a folder (batch operation)
 func CheckFilesExistence(ctx context.Context, cfg *config.Config, folderUUID string, files []FileExistenceCheck) (*CheckFilesExistenceResponse, error) {
-	if err := consistency.AwaitFolder(ctx, folderUUID); err != nil {
+	if err := consistency.AwaitFolderContents(ctx, folderUUID); err != nil {
 		return nil, err
 	}
 
@@ -83,7 +83,7 @@ func CheckFilesExistence(ctx context.Context, cfg *config.Config, folderUUID str
 	if err != nil {
 		return nil, fmt.Errorf("failed to create existence check request: %w", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	cfg.AuthorizeRequest(req)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := cfg.HTTPClient.Do(req)
@@ -106,6 +106,10 @@ func CheckFilesExistence(ctx context.Context, cfg *config.Config, folderUUID str
 
 // DeleteFile deletes a file by UUID
 func DeleteFile(ctx context.Context, cfg *config.Config, uuid string) error {
+	if err := consistency.AwaitFile(ctx, uuid); err != nil {
+		return err
+	}
+
 	u, err := url.Parse(cfg.Endpoints.Drive().Files().Delete(uuid))
 	if err != nil {
 		return fmt.Errorf("failed to parse delete file URL: %w", err)
@@ -114,7 +118,7 @@ func DeleteFile(ctx context.Context, cfg *config.Config, uuid string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create delete file request: %w", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	cfg.AuthorizeRequest(req)
 	resp, err := cfg.HTTPClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to execute delete file request: %w", err)
@@ -125,11 +129,16 @@ func DeleteFile(ctx context.Context, cfg *config.Config, uuid string) error {
 		return errors.NewHTTPError(resp, "delete file")
 	}
 
+	cfg.ListingCache.InvalidateItem(uuid)
 	return nil
 }
 
 // RenameFile renames a file by UUID with the given new name and optional type.
 func RenameFile(ctx context.Context, cfg *config.Config, fileUUID, newPlainName, newType string) error {
+	if err := consistency.AwaitFile(ctx, fileUUID); err != nil {
+		return err
+	}
+
 	endpoint := cfg.Endpoints.Drive().Files().Meta(fileUUID)
 
 	payload := map[string]string{
@@ -148,7 +157,7 @@ func RenameFile(ctx context.Context, cfg *config.Config, fileUUID, newPlainName,
 	if err != nil {
 		return fmt.Errorf("failed to create rename file request: %w", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	cfg.AuthorizeRequest(req)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := cfg.HTTPClient.Do(req)
@@ -161,12 +170,18 @@ func RenameFile(ctx context.Context, cfg *config.Config, fileUUID, newPlainName,
 		return errors.NewHTTPError(resp, "rename file")
 	}
 
+	consistency.TrackFile(fileUUID)
+	cfg.ListingCache.InvalidateItem(fileUUID)
 	return nil
 }
 
 // MoveFile moves a file to a new destination folder, optionally renaming it.
 // If newName or newType are empty, they are omitted and the server keeps the current values.
 func MoveFile(ctx context.Context, cfg *config.Config, fileUUID, destinationFolderUUID, newName, newType string) error {
+	if err := consistency.AwaitFile(ctx, fileUUID); err != nil {
+		return err
+	}
+
 	endpoint := cfg.Endpoints.Drive().Files().Move(fileUUID)
 
 	payload := map[string]string{
@@ -188,7 +203,7 @@ func MoveFile(ctx context.Context, cfg *config.Config, fileUUID, destinationFold
 	if err != nil {
 		return fmt.Errorf("failed to create move file request: %w", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	cfg.AuthorizeRequest(req)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := cfg.HTTPClient.Do(req)
@@ -201,17 +216,25 @@ func MoveFile(ctx context.Context, cfg *config.Config, fileUUID, destinationFold
 		return errors.NewHTTPError(resp, "move file")
 	}
 
+	consistency.TrackFile(fileUUID)
+	consistency.TrackFolderContents(destinationFolderUUID)
+	cfg.ListingCache.InvalidateItem(fileUUID)
+	cfg.ListingCache.InvalidateFolder(destinationFolderUUID)
 	return nil
 }
 
 func GetFileMeta(ctx context.Context, cfg *config.Config, fileUUID string) (*FileMeta, error) {
+	if err := consistency.AwaitFile(ctx, fileUUID); err != nil {
+		return nil, err
+	}
+
 	endpoint := cfg.Endpoints.Drive().Files().Meta(fileUUID)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create get file meta request: %w", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	cfg.AuthorizeRequest(req)
 	resp, err := cfg.HTTPClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute get file meta request: %w", err)
@@ -7,7 +7,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/internxt/rclone-adapter/config"
@@ -19,6 +21,7 @@ import (
 // It auto‑fills CreationTime/ModificationTime if empty, checks status, and returns the newly created Folder.
 // The folder UUID is tracked via the consistency package so that subsequent
 // operations on this folder automatically wait for eventual consistency.
+// When cfg.WorkspaceID is set, the folder is created in the workspace's drive.
 func CreateFolder(ctx context.Context, cfg *config.Config, reqBody CreateFolderRequest) (*Folder, error) {
 	now := time.Now().UTC().Format(time.RFC3339Nano)
 	if reqBody.CreationTime == "" {
@@ -29,6 +32,9 @@ func CreateFolder(ctx context.Context, cfg *config.Config, reqBody CreateFolderR
 	}
 
 	endpoint := cfg.Endpoints.Drive().Folders().Create()
+	if cfg.WorkspaceID != "" {
+		endpoint = cfg.Endpoints.Drive().Workspaces().Folders(cfg.WorkspaceID)
+	}
 	b, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal create folder request: %w", err)
@@ -39,7 +45,7 @@ func CreateFolder(ctx context.Context, cfg *config.Config, reqBody CreateFolderR
 		return nil, fmt.Errorf("failed to create folder request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	cfg.AuthorizeRequest(req)
 
 	resp, err := cfg.HTTPClient.Do(req)
 	if err != nil {
@@ -56,11 +62,41 @@ func CreateFolder(ctx context.Context, cfg *config.Config, reqBody CreateFolderR
 		return nil, fmt.Errorf("failed to decode create folder response: %w", err)
 	}
 
-	consistency.TrackFolder(folder.UUID)
+	if cfg.PollConsistency {
+		consistency.TrackFolderProbe(folder.UUID, folderProbe(cfg, folder.UUID))
+	} else {
+		consistency.TrackFolder(folder.UUID)
+	}
+	consistency.TrackFolderContents(reqBody.ParentFolderUUID)
+	cfg.ListingCache.InvalidateFolder(reqBody.ParentFolderUUID)
 
 	return &folder, nil
 }
 
+// folderProbe reports a folder as visible once its metadata can be fetched.
+func folderProbe(cfg *config.Config, uuid string) consistency.Probe {
+	return func(ctx context.Context) (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.Endpoints.Drive().Folders().Meta(uuid), nil)
+		if err != nil {
+			return false, fmt.Errorf("failed to create folder probe request: %w", err)
+		}
+		cfg.AuthorizeRequest(req)
+		resp, err := cfg.HTTPClient.Do(req)
+		if err != nil {
+			return false, fmt.Errorf("failed to execute folder probe request: %w", err)
+		}
+		defer resp.Body.Close()
+
+		switch {
+		case resp.StatusCode == http.StatusNotFound:
+			return false, nil
+		case resp.StatusCode < 200 || resp.StatusCode >= 300:
+			return false, errors.NewHTTPError(resp, "folder probe")
+		}
+		return true, nil
+	}
+}
+
 // DeleteFolder deletes a folder by UUID.
 func DeleteFolder(ctx context.Context, cfg *config.Config, uuid string) error {
 	if err := consistency.AwaitFolder(ctx, uuid); err != nil {
@@ -75,7 +111,7 @@ func DeleteFolder(ctx context.Context, cfg *config.Config, uuid string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create delete folder request: %w", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	cfg.AuthorizeRequest(req)
 	resp, err := cfg.HTTPClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to execute delete folder request: %w", err)
@@ -87,6 +123,7 @@ func DeleteFolder(ctx context.Context, cfg *config.Config, uuid string) error {
 		return errors.NewHTTPError(resp, "delete folder")
 	}
 
+	cfg.ListingCache.InvalidateItem(uuid)
 	return nil
 }
 
@@ -111,7 +148,7 @@ func RenameFolder(ctx context.Context, cfg *config.Config, folderUUID, newPlainN
 	if err != nil {
 		return fmt.Errorf("failed to create rename folder request: %w", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	cfg.AuthorizeRequest(req)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := cfg.HTTPClient.Do(req)
@@ -124,6 +161,8 @@ func RenameFolder(ctx context.Context, cfg *config.Config, folderUUID, newPlainN
 		return errors.NewHTTPError(resp, "rename folder")
 	}
 
+	consistency.TrackFolder(folderUUID)
+	cfg.ListingCache.InvalidateItem(folderUUID)
 	return nil
 }
 
@@ -152,7 +191,7 @@ func MoveFolder(ctx context.Context, cfg *config.Config, folderUUID, destination
 	if err != nil {
 		return fmt.Errorf("failed to create move folder request: %w", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	cfg.AuthorizeRequest(req)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := cfg.HTTPClient.Do(req)
@@ -165,17 +204,83 @@ func MoveFolder(ctx context.Context, cfg *config.Config, folderUUID, destination
 		return errors.NewHTTPError(resp, "move folder")
 	}
 
+	consistency.TrackFolder(folderUUID)
+	consistency.TrackFolderContents(destinationFolderUUID)
+	cfg.ListingCache.InvalidateItem(folderUUID)
+	cfg.ListingCache.InvalidateFolder(destinationFolderUUID)
 	return nil
 }
 
-// ListFolders lists child folders under the given parent UUID.
+// GetFolderMeta returns the metadata of a folder by UUID.
+func GetFolderMeta(ctx context.Context, cfg *config.Config, folderUUID string) (*Folder, error) {
+	folder, _, err := getFolderMeta(ctx, cfg, folderUUID, time.Time{})
+	return folder, err
+}
+
+// FolderModifiedSince reports whether a folder changed after since, typically
+// the UpdatedAt of the folder returned by a previous scan, so that sync tools
+// can skip re-listing unchanged folders. The request carries If-Modified-Since;
+// a 304 answer or a server-side updatedAt not after since both mean unchanged.
+// The folder metadata is returned when the server sent it.
+func FolderModifiedSince(ctx context.Context, cfg *config.Config, folderUUID string, since time.Time) (bool, *Folder, error) {
+	folder, notModified, err := getFolderMeta(ctx, cfg, folderUUID, since)
+	if err != nil {
+		return false, nil, err
+	}
+	if notModified {
+		return false, nil, nil
+	}
+	return folder.UpdatedAt.After(since), folder, nil
+}
+
+// getFolderMeta fetches the folder metadata, conditionally on it having been
+// modified since ifModifiedSince when that is non-zero.
+func getFolderMeta(ctx context.Context, cfg *config.Config, folderUUID string, ifModifiedSince time.Time) (*Folder, bool, error) {
+	if err := consistency.AwaitFolder(ctx, folderUUID); err != nil {
+		return nil, false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.Endpoints.Drive().Folders().Meta(folderUUID), nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create get folder meta request: %w", err)
+	}
+	cfg.AuthorizeRequest(req)
+	if !ifModifiedSince.IsZero() {
+		req.Header.Set("If-Modified-Since", ifModifiedSince.UTC().Format(http.TimeFormat))
+	}
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to execute get folder meta request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && !ifModifiedSince.IsZero() {
+		return nil, true, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, errors.NewHTTPError(resp, "get folder meta")
+	}
+
+	var folder Folder
+	if err := json.NewDecoder(resp.Body).Decode(&folder); err != nil {
+		return nil, false, fmt.Errorf("failed to decode get folder meta response: %w", err)
+	}
+	return &folder, false, nil
+}
+
+// ListFolders lists child folders under the given parent UUID, through the
+// workspace's endpoints when cfg.WorkspaceID is set.
 // Returns a slice of folders or error otherwise
 func ListFolders(ctx context.Context, cfg *config.Config, parentUUID string, opts ListOptions) ([]Folder, error) {
-	if err := consistency.AwaitFolder(ctx, parentUUID); err != nil {
+	if err := consistency.AwaitFolderContents(ctx, parentUUID); err != nil {
 		return nil, err
 	}
 
 	base := cfg.Endpoints.Drive().Folders().ContentFolders(parentUUID)
+	if cfg.WorkspaceID != "" {
+		base = cfg.Endpoints.Drive().Workspaces().ContentFolders(cfg.WorkspaceID, parentUUID)
+	}
 	u, err := url.Parse(base)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse list folders URL: %w", err)
@@ -184,7 +289,7 @@ func ListFolders(ctx context.Context, cfg *config.Config, parentUUID string, opt
 
 	limit := opts.Limit
 	if limit <= 0 {
-		limit = 50
+		limit = pageSize
 	}
 	offset := opts.Offset
 	if offset < 0 {
@@ -205,11 +310,16 @@ func ListFolders(ctx context.Context, cfg *config.Config, parentUUID string, opt
 
 	u.RawQuery = q.Encode()
 
+	cacheKey := "folders?" + u.RawQuery
+	if cached, ok := cachedListing[Folder](cfg, parentUUID, cacheKey); ok {
+		return cached, nil
+	}
+
 	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create list folders request: %w", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	cfg.AuthorizeRequest(req)
 	resp, err := cfg.HTTPClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute list folders request: %w", err)
@@ -222,21 +332,37 @@ func ListFolders(ctx context.Context, cfg *config.Config, parentUUID string, opt
 
 	var wrapper struct {
 		Folders []Folder `json:"folders"`
+		Result  []Folder `json:"result"` // Workspace listings
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&wrapper); err != nil {
 		return nil, fmt.Errorf("failed to decode list folders response: %w", err)
 	}
+	if wrapper.Folders == nil {
+		wrapper.Folders = wrapper.Result
+	}
+
+	if cfg.ListingCache != nil {
+		uuids := make([]string, len(wrapper.Folders))
+		for i, f := range wrapper.Folders {
+			uuids[i] = f.UUID
+		}
+		cfg.ListingCache.Put(parentUUID, cacheKey, slices.Clone(wrapper.Folders), uuids)
+	}
 	return wrapper.Folders, nil
 }
 
-// ListFiles lists files under the given parent folder UUID.
+// ListFiles lists files under the given parent folder UUID, through the
+// workspace's endpoints when cfg.WorkspaceID is set.
 // Returns a slice of files or error otherwise
 func ListFiles(ctx context.Context, cfg *config.Config, parentUUID string, opts ListOptions) ([]File, error) {
-	if err := consistency.AwaitFolder(ctx, parentUUID); err != nil {
+	if err := consistency.AwaitFolderContents(ctx, parentUUID); err != nil {
 		return nil, err
 	}
 
 	base := cfg.Endpoints.Drive().Folders().ContentFiles(parentUUID)
+	if cfg.WorkspaceID != "" {
+		base = cfg.Endpoints.Drive().Workspaces().ContentFiles(cfg.WorkspaceID, parentUUID)
+	}
 	u, err := url.Parse(base)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse list files URL: %w", err)
@@ -245,7 +371,7 @@ func ListFiles(ctx context.Context, cfg *config.Config, parentUUID string, opts
 
 	limit := opts.Limit
 	if limit <= 0 {
-		limit = 50
+		limit = pageSize
 	}
 	offset := opts.Offset
 	if offset < 0 {
@@ -266,11 +392,16 @@ func ListFiles(ctx context.Context, cfg *config.Config, parentUUID string, opts
 
 	u.RawQuery = q.Encode()
 
+	cacheKey := "files?" + u.RawQuery
+	if cached, ok := cachedListing[File](cfg, parentUUID, cacheKey); ok {
+		return cached, nil
+	}
+
 	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create list files request: %w", err)
 	}
-	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	cfg.AuthorizeRequest(req)
 	resp, err := cfg.HTTPClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute list files request: %w", err)
@@ -282,54 +413,101 @@ func ListFiles(ctx context.Context, cfg *config.Config, parentUUID string, opts
 	}
 
 	var wrapper struct {
-		Files []File `json:"files"`
+		Files  []File `json:"files"`
+		Result []File `json:"result"` // Workspace listings
 	}
 	dec := json.NewDecoder(resp.Body)
 	dec.UseNumber()
 	if err := dec.Decode(&wrapper); err != nil {
 		return nil, fmt.Errorf("failed to decode list files response: %w", err)
 	}
+	if wrapper.Files == nil {
+		wrapper.Files = wrapper.Result
+	}
+
+	if cfg.ListingCache != nil {
+		uuids := make([]string, len(wrapper.Files))
+		for i, f := range wrapper.Files {
+			uuids[i] = f.UUID
+		}
+		cfg.ListingCache.Put(parentUUID, cacheKey, slices.Clone(wrapper.Files), uuids)
+	}
 	return wrapper.Files, nil
 }
 
-// This function will get all of the files in a folder, getting 50 at a time until completed
-func ListAllFiles(ctx context.Context, cfg *config.Config, parentUUID string) ([]File, error) {
-	var outFiles []File
-	offset := 0
-	loops := 0
-	maxLoops := 10000 //Find sane number...
-	for {
-		files, err := ListFiles(ctx, cfg, parentUUID, ListOptions{Offset: offset})
-		if err != nil {
-			return nil, fmt.Errorf("failed to list all files at offset %d: %w", offset, err)
-		}
-		outFiles = append(outFiles, files...)
-		offset += 50
-		loops += 1
-		if len(files) != 50 || loops >= maxLoops {
-			break
+// cachedListing returns a copy of the listing cached under key, decoding
+// listings restored from disk by config.LoadState.
+func cachedListing[T any](cfg *config.Config, parentUUID, key string) ([]T, bool) {
+	cached, ok := cfg.ListingCache.Get(parentUUID, key)
+	if !ok {
+		return nil, false
+	}
+	switch v := cached.(type) {
+	case []T:
+		return slices.Clone(v), true
+	case json.RawMessage:
+		var listing []T
+		if json.Unmarshal(v, &listing) == nil {
+			return listing, true
 		}
 	}
-	return outFiles, nil
+	return nil, false
+}
+
+// pageSize is the number of items returned per page by the list endpoints.
+const pageSize = 50
+
+// maxPages bounds the number of pages ListAllFiles and ListAllFolders fetch.
+const maxPages = 10000
+
+// This function will get all of the files in a folder, getting 50 at a time until completed.
+// Once the first page is full, up to cfg.MaxConcurrency pages are fetched in parallel.
+func ListAllFiles(ctx context.Context, cfg *config.Config, parentUUID string) ([]File, error) {
+	return listAll(ctx, cfg, parentUUID, "files", ListFiles)
 }
 
-// This function will get all of the folders in a folder, getting 50 at a time until completed
+// This function will get all of the folders in a folder, getting 50 at a time until completed.
+// Once the first page is full, up to cfg.MaxConcurrency pages are fetched in parallel.
 func ListAllFolders(ctx context.Context, cfg *config.Config, parentUUID string) ([]Folder, error) {
-	var outFolders []Folder
-	offset := 0
-	loops := 0
-	maxLoops := 10000 //Find sane number...
-	for {
-		files, err := ListFolders(ctx, cfg, parentUUID, ListOptions{Offset: offset})
-		if err != nil {
-			return nil, fmt.Errorf("failed to list all folders at offset %d: %w", offset, err)
+	return listAll(ctx, cfg, parentUUID, "folders", ListFolders)
+}
+
+// listAll fetches the first page, then batches of cfg.MaxConcurrency pages
+// concurrently until a page is not full, keeping the pages in order. It
+// stops with ctx.Err() between batches once ctx is done, even if the
+// remaining pages are cached.
+func listAll[T any](ctx context.Context, cfg *config.Config, parentUUID, kind string, list func(context.Context, *config.Config, string, ListOptions) ([]T, error)) ([]T, error) {
+	var out []T
+	batch := 1
+	for first := 0; first < maxPages; {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("failed to list all %s at offset %d: %w", kind, first*pageSize, err)
+		}
+		n := min(batch, maxPages-first)
+		pages := make([][]T, n)
+		errs := make([]error, n)
+
+		var wg sync.WaitGroup
+		for i := range n {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				pages[i], errs[i] = list(ctx, cfg, parentUUID, ListOptions{Offset: (first + i) * pageSize})
+			}()
 		}
-		outFolders = append(outFolders, files...)
-		offset += 50
-		loops += 1
-		if len(files) != 50 || loops >= maxLoops {
-			break
+		wg.Wait()
+
+		for i, page := range pages {
+			if errs[i] != nil {
+				return nil, fmt.Errorf("failed to list all %s at offset %d: %w", kind, (first+i)*pageSize, errs[i])
+			}
+			out = append(out, page...)
+			if len(page) != pageSize {
+				return out, nil
+			}
 		}
+		first += n
+		batch = max(cfg.MaxConcurrency, 1)
 	}
-	return outFolders, nil
+	return out, nil
 }
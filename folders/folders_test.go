@@ -3,13 +3,18 @@ package folders
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/internxt/rclone-adapter/config"
 	"github.com/internxt/rclone-adapter/consistency"
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
 )
 
 func TestCreateFolder(t *testing.T) {
@@ -205,6 +210,39 @@ func TestCreateFolderTracksConsistency(t *testing.T) {
 	}
 }
 
+func TestCreateFolderPollsConsistency(t *testing.T) {
+	var probes atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			if probes.Add(1) < 2 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		json.NewEncoder(w).Encode(Folder{UUID: "polled-uuid", PlainName: "test"})
+	}))
+	defer mockServer.Close()
+
+	cfg := newTestConfig(mockServer.URL)
+	cfg.PollConsistency = true
+	if _, err := CreateFolder(context.Background(), cfg, CreateFolderRequest{PlainName: "test"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	start := time.Now()
+	if err := consistency.AwaitFolder(context.Background(), "polled-uuid"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if probes.Load() != 2 {
+		t.Errorf("expected 2 probes, got %d", probes.Load())
+	}
+	if time.Since(start) > 400*time.Millisecond {
+		t.Error("expected polling to return before the fixed window")
+	}
+}
+
 func TestDeleteFolder(t *testing.T) {
 	t.Run("successful deletion - 204", func(t *testing.T) {
 		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -270,6 +308,31 @@ func TestDeleteFolder(t *testing.T) {
 	})
 }
 
+func TestFolderErrorsPreserveHTTPDetails(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer mockServer.Close()
+
+	cfg := newTestConfig(mockServer.URL)
+
+	err := DeleteFolder(context.Background(), cfg, "test-uuid")
+	var httpErr *sdkerrors.HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("expected *errors.HTTPError, got %T: %v", err, err)
+	}
+	if !httpErr.Temporary() {
+		t.Error("expected 429 to be temporary")
+	}
+	if httpErr.RetryAfter() != 7*time.Second {
+		t.Errorf("expected RetryAfter 7s, got %v", httpErr.RetryAfter())
+	}
+	if !errors.Is(err, sdkerrors.ErrRateLimited) {
+		t.Error("expected errors.Is(err, ErrRateLimited)")
+	}
+}
+
 func TestRenameFolder(t *testing.T) {
 	t.Run("successful rename", func(t *testing.T) {
 		var capturedPayload map[string]string
@@ -410,6 +473,53 @@ func TestMoveFolder(t *testing.T) {
 	})
 }
 
+func TestFolderModifiedSince(t *testing.T) {
+	updatedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		since        time.Time
+		notModified  bool
+		wantModified bool
+		wantFolder   bool
+	}{
+		{"server answers 304", updatedAt, true, false, false},
+		{"updatedAt unchanged", updatedAt, false, false, true},
+		{"updatedAt newer", updatedAt.Add(-time.Hour), false, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasSuffix(r.URL.Path, "/folder-uuid/meta") {
+					t.Errorf("unexpected path %s", r.URL.Path)
+				}
+				if got := r.Header.Get("If-Modified-Since"); got != tt.since.Format(http.TimeFormat) {
+					t.Errorf("expected If-Modified-Since %q, got %q", tt.since.Format(http.TimeFormat), got)
+				}
+				if tt.notModified {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				json.NewEncoder(w).Encode(Folder{UUID: "folder-uuid", UpdatedAt: updatedAt})
+			}))
+			defer mockServer.Close()
+
+			cfg := newTestConfig(mockServer.URL)
+			modified, folder, err := FolderModifiedSince(context.Background(), cfg, "folder-uuid", tt.since)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if modified != tt.wantModified {
+				t.Errorf("expected modified %v, got %v", tt.wantModified, modified)
+			}
+			if (folder != nil) != tt.wantFolder {
+				t.Errorf("expected folder returned %v, got %+v", tt.wantFolder, folder)
+			}
+		})
+	}
+}
+
 func TestListFolders(t *testing.T) {
 	t.Run("successful list with default values", func(t *testing.T) {
 		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -565,6 +675,76 @@ func TestListFolders(t *testing.T) {
 	})
 }
 
+func TestListFoldersCache(t *testing.T) {
+	var lists atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		lists.Add(1)
+		json.NewEncoder(w).Encode(struct {
+			Folders []Folder `json:"folders"`
+		}{
+			Folders: []Folder{{UUID: "folder-1", PlainName: "folder1"}},
+		})
+	}))
+	defer mockServer.Close()
+
+	cfg := newTestConfig(mockServer.URL)
+	cfg.ListingCache = config.NewListingCache(time.Hour)
+	ctx := context.Background()
+
+	for range 2 {
+		folders, err := ListFolders(ctx, cfg, "parent-uuid", ListOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(folders) != 1 || folders[0].UUID != "folder-1" {
+			t.Fatalf("unexpected folders: %+v", folders)
+		}
+	}
+	if lists.Load() != 1 {
+		t.Errorf("expected the second listing to be served from the cache, got %d requests", lists.Load())
+	}
+
+	if _, err := ListFolders(ctx, cfg, "parent-uuid", ListOptions{Offset: 50}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lists.Load() != 2 {
+		t.Errorf("expected another page to be fetched, got %d requests", lists.Load())
+	}
+
+	if err := RenameFolder(ctx, cfg, "folder-1", "renamed"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ListFolders(ctx, cfg, "parent-uuid", ListOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lists.Load() != 3 {
+		t.Errorf("expected renaming a child to invalidate the listing, got %d requests", lists.Load())
+	}
+}
+
+func TestListFilesRestoredCache(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL.Path)
+	}))
+	defer mockServer.Close()
+
+	cfg := newTestConfig(mockServer.URL)
+	cfg.ListingCache = config.NewListingCache(time.Hour)
+	cfg.ListingCache.Put("parent-uuid", "files?limit=50&offset=0&order=ASC&sort=plainName", json.RawMessage(`[{"uuid":"file-1"}]`), nil)
+
+	files, err := ListFiles(context.Background(), cfg, "parent-uuid", ListOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 1 || files[0].UUID != "file-1" {
+		t.Errorf("expected restored listing to be decoded, got %+v", files)
+	}
+}
+
 func TestListFiles(t *testing.T) {
 	t.Run("successful list with JSON numbers", func(t *testing.T) {
 		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -620,24 +800,61 @@ func TestListFiles(t *testing.T) {
 	})
 }
 
+func TestWorkspaceFolders(t *testing.T) {
+	var requests []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Header.Get(config.WorkspaceHeader) != "ws-1" {
+			t.Errorf("expected the workspace header, got %q", r.Header.Get(config.WorkspaceHeader))
+		}
+		switch r.URL.Path {
+		case "/drive/workspaces/ws-1/folders":
+			json.NewEncoder(w).Encode(Folder{UUID: "team-uuid", PlainName: "team"})
+		case "/drive/workspaces/ws-1/folders/ws-root/folders":
+			json.NewEncoder(w).Encode(map[string][]Folder{"result": {{UUID: "team-uuid", PlainName: "team"}}})
+		case "/drive/workspaces/ws-1/folders/ws-root/files":
+			json.NewEncoder(w).Encode(map[string][]File{"result": {{UUID: "plan-uuid", PlainName: "plan", Size: "7"}}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockServer.Close()
+
+	cfg := newTestConfig(mockServer.URL)
+	cfg.WorkspaceID = "ws-1"
+	ctx := context.Background()
+
+	if _, err := CreateFolder(ctx, cfg, CreateFolderRequest{PlainName: "team", ParentFolderUUID: "ws-root"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	subfolders, err := ListFolders(ctx, cfg, "ws-root", ListOptions{})
+	if err != nil || len(subfolders) != 1 || subfolders[0].UUID != "team-uuid" {
+		t.Errorf("expected the workspace folder, got %+v, %v", subfolders, err)
+	}
+	files, err := ListFiles(ctx, cfg, "ws-root", ListOptions{})
+	if err != nil || len(files) != 1 || files[0].UUID != "plan-uuid" {
+		t.Errorf("expected the workspace file, got %+v, %v", files, err)
+	}
+
+	want := "POST /drive/workspaces/ws-1/folders, GET /drive/workspaces/ws-1/folders/ws-root/folders, GET /drive/workspaces/ws-1/folders/ws-root/files"
+	if got := strings.Join(requests, ", "); got != want {
+		t.Errorf("expected requests %s, got %s", want, got)
+	}
+}
+
 func TestListAllFiles(t *testing.T) {
 	t.Run("pagination loop - multiple pages", func(t *testing.T) {
-		callCount := 0
 		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			query := r.URL.Query()
-			offset := 0
-			if query.Get("offset") != "" {
-				// Parse offset from query
-				offset = callCount * 50
-			}
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
 
 			files := []File{}
-			// Return 50 files for first two calls, then 10 for the last
-			if callCount < 2 {
+			// Return 50 files for the first two pages, then 10
+			switch {
+			case offset < 100:
 				for i := range 50 {
 					files = append(files, File{UUID: "file-" + string(rune(offset+i))})
 				}
-			} else {
+			case offset == 100:
 				for i := range 10 {
 					files = append(files, File{UUID: "file-" + string(rune(offset+i))})
 				}
@@ -648,7 +865,6 @@ func TestListAllFiles(t *testing.T) {
 			}{Files: files}
 			w.WriteHeader(http.StatusOK)
 			json.NewEncoder(w).Encode(response)
-			callCount++
 		}))
 		defer mockServer.Close()
 
@@ -711,17 +927,89 @@ func TestListAllFiles(t *testing.T) {
 	})
 }
 
+func TestListAllFilesParallel(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		count := max(min(50, 420-offset), 0)
+		files := make([]File, count)
+		for i := range files {
+			files[i] = File{UUID: strconv.Itoa(offset + i)}
+		}
+		json.NewEncoder(w).Encode(struct {
+			Files []File `json:"files"`
+		}{Files: files})
+	}))
+	defer mockServer.Close()
+
+	cfg := newTestConfig(mockServer.URL)
+	cfg.MaxConcurrency = 4
+
+	files, err := ListAllFiles(context.Background(), cfg, "parent-uuid")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(files) != 420 {
+		t.Fatalf("expected 420 files, got %d", len(files))
+	}
+	for i, f := range files {
+		if f.UUID != strconv.Itoa(i) {
+			t.Fatalf("expected files in order, got %s at position %d", f.UUID, i)
+		}
+	}
+	if maxInFlight.Load() < 2 || maxInFlight.Load() > 4 {
+		t.Errorf("expected between 2 and 4 concurrent requests, got %d", maxInFlight.Load())
+	}
+}
+
+// TestListAllFilesCancelled tests that a listing stops between pages once
+// its context is cancelled, even when the pages are cached
+func TestListAllFilesCancelled(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to %s", r.URL)
+	}))
+	defer mockServer.Close()
+
+	cfg := newTestConfig(mockServer.URL)
+	cfg.MaxConcurrency = 1
+	cfg.ListingCache = config.NewListingCache(time.Hour)
+	cfg.ListingCache.Put("parent-uuid", "files?limit=50&offset=0&order=ASC&sort=plainName", make([]File, 50), nil)
+	cfg.ListingCache.Put("parent-uuid", "files?limit=50&offset=50&order=ASC&sort=plainName", []File{}, nil)
+
+	if files, err := ListAllFiles(context.Background(), cfg, "parent-uuid"); err != nil || len(files) != 50 {
+		t.Fatalf("expected the cached listing, got %d files and %v", len(files), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ListAllFiles(ctx, cfg, "parent-uuid"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
 func TestListAllFolders(t *testing.T) {
 	t.Run("pagination loop - multiple pages", func(t *testing.T) {
-		callCount := 0
 		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
 			folders := []Folder{}
-			// Return 50 folders for first call, then 25 for the second
-			if callCount == 0 {
+			// Return 50 folders for the first page, then 25
+			switch offset {
+			case 0:
 				for i := range 50 {
 					folders = append(folders, Folder{UUID: "folder-" + string(rune(i))})
 				}
-			} else {
+			case 50:
 				for i := range 25 {
 					folders = append(folders, Folder{UUID: "folder-" + string(rune(i+50))})
 				}
@@ -732,7 +1020,6 @@ func TestListAllFolders(t *testing.T) {
 			}{Folders: folders}
 			w.WriteHeader(http.StatusOK)
 			json.NewEncoder(w).Encode(response)
-			callCount++
 		}))
 		defer mockServer.Close()
 
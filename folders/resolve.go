@@ -0,0 +1,116 @@
+package folders
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/errors"
+)
+
+// PathEntry is the item a path resolves to. Exactly one of Folder and File
+// is set.
+type PathEntry struct {
+	Folder *Folder
+	File   *File
+}
+
+// ResolvePath walks the slash-separated path from the folder rootUUID and
+// returns the folder or file it names. Files are matched by their plain name
+// plus, when they have one, "." and their type. A missing segment yields an
+// error matching errors.ErrNotFound; with cfg.ListingCache set, the miss is
+// remembered for its NegativeTTL so repeated probes of the same missing name
+// don't list the folder again.
+func ResolvePath(ctx context.Context, cfg *config.Config, rootUUID, path string) (*PathEntry, error) {
+	segments := strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+	if len(segments) == 0 {
+		return &PathEntry{Folder: &Folder{UUID: rootUUID}}, nil
+	}
+
+	parent := rootUUID
+	dirs, name := segments[:len(segments)-1], segments[len(segments)-1]
+	for _, dir := range dirs {
+		folder, err := lookupFolder(ctx, cfg, parent, dir, path)
+		if err != nil {
+			return nil, err
+		}
+		parent = folder.UUID
+	}
+
+	if cfg.ListingCache.IsMissing(parent, name) {
+		return nil, notFoundError(path)
+	}
+	folder, err := findFolder(ctx, cfg, parent, name)
+	if err != nil {
+		return nil, err
+	}
+	if folder != nil {
+		return &PathEntry{Folder: folder}, nil
+	}
+	file, err := findFile(ctx, cfg, parent, name)
+	if err != nil {
+		return nil, err
+	}
+	if file != nil {
+		return &PathEntry{File: file}, nil
+	}
+	cfg.ListingCache.PutMissing(parent, name)
+	return nil, notFoundError(path)
+}
+
+// lookupFolder returns the child folder called name of parentUUID, consulting
+// and updating the negative lookup cache.
+func lookupFolder(ctx context.Context, cfg *config.Config, parentUUID, name, path string) (*Folder, error) {
+	if cfg.ListingCache.IsMissing(parentUUID, name) {
+		return nil, notFoundError(path)
+	}
+	folder, err := findFolder(ctx, cfg, parentUUID, name)
+	if err != nil {
+		return nil, err
+	}
+	if folder == nil {
+		cfg.ListingCache.PutMissing(parentUUID, name)
+		return nil, notFoundError(path)
+	}
+	return folder, nil
+}
+
+func findFolder(ctx context.Context, cfg *config.Config, parentUUID, name string) (*Folder, error) {
+	folders, err := ListAllFolders(ctx, cfg, parentUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %q: %w", name, err)
+	}
+	for i := range folders {
+		if folders[i].PlainName == name {
+			return &folders[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func findFile(ctx context.Context, cfg *config.Config, parentUUID, name string) (*File, error) {
+	files, err := ListAllFiles(ctx, cfg, parentUUID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %q: %w", name, err)
+	}
+	for i := range files {
+		if FileName(&files[i]) == name {
+			return &files[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// FileName returns the name of f as shown to users: its plain name plus its
+// type as the extension.
+func FileName(f *File) string {
+	if f.Type == "" {
+		return f.PlainName
+	}
+	return f.PlainName + "." + f.Type
+}
+
+func notFoundError(path string) error {
+	return fmt.Errorf("failed to resolve path %q: %w", path, errors.ErrNotFound)
+}
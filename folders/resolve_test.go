@@ -0,0 +1,95 @@
+package folders
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/internxt/rclone-adapter/config"
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
+)
+
+func TestResolvePath(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/content/root/folders"):
+			json.NewEncoder(w).Encode(map[string][]Folder{"folders": {{UUID: "docs-uuid", PlainName: "docs"}}})
+		case strings.HasSuffix(r.URL.Path, "/content/docs-uuid/files"):
+			json.NewEncoder(w).Encode(map[string][]File{"files": {{UUID: "file-uuid", PlainName: "report", Type: "pdf"}}})
+		case strings.HasSuffix(r.URL.Path, "/folders"):
+			json.NewEncoder(w).Encode(map[string][]Folder{"folders": {}})
+		default:
+			json.NewEncoder(w).Encode(map[string][]File{"files": {}})
+		}
+	}))
+	defer mockServer.Close()
+
+	cfg := newTestConfig(mockServer.URL)
+	ctx := context.Background()
+
+	tests := []struct {
+		path       string
+		wantFolder string
+		wantFile   string
+	}{
+		{"", "root", ""},
+		{"docs", "docs-uuid", ""},
+		{"/docs/report.pdf", "", "file-uuid"},
+	}
+	for _, tt := range tests {
+		entry, err := ResolvePath(ctx, cfg, "root", tt.path)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tt.path, err)
+		}
+		if tt.wantFolder != "" && (entry.Folder == nil || entry.Folder.UUID != tt.wantFolder) {
+			t.Errorf("%q: expected folder %s, got %+v", tt.path, tt.wantFolder, entry)
+		}
+		if tt.wantFile != "" && (entry.File == nil || entry.File.UUID != tt.wantFile) {
+			t.Errorf("%q: expected file %s, got %+v", tt.path, tt.wantFile, entry)
+		}
+	}
+
+	if _, err := ResolvePath(ctx, cfg, "root", "docs/missing/x.txt"); !errors.Is(err, sdkerrors.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestResolvePathNegativeCache(t *testing.T) {
+	var requests atomic.Int32
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if strings.HasSuffix(r.URL.Path, "/folders") {
+			json.NewEncoder(w).Encode(map[string][]Folder{"folders": {}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string][]File{"files": {}})
+	}))
+	defer mockServer.Close()
+
+	cfg := newTestConfig(mockServer.URL)
+	cfg.ListingCache = config.NewListingCache(time.Nanosecond)
+	ctx := context.Background()
+
+	for range 3 {
+		if _, err := ResolvePath(ctx, cfg, "root", "missing.txt"); !errors.Is(err, sdkerrors.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if requests.Load() != 2 {
+		t.Errorf("expected repeated lookups to be served from the negative cache, got %d requests", requests.Load())
+	}
+
+	cfg.ListingCache.InvalidateFolder("root")
+	if _, err := ResolvePath(ctx, cfg, "root", "missing.txt"); !errors.Is(err, sdkerrors.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if requests.Load() != 4 {
+		t.Errorf("expected invalidation to drop the negative entry, got %d requests", requests.Load())
+	}
+}
@@ -0,0 +1,316 @@
+package fakedrive
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/internxt/rclone-adapter/backend"
+	"github.com/internxt/rclone-adapter/buckets"
+	"github.com/internxt/rclone-adapter/crypto"
+	"github.com/internxt/rclone-adapter/files"
+)
+
+func TestRoundTrip(t *testing.T) {
+	s := New()
+	defer s.Close()
+	fs := backend.NewFs(s.Config(), "")
+	ctx := context.Background()
+
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	obj, err := fs.Put(ctx, "a/b/c.txt", bytes.NewReader(data), int64(len(data)), time.Now())
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	got, ok := s.Content(obj.UUID())
+	if !ok || !bytes.Equal(got, data) {
+		t.Fatalf("stored content mismatch")
+	}
+
+	rc, err := obj.Open(ctx)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	full, _ := io.ReadAll(rc)
+	if err := rc.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if !bytes.Equal(full, data) {
+		t.Fatalf("full read mismatch")
+	}
+
+	rc, err = obj.Open(ctx, &backend.RangeOption{Start: 17, End: 46})
+	if err != nil {
+		t.Fatalf("open range: %v", err)
+	}
+	part, _ := io.ReadAll(rc)
+	rc.Close()
+	if !bytes.Equal(part, data[17:47]) {
+		t.Fatalf("range read mismatch: %q", part)
+	}
+
+	uuid := s.AddFile(RootUUID, "seed.bin", []byte("hello"), time.Now())
+	if got, _ := s.Content(uuid); string(got) != "hello" {
+		t.Fatalf("seeded content %q", got)
+	}
+	o2, err := fs.NewObject(ctx, "seed.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, _ = o2.Open(ctx)
+	b, _ := io.ReadAll(rc)
+	if err := rc.Close(); err != nil || string(b) != "hello" {
+		t.Fatalf("seed read %q %v", b, err)
+	}
+}
+
+func TestMultipartRoundTrip(t *testing.T) {
+	s := New()
+	defer s.Close()
+	cfg := s.Config()
+	cfg.ChunkSize = 1024
+	cfg.MultipartMinSize = 2048
+	fs := backend.NewFs(cfg, "")
+
+	data := bytes.Repeat([]byte("abcdefg"), 1000)
+	obj, err := fs.Put(context.Background(), "big.bin", bytes.NewReader(data), int64(len(data)), time.Now())
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if got, _ := s.Content(obj.UUID()); !bytes.Equal(got, data) {
+		t.Fatal("multipart content mismatch")
+	}
+}
+
+func TestGCMRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), 10000) // Three GCM chunks, the last short
+	testCases := []struct {
+		name      string
+		multipart bool
+	}{
+		{name: "single part"},
+		{name: "multipart", multipart: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := New()
+			defer s.Close()
+			cfg := s.Config()
+			cfg.EncryptVersion = crypto.EncryptVersionAESGCM
+			if tc.multipart {
+				cfg.ChunkSize = 50000
+				cfg.MultipartMinSize = 100000
+			}
+			fs := backend.NewFs(cfg, "")
+			ctx := context.Background()
+
+			obj, err := fs.Put(ctx, "big.bin", bytes.NewReader(data), int64(len(data)), time.Now())
+			if err != nil {
+				t.Fatalf("put: %v", err)
+			}
+			if got, _ := s.Content(obj.UUID()); !bytes.Equal(got, data) {
+				t.Fatal("stored content mismatch")
+			}
+
+			o2, err := fs.NewObject(ctx, "big.bin")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range [][2]int64{{0, -1}, {65530, 65549}, {100000, -1}, {crypto.GCMChunkSize * 2, crypto.GCMChunkSize*2 + 4}, {-1, 7}} {
+				opt := &backend.RangeOption{Start: r[0], End: r[1]}
+				rc, err := o2.Open(ctx, opt)
+				if err != nil {
+					t.Fatalf("open %v: %v", r, err)
+				}
+				got, err := io.ReadAll(rc)
+				rc.Close()
+				offset, limit := opt.Decode(int64(len(data)))
+				want := data[offset:]
+				if limit >= 0 {
+					want = want[:limit]
+				}
+				if err != nil || !bytes.Equal(got, want) {
+					t.Fatalf("read %v: got %d bytes, %v", r, len(got), err)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloadFileGCM(t *testing.T) {
+	s := New()
+	defer s.Close()
+	cfg := s.Config()
+	cfg.EncryptVersion = crypto.EncryptVersionAESGCM
+	ctx := context.Background()
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), 10000)
+	src := filepath.Join(t.TempDir(), "src.bin")
+	if err := os.WriteFile(src, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := buckets.UploadFile(ctx, cfg, src, RootUUID, time.Now())
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if meta.EncryptVersion != crypto.EncryptVersionAESGCM {
+		t.Fatalf("uploaded as %q", meta.EncryptVersion)
+	}
+
+	// Reads follow the version of the file, not the one of new uploads
+	ctrCfg := s.Config()
+	dest := filepath.Join(t.TempDir(), "dest.bin")
+	if err := buckets.DownloadFile(ctx, ctrCfg, meta.FileID, meta.EncryptVersion, dest); err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if got, _ := os.ReadFile(dest); !bytes.Equal(got, data) {
+		t.Fatal("DownloadFile content mismatch")
+	}
+
+	rc, err := buckets.DownloadFileStream(ctx, ctrCfg, meta.FileID, meta.EncryptVersion)
+	if err != nil {
+		t.Fatalf("download stream: %v", err)
+	}
+	got, err := io.ReadAll(rc)
+	if err := rc.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("DownloadFileStream content mismatch: %v", err)
+	}
+
+	// ...and a 03-aes file stays readable with 04-aes-gcm uploads configured
+	ctrSrc := filepath.Join(t.TempDir(), "ctr.bin")
+	if err := os.WriteFile(ctrSrc, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctrMeta, err := buckets.UploadFile(ctx, ctrCfg, ctrSrc, RootUUID, time.Now())
+	if err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if err := buckets.DownloadFile(ctx, cfg, ctrMeta.FileID, ctrMeta.EncryptVersion, dest); err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if got, _ := os.ReadFile(dest); !bytes.Equal(got, data) {
+		t.Fatal("DownloadFile content mismatch for a 03-aes file")
+	}
+}
+
+func TestKeyDeriverRoundTrip(t *testing.T) {
+	s := New()
+	defer s.Close()
+	cfg := s.Config()
+	var derived int
+	cfg.KeyDeriver = crypto.KeyDeriverFunc(func(mnemonic, bucketID, indexHex string) ([]byte, []byte, error) {
+		derived++
+		sum := sha256.Sum256([]byte("device key " + indexHex))
+		index, err := hex.DecodeString(indexHex)
+		return sum[:], index[:16], err
+	})
+	fs := backend.NewFs(cfg, "")
+	ctx := context.Background()
+
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	obj, err := fs.Put(ctx, "a.txt", bytes.NewReader(data), int64(len(data)), time.Now())
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if got, _ := s.Content(obj.UUID()); bytes.Equal(got, data) {
+		t.Fatal("expected content not to decrypt with the mnemonic keys")
+	}
+	rc, err := obj.Open(ctx, &backend.SeekOption{Offset: 25}, &backend.RangeOption{Start: 25, End: 34})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	got, _ := io.ReadAll(rc)
+	rc.Close()
+	if !bytes.Equal(got, data[25:35]) || derived != 2 {
+		t.Fatalf("read %q with %d derivations", got, derived)
+	}
+}
+
+// pathRecorder records the paths of the requests it forwards.
+type pathRecorder struct {
+	base  http.RoundTripper
+	mu    sync.Mutex
+	paths []string
+}
+
+func (p *pathRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	p.mu.Lock()
+	p.paths = append(p.paths, req.URL.Path)
+	p.mu.Unlock()
+	return p.base.RoundTrip(req)
+}
+
+func TestWorkspaceRoundTrip(t *testing.T) {
+	s := New()
+	defer s.Close()
+	cfg := s.Config()
+	cfg.WorkspaceID = "ws-1"
+	rec := &pathRecorder{base: cfg.HTTPClient.Transport}
+	cfg.HTTPClient.Transport = rec
+	fs := backend.NewFs(cfg, "")
+	ctx := context.Background()
+
+	if _, err := fs.Put(ctx, "team/plan.txt", strings.NewReader("ship it"), 7, time.Now()); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	entries, err := fs.List(ctx, "team")
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("list: %v, %v", entries, err)
+	}
+	if _, _, ok := s.Lookup("team/plan.txt"); !ok {
+		t.Fatal("expected the file in the drive")
+	}
+
+	created := false
+	for _, p := range rec.paths {
+		if strings.HasPrefix(p, "/drive/folders/content/") || p == "/drive/folders" || p == "/drive/files" {
+			t.Errorf("expected workspace endpoints, got a request to %s", p)
+		}
+		created = created || p == "/drive/workspaces/ws-1/files"
+	}
+	if !created {
+		t.Errorf("expected the file to be created in the workspace, got requests %v", rec.paths)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	s := New()
+	defer s.Close()
+	cfg := s.Config()
+	fs := backend.NewFs(cfg, "")
+	ctx := context.Background()
+
+	// A replace that stopped after setting the old file aside
+	docs := s.AddFolder(RootUUID, "docs")
+	aside := s.AddFile(docs, "report.txt", []byte("old"), time.Now())
+	if err := files.RenameFile(ctx, cfg, aside, "report.old-"+aside, "txt"); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	s.AddFile(docs, "report.txt", []byte("new"), time.Now())
+	s.AddFile(RootUUID, "keep.old-1234.txt", []byte("mine"), time.Now())
+
+	if err := fs.Cleanup(ctx); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if _, _, ok := s.Lookup("docs/report.old-" + aside + ".txt"); ok {
+		t.Error("expected the file set aside to be removed")
+	}
+	for _, p := range []string{"docs/report.txt", "keep.old-1234.txt"} {
+		if _, _, ok := s.Lookup(p); !ok {
+			t.Errorf("expected %s to be kept", p)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+// Package spool stores temporary data, such as buffered writes and staged
+// upload parts, in local files. With a Key the files are encrypted with
+// AES-256-GCM under a key that only lives in memory, so they never hold
+// plaintext user data and are unreadable once the process exits.
+package spool
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/internxt/rclone-adapter/crypto"
+)
+
+// Encrypted files are stored in blocks of blockSize plaintext bytes, each
+// sealed under its own random nonce and its index, so rewriting a block
+// never reuses a keystream. Only the last block may be shorter.
+const (
+	blockSize     = 4096
+	nonceSize     = 12
+	blockOverhead = nonceSize + 16 // Nonce and GCM tag
+	storedBlock   = blockSize + blockOverhead
+)
+
+// Key encrypts spool files. It is random and never leaves memory.
+type Key struct {
+	aead cipher.AEAD
+}
+
+// NewKey returns a new random key.
+func NewKey() *Key {
+	raw := make([]byte, 32)
+	rand.Read(raw) // Never fails, see crypto/rand.Read
+	defer crypto.Wipe(raw)
+	block, _ := aes.NewCipher(raw) // Cannot fail for 32 bytes
+	aead, _ := cipher.NewGCM(block)
+	return &Key{aead: aead}
+}
+
+// File is a spool file. ReadAt, WriteAt, Truncate and Size address its
+// plaintext; Read and Write do so from the current offset, which only
+// they move. Bytes never written, past a gap left by WriteAt or added by
+// Truncate, read as zeros.
+type File struct {
+	f   *os.File
+	key *Key
+	mu  sync.Mutex // Serializes block updates of encrypted files
+	off int64      // Offset of Read and Write
+}
+
+// Create creates a new spool file in dir, named like os.CreateTemp does.
+// A nil key stores plaintext.
+func Create(dir, pattern string, key *Key) (*File, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &File{f: f, key: key}, nil
+}
+
+// Open opens the spool file name, created with the same key, for reading.
+func Open(name string, key *Key) (*File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &File{f: f, key: key}, nil
+}
+
+// Name returns the path of the file.
+func (f *File) Name() string { return f.f.Name() }
+
+// Size returns the size of the contents.
+func (f *File) Size() (int64, error) {
+	if f.key == nil {
+		fi, err := f.f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return fi.Size(), nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.size()
+}
+
+// ReadAt implements io.ReaderAt.
+func (f *File) ReadAt(p []byte, off int64) (int, error) {
+	if f.key == nil {
+		return f.f.ReadAt(p, off)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		block, err := f.readBlock(pos / blockSize)
+		if err != nil {
+			return n, err
+		}
+		start := int(pos % blockSize)
+		if start >= len(block) {
+			return n, io.EOF
+		}
+		n += copy(p[n:], block[start:])
+	}
+	return n, nil
+}
+
+// WriteAt implements io.WriterAt.
+func (f *File) WriteAt(p []byte, off int64) (int, error) {
+	if f.key == nil {
+		return f.f.WriteAt(p, off)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.grow(off); err != nil {
+		return 0, err
+	}
+	return f.write(p, off)
+}
+
+// Read implements io.Reader.
+func (f *File) Read(p []byte) (int, error) {
+	n, err := f.ReadAt(p, f.off)
+	f.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// Write implements io.Writer.
+func (f *File) Write(p []byte) (int, error) {
+	n, err := f.WriteAt(p, f.off)
+	f.off += int64(n)
+	return n, err
+}
+
+// Truncate changes the size of the contents.
+func (f *File) Truncate(size int64) error {
+	if f.key == nil {
+		return f.f.Truncate(size)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	cur, err := f.size()
+	if err != nil {
+		return err
+	}
+	if size >= cur {
+		return f.grow(size)
+	}
+	i, keep := size/blockSize, size%blockSize
+	if keep > 0 {
+		block, err := f.readBlock(i)
+		if err != nil {
+			return err
+		}
+		if err := f.writeBlock(i, block[:keep]); err != nil {
+			return err
+		}
+		return f.f.Truncate(i*storedBlock + keep + blockOverhead)
+	}
+	return f.f.Truncate(i * storedBlock)
+}
+
+// Close closes the file. It does not remove it.
+func (f *File) Close() error {
+	return f.f.Close()
+}
+
+// size returns the size of the contents of an encrypted file.
+func (f *File) size() (int64, error) {
+	fi, err := f.f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	full, rest := fi.Size()/storedBlock, fi.Size()%storedBlock
+	if rest > 0 && rest <= blockOverhead {
+		return 0, fmt.Errorf("spool file %s is corrupted", f.Name())
+	}
+	return full*blockSize + max(rest-blockOverhead, 0), nil
+}
+
+// grow extends an encrypted file with zeros up to size.
+func (f *File) grow(size int64) error {
+	cur, err := f.size()
+	if err != nil {
+		return err
+	}
+	zeros := make([]byte, blockSize)
+	for cur < size {
+		n := min(blockSize-cur%blockSize, size-cur)
+		if _, err := f.write(zeros[:n], cur); err != nil {
+			return err
+		}
+		cur += n
+	}
+	return nil
+}
+
+// write writes p at off, which is at most the size of the file, rewriting
+// each block it touches.
+func (f *File) write(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		i, start := pos/blockSize, int(pos%blockSize)
+		block, err := f.readBlock(i)
+		if err != nil {
+			return n, err
+		}
+		end := min(start+len(p)-n, blockSize)
+		if end > len(block) {
+			block = append(block, make([]byte, end-len(block))...)
+		}
+		copy(block[start:end], p[n:])
+		if err := f.writeBlock(i, block); err != nil {
+			return n, err
+		}
+		n += end - start
+	}
+	return n, nil
+}
+
+// readBlock returns the plaintext of block i, empty past the end.
+func (f *File) readBlock(i int64) ([]byte, error) {
+	stored := make([]byte, storedBlock)
+	n, err := f.f.ReadAt(stored, i*storedBlock)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	if n <= blockOverhead {
+		return nil, fmt.Errorf("spool file %s is corrupted", f.Name())
+	}
+	nonce, sealed := stored[:nonceSize], stored[nonceSize:n]
+	block, err := f.key.aead.Open(sealed[:0], nonce, sealed, blockAD(i))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt block %d of spool file %s: %w", i, f.Name(), err)
+	}
+	return block, nil
+}
+
+// writeBlock seals the plaintext of block i under a new nonce.
+func (f *File) writeBlock(i int64, block []byte) error {
+	stored := make([]byte, nonceSize, nonceSize+len(block)+blockOverhead)
+	rand.Read(stored) // Never fails, see crypto/rand.Read
+	stored = f.key.aead.Seal(stored, stored, block, blockAD(i))
+	_, err := f.f.WriteAt(stored, i*storedBlock)
+	return err
+}
+
+// blockAD binds a block to its position, so blocks cannot be moved.
+func blockAD(i int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(i))
+}
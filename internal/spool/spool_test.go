@@ -0,0 +1,159 @@
+package spool
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFile(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		key  *Key
+	}{
+		{name: "plaintext"},
+		{name: "encrypted", key: NewKey()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			f, err := Create(dir, "spool-*", tc.key)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			data := bytes.Repeat([]byte("user data "), 10)
+			if _, err := f.Write(data); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			f.WriteAt([]byte("USER"), 21) // Not block aligned
+			copy(data[21:], "USER")
+
+			p := make([]byte, 30)
+			if n, err := f.ReadAt(p, 17); err != nil || !bytes.Equal(p[:n], data[17:47]) {
+				t.Errorf("expected %q, got %q, %v", data[17:47], p[:n], err)
+			}
+			if err := f.Truncate(50); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if size, err := f.Size(); err != nil || size != 50 {
+				t.Errorf("expected size 50, got %d, %v", size, err)
+			}
+			f.Close()
+
+			raw, _ := os.ReadFile(f.Name())
+			if encrypted := !bytes.Contains(raw, []byte("user data")); encrypted != (tc.key != nil) {
+				t.Errorf("expected the file to be encrypted: %v, got %q", tc.key != nil, raw)
+			}
+
+			moved := filepath.Join(dir, "moved")
+			os.Rename(f.Name(), moved)
+			r, err := Open(moved, tc.key)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer r.Close()
+			if got, err := io.ReadAll(r); err != nil || !bytes.Equal(got, data[:50]) {
+				t.Errorf("expected %q, got %q, %v", data[:50], got, err)
+			}
+		})
+	}
+}
+
+func TestOpenWrongKey(t *testing.T) {
+	f, err := Create(t.TempDir(), "spool-*", NewKey())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.Write([]byte("user data"))
+	f.Close()
+
+	r, err := Open(f.Name(), NewKey())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+	if got, _ := io.ReadAll(r); bytes.Equal(got, []byte("user data")) {
+		t.Error("expected another key not to decrypt the file")
+	}
+}
+
+func TestFileOverwrite(t *testing.T) {
+	f, err := Create(t.TempDir(), "spool-*", NewKey())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	old := bytes.Repeat([]byte("old plaintext "), 100)
+	f.WriteAt(old, 0)
+	before, _ := os.ReadFile(f.Name())
+	replaced := bytes.Repeat([]byte("NEW PLAINTEXT "), 100)
+	f.WriteAt(replaced, 0)
+	after, _ := os.ReadFile(f.Name())
+
+	// With a reused keystream the two ciphertexts XOR to the two plaintexts
+	xored := make([]byte, 64)
+	for i := range xored {
+		xored[i] = before[blockOverhead+i] ^ after[blockOverhead+i]
+	}
+	for i := range xored {
+		xored[i] ^= old[i] ^ replaced[i]
+	}
+	if bytes.Equal(xored, make([]byte, 64)) {
+		t.Error("expected overwriting to use a new keystream")
+	}
+
+	got := make([]byte, len(replaced))
+	if n, err := f.ReadAt(got, 0); err != nil || !bytes.Equal(got[:n], replaced) {
+		t.Errorf("expected the new data, got %q, %v", got[:n], err)
+	}
+}
+
+func TestFileZeroFill(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		key  *Key
+	}{
+		{name: "plaintext"},
+		{name: "encrypted", key: NewKey()},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := Create(t.TempDir(), "spool-*", tc.key)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer f.Close()
+
+			want := make([]byte, 3*blockSize+100)
+			f.Write([]byte("head"))
+			copy(want, "head")
+			if err := f.Truncate(blockSize + 10); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			// Past the end, leaving a gap
+			f.WriteAt([]byte("tail"), 3*blockSize+96)
+			copy(want[3*blockSize+96:], "tail")
+
+			if size, err := f.Size(); err != nil || size != int64(len(want)) {
+				t.Fatalf("expected size %d, got %d, %v", len(want), size, err)
+			}
+			got := make([]byte, len(want))
+			if n, err := f.ReadAt(got, 0); err != nil || !bytes.Equal(got[:n], want) {
+				t.Errorf("expected zeros in the gaps, got %q, %v", got[:n], err)
+			}
+
+			// Shrinking into a block and growing again zeroes the rest
+			if err := f.Truncate(2); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := f.Truncate(8); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got = make([]byte, 8)
+			if n, err := f.ReadAt(got, 0); err != nil || !bytes.Equal(got[:n], []byte("he\x00\x00\x00\x00\x00\x00")) {
+				t.Errorf("expected truncated data followed by zeros, got %q, %v", got[:n], err)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package send
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/internxt/rclone-adapter/auth"
+	"github.com/internxt/rclone-adapter/buckets"
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/crypto"
+)
+
+// Transfer is a received Send link, opened with the code of its URL.
+type Transfer struct {
+	Link
+
+	cfg *config.Config // Network access to the sender's bucket
+}
+
+// receivedLink is a link as resolved by its receivers.
+type receivedLink struct {
+	Link
+	Mnemonic    string `json:"mnemonic"`
+	Bucket      string `json:"bucket"`
+	Credentials struct {
+		NetworkUser string `json:"networkUser"`
+		NetworkPass string `json:"networkPass"`
+	} `json:"credentials"`
+}
+
+// Fetch opens a Send link, as built by Upload or the web app. No account
+// is needed; only the endpoints and HTTP client of cfg are used.
+func Fetch(ctx context.Context, cfg *config.Config, linkURL string) (*Transfer, error) {
+	id, code, err := parseLink(linkURL)
+	if err != nil {
+		return nil, err
+	}
+	var link receivedLink
+	if err := doJSON(ctx, cfg, http.MethodGet, cfg.Endpoints.Drive().Sends().Link(id), "get send link", nil, &link); err != nil {
+		return nil, err
+	}
+	mnemonic, err := crypto.DecryptTextGCM(link.Mnemonic, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt key of send link %s: %w", id, err)
+	}
+
+	out := cfg.Clone()
+	out.SetMnemonic(mnemonic)
+	out.KeyDeriver = nil
+	out.Bucket = link.Bucket
+	out.BasicAuthHeader = auth.NetworkBasicAuth(link.Credentials.NetworkUser, link.Credentials.NetworkPass)
+	link.Link.URL = linkURL
+	return &Transfer{Link: link.Link, cfg: out}, nil
+}
+
+// parseLink splits a link of the form {base}/download/{linkId}?code={code}.
+func parseLink(linkURL string) (string, string, error) {
+	u, err := url.Parse(linkURL)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse send link: %w", err)
+	}
+	seg := strings.Split(strings.Trim(u.Path, "/"), "/")
+	code := u.Query().Get("code")
+	if len(seg) < 2 || seg[len(seg)-2] != "download" || code == "" {
+		return "", "", fmt.Errorf("failed to parse send link %q: not a send link", linkURL)
+	}
+	return seg[len(seg)-1], code, nil
+}
+
+// Open streams the decrypted contents of item, one of t.Items.
+func (t *Transfer) Open(ctx context.Context, item *Item) (io.ReadCloser, error) {
+	if item.NetworkID == "" {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	return buckets.DownloadFileStream(ctx, t.cfg, item.NetworkID, item.EncryptVersion)
+}
@@ -0,0 +1,162 @@
+// Package send transfers files through Internxt Send: files are uploaded to
+// a transient link that expires, and anyone with the link downloads them
+// without an account, wetransfer-style.
+//
+// Every transfer gets a fresh mnemonic, so the sender's own keys are never
+// shared. The files are encrypted with it and stored in the sender's
+// bucket; the mnemonic is encrypted with a random code and stored with the
+// link, and the code only travels in the link URL.
+package send
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/internxt/rclone-adapter/buckets"
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/crypto"
+)
+
+// DefaultLinkBaseURL is the web app that opens Send links.
+const DefaultLinkBaseURL = "https://send.internxt.com"
+
+// DefaultExpiry is how long a link lasts unless Options.ExpiresAt is set.
+const DefaultExpiry = 14 * 24 * time.Hour
+
+// File is a file to send.
+type File struct {
+	Name    string
+	Size    int64
+	Content io.Reader
+}
+
+// Options configures a new transfer. The zero value creates a link that
+// expires after DefaultExpiry without emailing anyone.
+type Options struct {
+	Title     string
+	Message   string
+	Sender    string    // Email of the sender, shown to receivers
+	Receivers []string  // Emails the link is sent to
+	ExpiresAt time.Time // Zero for DefaultExpiry
+	BaseURL   string    // Web app the link points to (default DefaultLinkBaseURL)
+}
+
+// Item is a file of a transfer.
+type Item struct {
+	ID             string `json:"id,omitempty"`
+	Name           string `json:"name"`
+	Type           string `json:"type"`
+	Size           int64  `json:"size"`
+	NetworkID      string `json:"networkId"`
+	EncryptVersion string `json:"encryptVersion,omitempty"`
+}
+
+// Link is a transfer as returned by the Send API. URL, which carries the
+// code, is only known to the sender and to receivers of Fetch.
+type Link struct {
+	ID           string `json:"id"`
+	Title        string `json:"title"`
+	Subject      string `json:"subject"`
+	Sender       string `json:"sender"`
+	Items        []Item `json:"items"`
+	Size         int64  `json:"size"`
+	Views        int    `json:"views"`
+	Downloads    int    `json:"downloads"`
+	ExpirationAt string `json:"expirationAt"`
+	URL          string `json:"-"`
+}
+
+type createLinkRequest struct {
+	Items        []Item   `json:"items"`
+	Mnemonic     string   `json:"mnemonic"`
+	Code         string   `json:"code"`
+	Bucket       string   `json:"bucket"`
+	Title        string   `json:"title,omitempty"`
+	Subject      string   `json:"subject,omitempty"`
+	Sender       string   `json:"sender,omitempty"`
+	Receivers    []string `json:"receivers,omitempty"`
+	ExpirationAt string   `json:"expirationAt"`
+}
+
+// Upload encrypts files into the account's bucket and creates a Send link
+// for them. The returned link has its URL set.
+func Upload(ctx context.Context, cfg *config.Config, files []File, opts *Options) (*Link, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("failed to create send link: no files")
+	}
+	mnemonic, err := crypto.GenerateMnemonic()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate transfer key: %w", err)
+	}
+	var raw [32]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate link code: %w", err)
+	}
+	code := hex.EncodeToString(raw[:])
+
+	fc, err := crypto.CipherFor(cfg.EncryptVersion)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create send link: %w", err)
+	}
+
+	transfer := cfg.Clone()
+	transfer.SetMnemonic(mnemonic)
+	transfer.KeyDeriver = nil // Receivers derive keys the standard way
+	items := make([]Item, 0, len(files))
+	for _, f := range files {
+		item := Item{Name: f.Name, Type: "file", Size: f.Size}
+		if f.Size > 0 {
+			item.EncryptVersion = fc.Version()
+			item.NetworkID, err = buckets.UploadData(ctx, transfer, f.Content, f.Size)
+			if err != nil {
+				return nil, fmt.Errorf("failed to upload %s: %w", f.Name, err)
+			}
+		}
+		items = append(items, item)
+	}
+
+	encMnemonic, err := crypto.EncryptTextGCM(mnemonic, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt transfer key: %w", err)
+	}
+	encCode, err := crypto.EncryptTextGCM(code, mnemonic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt link code: %w", err)
+	}
+	expiresAt := opts.ExpiresAt
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(DefaultExpiry)
+	}
+	body := createLinkRequest{
+		Items:        items,
+		Mnemonic:     encMnemonic,
+		Code:         encCode,
+		Bucket:       cfg.Bucket,
+		Title:        opts.Title,
+		Subject:      opts.Message,
+		Sender:       opts.Sender,
+		Receivers:    opts.Receivers,
+		ExpirationAt: expiresAt.UTC().Format(time.RFC3339),
+	}
+	var link Link
+	if err := doJSON(ctx, cfg, http.MethodPost, cfg.Endpoints.Drive().Sends().Create(), "create send link", body, &link); err != nil {
+		return nil, err
+	}
+
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultLinkBaseURL
+	}
+	link.URL = strings.TrimSuffix(baseURL, "/") + "/download/" + link.ID + "?" + url.Values{"code": {code}}.Encode()
+	return &link, nil
+}
@@ -0,0 +1,131 @@
+package send
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/endpoints"
+	"github.com/internxt/rclone-adapter/internal/fakedrive"
+)
+
+// newSendServer serves the Send API in front of the sender's fake drive.
+// The created link is stored in *stored.
+func newSendServer(t *testing.T, server *fakedrive.Server, stored *createLinkRequest) *httptest.Server {
+	target, _ := url.Parse(server.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/drive/links":
+			if r.Header.Get("Authorization") == "" {
+				t.Error("expected the sender's token")
+			}
+			json.NewDecoder(r.Body).Decode(stored)
+			json.NewEncoder(w).Encode(Link{ID: "link-1", Title: stored.Title, Items: stored.Items, ExpirationAt: stored.ExpirationAt})
+		case r.Method == http.MethodGet && r.URL.Path == "/drive/links/link-1":
+			if r.Header.Get("Authorization") != "" {
+				t.Error("expected no Authorization header")
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"id": "link-1", "title": stored.Title, "items": stored.Items,
+				"mnemonic": stored.Mnemonic, "bucket": stored.Bucket,
+				"credentials": map[string]string{"networkUser": "sender@example.com", "networkPass": "sender-pass"},
+			})
+		default:
+			proxy.ServeHTTP(w, r)
+		}
+	}))
+	t.Cleanup(front.Close)
+	return front
+}
+
+func TestUploadAndFetch(t *testing.T) {
+	server := fakedrive.New()
+	defer server.Close()
+	var stored createLinkRequest
+	front := newSendServer(t, server, &stored)
+
+	sender := server.Config()
+	sender.Endpoints = endpoints.NewConfig(front.URL)
+	files := []File{
+		{Name: "a.txt", Size: 5, Content: strings.NewReader("first")},
+		{Name: "empty.txt", Size: 0, Content: strings.NewReader("")},
+	}
+	expiresAt := time.Now().Add(time.Hour)
+	link, err := Upload(context.Background(), sender, files, &Options{Title: "holidays", ExpiresAt: expiresAt})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(link.URL, DefaultLinkBaseURL+"/download/link-1?code=") {
+		t.Errorf("unexpected link URL %s", link.URL)
+	}
+	if stored.Bucket != sender.Bucket || stored.ExpirationAt != expiresAt.UTC().Format(time.RFC3339) {
+		t.Errorf("unexpected link request %+v", stored)
+	}
+	if strings.Contains(stored.Mnemonic, sender.MnemonicString()) || strings.Contains(link.URL, stored.Mnemonic) {
+		t.Error("expected the transfer key to be encrypted and kept out of the URL")
+	}
+
+	receiver := &config.Config{Endpoints: endpoints.NewConfig(front.URL)}
+	receiver.ApplyDefaults()
+	transfer, err := Fetch(context.Background(), receiver, link.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if transfer.Title != "holidays" || len(transfer.Items) != 2 {
+		t.Fatalf("unexpected transfer %+v", transfer.Link)
+	}
+	for i, want := range []string{"first", ""} {
+		rc, err := transfer.Open(context.Background(), &transfer.Items[i])
+		if err != nil {
+			t.Fatalf("failed to open %s: %v", transfer.Items[i].Name, err)
+		}
+		data, err := io.ReadAll(rc)
+		if cerr := rc.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil || string(data) != want {
+			t.Errorf("expected %s to contain %q, got %q, %v", transfer.Items[i].Name, want, data, err)
+		}
+	}
+}
+
+func TestFetchErrors(t *testing.T) {
+	server := fakedrive.New()
+	defer server.Close()
+	var stored createLinkRequest
+	front := newSendServer(t, server, &stored)
+	sender := server.Config()
+	sender.Endpoints = endpoints.NewConfig(front.URL)
+	if _, err := Upload(context.Background(), sender, []File{{Name: "a.txt", Size: 1, Content: strings.NewReader("a")}}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	receiver := &config.Config{Endpoints: endpoints.NewConfig(front.URL)}
+	receiver.ApplyDefaults()
+
+	testCases := []struct {
+		name string
+		link string
+		want string
+	}{
+		{"not a link", "https://send.internxt.com/", "not a send link"},
+		{"missing code", "https://send.internxt.com/download/link-1", "not a send link"},
+		{"unknown link", "https://send.internxt.com/download/link-2?code=abcd", "404"},
+		{"wrong code", "https://send.internxt.com/download/link-1?code=" + strings.Repeat("cd", 32), "failed to decrypt key"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Fetch(context.Background(), receiver, tc.link); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
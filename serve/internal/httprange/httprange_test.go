@@ -0,0 +1,28 @@
+package httprange
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		header    string
+		wantStart int64
+		wantEnd   int64
+		wantOK    bool
+	}{
+		{"bytes=0-9", 0, 9, true},
+		{"bytes=10-", 10, 99, true},
+		{"bytes=-10", 90, 99, true},
+		{"bytes=-200", 0, 99, true},
+		{"bytes=50-500", 50, 99, true},
+		{"bytes=100-", 0, 0, false},
+		{"bytes=9-5", 0, 0, false},
+		{"bytes=0-1,5-6", 0, 0, false},
+		{"items=0-1", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, end, ok := Parse(tt.header, 100)
+		if ok != tt.wantOK || (ok && (start != tt.wantStart || end != tt.wantEnd)) {
+			t.Errorf("%q: expected %d-%d %v, got %d-%d %v", tt.header, tt.wantStart, tt.wantEnd, tt.wantOK, start, end, ok)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package s3
+
+import (
+	"encoding/xml"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/internxt/rclone-adapter/backend"
+	"github.com/internxt/rclone-adapter/errors"
+)
+
+// apiError is an S3 error response.
+type apiError struct {
+	Code    string
+	Message string
+	Status  int
+}
+
+func (e *apiError) Error() string { return e.Code + ": " + e.Message }
+
+var (
+	errAccessDenied            = &apiError{"AccessDenied", "Access Denied.", http.StatusForbidden}
+	errBucketAlreadyOwnedByYou = &apiError{"BucketAlreadyOwnedByYou", "The bucket already exists.", http.StatusConflict}
+	errBucketNotEmpty          = &apiError{"BucketNotEmpty", "The bucket you tried to delete is not empty.", http.StatusConflict}
+	errEntityTooLarge          = &apiError{"EntityTooLarge", "Your proposed upload exceeds the maximum allowed object size.", http.StatusBadRequest}
+	errIncompleteBody          = &apiError{"IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header.", http.StatusBadRequest}
+	errInternalError           = &apiError{"InternalError", "We encountered an internal error. Please try again.", http.StatusInternalServerError}
+	errInvalidAccessKeyID      = &apiError{"InvalidAccessKeyId", "The access key ID you provided does not exist in our records.", http.StatusForbidden}
+	errInvalidArgument         = &apiError{"InvalidArgument", "Invalid argument.", http.StatusBadRequest}
+	errInvalidPart             = &apiError{"InvalidPart", "One or more of the specified parts could not be found.", http.StatusBadRequest}
+	errInvalidPartOrder        = &apiError{"InvalidPartOrder", "The list of parts was not in ascending order.", http.StatusBadRequest}
+	errInvalidRange            = &apiError{"InvalidRange", "The requested range is not satisfiable.", http.StatusRequestedRangeNotSatisfiable}
+	errMalformedXML            = &apiError{"MalformedXML", "The XML you provided was not well-formed.", http.StatusBadRequest}
+	errMethodNotAllowed        = &apiError{"MethodNotAllowed", "The specified method is not allowed against this resource.", http.StatusMethodNotAllowed}
+	errNoSuchBucket            = &apiError{"NoSuchBucket", "The specified bucket does not exist.", http.StatusNotFound}
+	errNoSuchKey               = &apiError{"NoSuchKey", "The specified key does not exist.", http.StatusNotFound}
+	errNoSuchUpload            = &apiError{"NoSuchUpload", "The specified multipart upload does not exist.", http.StatusNotFound}
+	errNotImplemented          = &apiError{"NotImplemented", "A header or query you provided implies functionality that is not implemented.", http.StatusNotImplemented}
+	errQuotaExceeded           = &apiError{"QuotaExceeded", "The account storage quota is exceeded.", http.StatusInsufficientStorage}
+	errRequestTimeTooSkewed    = &apiError{"RequestTimeTooSkewed", "The difference between the request time and the server's time is too large.", http.StatusForbidden}
+	errSignatureDoesNotMatch   = &apiError{"SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided.", http.StatusForbidden}
+	errServiceUnavailable      = &apiError{"ServiceUnavailable", "The drive API could not serve the request.", http.StatusServiceUnavailable}
+)
+
+type errorResponse struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource"`
+}
+
+// toAPIError maps an error to the S3 error reported to the client.
+func toAPIError(err error) *apiError {
+	var apiErr *apiError
+	var httpErr *errors.HTTPError
+	switch {
+	case stderrors.As(err, &apiErr):
+		return apiErr
+	case stderrors.Is(err, errors.ErrNotFound):
+		return errNoSuchKey
+	case stderrors.Is(err, backend.ErrIsDir), stderrors.Is(err, backend.ErrIsFile), stderrors.Is(err, errors.ErrAlreadyExists):
+		return errInvalidArgument
+	case stderrors.Is(err, errors.ErrFileTooLarge):
+		return errEntityTooLarge
+	case stderrors.Is(err, errors.ErrQuotaExceeded):
+		return errQuotaExceeded
+	case stderrors.Is(err, errors.ErrUnauthorized):
+		return errAccessDenied
+	case stderrors.As(err, &httpErr):
+		return errServiceUnavailable
+	default:
+		return errInternalError
+	}
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := toAPIError(err)
+	if apiErr.Status >= http.StatusInternalServerError {
+		h.logger.Warn("s3 request failed", "method", r.Method, "path", r.URL.Path, "code", apiErr.Code, "error", err)
+	}
+	writeXML(w, apiErr.Status, errorResponse{Code: apiErr.Code, Message: apiErr.Message, Resource: r.URL.Path})
+}
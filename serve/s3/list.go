@@ -0,0 +1,216 @@
+package s3
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	stderrors "errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/internxt/rclone-adapter/backend"
+	"github.com/internxt/rclone-adapter/errors"
+)
+
+const maxKeys = 1000
+
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+type listAllMyBucketsResult struct {
+	XMLName xml.Name     `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListAllMyBucketsResult"`
+	Owner   owner        `xml:"Owner"`
+	Buckets []bucketInfo `xml:"Buckets>Bucket"`
+}
+
+type owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+type bucketInfo struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type locationConstraint struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+	Region  string   `xml:",chardata"`
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	KeyCount              int            `xml:"KeyCount"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Contents              []objectInfo   `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+type objectInfo struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+func (h *Handler) listBuckets(w http.ResponseWriter, r *http.Request) error {
+	entries, err := h.fs.List(r.Context(), "")
+	if err != nil {
+		return err
+	}
+	result := listAllMyBucketsResult{Owner: owner{ID: "internxt", DisplayName: "internxt"}}
+	for _, entry := range entries {
+		if _, ok := entry.(*backend.Directory); ok {
+			result.Buckets = append(result.Buckets, bucketInfo{Name: entry.Remote(), CreationDate: formatTime(entry.ModTime())})
+		}
+	}
+	return writeXML(w, http.StatusOK, result)
+}
+
+// listItem is a key or common prefix of a listing.
+type listItem struct {
+	key    string
+	prefix bool
+	obj    *backend.Object
+}
+
+func (h *Handler) listObjectsV2(w http.ResponseWriter, r *http.Request, bucket string) error {
+	query := r.URL.Query()
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	limit := maxKeys
+	if v := query.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return errInvalidArgument
+		}
+		limit = min(n, maxKeys)
+	}
+	after := query.Get("start-after")
+	token := query.Get("continuation-token")
+	if token != "" {
+		key, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			return errInvalidArgument
+		}
+		after = string(key)
+	}
+
+	if err := h.checkBucket(r.Context(), bucket); err != nil {
+		return err
+	}
+	items, err := h.collect(r.Context(), bucket, prefix, delimiter)
+	if err != nil {
+		return err
+	}
+	i := sort.Search(len(items), func(i int) bool { return items[i].key > after })
+	items = items[i:]
+
+	result := listBucketResult{
+		Name:              bucket,
+		Prefix:            prefix,
+		Delimiter:         delimiter,
+		StartAfter:        query.Get("start-after"),
+		ContinuationToken: token,
+		MaxKeys:           limit,
+	}
+	if len(items) > limit {
+		items = items[:limit]
+		result.IsTruncated = true
+		result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(items[len(items)-1].key))
+	}
+	for _, item := range items {
+		if item.prefix {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: item.key})
+			continue
+		}
+		result.Contents = append(result.Contents, objectInfo{
+			Key:          item.key,
+			LastModified: formatTime(item.obj.ModTime()),
+			ETag:         etag(item.obj),
+			Size:         item.obj.Size(),
+			StorageClass: "STANDARD",
+		})
+	}
+	result.KeyCount = len(items)
+	return writeXML(w, http.StatusOK, result)
+}
+
+// collect returns the sorted keys and common prefixes of bucket matching
+// prefix. With the "/" delimiter only the directory holding prefix is
+// listed; otherwise the tree below it is walked.
+func (h *Handler) collect(ctx context.Context, bucket, prefix, delimiter string) ([]listItem, error) {
+	dir := prefix[:strings.LastIndex(prefix, "/")+1]
+	var items []listItem
+	seen := map[string]bool{}
+	add := func(key string, entry backend.Entry) {
+		if !strings.HasPrefix(key, prefix) {
+			return
+		}
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				common := key[:len(prefix)+i+len(delimiter)]
+				if !seen[common] {
+					seen[common] = true
+					items = append(items, listItem{key: common, prefix: true})
+				}
+				return
+			}
+		}
+		if obj, ok := entry.(*backend.Object); ok {
+			items = append(items, listItem{key: key, obj: obj})
+		}
+	}
+
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := h.fs.List(ctx, bucket+"/"+dir)
+		if stderrors.Is(err, errors.ErrNotFound) || stderrors.Is(err, backend.ErrIsFile) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			key := strings.TrimPrefix(entry.Remote(), bucket+"/")
+			if _, ok := entry.(*backend.Directory); !ok {
+				add(key, entry)
+				continue
+			}
+			if delimiter == "/" {
+				add(key+"/", entry)
+				continue
+			}
+			// Only descend where keys can still match the prefix.
+			if strings.HasPrefix(key+"/", prefix) || strings.HasPrefix(prefix, key+"/") {
+				if err := walk(key + "/"); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(dir); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].key < items[j].key })
+	return items, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
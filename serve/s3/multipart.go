@@ -0,0 +1,232 @@
+package s3
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/internxt/rclone-adapter/internal/spool"
+)
+
+const maxPartNumber = 10000
+
+// upload is a multipart upload in progress. Its parts are staged in dir.
+type upload struct {
+	bucket string
+	key    string
+	dir    string
+
+	mu    sync.Mutex
+	parts map[int]part
+}
+
+type part struct {
+	etag string // quoted hex MD5 of the part
+	size int64
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type completeMultipartUpload struct {
+	Parts []struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	} `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+func (h *Handler) serveMultipart(w http.ResponseWriter, r *http.Request, bucket, key string, query url.Values) error {
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		return h.createMultipartUpload(w, r, bucket, key)
+	case r.Method == http.MethodPut && query.Has("partNumber"):
+		return h.uploadPart(w, r, bucket, key, query)
+	case r.Method == http.MethodPost:
+		return h.completeMultipartUpload(w, r, bucket, key, query.Get("uploadId"))
+	case r.Method == http.MethodDelete:
+		up, err := h.upload(bucket, key, query.Get("uploadId"))
+		if err != nil {
+			return err
+		}
+		h.removeUpload(query.Get("uploadId"), up)
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	default:
+		return errNotImplemented
+	}
+}
+
+func (h *Handler) createMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	if err := h.checkBucket(r.Context(), bucket); err != nil {
+		return err
+	}
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return fmt.Errorf("failed to generate upload ID: %w", err)
+	}
+	dir, err := os.MkdirTemp(h.opts.TempDir, "s3-upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create upload staging directory: %w", err)
+	}
+
+	uploadID := hex.EncodeToString(id[:])
+	h.mu.Lock()
+	h.uploads[uploadID] = &upload{bucket: bucket, key: key, dir: dir, parts: map[int]part{}}
+	h.mu.Unlock()
+	return writeXML(w, http.StatusOK, initiateMultipartUploadResult{Bucket: bucket, Key: key, UploadID: uploadID})
+}
+
+func (h *Handler) uploadPart(w http.ResponseWriter, r *http.Request, bucket, key string, query url.Values) error {
+	up, err := h.upload(bucket, key, query.Get("uploadId"))
+	if err != nil {
+		return err
+	}
+	number, err := strconv.Atoi(query.Get("partNumber"))
+	if err != nil || number < 1 || number > maxPartNumber {
+		return errInvalidArgument
+	}
+	body, size, err := requestBody(r)
+	if err != nil {
+		return err
+	}
+
+	// Parts may be uploaded concurrently and re-uploaded, so each is written
+	// to its own temporary file and renamed into place.
+	tmp, err := spool.Create(up.dir, "tmp-*", h.key)
+	if err != nil {
+		return fmt.Errorf("failed to stage part: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	sum := md5.New()
+	n, err := io.Copy(io.MultiWriter(tmp, sum), body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stage part: %w", err)
+	}
+	if size >= 0 && n != size {
+		return errIncompleteBody
+	}
+
+	etag := quote(hex.EncodeToString(sum.Sum(nil)))
+	up.mu.Lock()
+	defer up.mu.Unlock()
+	if err := os.Rename(tmp.Name(), up.partPath(number)); err != nil {
+		return fmt.Errorf("failed to stage part: %w", err)
+	}
+	up.parts[number] = part{etag: etag, size: n}
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (h *Handler) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key, uploadID string) error {
+	up, err := h.upload(bucket, key, uploadID)
+	if err != nil {
+		return err
+	}
+	var req completeMultipartUpload
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Parts) == 0 {
+		return errMalformedXML
+	}
+
+	up.mu.Lock()
+	defer up.mu.Unlock()
+	readers := make([]io.Reader, 0, len(req.Parts))
+	sums := md5.New()
+	var size int64
+	for i, p := range req.Parts {
+		if i > 0 && p.PartNumber <= req.Parts[i-1].PartNumber {
+			return errInvalidPartOrder
+		}
+		staged, ok := up.parts[p.PartNumber]
+		if !ok || strings.Trim(p.ETag, `"`) != strings.Trim(staged.etag, `"`) {
+			return errInvalidPart
+		}
+		f, err := spool.Open(up.partPath(p.PartNumber), h.key)
+		if err != nil {
+			return fmt.Errorf("failed to open staged part: %w", err)
+		}
+		defer f.Close()
+		readers = append(readers, f)
+		sum, _ := hex.DecodeString(strings.Trim(staged.etag, `"`))
+		sums.Write(sum)
+		size += staged.size
+	}
+
+	if err := h.checkBucket(r.Context(), bucket); err != nil {
+		return err
+	}
+	if _, err := h.fs.Put(r.Context(), bucket+"/"+key, io.MultiReader(readers...), size, h.now()); err != nil {
+		return err
+	}
+	h.removeUpload(uploadID, up)
+
+	etag := quote(fmt.Sprintf("%s-%d", hex.EncodeToString(sums.Sum(nil)), len(req.Parts)))
+	return writeXML(w, http.StatusOK, completeMultipartUploadResult{
+		Location: "/" + bucket + "/" + key,
+		Bucket:   bucket,
+		Key:      key,
+		ETag:     etag,
+	})
+}
+
+// upload returns the upload uploadID, which must be for bucket and key.
+func (h *Handler) upload(bucket, key, uploadID string) (*upload, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	up, ok := h.uploads[uploadID]
+	if !ok || up.bucket != bucket || up.key != key {
+		return nil, errNoSuchUpload
+	}
+	return up, nil
+}
+
+func (h *Handler) removeUpload(uploadID string, up *upload) {
+	h.mu.Lock()
+	delete(h.uploads, uploadID)
+	h.mu.Unlock()
+	if err := os.RemoveAll(up.dir); err != nil {
+		h.logger.Warn("failed to remove upload staging directory", "dir", up.dir, "error", err)
+	}
+}
+
+// Close aborts the multipart uploads in progress and removes their staged
+// parts.
+func (h *Handler) Close() error {
+	h.mu.Lock()
+	uploads := h.uploads
+	h.uploads = map[string]*upload{}
+	h.mu.Unlock()
+	for _, up := range uploads {
+		os.RemoveAll(up.dir)
+	}
+	return nil
+}
+
+func (u *upload) partPath(number int) string {
+	return filepath.Join(u.dir, fmt.Sprintf("part-%05d", number))
+}
@@ -0,0 +1,330 @@
+// Package s3 serves a drive folder through a subset of the Amazon S3 API, so
+// S3 tooling such as restic or Veeam can use Internxt through a local
+// gateway. The top-level directories of the Fs are the buckets and object
+// keys are slash-separated paths below them.
+//
+// Supported are ListBuckets, CreateBucket, HeadBucket, DeleteBucket,
+// GetBucketLocation, ListObjectsV2, GetObject and HeadObject with ranges,
+// PutObject, DeleteObject and multipart uploads. Requests must use
+// path-style addressing. Parts of multipart uploads are staged in local
+// files until the upload is completed.
+package s3
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/internxt/rclone-adapter/backend"
+	"github.com/internxt/rclone-adapter/errors"
+	"github.com/internxt/rclone-adapter/internal/spool"
+	"github.com/internxt/rclone-adapter/serve/internal/httprange"
+)
+
+const DefaultRegion = "us-east-1"
+
+// Options configures a Handler. Zero values select the defaults.
+type Options struct {
+	Credentials *Credentials // Keys clients must sign requests with; nil accepts unsigned requests
+	Region      string       // Region reported to and expected from clients (default DefaultRegion)
+	TempDir     string       // Directory staging multipart parts (default os.TempDir())
+	EncryptTemp bool         // Encrypt staged parts on disk with a key held only in memory
+}
+
+// Handler is an http.Handler serving an Fs over the S3 API.
+type Handler struct {
+	fs     *backend.Fs
+	opts   Options
+	key    *spool.Key // Encrypts staged parts, nil unless opts.EncryptTemp
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu      sync.Mutex
+	uploads map[string]*upload
+}
+
+// NewHandler returns a Handler serving fs. opts may be nil; a nil logger
+// discards request errors.
+func NewHandler(fs *backend.Fs, opts *Options, logger *slog.Logger) *Handler {
+	h := &Handler{fs: fs, logger: logger, now: time.Now, uploads: map[string]*upload{}}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Region == "" {
+		h.opts.Region = DefaultRegion
+	}
+	if h.opts.TempDir == "" {
+		h.opts.TempDir = os.TempDir()
+	}
+	if h.opts.EncryptTemp {
+		h.key = spool.NewKey()
+	}
+	if h.logger == nil {
+		h.logger = slog.New(slog.DiscardHandler)
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := h.authenticate(r); err != nil {
+		h.writeError(w, r, err)
+		return
+	}
+
+	bucket, key := splitPath(r.URL.Path)
+	query := r.URL.Query()
+	var err error
+	switch {
+	case bucket == "" && r.Method == http.MethodGet:
+		err = h.listBuckets(w, r)
+	case bucket == "":
+		err = errMethodNotAllowed
+	case !validKey(key):
+		err = errInvalidArgument
+	case key == "":
+		err = h.serveBucket(w, r, bucket, query)
+	case query.Has("uploadId") || query.Has("uploads"):
+		err = h.serveMultipart(w, r, bucket, key, query)
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		err = h.getObject(w, r, bucket, key)
+	case r.Method == http.MethodPut:
+		err = h.putObject(w, r, bucket, key)
+	case r.Method == http.MethodDelete:
+		err = h.deleteObject(w, r, bucket, key)
+	default:
+		err = errMethodNotAllowed
+	}
+	if err != nil {
+		h.writeError(w, r, err)
+	}
+}
+
+func (h *Handler) serveBucket(w http.ResponseWriter, r *http.Request, bucket string, query map[string][]string) error {
+	switch r.Method {
+	case http.MethodGet:
+		if _, ok := query["location"]; ok {
+			if err := h.checkBucket(r.Context(), bucket); err != nil {
+				return err
+			}
+			return writeXML(w, http.StatusOK, locationConstraint{Region: h.opts.Region})
+		}
+		if v := query["list-type"]; len(v) == 1 && v[0] == "2" {
+			return h.listObjectsV2(w, r, bucket)
+		}
+		return errNotImplemented
+	case http.MethodHead:
+		if err := h.checkBucket(r.Context(), bucket); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusOK)
+		return nil
+	case http.MethodPut:
+		if _, err := h.fs.Stat(r.Context(), bucket); err == nil {
+			return errBucketAlreadyOwnedByYou
+		}
+		if err := h.fs.Mkdir(r.Context(), bucket); err != nil {
+			return err
+		}
+		w.Header().Set("Location", "/"+bucket)
+		w.WriteHeader(http.StatusOK)
+		return nil
+	case http.MethodDelete:
+		if err := h.checkBucket(r.Context(), bucket); err != nil {
+			return err
+		}
+		if err := h.fs.Rmdir(r.Context(), bucket); err != nil {
+			if stderrors.Is(err, backend.ErrDirNotEmpty) {
+				return errBucketNotEmpty
+			}
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	default:
+		return errMethodNotAllowed
+	}
+}
+
+// checkBucket fails with NoSuchBucket unless bucket is a top-level directory.
+func (h *Handler) checkBucket(ctx context.Context, bucket string) error {
+	entry, err := h.fs.Stat(ctx, bucket)
+	if stderrors.Is(err, errors.ErrNotFound) {
+		return errNoSuchBucket
+	}
+	if err != nil {
+		return err
+	}
+	if _, ok := entry.(*backend.Directory); !ok {
+		return errNoSuchBucket
+	}
+	return nil
+}
+
+// object returns the file at key, with NoSuchKey for directories and
+// missing files.
+func (h *Handler) object(ctx context.Context, bucket, key string) (*backend.Object, error) {
+	if err := h.checkBucket(ctx, bucket); err != nil {
+		return nil, err
+	}
+	obj, err := h.fs.NewObject(ctx, bucket+"/"+key)
+	if stderrors.Is(err, errors.ErrNotFound) || stderrors.Is(err, backend.ErrIsDir) {
+		return nil, errNoSuchKey
+	}
+	return obj, err
+}
+
+func (h *Handler) getObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	obj, err := h.object(r.Context(), bucket, key)
+	if err != nil {
+		return err
+	}
+
+	size := obj.Size()
+	contentLength := size
+	var options []backend.OpenOption
+	status := http.StatusOK
+	if rng := r.Header.Get("Range"); rng != "" {
+		start, end, ok := httprange.Parse(rng, size)
+		if !ok {
+			return errInvalidRange
+		}
+		contentLength = end - start + 1
+		options = append(options, &backend.RangeOption{Start: start, End: end})
+		status = http.StatusPartialContent
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+	}
+
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Length", strconv.FormatInt(contentLength, 10))
+	w.Header().Set("Content-Type", contentType(key))
+	w.Header().Set("ETag", etag(obj))
+	w.Header().Set("Last-Modified", obj.ModTime().UTC().Format(http.TimeFormat))
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return nil
+	}
+
+	rc, err := obj.Open(r.Context(), options...)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	w.WriteHeader(status)
+	if _, err := io.Copy(w, rc); err != nil {
+		h.logger.Warn("s3 download interrupted", "bucket", bucket, "key", key, "error", err)
+	}
+	return nil
+}
+
+func (h *Handler) putObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	if r.Header.Get("X-Amz-Copy-Source") != "" {
+		return errNotImplemented
+	}
+	if err := h.checkBucket(r.Context(), bucket); err != nil {
+		return err
+	}
+	body, size, err := requestBody(r)
+	if err != nil {
+		return err
+	}
+
+	// A zero-length key ending in "/" is the usual directory marker.
+	if strings.HasSuffix(key, "/") {
+		if size != 0 {
+			return errInvalidArgument
+		}
+		if err := h.fs.Mkdir(r.Context(), bucket+"/"+key); err != nil {
+			return err
+		}
+		w.Header().Set("ETag", quote(hex.EncodeToString(md5.New().Sum(nil))))
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	sum := md5.New()
+	if _, err := h.fs.Put(r.Context(), bucket+"/"+key, io.TeeReader(body, sum), size, h.now()); err != nil {
+		return err
+	}
+	w.Header().Set("ETag", quote(hex.EncodeToString(sum.Sum(nil))))
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (h *Handler) deleteObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	obj, err := h.object(r.Context(), bucket, key)
+	switch {
+	case stderrors.Is(err, errNoSuchKey):
+		// Deleting a missing key succeeds in S3.
+	case err != nil:
+		return err
+	default:
+		if err := obj.Remove(r.Context()); err != nil && !stderrors.Is(err, errors.ErrNotFound) {
+			return err
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// splitPath splits a path-style URL path into bucket and key.
+func splitPath(urlPath string) (bucket, key string) {
+	bucket, key, _ = strings.Cut(strings.TrimPrefix(urlPath, "/"), "/")
+	return bucket, key
+}
+
+// validKey reports whether key maps to a path unambiguously: Drive paths
+// have no empty, "." or ".." components.
+func validKey(key string) bool {
+	if key == "" {
+		return true
+	}
+	for _, name := range strings.Split(strings.TrimSuffix(key, "/"), "/") {
+		if name == "" || name == "." || name == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// etag is the ETag reported for stored objects. Drive keeps no MD5 of the
+// plaintext, so it is derived from the file UUID, which changes whenever
+// the content is replaced.
+func etag(obj *backend.Object) string {
+	return quote(obj.UUID())
+}
+
+func quote(s string) string {
+	return `"` + s + `"`
+}
+
+func contentType(key string) string {
+	if t := mime.TypeByExtension(path.Ext(key)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+func writeXML(w http.ResponseWriter, status int, v any) error {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal response: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+	return nil
+}
@@ -0,0 +1,346 @@
+package s3
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/internxt/rclone-adapter/backend"
+	"github.com/internxt/rclone-adapter/internal/fakedrive"
+)
+
+// newTestServer serves an S3 handler over a fake drive whose root holds
+// the bucket "photos".
+func newTestServer(t *testing.T) (*httptest.Server, *fakedrive.Server) {
+	drive := fakedrive.New()
+	t.Cleanup(drive.Close)
+	drive.AddFolder(fakedrive.RootUUID, "photos")
+
+	h := NewHandler(backend.NewFs(drive.Config(), ""), &Options{TempDir: t.TempDir()}, nil)
+	t.Cleanup(func() { h.Close() })
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+	return server, drive
+}
+
+func do(t *testing.T, method, url string, body string, header map[string]string) (*http.Response, string) {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(method, url, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, string(data)
+}
+
+func errorCode(t *testing.T, body string) string {
+	var e errorResponse
+	if err := xml.Unmarshal([]byte(body), &e); err != nil {
+		t.Fatalf("invalid error body %q: %v", body, err)
+	}
+	return e.Code
+}
+
+func TestObjects(t *testing.T) {
+	server, drive := newTestServer(t)
+
+	resp, body := do(t, http.MethodPut, server.URL+"/photos/2024/cat.jpg", "0123456789", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("ETag") != `"781e5e245d69b566979b86e28d23f2c7"` {
+		t.Fatalf("put: unexpected %d %q %s", resp.StatusCode, resp.Header.Get("ETag"), body)
+	}
+	if uuid, isDir, ok := drive.Lookup("photos/2024/cat.jpg"); !ok || isDir {
+		t.Fatal("expected the object stored as a drive file")
+	} else if data, _ := drive.Content(uuid); string(data) != "0123456789" {
+		t.Fatalf("unexpected stored content %q", data)
+	}
+
+	resp, body = do(t, http.MethodGet, server.URL+"/photos/2024/cat.jpg", "", map[string]string{"Range": "bytes=2-5"})
+	if resp.StatusCode != http.StatusPartialContent || body != "2345" || resp.Header.Get("Content-Range") != "bytes 2-5/10" {
+		t.Errorf("ranged get: unexpected %d %q %q", resp.StatusCode, body, resp.Header.Get("Content-Range"))
+	}
+	resp, _ = do(t, http.MethodHead, server.URL+"/photos/2024/cat.jpg", "", nil)
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Length") != "10" || resp.Header.Get("Content-Type") != "image/jpeg" {
+		t.Errorf("head: unexpected %d %v", resp.StatusCode, resp.Header)
+	}
+	resp, body = do(t, http.MethodGet, server.URL+"/photos/2024/cat.jpg", "", map[string]string{"Range": "bytes=20-"})
+	if resp.StatusCode != http.StatusRequestedRangeNotSatisfiable || errorCode(t, body) != "InvalidRange" {
+		t.Errorf("unsatisfiable range: unexpected %d %s", resp.StatusCode, body)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		wantCode string
+	}{
+		{"missing key", http.MethodGet, "/photos/2024/dog.jpg", "NoSuchKey"},
+		{"directory key", http.MethodGet, "/photos/2024", "NoSuchKey"},
+		{"missing bucket", http.MethodGet, "/videos/a.mp4", "NoSuchBucket"},
+		{"dot segment", http.MethodPut, "/photos/a/../b", "InvalidArgument"},
+		{"copy", http.MethodPost, "/photos/a", "MethodNotAllowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, body := do(t, tt.method, server.URL+tt.path, "", nil)
+			if code := errorCode(t, body); code != tt.wantCode {
+				t.Errorf("expected %s, got %s", tt.wantCode, code)
+			}
+		})
+	}
+
+	resp, _ = do(t, http.MethodDelete, server.URL+"/photos/2024/cat.jpg", "", nil)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete: unexpected %d", resp.StatusCode)
+	}
+	if _, _, ok := drive.Lookup("photos/2024/cat.jpg"); ok {
+		t.Error("expected the object deleted")
+	}
+	resp, _ = do(t, http.MethodDelete, server.URL+"/photos/2024/cat.jpg", "", nil)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete of missing key: unexpected %d", resp.StatusCode)
+	}
+}
+
+func TestListObjectsV2(t *testing.T) {
+	server, drive := newTestServer(t)
+	photos, _, _ := drive.Lookup("photos")
+	y2024 := drive.AddFolder(photos, "2024")
+	drive.AddFolder(photos, "empty")
+	drive.AddFile(photos, "a.jpg", []byte("a"), time.Now())
+	drive.AddFile(photos, "b.jpg", []byte("bb"), time.Now())
+	drive.AddFile(y2024, "c.jpg", []byte("ccc"), time.Now())
+	drive.AddFile(y2024, "d.png", []byte("dddd"), time.Now())
+
+	list := func(t *testing.T, query string) listBucketResult {
+		resp, body := do(t, http.MethodGet, server.URL+"/photos?list-type=2"+query, "", nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("list: unexpected %d %s", resp.StatusCode, body)
+		}
+		var result listBucketResult
+		if err := xml.Unmarshal([]byte(body), &result); err != nil {
+			t.Fatalf("invalid list body: %v", err)
+		}
+		return result
+	}
+	summary := func(result listBucketResult) string {
+		var names []string
+		for _, p := range result.CommonPrefixes {
+			names = append(names, p.Prefix)
+		}
+		for _, c := range result.Contents {
+			names = append(names, c.Key)
+		}
+		return strings.Join(names, ",")
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"recursive", "", "2024/c.jpg,2024/d.png,a.jpg,b.jpg"},
+		{"delimiter", "&delimiter=/", "2024/,empty/,a.jpg,b.jpg"},
+		{"prefix directory", "&prefix=2024/&delimiter=/", "2024/c.jpg,2024/d.png"},
+		{"partial name", "&prefix=2024/c", "2024/c.jpg"},
+		{"partial directory", "&prefix=20&delimiter=/", "2024/"},
+		{"start after", "&start-after=2024/d.png", "a.jpg,b.jpg"},
+		{"missing prefix", "&prefix=nope/", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summary(list(t, tt.query)); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	var keys []string
+	query := "&max-keys=3"
+	for {
+		result := list(t, query)
+		keys = append(keys, summary(result))
+		if !result.IsTruncated {
+			break
+		}
+		query = "&max-keys=3&continuation-token=" + result.NextContinuationToken
+	}
+	if got := strings.Join(keys, "|"); got != "2024/c.jpg,2024/d.png,a.jpg|b.jpg" {
+		t.Errorf("unexpected pages %q", got)
+	}
+
+	result := list(t, "&prefix=2024/d")
+	if len(result.Contents) != 1 || result.Contents[0].Size != 4 || result.KeyCount != 1 {
+		t.Errorf("unexpected contents %+v", result)
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	resp, body := do(t, http.MethodPut, server.URL+"/backups", "", nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("create bucket: unexpected %d %s", resp.StatusCode, body)
+	}
+	if _, body := do(t, http.MethodPut, server.URL+"/backups", "", nil); errorCode(t, body) != "BucketAlreadyOwnedByYou" {
+		t.Errorf("expected BucketAlreadyOwnedByYou, got %s", body)
+	}
+	if resp, _ := do(t, http.MethodHead, server.URL+"/backups", "", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("head bucket: unexpected %d", resp.StatusCode)
+	}
+
+	_, body = do(t, http.MethodGet, server.URL+"/", "", nil)
+	var result listAllMyBucketsResult
+	if err := xml.Unmarshal([]byte(body), &result); err != nil {
+		t.Fatalf("invalid body: %v", err)
+	}
+	if len(result.Buckets) != 2 {
+		t.Errorf("expected 2 buckets, got %+v", result.Buckets)
+	}
+
+	do(t, http.MethodPut, server.URL+"/backups/x", "x", nil)
+	if _, body := do(t, http.MethodDelete, server.URL+"/backups", "", nil); errorCode(t, body) != "BucketNotEmpty" {
+		t.Errorf("expected BucketNotEmpty, got %s", body)
+	}
+	do(t, http.MethodDelete, server.URL+"/backups/x", "", nil)
+	if resp, _ := do(t, http.MethodDelete, server.URL+"/backups", "", nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("delete bucket: unexpected %d", resp.StatusCode)
+	}
+	if resp, _ := do(t, http.MethodHead, server.URL+"/backups", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("head deleted bucket: unexpected %d", resp.StatusCode)
+	}
+}
+
+func TestMultipartUpload(t *testing.T) {
+	server, drive := newTestServer(t)
+	url := server.URL + "/photos/big.bin"
+
+	_, body := do(t, http.MethodPost, url+"?uploads", "", nil)
+	var initiated initiateMultipartUploadResult
+	if err := xml.Unmarshal([]byte(body), &initiated); err != nil || initiated.UploadID == "" {
+		t.Fatalf("invalid initiate response %q: %v", body, err)
+	}
+	id := initiated.UploadID
+
+	parts := []string{strings.Repeat("a", 100), strings.Repeat("b", 50), "c"}
+	etags := make([]string, len(parts))
+	// Upload out of order, re-uploading part 2, as concurrent clients do.
+	for _, i := range []int{2, 0, 1, 1} {
+		resp, body := do(t, http.MethodPut, url+"?partNumber="+string(rune('1'+i))+"&uploadId="+id, parts[i], nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("upload part: unexpected %d %s", resp.StatusCode, body)
+		}
+		etags[i] = resp.Header.Get("ETag")
+	}
+
+	complete := func(parts string) (*http.Response, string) {
+		return do(t, http.MethodPost, url+"?uploadId="+id, "<CompleteMultipartUpload>"+parts+"</CompleteMultipartUpload>", nil)
+	}
+	part := func(n int, etag string) string {
+		return "<Part><PartNumber>" + string(rune('0'+n)) + "</PartNumber><ETag>" + etag + "</ETag></Part>"
+	}
+	if _, body := complete(part(2, etags[1]) + part(1, etags[0])); errorCode(t, body) != "InvalidPartOrder" {
+		t.Errorf("expected InvalidPartOrder, got %s", body)
+	}
+	if _, body := complete(part(1, etags[1])); errorCode(t, body) != "InvalidPart" {
+		t.Errorf("expected InvalidPart, got %s", body)
+	}
+
+	resp, body := complete(part(1, etags[0]) + part(2, etags[1]) + part(3, etags[2]))
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("complete: unexpected %d %s", resp.StatusCode, body)
+	}
+	var result completeMultipartUploadResult
+	if err := xml.Unmarshal([]byte(body), &result); err != nil || !strings.HasSuffix(result.ETag, `-3"`) {
+		t.Errorf("unexpected complete result %+v: %v", result, err)
+	}
+	uuid, _, _ := drive.Lookup("photos/big.bin")
+	if data, _ := drive.Content(uuid); string(data) != strings.Join(parts, "") {
+		t.Errorf("unexpected stored content %q", data)
+	}
+	if _, body := complete(part(1, etags[0])); errorCode(t, body) != "NoSuchUpload" {
+		t.Errorf("expected NoSuchUpload after completion, got %s", body)
+	}
+
+	_, body = do(t, http.MethodPost, url+"?uploads", "", nil)
+	xml.Unmarshal([]byte(body), &initiated)
+	if resp, _ := do(t, http.MethodDelete, url+"?uploadId="+initiated.UploadID, "", nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("abort: unexpected %d", resp.StatusCode)
+	}
+	if _, body := do(t, http.MethodPut, url+"?partNumber=1&uploadId="+initiated.UploadID, "x", nil); errorCode(t, body) != "NoSuchUpload" {
+		t.Errorf("expected NoSuchUpload after abort, got %s", body)
+	}
+}
+
+func TestEncryptedStaging(t *testing.T) {
+	drive := fakedrive.New()
+	t.Cleanup(drive.Close)
+	drive.AddFolder(fakedrive.RootUUID, "photos")
+	tmp := t.TempDir()
+	h := NewHandler(backend.NewFs(drive.Config(), ""), &Options{TempDir: tmp, EncryptTemp: true}, nil)
+	t.Cleanup(func() { h.Close() })
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+	url := server.URL + "/photos/secret.bin"
+
+	_, body := do(t, http.MethodPost, url+"?uploads", "", nil)
+	var initiated initiateMultipartUploadResult
+	xml.Unmarshal([]byte(body), &initiated)
+	resp, _ := do(t, http.MethodPut, url+"?partNumber=1&uploadId="+initiated.UploadID, "secret part", nil)
+	staged, _ := filepath.Glob(filepath.Join(tmp, "s3-upload-*", "*"))
+	for _, name := range staged {
+		if raw, _ := os.ReadFile(name); strings.Contains(string(raw), "secret") {
+			t.Errorf("expected %s to be encrypted, got %q", name, raw)
+		}
+	}
+	if len(staged) != 1 {
+		t.Errorf("expected one staged part, got %v", staged)
+	}
+
+	part := "<Part><PartNumber>1</PartNumber><ETag>" + resp.Header.Get("ETag") + "</ETag></Part>"
+	if resp, body := do(t, http.MethodPost, url+"?uploadId="+initiated.UploadID, "<CompleteMultipartUpload>"+part+"</CompleteMultipartUpload>", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("complete: unexpected %d %s", resp.StatusCode, body)
+	}
+	uuid, _, _ := drive.Lookup("photos/secret.bin")
+	if data, _ := drive.Content(uuid); string(data) != "secret part" {
+		t.Errorf("unexpected stored content %q", data)
+	}
+}
+
+func TestChunkedBody(t *testing.T) {
+	server, drive := newTestServer(t)
+	body := "5;chunk-signature=abc\r\nhello\r\n6;chunk-signature=def\r\n world\r\n0;chunk-signature=ghi\r\nx-amz-checksum-crc32:AAAA\r\n\r\n"
+	resp, respBody := do(t, http.MethodPut, server.URL+"/photos/chunked.txt", body, map[string]string{
+		"X-Amz-Content-Sha256":         "STREAMING-AWS4-HMAC-SHA256-PAYLOAD",
+		"X-Amz-Decoded-Content-Length": "11",
+	})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("put: unexpected %d %s", resp.StatusCode, respBody)
+	}
+	uuid, _, _ := drive.Lookup("photos/chunked.txt")
+	if data, _ := drive.Content(uuid); !bytes.Equal(data, []byte("hello world")) {
+		t.Errorf("unexpected stored content %q", data)
+	}
+
+	r := &chunkedReader{r: bufioReader("5\r\nhel")}
+	if _, err := io.ReadAll(r); err == nil {
+		t.Error("expected an error for a truncated body")
+	}
+}
@@ -0,0 +1,163 @@
+// Package sftp serves the drive over SFTP (protocol version 3) on an
+// embedded SSH server. Users log in either with their Internxt email and
+// password, or with an SSH key mapped to an already logged-in account.
+// Files are read and written through the vfs package: downloads go through
+// its read-ahead stream and writes are buffered locally and uploaded when
+// the file is closed.
+package sftp
+
+import (
+	"bytes"
+	"context"
+	stderrors "errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/internxt/rclone-adapter/auth"
+	"github.com/internxt/rclone-adapter/backend"
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/vfs"
+)
+
+// AuthorizedKey lets the holder of Key log in as User to the account of
+// Config.
+type AuthorizedKey struct {
+	User   string
+	Key    ssh.PublicKey
+	Config *config.Config
+}
+
+// Options configures a Server.
+type Options struct {
+	HostKey        ssh.Signer      // Required server host key
+	PasswordConfig *config.Config  // Base config for logins with Internxt email and password; nil disables password logins
+	AuthorizedKeys []AuthorizedKey // Keys accepted for public key logins
+	VFS            *vfs.Options    // Options of each session's VFS; nil selects the defaults
+}
+
+// Server accepts SSH connections and serves the sftp subsystem.
+type Server struct {
+	opts   Options
+	logger *slog.Logger
+	login  func(ctx context.Context, cfg *config.Config, email, password string) (*config.Config, error)
+}
+
+// NewServer returns a Server. At least one login method must be enabled.
+// A nil logger discards connection errors.
+func NewServer(opts Options, logger *slog.Logger) (*Server, error) {
+	if opts.HostKey == nil {
+		return nil, stderrors.New("sftp: host key is required")
+	}
+	if opts.PasswordConfig == nil && len(opts.AuthorizedKeys) == 0 {
+		return nil, stderrors.New("sftp: no login method enabled")
+	}
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Server{opts: opts, logger: logger, login: auth.LoginWithPassword}, nil
+}
+
+// Serve accepts connections on l until it is closed, serving each in its
+// own goroutine.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			if err := s.ServeConn(conn); err != nil {
+				s.logger.Warn("sftp connection failed", "remote", conn.RemoteAddr().String(), "error", err)
+			}
+		}()
+	}
+}
+
+// ServeConn performs the SSH handshake on conn and serves its sessions
+// until the client disconnects.
+func (s *Server) ServeConn(conn net.Conn) error {
+	defer conn.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Logins are resolved per connection; the permissions of the method
+	// that succeeded tell which one to use.
+	var passwordLogin *config.Config
+	sshConfig := &ssh.ServerConfig{}
+	sshConfig.AddHostKey(s.opts.HostKey)
+	if s.opts.PasswordConfig != nil {
+		sshConfig.PasswordCallback = func(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			cfg, err := s.login(ctx, s.opts.PasswordConfig, meta.User(), string(password))
+			if err != nil {
+				return nil, fmt.Errorf("failed to log in %q: %w", meta.User(), err)
+			}
+			passwordLogin = cfg
+			return &ssh.Permissions{Extensions: map[string]string{"login": "password"}}, nil
+		}
+	}
+	if len(s.opts.AuthorizedKeys) > 0 {
+		sshConfig.PublicKeyCallback = func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			for i, ak := range s.opts.AuthorizedKeys {
+				if ak.User == meta.User() && bytes.Equal(ak.Key.Marshal(), key.Marshal()) {
+					return &ssh.Permissions{Extensions: map[string]string{"login": "key", "key": strconv.Itoa(i)}}, nil
+				}
+			}
+			return nil, fmt.Errorf("unknown public key for %q", meta.User())
+		}
+	}
+
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, sshConfig)
+	if err != nil {
+		return fmt.Errorf("failed to perform ssh handshake: %w", err)
+	}
+	defer sshConn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	cfg := passwordLogin
+	if ext := sshConn.Permissions.Extensions; ext["login"] == "key" {
+		i, _ := strconv.Atoi(ext["key"])
+		cfg = s.opts.AuthorizedKeys[i].Config
+	}
+	fs := vfs.New(backend.NewFs(cfg, ""), s.opts.VFS)
+	s.logger.Info("sftp login", "user", sshConn.User(), "remote", conn.RemoteAddr().String())
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return fmt.Errorf("failed to accept channel: %w", err)
+		}
+		go s.serveSession(ctx, channel, requests, fs)
+	}
+	return nil
+}
+
+// serveSession waits for the sftp subsystem request on a session channel
+// and runs the protocol. Shells and commands are refused.
+func (s *Server) serveSession(ctx context.Context, channel ssh.Channel, requests <-chan *ssh.Request, fs *vfs.VFS) {
+	defer channel.Close()
+	for req := range requests {
+		if req.Type != "subsystem" || len(req.Payload) < 4 || string(req.Payload[4:]) != "sftp" {
+			req.Reply(false, nil)
+			continue
+		}
+		req.Reply(true, nil)
+		go ssh.DiscardRequests(requests)
+
+		err := serveSFTP(ctx, channel, fs, s.logger)
+		status := uint32(0)
+		if err != nil {
+			s.logger.Warn("sftp session failed", "error", err)
+			status = 1
+		}
+		channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+		return
+	}
+}
@@ -0,0 +1,123 @@
+package sftp
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/internal/fakedrive"
+)
+
+func newSigner(t *testing.T) ssh.Signer {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func TestNewServer(t *testing.T) {
+	if _, err := NewServer(Options{}, nil); err == nil {
+		t.Error("expected an error without host key")
+	}
+	if _, err := NewServer(Options{HostKey: newSigner(t)}, nil); err == nil {
+		t.Error("expected an error without login method")
+	}
+}
+
+func TestServerLogin(t *testing.T) {
+	drive := fakedrive.New()
+	defer drive.Close()
+	userKey, otherKey := newSigner(t), newSigner(t)
+
+	server, err := NewServer(Options{
+		HostKey:        newSigner(t),
+		PasswordConfig: &config.Config{},
+		AuthorizedKeys: []AuthorizedKey{{User: "backup", Key: userKey.PublicKey(), Config: drive.Config()}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.login = func(ctx context.Context, cfg *config.Config, email, password string) (*config.Config, error) {
+		if email != "user@example.com" || password != "secret" {
+			return nil, context.Canceled
+		}
+		return drive.Config(), nil
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go server.Serve(l)
+
+	tests := []struct {
+		name    string
+		user    string
+		auth    ssh.AuthMethod
+		wantErr bool
+	}{
+		{"password", "user@example.com", ssh.Password("secret"), false},
+		{"wrong password", "user@example.com", ssh.Password("nope"), true},
+		{"key", "backup", ssh.PublicKeys(userKey), false},
+		{"unknown key", "backup", ssh.PublicKeys(otherKey), true},
+		{"key for other user", "user@example.com", ssh.PublicKeys(userKey), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := ssh.Dial("tcp", l.Addr().String(), &ssh.ClientConfig{
+				User:            tt.user,
+				Auth:            []ssh.AuthMethod{tt.auth},
+				HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+			})
+			if tt.wantErr {
+				if err == nil {
+					client.Close()
+					t.Fatal("expected login to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer client.Close()
+
+			session, err := client.NewSession()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer session.Close()
+			if err := session.Run("ls"); err == nil {
+				t.Error("expected commands to be refused")
+			}
+
+			session, err = client.NewSession()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer session.Close()
+			w, _ := session.StdinPipe()
+			r, _ := session.StdoutPipe()
+			if err := session.RequestSubsystem("sftp"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			c := startSession(t, pipeConn{r, w})
+			if code := c.pathOp(fxpMkdir, "/"+tt.name); code != fxOK {
+				t.Fatalf("mkdir: unexpected status %d", code)
+			}
+			if _, isDir, ok := drive.Lookup(tt.name); !ok || !isDir {
+				t.Error("expected the directory created in the account")
+			}
+		})
+	}
+}
@@ -0,0 +1,409 @@
+package sftp
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/internxt/rclone-adapter/errors"
+	"github.com/internxt/rclone-adapter/vfs"
+)
+
+const (
+	maxReadLength  = 256 * 1024
+	readDirBatch   = 100
+	posixRenameExt = "posix-rename@openssh.com"
+)
+
+var errUnsupported = stderrors.New("sftp: operation unsupported")
+
+// session serves the SFTP protocol for one subsystem channel. Requests are
+// handled in order, so writes pipelined by the client arrive sequentially.
+type session struct {
+	ctx    context.Context
+	vfs    *vfs.VFS
+	rw     io.ReadWriter
+	logger *slog.Logger
+
+	handles    map[string]any // *vfs.Handle or *dirHandle
+	nextHandle int
+}
+
+// dirHandle is an open directory, read in batches by READDIR.
+type dirHandle struct {
+	attrs []vfs.Attr
+}
+
+// serveSFTP runs the protocol on rw until the client disconnects.
+func serveSFTP(ctx context.Context, rw io.ReadWriter, v *vfs.VFS, logger *slog.Logger) error {
+	s := &session{ctx: ctx, vfs: v, rw: rw, logger: logger, handles: map[string]any{}}
+	defer s.closeAll()
+
+	typ, _, err := readPacket(rw)
+	if err != nil {
+		return err
+	}
+	if typ != fxpInit {
+		return fmt.Errorf("sftp: expected init packet, got type %d", typ)
+	}
+	version := newPacket(fxpVersion).uint32(3).string(posixRenameExt).string("1")
+	if _, err := rw.Write(version.packet()); err != nil {
+		return err
+	}
+
+	for {
+		typ, data, err := readPacket(rw)
+		if stderrors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := rw.Write(s.handle(typ, data)); err != nil {
+			return err
+		}
+	}
+}
+
+// handle serves one request and returns the response packet.
+func (s *session) handle(typ byte, data []byte) []byte {
+	d := &decoder{data: data}
+	id := d.uint32()
+	if d.err != nil {
+		return s.status(id, errShortPacket)
+	}
+
+	var resp []byte
+	var err error
+	switch typ {
+	case fxpOpen:
+		p, pflags := d.string(), d.uint32()
+		d.attrs()
+		if d.err == nil {
+			resp, err = s.open(id, p, pflags)
+		}
+	case fxpClose:
+		err = s.close(d.string())
+	case fxpRead:
+		h, off, n := d.string(), d.uint64(), d.uint32()
+		if d.err == nil {
+			resp, err = s.read(id, h, int64(off), n)
+		}
+	case fxpWrite:
+		h, off, p := d.string(), d.uint64(), d.bytes()
+		if d.err == nil {
+			err = s.write(h, int64(off), p)
+		}
+	case fxpStat, fxpLstat:
+		p := d.string()
+		if d.err == nil {
+			resp, err = s.stat(id, p)
+		}
+	case fxpFstat:
+		h := d.string()
+		if d.err == nil {
+			resp, err = s.fstat(id, h)
+		}
+	case fxpSetstat:
+		p := d.string()
+		size, hasSize := d.attrs()
+		if d.err == nil && hasSize {
+			err = s.truncate(p, size)
+		}
+	case fxpFsetstat:
+		h := d.string()
+		size, hasSize := d.attrs()
+		if d.err == nil && hasSize {
+			var f *vfs.Handle
+			if f, err = s.file(h); err == nil {
+				err = f.Truncate(size)
+			}
+		}
+	case fxpOpendir:
+		p := d.string()
+		if d.err == nil {
+			resp, err = s.opendir(id, p)
+		}
+	case fxpReaddir:
+		h := d.string()
+		if d.err == nil {
+			resp, err = s.readdir(id, h)
+		}
+	case fxpRemove:
+		p := d.string()
+		if d.err == nil {
+			err = s.remove(p, false)
+		}
+	case fxpRmdir:
+		p := d.string()
+		if d.err == nil {
+			err = s.remove(p, true)
+		}
+	case fxpMkdir:
+		p := d.string()
+		d.attrs()
+		if d.err == nil {
+			err = s.vfs.Mkdir(s.ctx, p)
+		}
+	case fxpRealpath:
+		p := d.string()
+		if d.err == nil {
+			name := path.Clean("/" + p)
+			resp = newPacket(fxpName).uint32(id).uint32(1).string(name).string(name).uint32(0).packet()
+		}
+	case fxpRename:
+		oldPath, newPath := d.string(), d.string()
+		if d.err == nil {
+			// SFTP v3 rename must not replace an existing target.
+			if _, serr := s.vfs.Stat(s.ctx, newPath); serr == nil {
+				err = fmt.Errorf("failed to rename %q: %w", oldPath, os.ErrExist)
+			} else {
+				err = s.vfs.Rename(s.ctx, oldPath, newPath)
+			}
+		}
+	case fxpExtended:
+		if name := d.string(); name == posixRenameExt {
+			oldPath, newPath := d.string(), d.string()
+			if d.err == nil {
+				err = s.vfs.Rename(s.ctx, oldPath, newPath)
+			}
+		} else {
+			err = fmt.Errorf("extension %q: %w", name, errUnsupported)
+		}
+	default:
+		err = errUnsupported
+	}
+
+	if d.err != nil {
+		return s.status(id, d.err)
+	}
+	if err != nil || resp == nil {
+		return s.status(id, err)
+	}
+	return resp
+}
+
+func (s *session) open(id uint32, p string, pflags uint32) ([]byte, error) {
+	var flag int
+	switch {
+	case pflags&fxfRead != 0 && pflags&fxfWrite != 0:
+		flag = os.O_RDWR
+	case pflags&fxfWrite != 0:
+		flag = os.O_WRONLY
+	}
+	if pflags&fxfAppend != 0 {
+		flag |= os.O_APPEND
+	}
+	if pflags&fxfCreat != 0 {
+		flag |= os.O_CREATE
+	}
+	if pflags&fxfTrunc != 0 {
+		flag |= os.O_TRUNC
+	}
+	if pflags&fxfExcl != 0 {
+		flag |= os.O_EXCL
+	}
+
+	f, err := s.vfs.Open(s.ctx, p, flag)
+	if err != nil {
+		return nil, err
+	}
+	return s.newHandle(id, f), nil
+}
+
+func (s *session) close(h string) error {
+	handle, ok := s.handles[h]
+	if !ok {
+		return os.ErrInvalid
+	}
+	if f, ok := handle.(*vfs.Handle); ok {
+		// A failed upload keeps the handle, so the client can close it
+		// again; closeAll gives up on it when the session ends.
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	delete(s.handles, h)
+	return nil
+}
+
+func (s *session) read(id uint32, h string, off int64, n uint32) ([]byte, error) {
+	f, err := s.file(h)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, min(n, maxReadLength))
+	read, err := f.ReadAt(buf, off)
+	if read == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return nil, err
+	}
+	return newPacket(fxpData).uint32(id).bytes(buf[:read]).packet(), nil
+}
+
+func (s *session) write(h string, off int64, p []byte) error {
+	f, err := s.file(h)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteAt(p, off)
+	return err
+}
+
+func (s *session) stat(id uint32, p string) ([]byte, error) {
+	attr, err := s.vfs.Stat(s.ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return encodeAttrs(newPacket(fxpAttrs).uint32(id), attr).packet(), nil
+}
+
+func (s *session) fstat(id uint32, h string) ([]byte, error) {
+	f, err := s.file(h)
+	if err != nil {
+		return nil, err
+	}
+	// A file created through the handle exists remotely only once flushed.
+	attr, err := s.vfs.Stat(s.ctx, f.Name())
+	if err != nil {
+		attr = vfs.Attr{Name: path.Base(f.Name()), ModTime: time.Now()}
+	}
+	attr.Size = f.Size()
+	return encodeAttrs(newPacket(fxpAttrs).uint32(id), attr).packet(), nil
+}
+
+func (s *session) truncate(p string, size int64) error {
+	f, err := s.vfs.Open(s.ctx, p, os.O_WRONLY)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Discard()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		f.Discard()
+		return err
+	}
+	return nil
+}
+
+func (s *session) opendir(id uint32, p string) ([]byte, error) {
+	attrs, err := s.vfs.ReadDir(s.ctx, p)
+	if err != nil {
+		return nil, err
+	}
+	return s.newHandle(id, &dirHandle{attrs: attrs}), nil
+}
+
+func (s *session) readdir(id uint32, h string) ([]byte, error) {
+	dir, ok := s.handles[h].(*dirHandle)
+	if !ok {
+		return nil, os.ErrInvalid
+	}
+	if len(dir.attrs) == 0 {
+		return nil, io.EOF
+	}
+	batch := dir.attrs[:min(len(dir.attrs), readDirBatch)]
+	dir.attrs = dir.attrs[len(batch):]
+
+	e := newPacket(fxpName).uint32(id).uint32(uint32(len(batch)))
+	for _, attr := range batch {
+		e.string(attr.Name).string(longName(attr))
+		encodeAttrs(e, attr)
+	}
+	return e.packet(), nil
+}
+
+// remove deletes the file, or with dir set the empty directory, at p.
+func (s *session) remove(p string, dir bool) error {
+	attr, err := s.vfs.Stat(s.ctx, p)
+	if err != nil {
+		return err
+	}
+	if attr.IsDir != dir {
+		return fmt.Errorf("failed to remove %q: %w", p, os.ErrInvalid)
+	}
+	return s.vfs.Remove(s.ctx, p)
+}
+
+func (s *session) file(h string) (*vfs.Handle, error) {
+	f, ok := s.handles[h].(*vfs.Handle)
+	if !ok {
+		return nil, os.ErrInvalid
+	}
+	return f, nil
+}
+
+func (s *session) newHandle(id uint32, handle any) []byte {
+	s.nextHandle++
+	h := strconv.Itoa(s.nextHandle)
+	s.handles[h] = handle
+	return newPacket(fxpHandle).uint32(id).string(h).packet()
+}
+
+// closeAll closes the handles left open by the client, uploading pending
+// writes.
+func (s *session) closeAll() {
+	for h, handle := range s.handles {
+		if f, ok := handle.(*vfs.Handle); ok {
+			if err := f.Close(); err != nil {
+				s.logger.Warn("failed to close sftp file", "path", f.Name(), "error", err)
+				f.Discard()
+			}
+		}
+		delete(s.handles, h)
+	}
+}
+
+// status returns a STATUS response for err.
+func (s *session) status(id uint32, err error) []byte {
+	code, msg := uint32(fxOK), "OK"
+	switch {
+	case err == nil:
+	case stderrors.Is(err, io.EOF):
+		code, msg = fxEOF, "EOF"
+	case stderrors.Is(err, errShortPacket):
+		code, msg = fxBadMessage, err.Error()
+	case stderrors.Is(err, errUnsupported):
+		code, msg = fxOpUnsupported, err.Error()
+	case stderrors.Is(err, errors.ErrNotFound), stderrors.Is(err, os.ErrNotExist):
+		code, msg = fxNoSuchFile, err.Error()
+	case stderrors.Is(err, os.ErrPermission), stderrors.Is(err, errors.ErrUnauthorized):
+		code, msg = fxPermissionDenied, err.Error()
+	default:
+		code, msg = fxFailure, err.Error()
+		s.logger.Warn("sftp request failed", "error", err)
+	}
+	return newPacket(fxpStatus).uint32(id).uint32(code).string(msg).string("").packet()
+}
+
+func encodeAttrs(e *encoder, attr vfs.Attr) *encoder {
+	perm := uint32(0o100644)
+	if attr.IsDir {
+		perm = 0o40755
+	}
+	mtime := uint32(attr.ModTime.Unix())
+	return e.uint32(attrSize | attrPermissions | attrACModTime).
+		uint64(uint64(attr.Size)).
+		uint32(perm).
+		uint32(mtime).
+		uint32(mtime)
+}
+
+// longName formats attr the way "ls -l" does, which clients display as is.
+func longName(attr vfs.Attr) string {
+	mode := "-rw-r--r--"
+	if attr.IsDir {
+		mode = "drwxr-xr-x"
+	}
+	return fmt.Sprintf("%s 1 internxt internxt %12d %s %s", mode, attr.Size, attr.ModTime.Format("Jan _2 15:04"), attr.Name)
+}
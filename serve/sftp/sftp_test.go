@@ -0,0 +1,260 @@
+package sftp
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/internxt/rclone-adapter/backend"
+	"github.com/internxt/rclone-adapter/internal/fakedrive"
+	"github.com/internxt/rclone-adapter/vfs"
+)
+
+// testClient speaks SFTP to a session, one request at a time.
+type testClient struct {
+	t  *testing.T
+	w  io.Writer
+	r  io.Reader
+	id uint32
+}
+
+// call sends a request built by fill and returns the response type and a
+// decoder positioned after the request ID.
+func (c *testClient) call(typ byte, fill func(e *encoder)) (byte, *decoder) {
+	c.t.Helper()
+	c.id++
+	e := newPacket(typ).uint32(c.id)
+	if fill != nil {
+		fill(e)
+	}
+	if _, err := c.w.Write(e.packet()); err != nil {
+		c.t.Fatalf("failed to send request: %v", err)
+	}
+	respType, data, err := readPacket(c.r)
+	if err != nil {
+		c.t.Fatalf("failed to read response: %v", err)
+	}
+	d := &decoder{data: data}
+	if id := d.uint32(); id != c.id {
+		c.t.Fatalf("expected response to %d, got %d", c.id, id)
+	}
+	return respType, d
+}
+
+// status expects a STATUS response and returns its code.
+func (c *testClient) status(typ byte, d *decoder) uint32 {
+	c.t.Helper()
+	if typ != fxpStatus {
+		c.t.Fatalf("expected status, got type %d", typ)
+	}
+	return d.uint32()
+}
+
+func (c *testClient) handle(typ byte, d *decoder) string {
+	c.t.Helper()
+	if typ != fxpHandle {
+		c.t.Fatalf("expected handle, got type %d (code %d)", typ, d.uint32())
+	}
+	return d.string()
+}
+
+func (c *testClient) open(p string, pflags uint32) string {
+	c.t.Helper()
+	return c.handle(c.call(fxpOpen, func(e *encoder) { e.string(p).uint32(pflags).uint32(0) }))
+}
+
+func (c *testClient) close(h string) uint32 {
+	c.t.Helper()
+	return c.status(c.call(fxpClose, func(e *encoder) { e.string(h) }))
+}
+
+func (c *testClient) pathOp(typ byte, p string) uint32 {
+	c.t.Helper()
+	return c.status(c.call(typ, func(e *encoder) {
+		e.string(p)
+		if typ == fxpMkdir {
+			e.uint32(0)
+		}
+	}))
+}
+
+// startSession runs an SFTP session over pipes and completes the version
+// handshake.
+func startSession(t *testing.T, conn io.ReadWriter) *testClient {
+	t.Helper()
+	c := &testClient{t: t, w: conn, r: conn}
+	if _, err := conn.Write(newPacket(fxpInit).uint32(3).packet()); err != nil {
+		t.Fatal(err)
+	}
+	typ, data, err := readPacket(conn)
+	if err != nil || typ != fxpVersion {
+		t.Fatalf("expected version, got %d: %v", typ, err)
+	}
+	d := &decoder{data: data}
+	if v := d.uint32(); v != 3 {
+		t.Fatalf("expected version 3, got %d", v)
+	}
+	return c
+}
+
+type pipeConn struct {
+	io.Reader
+	io.Writer
+}
+
+func newTestSession(t *testing.T) (*testClient, *fakedrive.Server) {
+	drive := fakedrive.New()
+	t.Cleanup(drive.Close)
+	v := vfs.New(backend.NewFs(drive.Config(), ""), &vfs.Options{CacheDir: t.TempDir()})
+
+	cr, cw := io.Pipe()
+	sr, sw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- serveSFTP(context.Background(), pipeConn{cr, sw}, v, slog.New(slog.DiscardHandler))
+		sw.Close()
+	}()
+	t.Cleanup(func() {
+		cw.Close()
+		if err := <-done; err != nil {
+			t.Errorf("session failed: %v", err)
+		}
+	})
+	return startSession(t, pipeConn{sr, cw}), drive
+}
+
+func TestReadWrite(t *testing.T) {
+	c, drive := newTestSession(t)
+	if code := c.pathOp(fxpMkdir, "/docs"); code != fxOK {
+		t.Fatalf("mkdir: unexpected status %d", code)
+	}
+
+	h := c.open("/docs/a.txt", fxfWrite|fxfCreat|fxfTrunc)
+	for _, chunk := range []struct {
+		off  uint64
+		data string
+	}{{0, "hello "}, {6, "world"}} {
+		code := c.status(c.call(fxpWrite, func(e *encoder) { e.string(h).uint64(chunk.off).string(chunk.data) }))
+		if code != fxOK {
+			t.Fatalf("write: unexpected status %d", code)
+		}
+	}
+	typ, d := c.call(fxpFstat, func(e *encoder) { e.string(h) })
+	if typ != fxpAttrs {
+		t.Fatalf("fstat: unexpected type %d", typ)
+	}
+	if size, _ := d.attrs(); size != 11 {
+		t.Errorf("fstat: expected size 11, got %d", size)
+	}
+	if code := c.close(h); code != fxOK {
+		t.Fatalf("close: unexpected status %d", code)
+	}
+	uuid, _, ok := drive.Lookup("docs/a.txt")
+	if data, _ := drive.Content(uuid); !ok || string(data) != "hello world" {
+		t.Fatalf("expected uploaded content, got %q", data)
+	}
+
+	h = c.open("/docs/a.txt", fxfRead)
+	typ, d = c.call(fxpRead, func(e *encoder) { e.string(h).uint64(6).uint32(100) })
+	if typ != fxpData || string(d.bytes()) != "world" {
+		t.Errorf("read: unexpected response type %d", typ)
+	}
+	if code := c.status(c.call(fxpRead, func(e *encoder) { e.string(h).uint64(11).uint32(100) })); code != fxEOF {
+		t.Errorf("read at end: expected EOF, got %d", code)
+	}
+	if code := c.status(c.call(fxpWrite, func(e *encoder) { e.string(h).uint64(0).string("x") })); code != fxPermissionDenied {
+		t.Errorf("write to read-only handle: expected permission denied, got %d", code)
+	}
+	c.close(h)
+
+	if typ, _ := c.call(fxpOpen, func(e *encoder) { e.string("/docs/a.txt").uint32(fxfWrite | fxfCreat | fxfExcl).uint32(0) }); typ != fxpStatus {
+		t.Errorf("exclusive create of existing file: expected failure, got type %d", typ)
+	}
+	if code := c.status(c.call(fxpOpen, func(e *encoder) { e.string("/missing.txt").uint32(fxfRead).uint32(0) })); code != fxNoSuchFile {
+		t.Errorf("open missing: expected no such file, got %d", code)
+	}
+}
+
+func TestDirectories(t *testing.T) {
+	c, drive := newTestSession(t)
+	docs := drive.AddFolder(fakedrive.RootUUID, "docs")
+	for i := range readDirBatch + 5 {
+		drive.AddFile(docs, "f"+string(rune('a'+i%26))+string(rune('a'+i/26))+".txt", []byte("x"), time.Now())
+	}
+
+	typ, d := c.call(fxpRealpath, func(e *encoder) { e.string("docs/../docs/.") })
+	if typ != fxpName || d.uint32() != 1 || d.string() != "/docs" {
+		t.Fatalf("realpath: unexpected response type %d", typ)
+	}
+
+	h := c.handle(c.call(fxpOpendir, func(e *encoder) { e.string("/docs") }))
+	total := 0
+	for {
+		typ, d := c.call(fxpReaddir, func(e *encoder) { e.string(h) })
+		if typ == fxpStatus {
+			if code := d.uint32(); code != fxEOF {
+				t.Fatalf("readdir: unexpected status %d", code)
+			}
+			break
+		}
+		n := int(d.uint32())
+		for range n {
+			d.string()
+			d.string()
+			if size, _ := d.attrs(); size != 1 {
+				t.Errorf("readdir: expected size 1, got %d", size)
+			}
+		}
+		total += n
+	}
+	if total != readDirBatch+5 {
+		t.Errorf("readdir: expected %d entries, got %d", readDirBatch+5, total)
+	}
+	c.close(h)
+
+	if code := c.pathOp(fxpRmdir, "/docs"); code != fxFailure {
+		t.Errorf("rmdir of non-empty directory: expected failure, got %d", code)
+	}
+	if code := c.pathOp(fxpRemove, "/docs"); code != fxFailure {
+		t.Errorf("remove of directory: expected failure, got %d", code)
+	}
+	if code := c.pathOp(fxpRemove, "/docs/faa.txt"); code != fxOK {
+		t.Errorf("remove: unexpected status %d", code)
+	}
+	if code := c.pathOp(fxpStat, "/docs/faa.txt"); code != fxNoSuchFile {
+		t.Errorf("stat of removed file: expected no such file, got %d", code)
+	}
+}
+
+func TestRename(t *testing.T) {
+	c, drive := newTestSession(t)
+	drive.AddFile(fakedrive.RootUUID, "a.txt", []byte("a"), time.Now())
+	drive.AddFile(fakedrive.RootUUID, "b.txt", []byte("b"), time.Now())
+
+	rename := func(typ byte, oldPath, newPath string) uint32 {
+		return c.status(c.call(typ, func(e *encoder) {
+			if typ == fxpExtended {
+				e.string(posixRenameExt)
+			}
+			e.string(oldPath).string(newPath)
+		}))
+	}
+	if code := rename(fxpRename, "/a.txt", "/b.txt"); code != fxFailure {
+		t.Errorf("rename onto existing file: expected failure, got %d", code)
+	}
+	if code := rename(fxpExtended, "/a.txt", "/b.txt"); code != fxOK {
+		t.Errorf("posix rename: unexpected status %d", code)
+	}
+	uuid, _, _ := drive.Lookup("b.txt")
+	if data, _ := drive.Content(uuid); string(data) != "a" {
+		t.Errorf("expected b.txt replaced, got %q", data)
+	}
+	if code := rename(fxpRename, "/b.txt", "/c.txt"); code != fxOK {
+		t.Errorf("rename: unexpected status %d", code)
+	}
+	if code := c.status(c.call(fxpSymlink, func(e *encoder) { e.string("/c.txt").string("/d.txt") })); code != fxOpUnsupported {
+		t.Errorf("symlink: expected unsupported, got %d", code)
+	}
+}
@@ -0,0 +1,108 @@
+package webdav
+
+import (
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/internxt/rclone-adapter/backend"
+)
+
+type multistatus struct {
+	XMLName   xml.Name   `xml:"D:multistatus"`
+	XMLNS     string     `xml:"xmlns:D,attr"`
+	Responses []response `xml:"D:response"`
+}
+
+type response struct {
+	Href     string   `xml:"D:href"`
+	Propstat propstat `xml:"D:propstat"`
+}
+
+type propstat struct {
+	Prop   prop   `xml:"D:prop"`
+	Status string `xml:"D:status"`
+}
+
+type prop struct {
+	DisplayName   string        `xml:"D:displayname"`
+	ResourceType  *resourceType `xml:"D:resourcetype"`
+	ContentLength string        `xml:"D:getcontentlength,omitempty"`
+	ContentType   string        `xml:"D:getcontenttype,omitempty"`
+	LastModified  string        `xml:"D:getlastmodified,omitempty"`
+}
+
+type resourceType struct {
+	Collection *struct{} `xml:"D:collection"`
+}
+
+// propfind answers with the standard live properties of remote and, for a
+// collection at Depth 1, of its children. A missing Depth is treated as 1
+// and Depth infinity is refused. Requested property names are not inspected;
+// every supported property is returned.
+func (h *Handler) propfind(w http.ResponseWriter, r *http.Request, remote string) error {
+	depth := r.Header.Get("Depth")
+	if depth == "infinity" {
+		w.WriteHeader(http.StatusForbidden)
+		return nil
+	}
+
+	entry, err := h.fs.Stat(r.Context(), remote)
+	if err != nil {
+		return err
+	}
+	ms := multistatus{XMLNS: "DAV:", Responses: []response{h.response(entry)}}
+
+	if _, isDir := entry.(*backend.Directory); isDir && depth != "0" {
+		children, err := h.fs.List(r.Context(), remote)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			ms.Responses = append(ms.Responses, h.response(child))
+		}
+	}
+
+	body, err := xml.Marshal(ms)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", `application/xml; charset="utf-8"`)
+	w.WriteHeader(http.StatusMultiStatus)
+	w.Write([]byte(xml.Header))
+	w.Write(body)
+	return nil
+}
+
+func (h *Handler) response(entry backend.Entry) response {
+	_, isDir := entry.(*backend.Directory)
+	p := prop{
+		DisplayName:  path.Base("/" + entry.Remote()),
+		ResourceType: &resourceType{},
+	}
+	if !entry.ModTime().IsZero() {
+		p.LastModified = entry.ModTime().UTC().Format(http.TimeFormat)
+	}
+	if isDir {
+		p.ResourceType.Collection = &struct{}{}
+	} else {
+		p.ContentLength = strconv.FormatInt(entry.Size(), 10)
+		p.ContentType = contentType(entry.Remote())
+	}
+	if entry.Remote() == "" {
+		p.DisplayName = ""
+	}
+	return response{
+		Href:     h.href(entry.Remote(), isDir),
+		Propstat: propstat{Prop: p, Status: "HTTP/1.1 200 OK"},
+	}
+}
+
+func contentType(remote string) string {
+	if t := mime.TypeByExtension(path.Ext(remote)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,288 @@
+// Package webdav serves a drive folder over WebDAV (RFC 4918 class 1), so it
+// can be mounted by the file managers of most operating systems without
+// rclone. It supports OPTIONS, PROPFIND, GET, HEAD, PUT, MKCOL, MOVE and
+// DELETE on top of backend.Fs; locking, COPY and PROPPATCH are not supported.
+package webdav
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/internxt/rclone-adapter/backend"
+	"github.com/internxt/rclone-adapter/errors"
+	"github.com/internxt/rclone-adapter/serve/internal/httprange"
+)
+
+// Handler is an http.Handler serving an Fs over WebDAV.
+type Handler struct {
+	fs     *backend.Fs
+	prefix string
+	logger *slog.Logger
+}
+
+// NewHandler returns a Handler serving fs at URL paths below prefix.
+// A nil logger discards request errors.
+func NewHandler(fs *backend.Fs, prefix string, logger *slog.Logger) *Handler {
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	return &Handler{fs: fs, prefix: "/" + strings.Trim(prefix, "/"), logger: logger}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	remote, ok := h.remote(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var err error
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("DAV", "1")
+		w.Header().Set("Allow", "OPTIONS, PROPFIND, GET, HEAD, PUT, MKCOL, MOVE, DELETE")
+		w.WriteHeader(http.StatusOK)
+	case "PROPFIND":
+		err = h.propfind(w, r, remote)
+	case http.MethodGet, http.MethodHead:
+		err = h.get(w, r, remote)
+	case http.MethodPut:
+		err = h.put(w, r, remote)
+	case "MKCOL":
+		err = h.mkcol(w, r, remote)
+	case "MOVE":
+		err = h.move(w, r, remote)
+	case http.MethodDelete:
+		err = h.delete(w, r, remote)
+	default:
+		w.Header().Set("Allow", "OPTIONS, PROPFIND, GET, HEAD, PUT, MKCOL, MOVE, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+	if err != nil {
+		status := statusOf(err)
+		h.logger.Warn("webdav request failed", "method", r.Method, "path", r.URL.Path, "status", status, "error", err)
+		http.Error(w, http.StatusText(status), status)
+	}
+}
+
+// remote maps a URL path to a path relative to the Fs root.
+func (h *Handler) remote(urlPath string) (string, bool) {
+	p := path.Clean("/" + urlPath)
+	if h.prefix != "/" {
+		if p != h.prefix && !strings.HasPrefix(p, h.prefix+"/") {
+			return "", false
+		}
+		p = strings.TrimPrefix(p, h.prefix)
+	}
+	return strings.Trim(p, "/"), true
+}
+
+// href returns the URL path of remote.
+func (h *Handler) href(remote string, isDir bool) string {
+	p := path.Join(h.prefix, remote)
+	if isDir && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return (&url.URL{Path: p}).EscapedPath()
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, remote string) error {
+	obj, err := h.fs.NewObject(r.Context(), remote)
+	if err != nil {
+		return err
+	}
+
+	size := obj.Size()
+	contentLength := size
+	var options []backend.OpenOption
+	status := http.StatusOK
+	if rng := r.Header.Get("Range"); rng != "" && size > 0 {
+		start, end, ok := httprange.Parse(rng, size)
+		if !ok {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return nil
+		}
+		contentLength = end - start + 1
+		options = append(options, &backend.RangeOption{Start: start, End: end})
+		status = http.StatusPartialContent
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+	}
+
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Length", strconv.FormatInt(contentLength, 10))
+	w.Header().Set("Last-Modified", obj.ModTime().UTC().Format(http.TimeFormat))
+	w.Header().Set("Content-Type", contentType(remote))
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return nil
+	}
+
+	rc, err := obj.Open(r.Context(), options...)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	w.WriteHeader(status)
+	if _, err := io.Copy(w, rc); err != nil {
+		h.logger.Warn("webdav download interrupted", "path", r.URL.Path, "error", err)
+	}
+	return nil
+}
+
+func (h *Handler) put(w http.ResponseWriter, r *http.Request, remote string) error {
+	if remote == "" {
+		return backend.ErrIsDir
+	}
+	modTime := time.Now()
+	if mtime := r.Header.Get("X-OC-Mtime"); mtime != "" {
+		if secs, err := strconv.ParseInt(mtime, 10, 64); err == nil {
+			modTime = time.Unix(secs, 0)
+		}
+	}
+
+	_, statErr := h.fs.NewObject(r.Context(), remote)
+	if _, err := h.fs.Put(r.Context(), remote, r.Body, r.ContentLength, modTime); err != nil {
+		return err
+	}
+	if statErr == nil {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+	return nil
+}
+
+func (h *Handler) mkcol(w http.ResponseWriter, r *http.Request, remote string) error {
+	if r.ContentLength > 0 {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return nil
+	}
+	if _, err := h.fs.Stat(r.Context(), remote); err == nil {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return nil
+	}
+	parent, _ := path.Split(remote)
+	if _, err := h.fs.Stat(r.Context(), parent); err != nil {
+		if stderrors.Is(err, errors.ErrNotFound) {
+			w.WriteHeader(http.StatusConflict)
+			return nil
+		}
+		return err
+	}
+	if err := h.fs.Mkdir(r.Context(), remote); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+func (h *Handler) move(w http.ResponseWriter, r *http.Request, remote string) error {
+	dest, err := url.Parse(r.Header.Get("Destination"))
+	if err != nil || dest.Path == "" {
+		http.Error(w, "invalid Destination header", http.StatusBadRequest)
+		return nil
+	}
+	dstRemote, ok := h.remote(dest.Path)
+	if !ok || remote == "" || dstRemote == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return nil
+	}
+	if dstRemote == remote {
+		w.WriteHeader(http.StatusForbidden)
+		return nil
+	}
+
+	ctx := r.Context()
+	src, err := h.fs.Stat(ctx, remote)
+	if err != nil {
+		return err
+	}
+
+	status := http.StatusCreated
+	if existing, err := h.fs.Stat(ctx, dstRemote); err == nil {
+		if r.Header.Get("Overwrite") == "F" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return nil
+		}
+		// Fs.Move replaces a file only once the move succeeds; a
+		// directory on either side has to be cleared beforehand.
+		_, srcFile := src.(*backend.Object)
+		_, dstFile := existing.(*backend.Object)
+		if !srcFile || !dstFile {
+			if err := h.remove(ctx, existing); err != nil {
+				return err
+			}
+		}
+		status = http.StatusNoContent
+	} else if !stderrors.Is(err, errors.ErrNotFound) {
+		return err
+	}
+
+	switch src := src.(type) {
+	case *backend.Object:
+		_, err = h.fs.Move(ctx, src, dstRemote)
+	default:
+		err = h.fs.DirMove(ctx, remote, dstRemote)
+	}
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(status)
+	return nil
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, remote string) error {
+	if remote == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return nil
+	}
+	entry, err := h.fs.Stat(r.Context(), remote)
+	if err != nil {
+		return err
+	}
+	if err := h.remove(r.Context(), entry); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h *Handler) remove(ctx context.Context, entry backend.Entry) error {
+	if obj, ok := entry.(*backend.Object); ok {
+		return obj.Remove(ctx)
+	}
+	return h.fs.Purge(ctx, entry.Remote())
+}
+
+// statusOf maps an error to the HTTP status reported to the client.
+func statusOf(err error) int {
+	var httpErr *errors.HTTPError
+	switch {
+	case stderrors.Is(err, errors.ErrNotFound):
+		return http.StatusNotFound
+	case stderrors.Is(err, backend.ErrIsDir), stderrors.Is(err, backend.ErrIsFile):
+		return http.StatusMethodNotAllowed
+	case stderrors.Is(err, backend.ErrDirNotEmpty), stderrors.Is(err, errors.ErrAlreadyExists):
+		return http.StatusConflict
+	case stderrors.Is(err, errors.ErrFileTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case stderrors.Is(err, errors.ErrQuotaExceeded):
+		return http.StatusInsufficientStorage
+	case stderrors.Is(err, errors.ErrUnauthorized):
+		return http.StatusForbidden
+	case stderrors.As(err, &httpErr):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,165 @@
+package webdav
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/internxt/rclone-adapter/backend"
+	"github.com/internxt/rclone-adapter/folders"
+)
+
+// newTestServer serves a WebDAV handler at /dav over a mock drive whose root
+// holds the file "notes.txt" and the folder "docs" with the file "old.txt".
+// Mutating drive requests are recorded.
+func newTestServer(t *testing.T) (*httptest.Server, *[]string) {
+	var mu sync.Mutex
+	var requests []string
+	drive := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			mu.Lock()
+			requests = append(requests, r.Method+" "+r.URL.Path)
+			mu.Unlock()
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/drive/folders":
+			var req folders.CreateFolderRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			json.NewEncoder(w).Encode(folders.Folder{UUID: req.PlainName + "-uuid", PlainName: req.PlainName})
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/drive/folders/"):
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodPatch && strings.HasPrefix(r.URL.Path, "/drive/files/"),
+			r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/drive/files/"),
+			r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/drive/files/"):
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/drive/folders/content/root/folders":
+			json.NewEncoder(w).Encode(map[string][]folders.Folder{"folders": {{UUID: "docs-uuid", PlainName: "docs"}}})
+		case r.URL.Path == "/drive/folders/content/root/files":
+			json.NewEncoder(w).Encode(map[string][]folders.File{"files": {{UUID: "notes-uuid", PlainName: "notes", Type: "txt", Size: "42"}}})
+		case r.URL.Path == "/drive/folders/content/docs-uuid/files":
+			json.NewEncoder(w).Encode(map[string][]folders.File{"files": {{UUID: "old-uuid", PlainName: "old", Type: "txt", Size: "7"}}})
+		case strings.HasSuffix(r.URL.Path, "/folders"):
+			json.NewEncoder(w).Encode(map[string][]folders.Folder{"folders": {}})
+		case strings.HasSuffix(r.URL.Path, "/files"):
+			json.NewEncoder(w).Encode(map[string][]folders.File{"files": {}})
+		default:
+			t.Errorf("unexpected drive request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(drive.Close)
+
+	fs := backend.NewFs(newTestConfig(drive.URL), "root")
+	server := httptest.NewServer(NewHandler(fs, "/dav", nil))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func do(t *testing.T, method, url string, header map[string]string) *http.Response {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestPropfind(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	resp := do(t, "PROPFIND", server.URL+"/dav/", map[string]string{"Depth": "1"})
+	if resp.StatusCode != http.StatusMultiStatus {
+		t.Fatalf("expected 207, got %d", resp.StatusCode)
+	}
+	var ms struct {
+		Responses []struct {
+			Href string `xml:"href"`
+			Prop struct {
+				ContentLength string `xml:"getcontentlength"`
+				ResourceType  struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+			} `xml:"propstat>prop"`
+		} `xml:"response"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		t.Fatalf("failed to decode multistatus: %v", err)
+	}
+	if len(ms.Responses) != 3 {
+		t.Fatalf("expected 3 responses, got %d", len(ms.Responses))
+	}
+	want := []struct {
+		href   string
+		isDir  bool
+		length string
+	}{
+		{"/dav/", true, ""},
+		{"/dav/docs/", true, ""},
+		{"/dav/notes.txt", false, "42"},
+	}
+	for i, w := range want {
+		got := ms.Responses[i]
+		if got.Href != w.href || (got.Prop.ResourceType.Collection != nil) != w.isDir || got.Prop.ContentLength != w.length {
+			t.Errorf("response %d: expected %+v, got %+v", i, w, got)
+		}
+	}
+
+	if resp := do(t, "PROPFIND", server.URL+"/dav/", map[string]string{"Depth": "infinity"}); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected 403 for Depth infinity, got %d", resp.StatusCode)
+	}
+	if resp := do(t, "PROPFIND", server.URL+"/dav/missing", map[string]string{"Depth": "0"}); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestMethods(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		header       map[string]string
+		wantStatus   int
+		wantRequests []string
+	}{
+		{"options", http.MethodOptions, "/dav/", nil, http.StatusOK, nil},
+		{"head file", http.MethodHead, "/dav/notes.txt", nil, http.StatusOK, nil},
+		{"get dir", http.MethodGet, "/dav/docs", nil, http.StatusMethodNotAllowed, nil},
+		{"get missing", http.MethodGet, "/dav/missing.txt", nil, http.StatusNotFound, nil},
+		{"outside prefix", http.MethodGet, "/other", nil, http.StatusNotFound, nil},
+		{"mkcol", "MKCOL", "/dav/photos", nil, http.StatusCreated, []string{"POST /drive/folders"}},
+		{"mkcol existing", "MKCOL", "/dav/docs", nil, http.StatusMethodNotAllowed, nil},
+		{"mkcol missing parent", "MKCOL", "/dav/a/b", nil, http.StatusConflict, nil},
+		{"delete dir", http.MethodDelete, "/dav/docs", nil, http.StatusNoContent, []string{"DELETE /drive/folders/docs-uuid"}},
+		{"delete root", http.MethodDelete, "/dav/", nil, http.StatusForbidden, nil},
+		{"move file", "MOVE", "/dav/notes.txt", map[string]string{"Destination": "/dav/docs/notes.md"}, http.StatusCreated, []string{"PATCH /drive/files/notes-uuid"}},
+		{"move over file", "MOVE", "/dav/notes.txt", map[string]string{"Destination": "/dav/docs/old.txt"}, http.StatusNoContent, []string{"PUT /drive/files/old-uuid/meta", "PATCH /drive/files/notes-uuid", "DELETE /drive/files/old-uuid"}},
+		{"move no overwrite", "MOVE", "/dav/notes.txt", map[string]string{"Destination": "/dav/docs", "Overwrite": "F"}, http.StatusPreconditionFailed, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, requests := newTestServer(t)
+			if dest, ok := tt.header["Destination"]; ok {
+				tt.header["Destination"] = server.URL + dest
+			}
+			resp := do(t, tt.method, server.URL+tt.path, tt.header)
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, resp.StatusCode)
+			}
+			if strings.Join(*requests, ",") != strings.Join(tt.wantRequests, ",") {
+				t.Errorf("expected drive requests %v, got %v", tt.wantRequests, *requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package sharing
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+
+	"github.com/internxt/rclone-adapter/crypto"
+)
+
+// publicKeyFor returns the public half of privateKey, base64-encoded as the
+// API serves it.
+func publicKeyFor(t *testing.T, privateKey string) string {
+	t.Helper()
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(privateKey))
+	if err != nil {
+		t.Fatalf("failed to read key: %v", err)
+	}
+	var key bytes.Buffer
+	w, _ := armor.Encode(&key, openpgp.PublicKeyType, nil)
+	keyring[0].Serialize(w)
+	w.Close()
+	return base64.StdEncoding.EncodeToString(key.Bytes())
+}
+
+func TestInviteUser(t *testing.T) {
+	privateKey, _ := newTestKeys(t)
+	publicKey := publicKeyFor(t, privateKey)
+
+	testCases := []struct {
+		name     string
+		opts     *InviteOptions
+		wantRole string
+		wantErr  string
+	}{
+		{name: "default role", opts: nil, wantRole: "role-reader"},
+		{name: "editor", opts: &InviteOptions{Role: "editor", Notify: true, Message: "for review"}, wantRole: "role-editor"},
+		{name: "unknown role", opts: &InviteOptions{Role: "OWNER"}, wantErr: "unknown role"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var body sendInviteRequest
+			mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/drive/users/public-key/guest@example.com":
+					json.NewEncoder(w).Encode(map[string]string{"publicKey": publicKey})
+				case "/drive/sharings/roles":
+					json.NewEncoder(w).Encode([]Role{{ID: "role-editor", Name: "EDITOR"}, {ID: "role-reader", Name: "READER"}})
+				case "/drive/sharings/invites/send":
+					json.NewDecoder(r.Body).Decode(&body)
+					json.NewEncoder(w).Encode(Invite{ID: "invite-1", ItemID: body.ItemID, ItemType: body.ItemType, RoleID: body.RoleID})
+				default:
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+			}))
+			defer mockServer.Close()
+
+			invite, err := InviteUser(context.Background(), newTestConfig(mockServer.URL), ItemFolder, "folder-uuid", "guest@example.com", tc.opts)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if invite.ID != "invite-1" || body.RoleID != tc.wantRole || body.SharedWith != "guest@example.com" || body.ItemType != ItemFolder {
+				t.Errorf("unexpected invite %+v for request %+v", invite, body)
+			}
+			if tc.opts != nil && (!body.NotifyUser || body.NotificationMessage != "for review") {
+				t.Errorf("expected the notification in the request, got %+v", body)
+			}
+			if mnemonic, err := crypto.DecryptWithPrivateKey(body.EncryptionKey, privateKey); err != nil || mnemonic != testMnemonic {
+				t.Errorf("expected the mnemonic wrapped for the invited user, got %q, %v", mnemonic, err)
+			}
+		})
+	}
+}
+
+func TestInvites(t *testing.T) {
+	var requests []string
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.URL.Path != "/drive/sharings/invites" {
+			return
+		}
+		// A full page followed by a partial one.
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		n := listPageSize
+		if offset > 0 {
+			n = 2
+		}
+		invites := make([]Invite, n)
+		for i := range invites {
+			invites[i].ID = "invite-" + strconv.Itoa(offset+i)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"invites": invites})
+	}))
+	defer mockServer.Close()
+	cfg := newTestConfig(mockServer.URL)
+	ctx := context.Background()
+
+	invites, err := ListInvites(ctx, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(invites) != listPageSize+2 || invites[listPageSize+1].ID != "invite-"+strconv.Itoa(listPageSize+1) {
+		t.Errorf("expected %d invites, got %d", listPageSize+2, len(invites))
+	}
+
+	requests = nil
+	if err := AcceptInvite(ctx, cfg, "invite-1"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := DeclineInvite(ctx, cfg, "invite-2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "POST /drive/sharings/invites/invite-1/accept, DELETE /drive/sharings/invites/invite-2"
+	if got := strings.Join(requests, ", "); got != want {
+		t.Errorf("expected requests %s, got %s", want, got)
+	}
+}
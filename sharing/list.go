@@ -0,0 +1,76 @@
+package sharing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/internxt/rclone-adapter/config"
+)
+
+// listPageSize is the default number of sharings fetched per request.
+const listPageSize = 50
+
+// ListOptions filters ListShares. The zero value lists every sharing.
+type ListOptions struct {
+	ItemType ItemType // Only list sharings of this kind (empty = files and folders)
+	PerPage  int      // Sharings fetched per request (default 50)
+}
+
+// ListShares returns the sharings owned by the account, files first, so
+// tools can audit what is exposed and revoke stale links.
+func ListShares(ctx context.Context, cfg *config.Config, opts *ListOptions) ([]Sharing, error) {
+	if opts == nil {
+		opts = &ListOptions{}
+	}
+	perPage := opts.PerPage
+	if perPage <= 0 {
+		perPage = listPageSize
+	}
+
+	endpoints := cfg.Endpoints.Drive().Sharings()
+	var all []Sharing
+	for _, itemType := range []ItemType{ItemFile, ItemFolder} {
+		if opts.ItemType != "" && opts.ItemType != itemType {
+			continue
+		}
+		base := endpoints.Files()
+		if itemType == ItemFolder {
+			base = endpoints.Folders()
+		}
+		for page := 0; ; page++ {
+			u, err := url.Parse(base)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse list sharings URL: %w", err)
+			}
+			q := u.Query()
+			q.Set("page", strconv.Itoa(page))
+			q.Set("perPage", strconv.Itoa(perPage))
+			u.RawQuery = q.Encode()
+
+			var resp struct {
+				Items []Sharing `json:"items"`
+			}
+			if err := doJSON(ctx, cfg, http.MethodGet, u.String(), "list sharings", nil, &resp); err != nil {
+				return nil, err
+			}
+			for i := range resp.Items {
+				if resp.Items[i].ItemType == "" {
+					resp.Items[i].ItemType = itemType
+				}
+			}
+			all = append(all, resp.Items...)
+			if len(resp.Items) < perPage {
+				break
+			}
+		}
+	}
+	return all, nil
+}
+
+// Revoke deletes the sharing shareID. Its link stops working at once.
+func Revoke(ctx context.Context, cfg *config.Config, shareID string) error {
+	return doJSON(ctx, cfg, http.MethodDelete, cfg.Endpoints.Drive().Sharings().Sharing(shareID), "revoke sharing", nil, nil)
+}
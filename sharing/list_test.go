@@ -0,0 +1,90 @@
+package sharing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
+)
+
+func TestListShares(t *testing.T) {
+	// Three file sharings and one folder sharing, served two per page.
+	sharings := map[string][]Sharing{
+		"/drive/sharings/files":   {{ID: "f1"}, {ID: "f2"}, {ID: "f3"}},
+		"/drive/sharings/folders": {{ID: "d1", ItemType: ItemFolder}},
+	}
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		items, ok := sharings[r.URL.Path]
+		if r.Method != http.MethodGet || !ok {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("perPage"))
+		start := min(page*perPage, len(items))
+		end := min(start+perPage, len(items))
+		json.NewEncoder(w).Encode(map[string]any{"items": items[start:end]})
+	}))
+	defer mockServer.Close()
+	cfg := newTestConfig(mockServer.URL)
+
+	testCases := []struct {
+		name string
+		opts *ListOptions
+		want string
+	}{
+		{"all", &ListOptions{PerPage: 2}, "f1:file f2:file f3:file d1:folder"},
+		{"files", &ListOptions{ItemType: ItemFile, PerPage: 2}, "f1:file f2:file f3:file"},
+		{"folders", &ListOptions{ItemType: ItemFolder}, "d1:folder"},
+		{"defaults", nil, "f1:file f2:file f3:file d1:folder"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ListShares(context.Background(), cfg, tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var ids []string
+			for _, s := range got {
+				ids = append(ids, fmt.Sprintf("%s:%s", s.ID, s.ItemType))
+			}
+			if strings.Join(ids, " ") != tc.want {
+				t.Errorf("expected %s, got %v", tc.want, ids)
+			}
+		})
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	testCases := []struct {
+		name       string
+		statusCode int
+		wantErr    error
+	}{
+		{"revoked", http.StatusOK, nil},
+		{"no content", http.StatusNoContent, nil},
+		{"unknown sharing", http.StatusNotFound, sdkerrors.ErrNotFound},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodDelete || r.URL.Path != "/drive/sharings/sharing-1" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+				w.WriteHeader(tc.statusCode)
+			}))
+			defer mockServer.Close()
+
+			err := Revoke(context.Background(), newTestConfig(mockServer.URL), "sharing-1")
+			if tc.wantErr == nil && err != nil || tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Errorf("expected %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
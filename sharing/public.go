@@ -0,0 +1,165 @@
+package sharing
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/internxt/rclone-adapter/auth"
+	"github.com/internxt/rclone-adapter/buckets"
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/crypto"
+)
+
+// passwordHeader carries the password of a protected link.
+const passwordHeader = "x-share-password"
+
+// publicSharing is a public sharing as resolved by visitors of its link.
+type publicSharing struct {
+	Sharing
+	Item        SharedItem `json:"item"`
+	ItemToken   string     `json:"itemToken"`
+	Credentials struct {
+		NetworkUser string `json:"networkUser"`
+		NetworkPass string `json:"networkPass"`
+	} `json:"credentials"`
+}
+
+// publicLink is an opened link: the owner's mnemonic recovered with its code.
+type publicLink struct {
+	cfg      *config.Config
+	code     string
+	mnemonic string
+}
+
+// DownloadFromLink writes the item behind a public share link, as built by
+// CreateFileLink or the web app, to w: the decrypted file, or a zip archive
+// of a folder and everything below it. password is only needed for links
+// protected with one. No account is needed; only the endpoints and HTTP
+// client of cfg are used.
+func DownloadFromLink(ctx context.Context, cfg *config.Config, linkURL, password string, w io.Writer) error {
+	itemType, sharingID, code, err := parseLink(linkURL)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	if password != "" {
+		header.Set(passwordHeader, password)
+	}
+	metaURL := cfg.Endpoints.Drive().Sharings().Meta(sharingID) + "?" + url.Values{"code": {code}}.Encode()
+	var sharing publicSharing
+	if err := doJSONHeader(ctx, cfg, http.MethodGet, metaURL, "get public sharing", header, nil, &sharing); err != nil {
+		return err
+	}
+	mnemonic, err := crypto.DecryptTextGCM(sharing.EncryptionKey, code)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt key of sharing %s: %w", sharingID, err)
+	}
+
+	item := sharing.Item
+	item.Type = itemType
+	if item.UUID == "" {
+		item.UUID = sharing.ItemID
+	}
+	item.access = sharedAccess{
+		token:       sharing.ItemToken,
+		networkUser: sharing.Credentials.NetworkUser,
+		networkPass: sharing.Credentials.NetworkPass,
+	}
+	link := &publicLink{cfg: cfg, code: code, mnemonic: mnemonic}
+
+	if itemType == ItemFile {
+		return link.copyFile(ctx, &item, w)
+	}
+	zw := zip.NewWriter(w)
+	if err := link.zipFolder(ctx, zw, &item, ""); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write zip archive: %w", err)
+	}
+	return nil
+}
+
+// parseLink splits a link of the form {base}/sh/{itemType}/{sharingId}/{code}.
+func parseLink(linkURL string) (ItemType, string, string, error) {
+	u, err := url.Parse(linkURL)
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to parse share link: %w", err)
+	}
+	seg := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(seg) < 4 || seg[len(seg)-4] != "sh" {
+		return "", "", "", fmt.Errorf("failed to parse share link %q: not a share link", linkURL)
+	}
+	seg = seg[len(seg)-4:]
+	itemType := ItemType(seg[1])
+	if itemType != ItemFile && itemType != ItemFolder {
+		return "", "", "", fmt.Errorf("failed to parse share link %q: unknown item type %q", linkURL, seg[1])
+	}
+	return itemType, seg[2], seg[3], nil
+}
+
+// copyFile writes the decrypted contents of file to w.
+func (l *publicLink) copyFile(ctx context.Context, file *SharedItem, w io.Writer) error {
+	shared := l.cfg.Clone()
+	shared.SetMnemonic(l.mnemonic)
+	shared.KeyDeriver = nil
+	shared.Bucket = file.Bucket
+	shared.BasicAuthHeader = auth.NetworkBasicAuth(file.access.networkUser, file.access.networkPass)
+
+	rc, err := buckets.DownloadFileStream(ctx, shared, file.FileID, file.EncryptVersion)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, rc); err != nil {
+		rc.Close()
+		return fmt.Errorf("failed to download shared file %s: %w", file.UUID, err)
+	}
+	return rc.Close()
+}
+
+// zipFolder adds the contents of folder to zw, with names below prefix.
+func (l *publicLink) zipFolder(ctx context.Context, zw *zip.Writer, folder *SharedItem, prefix string) error {
+	endpoints := l.cfg.Endpoints.Drive().Sharings()
+	query := "?" + url.Values{"token": {folder.access.token}, "code": {l.code}}.Encode()
+	for _, itemType := range []ItemType{ItemFolder, ItemFile} {
+		base := endpoints.PublicItemFolders(folder.UUID)
+		if itemType == ItemFile {
+			base = endpoints.PublicItemFiles(folder.UUID)
+		}
+		items, err := listShared(ctx, l.cfg, base+query, itemType, 0, nil)
+		if err != nil {
+			return err
+		}
+		for i := range items {
+			item := &items[i]
+			item.inherit(folder)
+			name := prefix + item.PlainName
+			if itemType == ItemFolder {
+				if _, err := zw.Create(name + "/"); err != nil {
+					return fmt.Errorf("failed to write zip archive: %w", err)
+				}
+				if err := l.zipFolder(ctx, zw, item, name+"/"); err != nil {
+					return err
+				}
+				continue
+			}
+			if item.FileType != "" {
+				name += "." + item.FileType
+			}
+			fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+			if err != nil {
+				return fmt.Errorf("failed to write zip archive: %w", err)
+			}
+			if err := l.copyFile(ctx, item, fw); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
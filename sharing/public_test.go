@@ -0,0 +1,163 @@
+package sharing
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/internxt/rclone-adapter/crypto"
+	"github.com/internxt/rclone-adapter/folders"
+	"github.com/internxt/rclone-adapter/internal/fakedrive"
+)
+
+// newLinkServer serves the public sharing sharing-1 of item, protected with
+// password if not empty, in front of the owner's fake drive.
+func newLinkServer(t *testing.T, server *fakedrive.Server, itemType ItemType, item map[string]any, code, password string) *httptest.Server {
+	owner := server.Config()
+	encryptionKey, err := crypto.EncryptTextGCM(owner.MnemonicString(), code)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	target, _ := url.Parse(server.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seg := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		switch {
+		case r.URL.Path == "/drive/sharings/sharing-1/meta":
+			if r.URL.Query().Get("code") == "" {
+				t.Error("expected the link code")
+			}
+			if r.Header.Get("Authorization") != "" {
+				t.Error("expected no Authorization header")
+			}
+			if password != "" && r.Header.Get(passwordHeader) != password {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"id": "sharing-1", "itemType": itemType, "encryptionKey": encryptionKey,
+				"item": item, "itemToken": "item-token",
+				"credentials": map[string]string{"networkUser": "owner@example.com", "networkPass": "owner-pass"},
+			})
+		case len(seg) == 6 && seg[2] == "public" && seg[3] == "items":
+			if r.URL.Query().Get("token") != "item-token" || r.URL.Query().Get("code") != code {
+				t.Errorf("unexpected query %s", r.URL.RawQuery)
+			}
+			var items []map[string]any
+			if seg[5] == "folders" {
+				children, _ := folders.ListAllFolders(r.Context(), owner, seg[4])
+				for _, f := range children {
+					items = append(items, map[string]any{"uuid": f.UUID, "plainName": f.PlainName})
+				}
+			} else {
+				children, _ := folders.ListAllFiles(r.Context(), owner, seg[4])
+				for _, f := range children {
+					items = append(items, map[string]any{"uuid": f.UUID, "plainName": f.PlainName, "type": f.Type,
+						"fileId": f.FileID, "bucket": f.Bucket})
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]any{"items": items})
+		default:
+			proxy.ServeHTTP(w, r)
+		}
+	}))
+	t.Cleanup(front.Close)
+	return front
+}
+
+func TestDownloadFromLink(t *testing.T) {
+	server := fakedrive.New()
+	defer server.Close()
+	owner := server.Config()
+	docsUUID := server.AddFolder(fakedrive.RootUUID, "docs")
+	subUUID := server.AddFolder(docsUUID, "sub")
+	server.AddFile(docsUUID, "a.txt", []byte("first"), time.Now())
+	server.AddFile(subUUID, "b.txt", []byte("second"), time.Now())
+	files, err := folders.ListAllFiles(context.Background(), owner, docsUUID)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("failed to list owner files: %v, %v", files, err)
+	}
+	code := strings.Repeat("ab", 32)
+
+	t.Run("file", func(t *testing.T) {
+		item := map[string]any{"uuid": files[0].UUID, "plainName": "a", "type": "txt", "fileId": files[0].FileID, "bucket": owner.Bucket}
+		front := newLinkServer(t, server, ItemFile, item, code, "")
+		var buf bytes.Buffer
+		link := DefaultLinkBaseURL + "/sh/file/sharing-1/" + code
+		if err := DownloadFromLink(context.Background(), newPublicConfig(front.URL), link, "", &buf); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if buf.String() != "first" {
+			t.Errorf("expected the file content, got %q", buf.String())
+		}
+	})
+
+	t.Run("folder", func(t *testing.T) {
+		item := map[string]any{"uuid": docsUUID, "plainName": "docs", "bucket": owner.Bucket}
+		front := newLinkServer(t, server, ItemFolder, item, code, "hunter2")
+		var buf bytes.Buffer
+		link := DefaultLinkBaseURL + "/sh/folder/sharing-1/" + code
+		if err := DownloadFromLink(context.Background(), newPublicConfig(front.URL), link, "hunter2", &buf); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("failed to read zip archive: %v", err)
+		}
+		var got []string
+		for _, f := range zr.File {
+			entry := f.Name
+			if !strings.HasSuffix(f.Name, "/") {
+				rc, _ := f.Open()
+				data, _ := io.ReadAll(rc)
+				rc.Close()
+				entry += "=" + string(data)
+			}
+			got = append(got, entry)
+		}
+		sort.Strings(got)
+		want := "a.txt=first,sub/,sub/b.txt=second"
+		if strings.Join(got, ",") != want {
+			t.Errorf("expected archive %s, got %v", want, got)
+		}
+	})
+}
+
+func TestDownloadFromLinkErrors(t *testing.T) {
+	server := fakedrive.New()
+	defer server.Close()
+	code := strings.Repeat("ab", 32)
+	front := newLinkServer(t, server, ItemFolder, map[string]any{"uuid": fakedrive.RootUUID}, code, "hunter2")
+	cfg := newPublicConfig(front.URL)
+
+	testCases := []struct {
+		name     string
+		link     string
+		password string
+		want     string
+	}{
+		{"not a link", "https://drive.internxt.com/app/folder", "", "not a share link"},
+		{"unknown item type", "https://drive.internxt.com/sh/album/sharing-1/" + code, "", "unknown item type"},
+		{"missing password", "https://drive.internxt.com/sh/folder/sharing-1/" + code, "", "403"},
+		{"wrong code", "https://drive.internxt.com/sh/folder/sharing-1/" + strings.Repeat("cd", 32), "hunter2", "failed to decrypt key"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := DownloadFromLink(context.Background(), cfg, tc.link, tc.password, io.Discard); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
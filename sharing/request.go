@@ -0,0 +1,62 @@
+package sharing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/errors"
+)
+
+// doJSON sends body, if not nil, as JSON and decodes the response into dst,
+// if not nil. op names the call in errors.
+func doJSON(ctx context.Context, cfg *config.Config, method, url, op string, body, dst any) error {
+	return doJSONHeader(ctx, cfg, method, url, op, nil, body, dst)
+}
+
+// doJSONHeader is doJSON with extra request headers. The Authorization
+// header is left out when cfg has no token, as for public links.
+func doJSONHeader(ctx context.Context, cfg *config.Config, method, url, op string, header http.Header, body, dst any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to marshal %s request: %w", op, err)
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, r)
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", op, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if cfg.CurrentToken() != "" {
+		cfg.AuthorizeRequest(req)
+	}
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute %s request: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusNoContent {
+		return errors.NewHTTPError(resp, op)
+	}
+	if dst == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(dst); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", op, err)
+	}
+	return nil
+}
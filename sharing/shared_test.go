@@ -0,0 +1,187 @@
+package sharing
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/folders"
+	"github.com/internxt/rclone-adapter/internal/fakedrive"
+)
+
+// newTestKeys returns an armored private key and a function wrapping text
+// for it, as the web app wraps the keys of items shared with a user.
+func newTestKeys(t *testing.T) (string, func(string) string) {
+	t.Helper()
+	entity, err := openpgp.NewEntity("recipient", "", "recipient@example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	var key bytes.Buffer
+	w, _ := armor.Encode(&key, openpgp.PrivateKeyType, nil)
+	if err := entity.SerializePrivate(w, nil); err != nil {
+		t.Fatalf("failed to serialize key: %v", err)
+	}
+	w.Close()
+
+	wrap := func(text string) string {
+		var msg bytes.Buffer
+		aw, _ := armor.Encode(&msg, "PGP MESSAGE", nil)
+		pw, err := openpgp.Encrypt(aw, openpgp.EntityList{entity}, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("failed to encrypt: %v", err)
+		}
+		io.WriteString(pw, text)
+		pw.Close()
+		aw.Close()
+		return base64.StdEncoding.EncodeToString(msg.Bytes())
+	}
+	return key.String(), wrap
+}
+
+func TestSharedWithMe(t *testing.T) {
+	// The owner's drive holds a shared folder with a subfolder and a file.
+	server := fakedrive.New()
+	defer server.Close()
+	owner := server.Config()
+	teamUUID := server.AddFolder(fakedrive.RootUUID, "team")
+	server.AddFolder(teamUUID, "drafts")
+	fileUUID := server.AddFile(teamUUID, "plan.txt", []byte("ship it"), time.Now())
+	files, err := folders.ListAllFiles(context.Background(), owner, teamUUID)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("failed to list owner files: %v, %v", files, err)
+	}
+
+	privateKey, wrap := newTestKeys(t)
+	encryptionKey := wrap(owner.MnemonicString())
+
+	// The sharing listings are served here; everything else goes to the
+	// owner's drive.
+	target, _ := url.Parse(server.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	var tokens []string
+	front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/drive/sharings/shared-with-me/folders":
+			json.NewEncoder(w).Encode(map[string]any{
+				"items": []map[string]any{{"uuid": teamUUID, "plainName": "team", "bucket": owner.Bucket,
+					"encryptionKey": encryptionKey, "sharingId": "sharing-1"}},
+				"token":       "resources-token",
+				"role":        "EDITOR",
+				"credentials": map[string]string{"networkUser": "owner@example.com", "networkPass": "owner-pass"},
+			})
+		case "/drive/sharings/shared-with-me/files":
+			json.NewEncoder(w).Encode(map[string]any{"items": []any{}})
+		case "/drive/sharings/items/" + teamUUID + "/folders":
+			tokens = append(tokens, r.Header.Get(config.ResourcesTokenHeader))
+			json.NewEncoder(w).Encode(map[string]any{"items": []map[string]any{{"uuid": "drafts-uuid", "plainName": "drafts"}}})
+		case "/drive/sharings/items/" + teamUUID + "/files":
+			tokens = append(tokens, r.Header.Get(config.ResourcesTokenHeader))
+			json.NewEncoder(w).Encode(map[string]any{"items": []map[string]any{{"uuid": fileUUID, "plainName": "plan",
+				"type": "txt", "size": "7", "fileId": files[0].FileID}}})
+		default:
+			proxy.ServeHTTP(w, r)
+		}
+	}))
+	defer front.Close()
+
+	ctx := context.Background()
+	cfg := newTestConfig(front.URL)
+	cfg.PrivateKey = privateKey
+
+	shared, err := SharedWithMe(ctx, cfg, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(shared) != 1 || shared[0].Type != ItemFolder || shared[0].UUID != teamUUID {
+		t.Fatalf("expected the shared folder, got %+v", shared)
+	}
+	team := &shared[0]
+
+	items, err := ListSharedFolder(ctx, cfg, team)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 2 || items[0].Type != ItemFolder || items[1].Type != ItemFile {
+		t.Fatalf("expected a folder and a file, got %+v", items)
+	}
+	if strings.Join(tokens, ",") != "resources-token,resources-token" {
+		t.Errorf("expected the resources token on the folder listings, got %v", tokens)
+	}
+	plan := &items[1]
+	if plan.EncryptionKey != encryptionKey || plan.Bucket != owner.Bucket {
+		t.Errorf("expected the file to inherit the folder's key and bucket, got %+v", plan)
+	}
+
+	opened, err := OpenShared(cfg, team)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opened.MnemonicString() != owner.MnemonicString() || opened.Bucket != owner.Bucket || opened.RootFolderID != teamUUID ||
+		opened.ResourcesToken != "resources-token" || opened.BasicAuthHeader == "" {
+		t.Errorf("unexpected shared config %+v", opened)
+	}
+	if cfg.MnemonicString() != testMnemonic {
+		t.Error("expected the account's config to be left unchanged")
+	}
+
+	rc, err := DownloadShared(ctx, cfg, plan)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(data) != "ship it" {
+		t.Errorf("expected the shared file's content, got %q, %v", data, err)
+	}
+
+	if _, err := UploadShared(ctx, cfg, team, "notes.txt", strings.NewReader("from a guest"), 12, time.Now()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	uuid, _, ok := server.Lookup("team/notes.txt")
+	if !ok {
+		t.Fatal("expected the upload in the shared folder")
+	}
+	if data, _ := server.Content(uuid); string(data) != "from a guest" {
+		t.Errorf("expected the upload encrypted for the owner, got %q", data)
+	}
+}
+
+func TestOpenSharedErrors(t *testing.T) {
+	privateKey, wrap := newTestKeys(t)
+	otherKey, _ := newTestKeys(t)
+	item := &SharedItem{UUID: "item-uuid", Type: ItemFile, EncryptionKey: wrap(testMnemonic)}
+
+	testCases := []struct {
+		name       string
+		privateKey string
+		item       *SharedItem
+		want       string
+	}{
+		{"missing private key", "", item, "private key is required"},
+		{"other recipient", otherKey, item, "failed to unwrap key"},
+		{"download folder", privateKey, &SharedItem{UUID: "folder-uuid", Type: ItemFolder}, "not a file"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTestConfig("http://127.0.0.1:0")
+			cfg.PrivateKey = tc.privateKey
+			if _, err := DownloadShared(context.Background(), cfg, tc.item); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
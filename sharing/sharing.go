@@ -0,0 +1,277 @@
+// Package sharing publishes drive items through public share links, the
+// "anyone with the link" sharings of the web app, and shares them privately
+// with other users.
+//
+// Items are encrypted with keys derived from the owner's mnemonic, so a link
+// must carry them: a random code is generated per sharing, the mnemonic is
+// encrypted with the code and stored with the sharing, and the code itself
+// only travels in the link. The code is also stored encrypted with the
+// mnemonic so the owner can rebuild the link later.
+//
+// Private sharings start as invitations instead, which carry the mnemonic
+// wrapped with the invited user's OpenPGP public key.
+package sharing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/crypto"
+)
+
+// DefaultLinkBaseURL is the web app that opens share links.
+const DefaultLinkBaseURL = "https://drive.internxt.com"
+
+const encryptionAlgorithm = "inxt-v2"
+
+// ItemType is the kind of a shared item.
+type ItemType string
+
+const (
+	ItemFile   ItemType = "file"
+	ItemFolder ItemType = "folder"
+)
+
+// Permission is what visitors of a link may do with the shared items.
+type Permission string
+
+const (
+	PermissionDownload Permission = "download" // Preview and download (the default)
+	PermissionView     Permission = "view"     // Preview only
+)
+
+// LinkOptions configures a new share link. The zero value allows downloads
+// by anyone with the link, forever.
+type LinkOptions struct {
+	Permission   Permission // What visitors may do (default PermissionDownload)
+	Password     string     // Asked for before the items are shown (empty = none)
+	ExpiresAt    time.Time  // The link stops working after this time (zero = never)
+	MaxDownloads int        // The link stops working after this many downloads (0 = unlimited)
+	BaseURL      string     // Web app the link points to (default DefaultLinkBaseURL)
+}
+
+func (o *LinkOptions) restricted() bool {
+	return o.Password != "" || !o.ExpiresAt.IsZero() || o.MaxDownloads > 0
+}
+
+// Sharing is a sharing as returned by the API.
+type Sharing struct {
+	ID                string     `json:"id"`
+	ItemID            string     `json:"itemId"`
+	ItemType          ItemType   `json:"itemType"`
+	OwnerID           string     `json:"ownerId"`
+	SharedWith        string     `json:"sharedWith"`
+	Type              string     `json:"type"`
+	Permission        Permission `json:"permission,omitempty"`
+	EncryptionKey     string     `json:"encryptionKey"`
+	EncryptedCode     string     `json:"encryptedCode"`
+	EncryptedPassword string     `json:"encryptedPassword,omitempty"` // Set when the link asks for a password
+	ExpirationAt      string     `json:"expirationAt,omitempty"`
+	MaxDownloads      int        `json:"maxDownloads,omitempty"`
+	CreatedAt         string     `json:"createdAt"`
+	UpdatedAt         string     `json:"updatedAt"`
+}
+
+// Link is a public sharing together with the secret needed to open it.
+type Link struct {
+	Sharing
+	Code string // Decrypts the sharing's EncryptionKey; only ever part of URL
+	URL  string
+}
+
+type createSharingRequest struct {
+	ItemID                 string     `json:"itemId"`
+	ItemType               ItemType   `json:"itemType"`
+	EncryptionKey          string     `json:"encryptionKey"`
+	EncryptionAlgorithm    string     `json:"encryptionAlgorithm"`
+	EncryptedCode          string     `json:"encryptedCode"`
+	PersistPreviousSharing bool       `json:"persistPreviousSharing"`
+	Permission             Permission `json:"permission"`
+	EncryptedPassword      string     `json:"encryptedPassword,omitempty"`
+	ExpirationAt           string     `json:"expirationAt,omitempty"`
+	MaxDownloads           int        `json:"maxDownloads,omitempty"`
+}
+
+// ShareUpdate changes the restrictions of an existing link. Nil fields are
+// left unchanged.
+type ShareUpdate struct {
+	Password     *string    // New password; "" removes it
+	ExpiresAt    *time.Time // New expiry; the zero time removes it
+	MaxDownloads *int       // New download limit; 0 removes it
+}
+
+// CreateFileLink creates a public link to the file fileUUID, or returns the
+// file's existing link.
+func CreateFileLink(ctx context.Context, cfg *config.Config, fileUUID string, opts *LinkOptions) (*Link, error) {
+	return createLink(ctx, cfg, ItemFile, fileUUID, opts)
+}
+
+// CreateFolderLink creates a public link to the folder folderUUID and
+// everything below it, or returns the folder's existing link. The folder's
+// files are decrypted with the mnemonic carried by the link.
+func CreateFolderLink(ctx context.Context, cfg *config.Config, folderUUID string, opts *LinkOptions) (*Link, error) {
+	return createLink(ctx, cfg, ItemFolder, folderUUID, opts)
+}
+
+func createLink(ctx context.Context, cfg *config.Config, itemType ItemType, itemID string, opts *LinkOptions) (*Link, error) {
+	if opts == nil {
+		opts = &LinkOptions{}
+	}
+	if cfg.Mnemonic.Len() == 0 {
+		return nil, fmt.Errorf("failed to share %s %s: mnemonic is required", itemType, itemID)
+	}
+	permission := opts.Permission
+	if permission == "" {
+		permission = PermissionDownload
+	}
+
+	code, err := newCode()
+	if err != nil {
+		return nil, err
+	}
+	encryptionKey, err := crypto.EncryptTextGCM(cfg.MnemonicString(), code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt share key: %w", err)
+	}
+	encryptedCode, err := crypto.EncryptTextGCM(code, cfg.MnemonicString())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt share code: %w", err)
+	}
+
+	body := createSharingRequest{
+		ItemID:                 itemID,
+		ItemType:               itemType,
+		EncryptionKey:          encryptionKey,
+		EncryptionAlgorithm:    encryptionAlgorithm,
+		EncryptedCode:          encryptedCode,
+		PersistPreviousSharing: true,
+		Permission:             permission,
+		MaxDownloads:           opts.MaxDownloads,
+	}
+	if opts.Password != "" {
+		if body.EncryptedPassword, err = crypto.EncryptTextGCM(opts.Password, code); err != nil {
+			return nil, fmt.Errorf("failed to encrypt share password: %w", err)
+		}
+	}
+	if !opts.ExpiresAt.IsZero() {
+		body.ExpirationAt = opts.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+
+	var sharing Sharing
+	if err := doJSON(ctx, cfg, http.MethodPost, cfg.Endpoints.Drive().Sharings().Create(), "create sharing", body, &sharing); err != nil {
+		return nil, err
+	}
+	link, err := linkFor(cfg, &sharing, opts.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// An existing sharing is returned unchanged; apply the restrictions
+	// asked for to it.
+	if link.Code != code && opts.restricted() {
+		update := ShareUpdate{}
+		if opts.Password != "" {
+			update.Password = &opts.Password
+		}
+		if !opts.ExpiresAt.IsZero() {
+			update.ExpiresAt = &opts.ExpiresAt
+		}
+		if opts.MaxDownloads > 0 {
+			update.MaxDownloads = &opts.MaxDownloads
+		}
+		if err := UpdateShare(ctx, cfg, &link.Sharing, update); err != nil {
+			return nil, err
+		}
+	}
+	return link, nil
+}
+
+// UpdateShare changes the password, expiry or download limit of sharing and
+// updates it to match. The password is encrypted with the sharing's code,
+// recovered with cfg.Mnemonic.
+func UpdateShare(ctx context.Context, cfg *config.Config, sharing *Sharing, update ShareUpdate) error {
+	endpoints := cfg.Endpoints.Drive().Sharings()
+
+	if update.Password != nil {
+		if *update.Password == "" {
+			if err := doJSON(ctx, cfg, http.MethodDelete, endpoints.Password(sharing.ID), "remove sharing password", nil, nil); err != nil {
+				return err
+			}
+			sharing.EncryptedPassword = ""
+		} else {
+			code, err := crypto.DecryptTextGCM(sharing.EncryptedCode, cfg.MnemonicString())
+			if err != nil {
+				return fmt.Errorf("failed to decrypt code of sharing %s: %w", sharing.ID, err)
+			}
+			encrypted, err := crypto.EncryptTextGCM(*update.Password, code)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt share password: %w", err)
+			}
+			body := map[string]string{"encryptedPassword": encrypted}
+			if err := doJSON(ctx, cfg, http.MethodPatch, endpoints.Password(sharing.ID), "set sharing password", body, nil); err != nil {
+				return err
+			}
+			sharing.EncryptedPassword = encrypted
+		}
+	}
+
+	if update.ExpiresAt == nil && update.MaxDownloads == nil {
+		return nil
+	}
+	// Nulls remove the restriction; absent fields leave it unchanged.
+	body := map[string]any{}
+	if update.ExpiresAt != nil {
+		body["expirationAt"] = nil
+		if !update.ExpiresAt.IsZero() {
+			body["expirationAt"] = update.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+	}
+	if update.MaxDownloads != nil {
+		body["maxDownloads"] = nil
+		if *update.MaxDownloads > 0 {
+			body["maxDownloads"] = *update.MaxDownloads
+		}
+	}
+	if err := doJSON(ctx, cfg, http.MethodPatch, endpoints.Sharing(sharing.ID), "update sharing", body, nil); err != nil {
+		return err
+	}
+	if update.ExpiresAt != nil {
+		sharing.ExpirationAt, _ = body["expirationAt"].(string)
+	}
+	if update.MaxDownloads != nil {
+		sharing.MaxDownloads, _ = body["maxDownloads"].(int)
+	}
+	return nil
+}
+
+// linkFor recovers the code of sharing, which differs from the one just
+// generated when the item was already shared, and builds its URL.
+func linkFor(cfg *config.Config, sharing *Sharing, baseURL string) (*Link, error) {
+	code, err := crypto.DecryptTextGCM(sharing.EncryptedCode, cfg.MnemonicString())
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt code of sharing %s: %w", sharing.ID, err)
+	}
+	if baseURL == "" {
+		baseURL = DefaultLinkBaseURL
+	}
+	u, err := url.JoinPath(strings.TrimSuffix(baseURL, "/"), "sh", string(sharing.ItemType), sharing.ID, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build link of sharing %s: %w", sharing.ID, err)
+	}
+	return &Link{Sharing: *sharing, Code: code, URL: u}, nil
+}
+
+func newCode() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate share code: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
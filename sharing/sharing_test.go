@@ -0,0 +1,247 @@
+package sharing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/internxt/rclone-adapter/crypto"
+)
+
+func TestCreateLink(t *testing.T) {
+	// An existing sharing is returned as is, with the code it was created with.
+	previousCode := strings.Repeat("ab", 32)
+	previousEncryptedCode, err := crypto.EncryptTextGCM(previousCode, testMnemonic)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	testCases := []struct {
+		name       string
+		create     func(ctx context.Context, srvURL string) (*Link, error)
+		existing   bool
+		itemType   ItemType
+		permission Permission
+		wantURL    string
+	}{
+		{
+			name: "folder link",
+			create: func(ctx context.Context, srvURL string) (*Link, error) {
+				return CreateFolderLink(ctx, newTestConfig(srvURL), "folder-uuid", nil)
+			},
+			itemType:   ItemFolder,
+			permission: PermissionDownload,
+			wantURL:    DefaultLinkBaseURL + "/sh/folder/sharing-1/",
+		},
+		{
+			name: "view-only file link",
+			create: func(ctx context.Context, srvURL string) (*Link, error) {
+				return CreateFileLink(ctx, newTestConfig(srvURL), "file-uuid", &LinkOptions{Permission: PermissionView, BaseURL: "https://drive.example.com/"})
+			},
+			itemType:   ItemFile,
+			permission: PermissionView,
+			wantURL:    "https://drive.example.com/sh/file/sharing-1/",
+		},
+		{
+			name: "existing folder link",
+			create: func(ctx context.Context, srvURL string) (*Link, error) {
+				return CreateFolderLink(ctx, newTestConfig(srvURL), "folder-uuid", nil)
+			},
+			existing:   true,
+			itemType:   ItemFolder,
+			permission: PermissionDownload,
+			wantURL:    DefaultLinkBaseURL + "/sh/folder/sharing-1/",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var body createSharingRequest
+			mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/drive/sharings" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+				if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+					t.Fatalf("failed to decode request: %v", err)
+				}
+				sharing := Sharing{ID: "sharing-1", ItemID: body.ItemID, ItemType: body.ItemType, Type: "public",
+					EncryptionKey: body.EncryptionKey, EncryptedCode: body.EncryptedCode}
+				if tc.existing {
+					sharing.EncryptedCode = previousEncryptedCode
+				}
+				w.WriteHeader(http.StatusCreated)
+				json.NewEncoder(w).Encode(sharing)
+			}))
+			defer mockServer.Close()
+
+			link, err := tc.create(context.Background(), mockServer.URL)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if body.ItemType != tc.itemType || body.Permission != tc.permission || body.EncryptionAlgorithm != "inxt-v2" || !body.PersistPreviousSharing {
+				t.Errorf("unexpected request body %+v", body)
+			}
+			if link.URL != tc.wantURL+link.Code {
+				t.Errorf("expected URL %s<code>, got %s", tc.wantURL, link.URL)
+			}
+			if tc.existing {
+				if link.Code != previousCode {
+					t.Errorf("expected the existing code, got %s", link.Code)
+				}
+				return
+			}
+			mnemonic, err := crypto.DecryptTextGCM(body.EncryptionKey, link.Code)
+			if err != nil || mnemonic != testMnemonic {
+				t.Errorf("expected the link code to decrypt the mnemonic, got %q, %v", mnemonic, err)
+			}
+			if code, err := crypto.DecryptTextGCM(body.EncryptedCode, testMnemonic); err != nil || code != link.Code {
+				t.Errorf("expected the encrypted code to match the link, got %q, %v", code, err)
+			}
+		})
+	}
+}
+
+func TestCreateLinkErrors(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer mockServer.Close()
+
+	if _, err := CreateFolderLink(context.Background(), newTestConfig(mockServer.URL), "folder-uuid", nil); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("expected 403 error, got %v", err)
+	}
+
+	cfg := newTestConfig(mockServer.URL)
+	cfg.SetMnemonic("")
+	if _, err := CreateFolderLink(context.Background(), cfg, "folder-uuid", nil); err == nil || !strings.Contains(err.Error(), "mnemonic") {
+		t.Errorf("expected missing mnemonic error, got %v", err)
+	}
+}
+
+func TestCreateLinkRestrictions(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	opts := &LinkOptions{Password: "hunter2", ExpiresAt: expires, MaxDownloads: 5}
+
+	t.Run("new sharing", func(t *testing.T) {
+		var body createSharingRequest
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/drive/sharings" {
+				t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			json.NewEncoder(w).Encode(Sharing{ID: "sharing-1", ItemType: body.ItemType, EncryptedCode: body.EncryptedCode})
+		}))
+		defer mockServer.Close()
+
+		link, err := CreateFolderLink(context.Background(), newTestConfig(mockServer.URL), "folder-uuid", opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if body.ExpirationAt != "2030-01-02T03:04:05Z" || body.MaxDownloads != 5 {
+			t.Errorf("unexpected request body %+v", body)
+		}
+		if password, err := crypto.DecryptTextGCM(body.EncryptedPassword, link.Code); err != nil || password != "hunter2" {
+			t.Errorf("expected the password encrypted with the link code, got %q, %v", password, err)
+		}
+	})
+
+	t.Run("existing sharing", func(t *testing.T) {
+		encryptedCode, _ := crypto.EncryptTextGCM(strings.Repeat("cd", 32), testMnemonic)
+		var requests []string
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.Method+" "+r.URL.Path)
+			if r.URL.Path == "/drive/sharings" {
+				json.NewEncoder(w).Encode(Sharing{ID: "sharing-1", ItemType: ItemFolder, EncryptedCode: encryptedCode})
+			}
+		}))
+		defer mockServer.Close()
+
+		link, err := CreateFolderLink(context.Background(), newTestConfig(mockServer.URL), "folder-uuid", opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"POST /drive/sharings", "PATCH /drive/sharings/sharing-1/password", "PATCH /drive/sharings/sharing-1"}
+		if strings.Join(requests, ", ") != strings.Join(want, ", ") {
+			t.Errorf("expected requests %v, got %v", want, requests)
+		}
+		if link.EncryptedPassword == "" || link.ExpirationAt != "2030-01-02T03:04:05Z" || link.MaxDownloads != 5 {
+			t.Errorf("expected the restrictions on the returned link, got %+v", link.Sharing)
+		}
+	})
+}
+
+func TestUpdateShare(t *testing.T) {
+	code := strings.Repeat("ef", 32)
+	encryptedCode, _ := crypto.EncryptTextGCM(code, testMnemonic)
+	password, empty := "s3cret", ""
+	expires, never := time.Date(2031, 6, 1, 0, 0, 0, 0, time.UTC), time.Time{}
+	limit, unlimited := 10, 0
+
+	testCases := []struct {
+		name   string
+		update ShareUpdate
+		want   map[string]string // Request path to its JSON body
+	}{
+		{
+			name:   "set password",
+			update: ShareUpdate{Password: &password},
+			want:   map[string]string{"PATCH /drive/sharings/sharing-1/password": ""},
+		},
+		{
+			name:   "remove password",
+			update: ShareUpdate{Password: &empty},
+			want:   map[string]string{"DELETE /drive/sharings/sharing-1/password": ""},
+		},
+		{
+			name:   "set expiry and limit",
+			update: ShareUpdate{ExpiresAt: &expires, MaxDownloads: &limit},
+			want:   map[string]string{"PATCH /drive/sharings/sharing-1": `{"expirationAt":"2031-06-01T00:00:00Z","maxDownloads":10}`},
+		},
+		{
+			name:   "remove expiry and limit",
+			update: ShareUpdate{ExpiresAt: &never, MaxDownloads: &unlimited},
+			want:   map[string]string{"PATCH /drive/sharings/sharing-1": `{"expirationAt":null,"maxDownloads":null}`},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := map[string]string{}
+			mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]any
+				json.NewDecoder(r.Body).Decode(&body)
+				key := r.Method + " " + r.URL.Path
+				if encrypted, ok := body["encryptedPassword"].(string); ok {
+					if p, err := crypto.DecryptTextGCM(encrypted, code); err != nil || p != password {
+						t.Errorf("expected the password encrypted with the sharing code, got %q, %v", p, err)
+					}
+					body = nil
+				}
+				got[key] = ""
+				if body != nil {
+					b, _ := json.Marshal(body)
+					got[key] = string(b)
+				}
+			}))
+			defer mockServer.Close()
+
+			sharing := &Sharing{ID: "sharing-1", EncryptedCode: encryptedCode}
+			if err := UpdateShare(context.Background(), newTestConfig(mockServer.URL), sharing, tc.update); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("expected requests %v, got %v", tc.want, got)
+			}
+			for key, body := range tc.want {
+				if got[key] != body {
+					t.Errorf("%s: expected body %s, got %s", key, body, got[key])
+				}
+			}
+		})
+	}
+}
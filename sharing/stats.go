@@ -0,0 +1,49 @@
+package sharing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/internxt/rclone-adapter/config"
+)
+
+// ShareStats is the usage of a sharing's link. Counters the backend does
+// not track for the sharing are nil.
+type ShareStats struct {
+	SharingID    string
+	Views        *int      // Times the link was opened
+	Downloads    *int      // Times the items were downloaded through the link
+	LastAccessAt time.Time // Zero when unknown
+}
+
+type shareInfoResponse struct {
+	ID              string `json:"id"`
+	Views           *int   `json:"views"`
+	TimesDownloaded *int   `json:"timesDownloaded"`
+	LastAccessAt    string `json:"lastAccessAt"`
+}
+
+// GetShareStats returns how often the link of sharing, e.g. an item of
+// ListShares, has been viewed and downloaded, and when it was last used.
+func GetShareStats(ctx context.Context, cfg *config.Config, sharing *Sharing) (*ShareStats, error) {
+	var info shareInfoResponse
+	endpoint := cfg.Endpoints.Drive().Sharings().Info(string(sharing.ItemType), sharing.ItemID)
+	if err := doJSON(ctx, cfg, http.MethodGet, endpoint, "get sharing info", nil, &info); err != nil {
+		return nil, err
+	}
+
+	stats := &ShareStats{SharingID: info.ID, Views: info.Views, Downloads: info.TimesDownloaded}
+	if stats.SharingID == "" {
+		stats.SharingID = sharing.ID
+	}
+	if info.LastAccessAt != "" {
+		t, err := time.Parse(time.RFC3339, info.LastAccessAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse last access time of sharing %s: %w", stats.SharingID, err)
+		}
+		stats.LastAccessAt = t
+	}
+	return stats, nil
+}
@@ -0,0 +1,73 @@
+package sharing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetShareStats(t *testing.T) {
+	testCases := []struct {
+		name      string
+		response  string
+		views     int // -1 when not tracked
+		downloads int
+		lastSeen  time.Time
+		wantErr   string
+	}{
+		{
+			name:      "tracked",
+			response:  `{"id":"share-1","views":7,"timesDownloaded":3,"lastAccessAt":"2026-03-01T10:00:00Z"}`,
+			views:     7,
+			downloads: 3,
+			lastSeen:  time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "downloads only",
+			response:  `{"timesDownloaded":0}`,
+			views:     -1,
+			downloads: 0,
+		},
+		{
+			name:     "invalid time",
+			response: `{"lastAccessAt":"yesterday"}`,
+			wantErr:  "failed to parse last access time of sharing share-1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet || r.URL.Path != "/drive/sharings/file/file-uuid/info" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+				w.Write([]byte(tc.response))
+			}))
+			defer mockServer.Close()
+
+			sharing := &Sharing{ID: "share-1", ItemType: ItemFile, ItemID: "file-uuid"}
+			stats, err := GetShareStats(context.Background(), newTestConfig(mockServer.URL), sharing)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if stats.SharingID != "share-1" || !stats.LastAccessAt.Equal(tc.lastSeen) {
+				t.Errorf("unexpected stats %+v", stats)
+			}
+			if (tc.views < 0) != (stats.Views == nil) || (stats.Views != nil && *stats.Views != tc.views) {
+				t.Errorf("expected %d views, got %v", tc.views, stats.Views)
+			}
+			if stats.Downloads == nil || *stats.Downloads != tc.downloads {
+				t.Errorf("expected %d downloads, got %v", tc.downloads, stats.Downloads)
+			}
+		})
+	}
+}
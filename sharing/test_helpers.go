@@ -0,0 +1,28 @@
+package sharing
+
+import (
+	"github.com/internxt/rclone-adapter/config"
+	"github.com/internxt/rclone-adapter/endpoints"
+)
+
+const testMnemonic = "abandon ability able about above absent absorb abstract absurd abuse access accident"
+
+// newTestConfig creates a test config with the given mock server URL.
+// The HTTPClient is properly configured with the centralized header transport.
+func newTestConfig(mockServerURL string) *config.Config {
+	cfg := &config.Config{
+		Token:     "test-token",
+		Mnemonic:  config.NewSecureBytes([]byte(testMnemonic)),
+		Endpoints: endpoints.NewConfig(mockServerURL),
+	}
+	cfg.ApplyDefaults()
+	return cfg
+}
+
+// newPublicConfig creates a test config without an account, as used to open
+// public links.
+func newPublicConfig(mockServerURL string) *config.Config {
+	cfg := &config.Config{Endpoints: endpoints.NewConfig(mockServerURL)}
+	cfg.ApplyDefaults()
+	return cfg
+}
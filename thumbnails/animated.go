@@ -0,0 +1,83 @@
+package thumbnails
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+// decode decodes imageData, using only the first frame of animated images.
+// GIF decoding already stops after the first frame; animated WebP, which
+// golang.org/x/image/webp rejects, is reduced to its first frame first.
+func decode(imageData []byte) (image.Image, string, error) {
+	if frame, ok := firstWebPFrame(imageData); ok {
+		imageData = frame
+	}
+	return image.Decode(bytes.NewReader(imageData))
+}
+
+// WebP container constants, see https://developers.google.com/speed/webp/docs/riff_container
+const (
+	webpHeaderSize     = 12 // "RIFF" size "WEBP"
+	webpChunkHeader    = 8  // FourCC size
+	webpVP8XSize       = 10
+	webpANMFHeaderSize = 16
+	webpAnimationFlag  = 1 << 1
+	webpAlphaFlag      = 1 << 4
+)
+
+// firstWebPFrame returns a still WebP image made of the first frame of an
+// animated WebP, or false if data is not an animated WebP.
+func firstWebPFrame(data []byte) ([]byte, bool) {
+	if len(data) < webpHeaderSize || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, false
+	}
+
+	animated := false
+	for rest := data[webpHeaderSize:]; len(rest) >= webpChunkHeader; {
+		id, size := string(rest[0:4]), int(binary.LittleEndian.Uint32(rest[4:8]))
+		if size < 0 || webpChunkHeader+size > len(rest) {
+			return nil, false
+		}
+		payload := rest[webpChunkHeader : webpChunkHeader+size]
+
+		switch id {
+		case "VP8X":
+			animated = len(payload) == webpVP8XSize && payload[0]&webpAnimationFlag != 0
+		case "ANMF":
+			if !animated || len(payload) < webpANMFHeaderSize {
+				return nil, false
+			}
+			return stillWebP(payload[6:12], payload[webpANMFHeaderSize:]), true
+		}
+
+		// Chunks are padded to an even size.
+		next := webpChunkHeader + size + size&1
+		if next > len(rest) {
+			break
+		}
+		rest = rest[next:]
+	}
+	return nil, false
+}
+
+// stillWebP wraps the ALPH/VP8/VP8L chunks of a frame of the given
+// (width-1, height-1) dimensions, 24-bit little endian each, in an extended
+// WebP container.
+func stillWebP(dims, frameChunks []byte) []byte {
+	vp8x := make([]byte, webpVP8XSize)
+	if bytes.HasPrefix(frameChunks, []byte("ALPH")) {
+		vp8x[0] = webpAlphaFlag
+	}
+	copy(vp8x[4:], dims)
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(4+webpChunkHeader+webpVP8XSize+len(frameChunks)))
+	buf.WriteString("WEBP")
+	buf.WriteString("VP8X")
+	binary.Write(&buf, binary.LittleEndian, uint32(webpVP8XSize))
+	buf.Write(vp8x)
+	buf.Write(frameChunks)
+	return buf.Bytes()
+}
@@ -0,0 +1,106 @@
+package thumbnails
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+)
+
+// losslessWebP is a 75x100 still WebP (golang.org/x/image testdata
+// gopher-doc.1bpp.lossless.webp).
+const losslessWebP = "" +
+	"UklGRrIBAABXRUJQVlA4TKUBAAAvSsAYAA8w//M///MfeJAkbXvaSG7m8Q3GfYSBJekwQztm/IcZ" +
+	"lgwnmWImn2BK7aFmBtnVir6q//8VOkFE/xm4baTIu8c48ArEo6+B3zFKYln3pqClSCKX0begFTAX" +
+	"FOLXHSyF8cCNcZEG4OywuA4KVVfJCiArU7GAgJI8+lJP/OKMT/fBAjevg1cYB7YVkFuWga2lyPi5" +
+	"I0HFy5YTpWIHg0RZpkniRVW9odHAKOwosWuOGdxIyn2OvaCDvhg/we6TwadPBPbqBV58MsLmMJ8y" +
+	"ZnOWk8SRz4N+QoyPL+MnamzMvcE1rHNEr91F9GKZPVUcS9w7PhhH36suB9qPeYb/oLk6cuTiJ0wO" +
+	"K3m5h1cKjW6EVZCYMK7dxcKCBdgP9HkKr9gkAO2P8GKZGWVdIAatQa+1IDpt6qyorVwdy01xdW8J" +
+	"kfk6xjEXmVQQ+HQdFr6OKhIN34dXWq0+0qr6EJSCeeVLH9+gvGTLyqM65PQ44ihzlTXxQKjKbAvs" +
+	"hXgir7Lil9w4L2bvMycmjQcqXaMCO6BlY28i+FOLzbfI1vEqxAhotocAAA=="
+
+// animatedWebP builds an animated WebP whose frames all use the VP8L chunk of
+// losslessWebP.
+func animatedWebP(t *testing.T, frames int) []byte {
+	still, err := base64.StdEncoding.DecodeString(losslessWebP)
+	if err != nil {
+		t.Fatalf("failed to decode test image: %v", err)
+	}
+	vp8l := still[webpHeaderSize:]
+
+	chunk := func(id string, payload []byte) []byte {
+		out := append([]byte(id), binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))...)
+		out = append(out, payload...)
+		if len(payload)%2 == 1 {
+			out = append(out, 0)
+		}
+		return out
+	}
+	dims := []byte{74, 0, 0, 99, 0, 0} // width-1, height-1
+
+	body := []byte("WEBP")
+	body = append(body, chunk("VP8X", append([]byte{webpAnimationFlag, 0, 0, 0}, dims...))...)
+	body = append(body, chunk("ANIM", make([]byte, 6))...)
+	for range frames {
+		header := append(append(make([]byte, 6), dims...), 100, 0, 0, 0)
+		body = append(body, chunk("ANMF", append(header, vp8l...))...)
+	}
+	return append(append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...), body...)
+}
+
+func TestGenerateAnimatedWebP(t *testing.T) {
+	data := animatedWebP(t, 3)
+
+	if got := DetectFormat(data); got != "webp" {
+		t.Errorf("DetectFormat() = %q, want webp", got)
+	}
+	thumb, _, err := Generate(data, DefaultConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatalf("failed to decode thumbnail: %v", err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 75, 100) {
+		t.Errorf("expected 75x100 first frame, got %v", img.Bounds())
+	}
+
+	still, _ := base64.StdEncoding.DecodeString(losslessWebP)
+	if _, ok := firstWebPFrame(still); ok {
+		t.Error("expected still WebP to be decoded as is")
+	}
+}
+
+func TestGenerateAnimatedGIF(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	anim := &gif.GIF{}
+	for i := range 2 {
+		frame := image.NewPaletted(image.Rect(0, 0, 20, 10), palette)
+		for p := range frame.Pix {
+			frame.Pix[p] = uint8(i)
+		}
+		anim.Image = append(anim.Image, frame)
+		anim.Delay = append(anim.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		t.Fatalf("failed to encode test gif: %v", err)
+	}
+
+	thumb, _, err := Generate(buf.Bytes(), DefaultConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(thumb))
+	if err != nil {
+		t.Fatalf("failed to decode thumbnail: %v", err)
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r != 0 {
+		t.Error("expected the first (black) frame")
+	}
+}
@@ -0,0 +1,53 @@
+package thumbnails
+
+import (
+	"context"
+	"sync"
+)
+
+// BatchItem is an image to generate a thumbnail for with GenerateBatch.
+type BatchItem struct {
+	ID     string  // Caller's identifier, e.g. the file UUID
+	Data   []byte  // Source image
+	Config *Config // nil uses DefaultConfig
+}
+
+// BatchResult is the outcome of one BatchItem.
+type BatchResult struct {
+	ID   string
+	Data []byte
+	Size int64
+	Err  error
+}
+
+// GenerateBatch generates thumbnails for items using up to workers goroutines
+// (at least one) and returns one result per item, in the same order. Once ctx
+// is done, items not yet started fail with ctx's error.
+func GenerateBatch(ctx context.Context, items []BatchItem, workers int) []BatchResult {
+	results := make([]BatchResult, len(items))
+	next := make(chan int)
+
+	var wg sync.WaitGroup
+	for range min(max(workers, 1), len(items)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i].ID = items[i].ID
+				if err := ctx.Err(); err != nil {
+					results[i].Err = err
+					continue
+				}
+				results[i].Data, results[i].Size, results[i].Err = Generate(items[i].Data, items[i].Config)
+			}
+		}()
+	}
+
+	for i := range items {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,50 @@
+package thumbnails
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestGenerateBatch(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 600, 400))); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+
+	items := []BatchItem{
+		{ID: "a", Data: buf.Bytes()},
+		{ID: "b", Data: []byte("not an image")},
+		{ID: "c", Data: buf.Bytes(), Config: &Config{MaxWidth: 60, MaxHeight: 60}},
+	}
+
+	results := GenerateBatch(context.Background(), items, 2)
+	if len(results) != len(items) {
+		t.Fatalf("expected %d results, got %d", len(items), len(results))
+	}
+	for i, r := range results {
+		if r.ID != items[i].ID {
+			t.Errorf("result %d: expected ID %s, got %s", i, items[i].ID, r.ID)
+		}
+	}
+	if results[0].Err != nil || results[0].Size == 0 {
+		t.Errorf("expected thumbnail for a, got %+v", results[0].Err)
+	}
+	if results[1].Err == nil {
+		t.Error("expected error for invalid image b")
+	}
+	if img, err := png.Decode(bytes.NewReader(results[2].Data)); err != nil || img.Bounds().Dx() != 60 {
+		t.Errorf("expected 60px wide thumbnail for c, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, r := range GenerateBatch(ctx, items, 0) {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("%s: expected context.Canceled, got %v", r.ID, r.Err)
+		}
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"image"
 	"image/draw"
 	_ "image/gif"
-	_ "image/jpeg"
+	"image/jpeg"
 	"image/png"
 	"strings"
 
@@ -32,7 +32,18 @@ func IsSupportedFormat(ext string) bool {
 	return supportedFormats[normalized]
 }
 
+// DetectFormat returns the image format of data as a file extension, e.g.
+// "png", or "" if it is not an image format this package decodes.
+func DetectFormat(data []byte) string {
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+	return format
+}
+
 // Generate creates a thumbnail from the provided image data.
+// Only the first frame of animated GIF and WebP images is decoded.
 // It resizes the image to fit within maxWidth x maxHeight while preserving aspect ratio,
 // and returns the thumbnail as PNG bytes.
 func Generate(imageData []byte, cfg *Config) ([]byte, int64, error) {
@@ -40,7 +51,7 @@ func Generate(imageData []byte, cfg *Config) ([]byte, int64, error) {
 		cfg = DefaultConfig()
 	}
 
-	img, _, err := image.Decode(bytes.NewReader(imageData))
+	img, _, err := decode(imageData)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to decode image: %w", err)
 	}
@@ -56,6 +67,24 @@ func Generate(imageData []byte, cfg *Config) ([]byte, int64, error) {
 	return thumbnailBytes, int64(len(thumbnailBytes)), nil
 }
 
+// ToJPEG converts an image in any supported format to JPEG at the given
+// quality (1-100, 0 uses jpeg.DefaultQuality), e.g. to preview HEIC photos.
+func ToJPEG(imageData []byte, quality int) ([]byte, error) {
+	img, _, err := decode(imageData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	if quality <= 0 {
+		quality = jpeg.DefaultQuality
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: min(quality, 100)}); err != nil {
+		return nil, fmt.Errorf("failed to encode jpeg: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
 // fit resizes src to fit within maxWidth x maxHeight, preserving aspect ratio,
 // using high-quality Catmull-Rom interpolation (similar to Lanczos).
 func fit(src image.Image, maxWidth, maxHeight int) image.Image {
@@ -23,7 +23,7 @@ func TestIsSupportedFormat(t *testing.T) {
 		{"gif", true},
 		{"tiff", true},
 		{"tif", true},
-		{"pdf", false},
+		{"pdf", pdfSupported},
 		{"txt", false},
 		{"mp4", false},
 		{"", false},
@@ -131,3 +131,36 @@ func TestDefaultConfig(t *testing.T) {
 		t.Errorf("DefaultConfig().Format = %q, want \"png\"", cfg.Format)
 	}
 }
+
+func TestToJPEG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 20))); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+
+	out, err := ToJPEG(buf.Bytes(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(out))
+	if err != nil || format != "jpeg" || cfg.Width != 40 || cfg.Height != 20 {
+		t.Errorf("expected 40x20 jpeg, got %s %dx%d: %v", format, cfg.Width, cfg.Height, err)
+	}
+
+	if _, err := ToJPEG([]byte("not an image"), 90); err == nil {
+		t.Error("expected error for invalid image data")
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	if got := DetectFormat(buf.Bytes()); got != "png" {
+		t.Errorf("DetectFormat(png) = %q, want png", got)
+	}
+	if got := DetectFormat([]byte("plain text")); got != "" {
+		t.Errorf("DetectFormat(text) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,74 @@
+//go:build libheif
+
+package thumbnails
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// Built with the libheif tag, image.Decode (and so Generate and ToJPEG)
+// decodes HEIC/HEIF and AVIF images with libheif's heif-convert, which must be
+// on PATH.
+func init() {
+	for _, brand := range []string{"heic", "heix", "hevc", "heim", "heis", "mif1", "msf1", "avif", "avis"} {
+		image.RegisterFormat("heif", "????ftyp"+brand, decodeHEIF, decodeHEIFConfig)
+	}
+	for _, ext := range []string{"heic", "heif", "avif"} {
+		supportedFormats[ext] = true
+	}
+}
+
+func decodeHEIF(r io.Reader) (image.Image, error) {
+	dir, err := os.MkdirTemp("", "heif-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// heif-convert only works on files.
+	in, out := filepath.Join(dir, "in.heif"), filepath.Join(dir, "out.png")
+	src, err := os.Create(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary image: %w", err)
+	}
+	_, err = io.Copy(src, r)
+	if closeErr := src.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write temporary image: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("heif-convert", in, out)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to decode heif image: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open decoded image: %w", err)
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func decodeHEIFConfig(r io.Reader) (image.Config, error) {
+	img, err := decodeHEIF(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{
+		ColorModel: img.ColorModel(),
+		Width:      img.Bounds().Dx(),
+		Height:     img.Bounds().Dy(),
+	}, nil
+}
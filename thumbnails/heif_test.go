@@ -0,0 +1,24 @@
+//go:build libheif
+
+package thumbnails
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestHEIFFormats(t *testing.T) {
+	for _, ext := range []string{"heic", "HEIF", ".avif"} {
+		if !IsSupportedFormat(ext) {
+			t.Errorf("expected %s to be supported", ext)
+		}
+	}
+
+	// A bare ftyp box is recognized as HEIF and handed to heif-convert,
+	// which fails on it, rather than being rejected as an unknown format.
+	header := []byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic")
+	if _, _, err := image.Decode(bytes.NewReader(header)); err == image.ErrFormat {
+		t.Error("expected HEIC header to be recognized")
+	}
+}
@@ -0,0 +1,5 @@
+//go:build !pdftoppm
+
+package thumbnails
+
+const pdfSupported = false
@@ -0,0 +1,49 @@
+//go:build pdftoppm
+
+package thumbnails
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os/exec"
+	"strconv"
+)
+
+// pdfRenderWidth is the width the first page is rasterized at before fit
+// scales it down to the thumbnail size.
+const pdfRenderWidth = 1024
+
+// Built with the pdftoppm tag, image.Decode (and so Generate) renders the
+// first page of PDF documents with poppler's pdftoppm, which must be on PATH.
+func init() {
+	image.RegisterFormat("pdf", "%PDF-", decodePDF, decodePDFConfig)
+	supportedFormats["pdf"] = true
+}
+
+func decodePDF(r io.Reader) (image.Image, error) {
+	cmd := exec.Command("pdftoppm", "-png", "-f", "1", "-l", "1", "-singlefile",
+		"-scale-to", strconv.Itoa(pdfRenderWidth), "-")
+	cmd.Stdin = r
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to render pdf: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return png.Decode(&stdout)
+}
+
+func decodePDFConfig(r io.Reader) (image.Config, error) {
+	img, err := decodePDF(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{
+		ColorModel: img.ColorModel(),
+		Width:      img.Bounds().Dx(),
+		Height:     img.Bounds().Dy(),
+	}, nil
+}
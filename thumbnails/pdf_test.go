@@ -0,0 +1,36 @@
+//go:build pdftoppm
+
+package thumbnails
+
+import (
+	"os/exec"
+	"testing"
+)
+
+const pdfSupported = true
+
+// minimalPDF is a one-page, 200x100pt PDF.
+const minimalPDF = `%PDF-1.4
+1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj
+2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj
+3 0 obj << /Type /Page /Parent 2 0 R /MediaBox [0 0 200 100] >> endobj
+trailer << /Root 1 0 R >>
+%%EOF
+`
+
+func TestGeneratePDF(t *testing.T) {
+	if _, err := exec.LookPath("pdftoppm"); err != nil {
+		t.Skip("pdftoppm not installed")
+	}
+	if !IsSupportedFormat("pdf") {
+		t.Fatal("expected pdf to be supported")
+	}
+
+	thumb, size, err := Generate([]byte(minimalPDF), DefaultConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if size == 0 || int64(len(thumb)) != size {
+		t.Errorf("unexpected thumbnail size %d", size)
+	}
+}
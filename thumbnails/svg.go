@@ -0,0 +1,51 @@
+//go:build rsvg
+
+package thumbnails
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os/exec"
+	"strconv"
+)
+
+// svgRenderSize bounds the size SVG images are rasterized at before fit
+// scales them down to the thumbnail size.
+const svgRenderSize = 1024
+
+// Built with the rsvg tag, image.Decode (and so Generate) rasterizes SVG
+// images with librsvg's rsvg-convert, which must be on PATH.
+func init() {
+	for _, magic := range []string{"<svg", "<?xml"} {
+		image.RegisterFormat("svg", magic, decodeSVG, decodeSVGConfig)
+	}
+	supportedFormats["svg"] = true
+}
+
+func decodeSVG(r io.Reader) (image.Image, error) {
+	size := strconv.Itoa(svgRenderSize)
+	cmd := exec.Command("rsvg-convert", "--format", "png", "--keep-aspect-ratio", "--width", size, "--height", size)
+	cmd.Stdin = r
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to rasterize svg: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return png.Decode(&stdout)
+}
+
+func decodeSVGConfig(r io.Reader) (image.Config, error) {
+	img, err := decodeSVG(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+	return image.Config{
+		ColorModel: img.ColorModel(),
+		Width:      img.Bounds().Dx(),
+		Height:     img.Bounds().Dy(),
+	}, nil
+}
@@ -0,0 +1,26 @@
+//go:build rsvg
+
+package thumbnails
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestGenerateSVG(t *testing.T) {
+	if !IsSupportedFormat("svg") {
+		t.Fatal("expected svg to be supported")
+	}
+	if _, err := exec.LookPath("rsvg-convert"); err != nil {
+		t.Skip("rsvg-convert not installed")
+	}
+
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" width="40" height="20"><rect width="40" height="20" fill="red"/></svg>`
+	thumb, size, err := Generate([]byte(svg), DefaultConfig())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if size == 0 || int64(len(thumb)) != size {
+		t.Errorf("unexpected thumbnail size %d", size)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
 )
 
 // GenerateAndPrepare generates a thumbnail and prepares it for upload.
@@ -43,6 +44,7 @@ type ThumbnailUploadTask struct {
 	FileUUID     string
 	FileType     string
 	OriginalData []byte
+	Logger       *slog.Logger // Receives upload failures; nil discards them
 }
 
 // UploadFunc is a function type that handles the actual upload of a thumbnail.
@@ -57,7 +59,9 @@ func ProcessAsync(task *ThumbnailUploadTask, uploadFunc UploadFunc) {
 		bgCtx := context.Background()
 
 		if err := uploadFunc(bgCtx, task); err != nil {
-			fmt.Printf("[WARN] Thumbnail generation failed for %s: %v\n", task.FileUUID, err)
+			if task.Logger != nil {
+				task.Logger.Warn("thumbnail generation failed", "file_uuid", task.FileUUID, "error", err)
+			}
 		}
 	}()
 }
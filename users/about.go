@@ -0,0 +1,48 @@
+package users
+
+import (
+	"context"
+	"sync"
+
+	"github.com/internxt/rclone-adapter/config"
+)
+
+// AboutInfo summarizes the account's storage in bytes.
+type AboutInfo struct {
+	Used  int64
+	Free  int64 // Total - Used, never negative
+	Total int64
+}
+
+// About fetches usage and limit concurrently and combines them.
+func About(ctx context.Context, cfg *config.Config) (*AboutInfo, error) {
+	var (
+		wg       sync.WaitGroup
+		usage    *UsageResponse
+		limit    *LimitResponse
+		usageErr error
+		limitErr error
+	)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		usage, usageErr = GetUsage(ctx, cfg)
+	}()
+	go func() {
+		defer wg.Done()
+		limit, limitErr = GetLimit(ctx, cfg)
+	}()
+	wg.Wait()
+
+	if usageErr != nil {
+		return nil, usageErr
+	}
+	if limitErr != nil {
+		return nil, limitErr
+	}
+	return &AboutInfo{
+		Used:  usage.Drive,
+		Free:  max(limit.MaxSpaceBytes-usage.Drive, 0),
+		Total: limit.MaxSpaceBytes,
+	}, nil
+}
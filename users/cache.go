@@ -0,0 +1,117 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/internxt/rclone-adapter/config"
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
+)
+
+// DefaultQuotaCacheTTL is used by NewQuotaCache for non-positive TTLs.
+const DefaultQuotaCacheTTL = time.Minute
+
+// QuotaCache serves GetUsage and GetLimit results for TTL, so that callers
+// asking for the quota before every operation don't hit the API each time.
+// Concurrent callers share a single request. It is safe for concurrent use.
+type QuotaCache struct {
+	cfg *config.Config
+	TTL time.Duration
+
+	mu           sync.Mutex
+	usage        *UsageResponse
+	usageExpires time.Time
+	limit        *LimitResponse
+	limitExpires time.Time
+}
+
+// NewQuotaCache creates a QuotaCache for cfg's account.
+func NewQuotaCache(cfg *config.Config, ttl time.Duration) *QuotaCache {
+	if ttl <= 0 {
+		ttl = DefaultQuotaCacheTTL
+	}
+	return &QuotaCache{cfg: cfg, TTL: ttl}
+}
+
+// Usage returns the cached usage, fetching it with GetUsage when expired.
+func (c *QuotaCache) Usage(ctx context.Context) (*UsageResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.usage == nil || time.Now().After(c.usageExpires) {
+		usage, err := GetUsage(ctx, c.cfg)
+		if err != nil {
+			return nil, err
+		}
+		c.usage, c.usageExpires = usage, time.Now().Add(c.TTL)
+	}
+	usage := *c.usage
+	return &usage, nil
+}
+
+// Limit returns the cached limit, fetching it with GetLimit when expired.
+func (c *QuotaCache) Limit(ctx context.Context) (*LimitResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.limit == nil || time.Now().After(c.limitExpires) {
+		limit, err := GetLimit(ctx, c.cfg)
+		if err != nil {
+			return nil, err
+		}
+		c.limit, c.limitExpires = limit, time.Now().Add(c.TTL)
+	}
+	limit := *c.limit
+	return &limit, nil
+}
+
+// Refresh drops the cached values, so the next calls fetch them again, e.g.
+// after uploading or deleting files.
+func (c *QuotaCache) Refresh() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.usage, c.limit = nil, nil
+}
+
+// CheckUpload fails with a *sdkerrors.QuotaExceededError when an upload of
+// size bytes does not fit in the space left, going by the cached usage and
+// limit. A limit of 0 is taken as unlimited.
+func (c *QuotaCache) CheckUpload(ctx context.Context, size int64) error {
+	limit, err := c.Limit(ctx)
+	if err != nil {
+		return err
+	}
+	if limit.MaxSpaceBytes <= 0 {
+		return nil
+	}
+	usage, err := c.Usage(ctx)
+	if err != nil {
+		return err
+	}
+	if available := max(limit.MaxSpaceBytes-usage.Drive, 0); size > available {
+		return &sdkerrors.QuotaExceededError{Size: size, Available: available}
+	}
+	return nil
+}
+
+// ApplyQuotaCheck sets cfg.QuotaCheck to check uploads against cache, so
+// uploads that cannot fit fail before transferring any data rather than
+// when the file is created. A nil cache uses NewQuotaCache(cfg, 0). When
+// the quota cannot be fetched the upload goes ahead; the server still
+// enforces it.
+func ApplyQuotaCheck(cfg *config.Config, cache *QuotaCache) {
+	if cache == nil {
+		cache = NewQuotaCache(cfg, 0)
+	}
+	cfg.QuotaCheck = func(ctx context.Context, size int64) error {
+		err := cache.CheckUpload(ctx, size)
+		var quotaErr *sdkerrors.QuotaExceededError
+		if err != nil && !errors.As(err, &quotaErr) {
+			cfg.Log().WarnContext(ctx, "failed to check storage quota before upload", "size", size, "error", err)
+			return nil
+		}
+		return err
+	}
+}
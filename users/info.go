@@ -0,0 +1,52 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/internxt/rclone-adapter/config"
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
+)
+
+// UserInfo is the account profile returned by GetUserInfo.
+type UserInfo struct {
+	Email         string `json:"email"`
+	UUID          string `json:"uuid"`
+	Name          string `json:"name"`
+	Lastname      string `json:"lastname"`
+	RootFolderID  string `json:"rootFolderId"`
+	Bucket        string `json:"bucket"`
+	EmailVerified bool   `json:"emailVerified"`
+}
+
+// GetUserInfo calls GET {DRIVE_API_URL}/users/cli/refresh and returns the account profile,
+// including the root folder and bucket, without running the full auth flow.
+// The renewed token the endpoint also returns is discarded; cfg is not modified.
+func GetUserInfo(ctx context.Context, cfg *config.Config) (*UserInfo, error) {
+	url := cfg.Endpoints.Drive().Users().Refresh()
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create get user info request: %w", err)
+	}
+	cfg.AuthorizeRequest(req)
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get user info request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, sdkerrors.NewHTTPError(resp, "get user info")
+	}
+
+	var wrapper struct {
+		User UserInfo `json:"user"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&wrapper); err != nil {
+		return nil, fmt.Errorf("failed to decode get user info response: %w", err)
+	}
+	return &wrapper.User, nil
+}
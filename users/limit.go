@@ -15,14 +15,22 @@ type LimitResponse struct {
 }
 
 // GetLimit calls {DRIVE_API_URL}/users/limit and returns the maximum available storage of the account.
+// When cfg.WorkspaceID is set, the workspace's space limit is returned instead.
 func GetLimit(ctx context.Context, cfg *config.Config) (*LimitResponse, error) {
+	if cfg.WorkspaceID != "" {
+		ws, err := GetWorkspaceUsage(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &LimitResponse{MaxSpaceBytes: ws.SpaceLimit}, nil
+	}
 	url := cfg.Endpoints.Drive().Users().Limit()
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create get limit request: %w", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	cfg.AuthorizeRequest(req)
 	resp, err := cfg.HTTPClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute get limit request: %w", err)
@@ -0,0 +1,97 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/internxt/rclone-adapter/config"
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
+)
+
+// Plan describes the account's subscription tier and the limits it imposes.
+// Zero limits mean the tier sets none.
+type Plan struct {
+	Tier          string     // Tier label, e.g. "Premium"
+	RenewalDate   *time.Time // nil for free and lifetime plans
+	MaxSpaceBytes int64
+	MaxUploadSize int64 // Largest single file, in bytes
+	MaxItems      int64 // Most files and folders the drive may hold
+}
+
+// tierResponse is the body of GET /users/tier.
+type tierResponse struct {
+	Label              string     `json:"label"`
+	RenewalDate        *time.Time `json:"renewalDate"`
+	FeaturesPerService struct {
+		Drive struct {
+			MaxSpaceBytes     int64 `json:"maxSpaceBytes"`
+			MaxUploadFileSize int64 `json:"maxUploadFileSize"`
+			MaxItems          int64 `json:"maxItems"`
+		} `json:"drive"`
+	} `json:"featuresPerService"`
+}
+
+// GetPlan calls GET {DRIVE_API_URL}/users/tier and returns the account's subscription tier,
+// renewal date and limits, so uploads can be validated before they start.
+func GetPlan(ctx context.Context, cfg *config.Config) (*Plan, error) {
+	url := cfg.Endpoints.Drive().Users().Tier()
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create get plan request: %w", err)
+	}
+	cfg.AuthorizeRequest(req)
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute get plan request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, sdkerrors.NewHTTPError(resp, "get plan")
+	}
+
+	var tier tierResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tier); err != nil {
+		return nil, fmt.Errorf("failed to decode get plan response: %w", err)
+	}
+
+	drive := tier.FeaturesPerService.Drive
+	return &Plan{
+		Tier:          tier.Label,
+		RenewalDate:   tier.RenewalDate,
+		MaxSpaceBytes: drive.MaxSpaceBytes,
+		MaxUploadSize: drive.MaxUploadFileSize,
+		MaxItems:      drive.MaxItems,
+	}, nil
+}
+
+// UploadLimits are the limits on a single upload.
+type UploadLimits struct {
+	MaxFileSize int64 // Largest single file, in bytes (0 = no limit)
+	MaxParts    int   // Most parts a multipart upload may have
+}
+
+// GetUploadLimits returns the largest file the account's plan accepts and the
+// multipart part-count limit.
+func GetUploadLimits(ctx context.Context, cfg *config.Config) (*UploadLimits, error) {
+	plan, err := GetPlan(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &UploadLimits{MaxFileSize: plan.MaxUploadSize, MaxParts: config.MaxMultipartParts}, nil
+}
+
+// ApplyUploadLimits sets cfg.MaxUploadSize from the account's plan, so
+// buckets.UploadFileStreamAuto rejects oversized files before transferring them.
+func ApplyUploadLimits(ctx context.Context, cfg *config.Config) error {
+	limits, err := GetUploadLimits(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	cfg.MaxUploadSize = limits.MaxFileSize
+	return nil
+}
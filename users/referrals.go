@@ -0,0 +1,63 @@
+package users
+
+import (
+	"context"
+
+	"github.com/internxt/rclone-adapter/config"
+)
+
+// Referral is one step of the referrals program, such as inviting a friend or
+// installing the desktop app, and the storage it earns.
+type Referral struct {
+	Key            string `json:"key"`
+	Type           string `json:"type"`
+	Credit         int64  `json:"credit"` // Storage earned on completion, in bytes
+	Steps          int    `json:"steps"`
+	CompletedSteps int    `json:"completedSteps"`
+	IsCompleted    bool   `json:"isCompleted"`
+}
+
+// ReferralInfo is the account's referral credit and progress.
+type ReferralInfo struct {
+	Credit              int64 // Account credit as reported with the profile
+	HasReferralsProgram bool
+	Referrals           []Referral
+}
+
+// EarnedStorage returns the bytes of storage earned by completed referrals.
+func (r *ReferralInfo) EarnedStorage() int64 {
+	var total int64
+	for _, ref := range r.Referrals {
+		if ref.IsCompleted {
+			total += ref.Credit
+		}
+	}
+	return total
+}
+
+// GetReferralInfo reads the account credit from GET {DRIVE_API_URL}/users/cli/refresh
+// and, when the account takes part in the referrals program, its referrals from
+// GET {DRIVE_API_URL}/users/referrals.
+func GetReferralInfo(ctx context.Context, cfg *config.Config) (*ReferralInfo, error) {
+	var refresh struct {
+		User struct {
+			Credit              int64 `json:"credit"`
+			HasReferralsProgram bool  `json:"hasReferralsProgram"`
+		} `json:"user"`
+	}
+	if err := getJSON(ctx, cfg, cfg.Endpoints.Drive().Users().Refresh(), "get referral info", &refresh); err != nil {
+		return nil, err
+	}
+
+	info := &ReferralInfo{
+		Credit:              refresh.User.Credit,
+		HasReferralsProgram: refresh.User.HasReferralsProgram,
+	}
+	if !info.HasReferralsProgram {
+		return info, nil
+	}
+	if err := getJSON(ctx, cfg, cfg.Endpoints.Drive().Users().Referrals(), "get referrals", &info.Referrals); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
@@ -0,0 +1,44 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/internxt/rclone-adapter/auth"
+	"github.com/internxt/rclone-adapter/config"
+)
+
+// SecurityInfo is the account's credential status. A password change
+// invalidates stored tokens and re-encrypts the mnemonic, so automation can
+// compare LastPasswordChange against when its credentials were saved.
+type SecurityInfo struct {
+	TwoFactorEnabled   bool
+	LastPasswordChange *time.Time // nil if the password was never changed
+}
+
+// GetSecurityInfo returns whether two-factor authentication is enabled and when
+// the password was last changed. It reads the profile from
+// GET {DRIVE_API_URL}/users/cli/refresh and the 2FA status from the login
+// endpoint for the account's email.
+func GetSecurityInfo(ctx context.Context, cfg *config.Config) (*SecurityInfo, error) {
+	var refresh struct {
+		User struct {
+			Email                 string     `json:"email"`
+			LastPasswordChangedAt *time.Time `json:"lastPasswordChangedAt"`
+		} `json:"user"`
+	}
+	if err := getJSON(ctx, cfg, cfg.Endpoints.Drive().Users().Refresh(), "get security info", &refresh); err != nil {
+		return nil, err
+	}
+
+	login, err := auth.Login(ctx, cfg, refresh.User.Email)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get 2FA status: %w", err)
+	}
+
+	return &SecurityInfo{
+		TwoFactorEnabled:   login.TFA,
+		LastPasswordChange: refresh.User.LastPasswordChangedAt,
+	}, nil
+}
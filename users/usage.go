@@ -2,41 +2,60 @@ package users
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"net/http"
 
 	"github.com/internxt/rclone-adapter/config"
-	sdkerrors "github.com/internxt/rclone-adapter/errors"
 )
 
 type UsageResponse struct {
 	Drive int64 `json:"drive"`
 }
 
+// UsageBreakdown is the account's usage in bytes per product.
+type UsageBreakdown struct {
+	Drive   int64 `json:"drive"`
+	Backups int64 `json:"backups"`
+	Photos  int64 `json:"photos"`
+	Total   int64 `json:"total"` // As reported by the server, else the sum of the others
+}
+
 // GetUsage calls GET {DRIVE_API_URL}/users/usage and returns the account's current usage in bytes.
+// When cfg.WorkspaceID is set, the workspace's drive usage is returned instead.
 func GetUsage(ctx context.Context, cfg *config.Config) (*UsageResponse, error) {
-	url := cfg.Endpoints.Drive().Users().Usage()
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create get usage request: %w", err)
+	if cfg.WorkspaceID != "" {
+		ws, err := GetWorkspaceUsage(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &UsageResponse{Drive: ws.DriveUsage}, nil
 	}
-
-	req.Header.Set("Authorization", "Bearer "+cfg.Token)
-	resp, err := cfg.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute get usage request: %w", err)
+	var usage UsageResponse
+	if err := getUsage(ctx, cfg, &usage); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
+	return &usage, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, sdkerrors.NewHTTPError(resp, "get usage")
+// GetUsageBreakdown calls GET {DRIVE_API_URL}/users/usage and returns the account's usage
+// split between drive, backups and photos. When cfg.WorkspaceID is set, the workspace's
+// usage is returned instead.
+func GetUsageBreakdown(ctx context.Context, cfg *config.Config) (*UsageBreakdown, error) {
+	if cfg.WorkspaceID != "" {
+		ws, err := GetWorkspaceUsage(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &UsageBreakdown{Drive: ws.DriveUsage, Backups: ws.BackupsUsage, Total: ws.DriveUsage + ws.BackupsUsage}, nil
 	}
-
-	var usage UsageResponse
-	if err := json.NewDecoder(resp.Body).Decode(&usage); err != nil {
-		return nil, fmt.Errorf("failed to decode get usage response: %w", err)
+	var usage UsageBreakdown
+	if err := getUsage(ctx, cfg, &usage); err != nil {
+		return nil, err
+	}
+	if usage.Total == 0 {
+		usage.Total = usage.Drive + usage.Backups + usage.Photos
 	}
-
 	return &usage, nil
 }
+
+func getUsage(ctx context.Context, cfg *config.Config, dst any) error {
+	return getJSON(ctx, cfg, cfg.Endpoints.Drive().Users().Usage(), "get usage", dst)
+}
@@ -10,6 +10,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/internxt/rclone-adapter/config"
 	sdkerrors "github.com/internxt/rclone-adapter/errors"
 )
 
@@ -353,3 +354,442 @@ func TestGetUsage408ReturnsHTTPError(t *testing.T) {
 		t.Error("expected Temporary() = true for 408")
 	}
 }
+
+func TestGetUserInfo(t *testing.T) {
+	t.Run("successful retrieval", func(t *testing.T) {
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/drive/users/cli/refresh" {
+				t.Errorf("unexpected path %s", r.URL.Path)
+			}
+			w.Write([]byte(`{"user":{"email":"user@example.com","uuid":"user-uuid","name":"Ada","rootFolderId":"root-uuid","bucket":"bucket-id","emailVerified":true,"mnemonic":"secret"},"newToken":"new-token"}`))
+		}))
+		defer mockServer.Close()
+
+		cfg := newTestConfig(mockServer.URL)
+		info, err := GetUserInfo(context.Background(), cfg)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := UserInfo{Email: "user@example.com", UUID: "user-uuid", Name: "Ada", RootFolderID: "root-uuid", Bucket: "bucket-id", EmailVerified: true}
+		if *info != want {
+			t.Errorf("expected %+v, got %+v", want, *info)
+		}
+		if cfg.CurrentToken() != "test-token" {
+			t.Error("expected the token to be left unchanged")
+		}
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer mockServer.Close()
+
+		_, err := GetUserInfo(context.Background(), newTestConfig(mockServer.URL))
+		if !errors.Is(err, sdkerrors.ErrUnauthorized) {
+			t.Errorf("expected ErrUnauthorized, got %v", err)
+		}
+	})
+}
+
+func TestGetUsageBreakdown(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want UsageBreakdown
+	}{
+		{"server total", `{"drive":100,"backups":20,"photos":3,"total":130}`, UsageBreakdown{Drive: 100, Backups: 20, Photos: 3, Total: 130}},
+		{"computed total", `{"drive":100,"backups":20}`, UsageBreakdown{Drive: 100, Backups: 20, Total: 120}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !strings.HasSuffix(r.URL.Path, "/users/usage") {
+					t.Errorf("unexpected path %s", r.URL.Path)
+				}
+				w.Write([]byte(tt.body))
+			}))
+			defer mockServer.Close()
+
+			usage, err := GetUsageBreakdown(context.Background(), newTestConfig(mockServer.URL))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *usage != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, *usage)
+			}
+		})
+	}
+}
+
+func TestGetPlan(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/users/tier") {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"label":"Premium","renewalDate":"2026-01-31T00:00:00Z","featuresPerService":{"drive":{"maxSpaceBytes":2199023255552,"maxUploadFileSize":42949672960,"maxItems":100000}}}`))
+	}))
+	defer mockServer.Close()
+
+	plan, err := GetPlan(context.Background(), newTestConfig(mockServer.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plan.Tier != "Premium" || plan.MaxSpaceBytes != 2199023255552 || plan.MaxUploadSize != 42949672960 || plan.MaxItems != 100000 {
+		t.Errorf("unexpected plan %+v", plan)
+	}
+	if plan.RenewalDate == nil || !plan.RenewalDate.Equal(time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected renewal date %v", plan.RenewalDate)
+	}
+}
+
+func TestQuotaCache(t *testing.T) {
+	var usageCalls, limitCalls int
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/usage") {
+			usageCalls++
+			json.NewEncoder(w).Encode(UsageResponse{Drive: int64(usageCalls)})
+			return
+		}
+		limitCalls++
+		json.NewEncoder(w).Encode(LimitResponse{MaxSpaceBytes: 1000})
+	}))
+	defer mockServer.Close()
+
+	ctx := context.Background()
+	cache := NewQuotaCache(newTestConfig(mockServer.URL), time.Hour)
+
+	for range 3 {
+		usage, err := cache.Usage(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if usage.Drive != 1 {
+			t.Errorf("expected cached usage 1, got %d", usage.Drive)
+		}
+		if _, err := cache.Limit(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if usageCalls != 1 || limitCalls != 1 {
+		t.Errorf("expected one request each, got %d usage and %d limit", usageCalls, limitCalls)
+	}
+
+	cache.Refresh()
+	usage, err := cache.Usage(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if usage.Drive != 2 || usageCalls != 2 {
+		t.Errorf("expected Refresh to refetch usage, got %d after %d calls", usage.Drive, usageCalls)
+	}
+
+	cache.TTL = time.Nanosecond
+	cache.Refresh()
+	cache.Limit(ctx)
+	time.Sleep(time.Millisecond)
+	cache.Limit(ctx)
+	if limitCalls != 3 {
+		t.Errorf("expected expired limit to be refetched, got %d calls", limitCalls)
+	}
+}
+
+func TestQuotaCacheCheckUpload(t *testing.T) {
+	tests := []struct {
+		name      string
+		used      int64
+		limit     int64
+		size      int64
+		shortfall int64 // 0 if the upload fits
+	}{
+		{"fits", 300, 1000, 700, 0},
+		{"short", 300, 1000, 900, 200},
+		{"over quota", 1200, 1000, 10, 10},
+		{"no limit", 1200, 0, 5000, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/usage") {
+					json.NewEncoder(w).Encode(UsageResponse{Drive: tt.used})
+					return
+				}
+				json.NewEncoder(w).Encode(LimitResponse{MaxSpaceBytes: tt.limit})
+			}))
+			defer mockServer.Close()
+
+			cache := NewQuotaCache(newTestConfig(mockServer.URL), time.Hour)
+			err := cache.CheckUpload(context.Background(), tt.size)
+			if tt.shortfall == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var quotaErr *sdkerrors.QuotaExceededError
+			if !errors.As(err, &quotaErr) || !errors.Is(err, sdkerrors.ErrQuotaExceeded) {
+				t.Fatalf("expected QuotaExceededError, got %v", err)
+			}
+			if quotaErr.Shortfall() != tt.shortfall {
+				t.Errorf("expected shortfall %d, got %d", tt.shortfall, quotaErr.Shortfall())
+			}
+		})
+	}
+}
+
+func TestApplyQuotaCheck(t *testing.T) {
+	var failing bool
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/usage") {
+			json.NewEncoder(w).Encode(UsageResponse{Drive: 900})
+			return
+		}
+		json.NewEncoder(w).Encode(LimitResponse{MaxSpaceBytes: 1000})
+	}))
+	defer mockServer.Close()
+
+	ctx := context.Background()
+	cfg := newTestConfig(mockServer.URL)
+	cache := NewQuotaCache(cfg, time.Hour)
+	ApplyQuotaCheck(cfg, cache)
+
+	if err := cfg.QuotaCheck(ctx, 100); err != nil {
+		t.Errorf("expected upload that fits to pass, got %v", err)
+	}
+	if err := cfg.QuotaCheck(ctx, 101); !errors.Is(err, sdkerrors.ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded, got %v", err)
+	}
+
+	failing = true
+	cache.Refresh()
+	if err := cfg.QuotaCheck(ctx, 101); err != nil {
+		t.Errorf("expected upload to go ahead when the quota cannot be fetched, got %v", err)
+	}
+}
+
+func TestAbout(t *testing.T) {
+	tests := []struct {
+		name  string
+		used  int64
+		limit int64
+		want  AboutInfo
+	}{
+		{"within quota", 300, 1000, AboutInfo{Used: 300, Free: 700, Total: 1000}},
+		{"over quota", 1200, 1000, AboutInfo{Used: 1200, Free: 0, Total: 1000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasSuffix(r.URL.Path, "/usage") {
+					json.NewEncoder(w).Encode(UsageResponse{Drive: tt.used})
+					return
+				}
+				json.NewEncoder(w).Encode(LimitResponse{MaxSpaceBytes: tt.limit})
+			}))
+			defer mockServer.Close()
+
+			about, err := About(context.Background(), newTestConfig(mockServer.URL))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *about != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, *about)
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/limit") {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(UsageResponse{Drive: 1})
+		}))
+		defer mockServer.Close()
+
+		if _, err := About(context.Background(), newTestConfig(mockServer.URL)); err == nil || !strings.Contains(err.Error(), "get limit") {
+			t.Errorf("expected get limit error, got %v", err)
+		}
+	})
+}
+
+func TestWorkspaceUsage(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/drive/workspaces/ws-1/usage":
+			json.NewEncoder(w).Encode(WorkspaceUsage{DriveUsage: 300, BackupsUsage: 50, SpaceLimit: 1000})
+		case "/drive/workspaces/ws-1/members":
+			w.Write([]byte(`{
+				"activatedUsers": [{"memberId": "u1", "driveUsage": 200, "backupsUsage": 50, "spaceLimit": 500, "member": {"uuid": "u1", "email": "a@example.com", "name": "Ann"}}],
+				"disabledUsers": [{"memberId": "u2", "driveUsage": 100, "spaceLimit": 500, "member": {"email": "b@example.com"}}]
+			}`))
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer mockServer.Close()
+
+	cfg := newTestConfig(mockServer.URL)
+	cfg.WorkspaceID = "ws-1"
+	ctx := context.Background()
+
+	usage, err := GetUsage(ctx, cfg)
+	if err != nil || usage.Drive != 300 {
+		t.Errorf("expected workspace drive usage 300, got %+v, %v", usage, err)
+	}
+	limit, err := GetLimit(ctx, cfg)
+	if err != nil || limit.MaxSpaceBytes != 1000 {
+		t.Errorf("expected workspace limit 1000, got %+v, %v", limit, err)
+	}
+	breakdown, err := GetUsageBreakdown(ctx, cfg)
+	if err != nil || *breakdown != (UsageBreakdown{Drive: 300, Backups: 50, Total: 350}) {
+		t.Errorf("unexpected workspace breakdown %+v, %v", breakdown, err)
+	}
+
+	members, err := GetWorkspaceMembersUsage(ctx, cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []MemberUsage{
+		{UUID: "u1", Email: "a@example.com", Name: "Ann", DriveUsage: 200, BackupsUsage: 50, SpaceLimit: 500},
+		{UUID: "u2", Email: "b@example.com", DriveUsage: 100, SpaceLimit: 500, Deactivated: true},
+	}
+	if len(members) != len(want) {
+		t.Fatalf("expected %d members, got %d", len(want), len(members))
+	}
+	for i := range want {
+		if members[i] != want[i] {
+			t.Errorf("member %d: expected %+v, got %+v", i, want[i], members[i])
+		}
+	}
+
+	cfg.WorkspaceID = ""
+	if _, err := GetWorkspaceMembersUsage(ctx, cfg); err == nil {
+		t.Error("expected error without a selected workspace")
+	}
+}
+
+func TestGetSecurityInfo(t *testing.T) {
+	changed := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		user string
+		tfa  bool
+		want *time.Time
+	}{
+		{"2FA and password changed", `{"email": "a@example.com", "lastPasswordChangedAt": "2024-03-01T12:00:00Z"}`, true, &changed},
+		{"never changed", `{"email": "a@example.com", "lastPasswordChangedAt": null}`, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/users/cli/refresh"):
+					w.Write([]byte(`{"user": ` + tt.user + `}`))
+				case strings.HasSuffix(r.URL.Path, "/auth/login"):
+					var body struct{ Email string }
+					json.NewDecoder(r.Body).Decode(&body)
+					if body.Email != "a@example.com" {
+						t.Errorf("expected login for a@example.com, got %q", body.Email)
+					}
+					json.NewEncoder(w).Encode(map[string]bool{"tfa": tt.tfa})
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer mockServer.Close()
+
+			info, err := GetSecurityInfo(context.Background(), newTestConfig(mockServer.URL))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.TwoFactorEnabled != tt.tfa {
+				t.Errorf("expected TwoFactorEnabled %v, got %v", tt.tfa, info.TwoFactorEnabled)
+			}
+			if (tt.want == nil) != (info.LastPasswordChange == nil) || (tt.want != nil && !tt.want.Equal(*info.LastPasswordChange)) {
+				t.Errorf("expected LastPasswordChange %v, got %v", tt.want, info.LastPasswordChange)
+			}
+		})
+	}
+}
+
+func TestApplyUploadLimits(t *testing.T) {
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"label":"Free","featuresPerService":{"drive":{"maxUploadFileSize":1073741824}}}`))
+	}))
+	defer mockServer.Close()
+
+	cfg := newTestConfig(mockServer.URL)
+	limits, err := GetUploadLimits(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if *limits != (UploadLimits{MaxFileSize: 1073741824, MaxParts: config.MaxMultipartParts}) {
+		t.Errorf("unexpected limits %+v", limits)
+	}
+
+	if err := ApplyUploadLimits(context.Background(), cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.MaxUploadSize != 1073741824 {
+		t.Errorf("expected MaxUploadSize 1073741824, got %d", cfg.MaxUploadSize)
+	}
+}
+
+func TestGetReferralInfo(t *testing.T) {
+	tests := []struct {
+		name         string
+		user         string
+		wantCalls    int
+		wantReferral int
+		wantEarned   int64
+	}{
+		{"in program", `{"credit": 5, "hasReferralsProgram": true}`, 2, 2, 1073741824},
+		{"not in program", `{"credit": 0, "hasReferralsProgram": false}`, 1, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/users/cli/refresh"):
+					w.Write([]byte(`{"user": ` + tt.user + `}`))
+				case strings.HasSuffix(r.URL.Path, "/users/referrals"):
+					w.Write([]byte(`[
+						{"key": "invite-friends", "type": "storage", "credit": 1073741824, "steps": 1, "completedSteps": 1, "isCompleted": true},
+						{"key": "install-desktop-app", "type": "storage", "credit": 536870912, "steps": 1, "completedSteps": 0, "isCompleted": false}
+					]`))
+				default:
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer mockServer.Close()
+
+			info, err := GetReferralInfo(context.Background(), newTestConfig(mockServer.URL))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("expected %d requests, got %d", tt.wantCalls, calls)
+			}
+			if len(info.Referrals) != tt.wantReferral {
+				t.Errorf("expected %d referrals, got %d", tt.wantReferral, len(info.Referrals))
+			}
+			if earned := info.EarnedStorage(); earned != tt.wantEarned {
+				t.Errorf("expected %d earned bytes, got %d", tt.wantEarned, earned)
+			}
+		})
+	}
+}
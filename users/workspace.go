@@ -0,0 +1,122 @@
+package users
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/internxt/rclone-adapter/config"
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
+)
+
+// WorkspaceUsage is the storage used and available in the workspace selected by cfg.WorkspaceID.
+type WorkspaceUsage struct {
+	DriveUsage   int64 `json:"driveUsage"`
+	BackupsUsage int64 `json:"backupsUsage"`
+	SpaceLimit   int64 `json:"spaceLimit"`
+}
+
+// MemberUsage is the storage used by one member of a workspace.
+type MemberUsage struct {
+	UUID         string
+	Email        string
+	Name         string
+	Lastname     string
+	DriveUsage   int64
+	BackupsUsage int64
+	SpaceLimit   int64
+	Deactivated  bool
+}
+
+type workspaceMember struct {
+	MemberID     string `json:"memberId"`
+	SpaceLimit   int64  `json:"spaceLimit"`
+	DriveUsage   int64  `json:"driveUsage"`
+	BackupsUsage int64  `json:"backupsUsage"`
+	Deactivated  bool   `json:"deactivated"`
+	Member       struct {
+		UUID     string `json:"uuid"`
+		Email    string `json:"email"`
+		Name     string `json:"name"`
+		Lastname string `json:"lastname"`
+	} `json:"member"`
+}
+
+// GetWorkspaceUsage calls GET {DRIVE_API_URL}/workspaces/{id}/usage for the workspace
+// selected by cfg.WorkspaceID.
+func GetWorkspaceUsage(ctx context.Context, cfg *config.Config) (*WorkspaceUsage, error) {
+	if cfg.WorkspaceID == "" {
+		return nil, fmt.Errorf("failed to get workspace usage: no workspace selected")
+	}
+	var usage WorkspaceUsage
+	if err := getJSON(ctx, cfg, cfg.Endpoints.Drive().Workspaces().Usage(cfg.WorkspaceID), "get workspace usage", &usage); err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// GetWorkspaceMembersUsage calls GET {DRIVE_API_URL}/workspaces/{id}/members for the
+// workspace selected by cfg.WorkspaceID and returns the usage of every member,
+// active members first.
+func GetWorkspaceMembersUsage(ctx context.Context, cfg *config.Config) ([]MemberUsage, error) {
+	if cfg.WorkspaceID == "" {
+		return nil, fmt.Errorf("failed to get workspace members: no workspace selected")
+	}
+	var members struct {
+		ActivatedUsers []workspaceMember `json:"activatedUsers"`
+		DisabledUsers  []workspaceMember `json:"disabledUsers"`
+	}
+	if err := getJSON(ctx, cfg, cfg.Endpoints.Drive().Workspaces().Members(cfg.WorkspaceID), "get workspace members", &members); err != nil {
+		return nil, err
+	}
+
+	out := make([]MemberUsage, 0, len(members.ActivatedUsers)+len(members.DisabledUsers))
+	for _, m := range members.ActivatedUsers {
+		out = append(out, m.usage(m.Deactivated))
+	}
+	for _, m := range members.DisabledUsers {
+		out = append(out, m.usage(true))
+	}
+	return out, nil
+}
+
+func (m workspaceMember) usage(deactivated bool) MemberUsage {
+	uuid := m.Member.UUID
+	if uuid == "" {
+		uuid = m.MemberID
+	}
+	return MemberUsage{
+		UUID:         uuid,
+		Email:        m.Member.Email,
+		Name:         m.Member.Name,
+		Lastname:     m.Member.Lastname,
+		DriveUsage:   m.DriveUsage,
+		BackupsUsage: m.BackupsUsage,
+		SpaceLimit:   m.SpaceLimit,
+		Deactivated:  deactivated,
+	}
+}
+
+func getJSON(ctx context.Context, cfg *config.Config, url, op string, dst any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", op, err)
+	}
+	cfg.AuthorizeRequest(req)
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute %s request: %w", op, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return sdkerrors.NewHTTPError(resp, op)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(dst); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", op, err)
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package vfs
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/internxt/rclone-adapter/backend"
+	"github.com/internxt/rclone-adapter/errors"
+	"github.com/internxt/rclone-adapter/internal/spool"
+)
+
+// Handle is an open file. Reads and writes at arbitrary offsets are
+// supported; writes are buffered locally until Flush or Close. It is safe
+// for concurrent use.
+type Handle struct {
+	vfs    *VFS
+	name   string
+	flag   int
+	ctx    context.Context // outlives individual operations, cancelled by Close
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	obj    *backend.Object // nil for a file not uploaded yet
+	closed bool
+
+	// Read-ahead state: buf holds the file bytes at [bufOff, bufOff+len(buf)),
+	// stream is positioned at streamOff.
+	buf       []byte
+	bufOff    int64
+	stream    io.ReadCloser
+	streamOff int64
+
+	// Write state: once written to, the whole file lives in tmp.
+	tmp   *spool.File
+	dirty bool
+}
+
+// Open opens the file name. flag takes the os.O_* flags: O_CREATE creates a
+// missing file, O_EXCL fails if it exists and O_TRUNC empties it. Opening a
+// directory fails with backend.ErrIsDir.
+func (v *VFS) Open(ctx context.Context, name string, flag int) (*Handle, error) {
+	name = clean(name)
+	_, entry, err := v.stat(ctx, name)
+	switch {
+	case err == nil && flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, fmt.Errorf("failed to create %q: %w", name, os.ErrExist)
+	case err == nil:
+	case stderrors.Is(err, errors.ErrNotFound) && flag&os.O_CREATE != 0:
+		if _, err := v.dirAttr(ctx, parent(name)); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	var obj *backend.Object
+	if entry != nil {
+		var ok bool
+		if obj, ok = entry.(*backend.Object); !ok {
+			return nil, fmt.Errorf("failed to open %q: %w", name, backend.ErrIsDir)
+		}
+	}
+
+	hctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	h := &Handle{vfs: v, name: name, flag: flag, ctx: hctx, cancel: cancel, obj: obj}
+	if obj == nil || (flag&os.O_TRUNC != 0 && writable(flag)) {
+		if err := h.startWrite(false); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+func writable(flag int) bool {
+	return flag&(os.O_WRONLY|os.O_RDWR) != 0
+}
+
+// Name returns the path the handle was opened with.
+func (h *Handle) Name() string { return h.name }
+
+// Size returns the current size of the file, including buffered writes.
+func (h *Handle) Size() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.size()
+}
+
+func (h *Handle) size() int64 {
+	if h.tmp != nil {
+		if size, err := h.tmp.Size(); err == nil {
+			return size
+		}
+	}
+	if h.obj != nil {
+		return h.obj.Size()
+	}
+	return 0
+}
+
+// ReadAt reads len(p) bytes at off. It implements io.ReaderAt.
+func (h *Handle) ReadAt(p []byte, off int64) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return 0, os.ErrClosed
+	}
+	if h.tmp != nil {
+		return h.tmp.ReadAt(p, off)
+	}
+
+	size := h.size()
+	n := 0
+	for n < len(p) && off+int64(n) < size {
+		pos := off + int64(n)
+		if pos < h.bufOff || pos >= h.bufOff+int64(len(h.buf)) {
+			if err := h.fill(pos, size); err != nil {
+				return n, err
+			}
+		}
+		n += copy(p[n:], h.buf[pos-h.bufOff:])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// fill loads the read-ahead window starting at pos, reusing the download
+// stream when reads are sequential and reopening it at pos otherwise.
+func (h *Handle) fill(pos, size int64) error {
+	if h.stream == nil || h.streamOff != pos {
+		h.closeStream()
+		stream, err := h.obj.Open(h.ctx, &backend.SeekOption{Offset: pos})
+		if err != nil {
+			return fmt.Errorf("failed to read %q: %w", h.name, err)
+		}
+		h.stream, h.streamOff = stream, pos
+	}
+
+	want := min(h.vfs.opts.ReadAhead, size-pos)
+	if int64(cap(h.buf)) < want {
+		h.buf = make([]byte, want)
+	}
+	h.buf = h.buf[:want]
+	n, err := io.ReadFull(h.stream, h.buf)
+	h.buf, h.bufOff = h.buf[:n], pos
+	h.streamOff += int64(n)
+	if err != nil && !(stderrors.Is(err, io.ErrUnexpectedEOF) && n > 0) {
+		h.closeStream()
+		return fmt.Errorf("failed to read %q: %w", h.name, err)
+	}
+	return nil
+}
+
+func (h *Handle) closeStream() {
+	if h.stream != nil {
+		h.stream.Close()
+		h.stream = nil
+	}
+}
+
+// WriteAt writes p at off into the local buffer. It implements io.WriterAt.
+func (h *Handle) WriteAt(p []byte, off int64) (int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return 0, os.ErrClosed
+	}
+	if !writable(h.flag) {
+		return 0, fmt.Errorf("failed to write %q: %w", h.name, os.ErrPermission)
+	}
+	if err := h.startWrite(true); err != nil {
+		return 0, err
+	}
+	if h.flag&os.O_APPEND != 0 {
+		off = h.size()
+	}
+	h.dirty = true
+	return h.tmp.WriteAt(p, off)
+}
+
+// Truncate changes the size of the file.
+func (h *Handle) Truncate(size int64) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return os.ErrClosed
+	}
+	if !writable(h.flag) {
+		return fmt.Errorf("failed to truncate %q: %w", h.name, os.ErrPermission)
+	}
+	if err := h.startWrite(size > 0); err != nil {
+		return err
+	}
+	h.dirty = true
+	return h.tmp.Truncate(size)
+}
+
+// startWrite moves the file into the local buffer, downloading its current
+// content first when keep is set.
+func (h *Handle) startWrite(keep bool) error {
+	if h.tmp != nil {
+		return nil
+	}
+	tmp, err := spool.Create(h.vfs.opts.CacheDir, "vfs-*", h.vfs.key)
+	if err != nil {
+		return fmt.Errorf("failed to create write buffer: %w", err)
+	}
+	if keep && h.obj != nil && h.obj.Size() > 0 {
+		rc, err := h.obj.Open(h.ctx)
+		if err == nil {
+			_, err = io.Copy(tmp, rc)
+			if cerr := rc.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return fmt.Errorf("failed to load %q for writing: %w", h.name, err)
+		}
+	}
+	h.closeStream()
+	h.buf = nil
+	h.tmp = tmp
+	h.dirty = h.obj == nil || !keep
+	return nil
+}
+
+// Flush uploads buffered writes. It does nothing if there are none.
+func (h *Handle) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.flush()
+}
+
+func (h *Handle) flush() error {
+	if !h.dirty {
+		return nil
+	}
+	size := h.size()
+	defer h.vfs.invalidate(h.name)
+	obj, err := h.vfs.fs.Put(h.ctx, h.name, io.NewSectionReader(h.tmp, 0, size), size, time.Now())
+	if err != nil {
+		return err
+	}
+	h.obj, h.dirty = obj, false
+	return nil
+}
+
+// Close flushes buffered writes and releases the handle. If the upload
+// fails, the handle stays open with its buffered writes so Close can be
+// retried; Discard releases it without uploading.
+func (h *Handle) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return os.ErrClosed
+	}
+	if err := h.flush(); err != nil {
+		return err
+	}
+	h.release()
+	return nil
+}
+
+// Discard releases the handle, dropping writes not flushed yet.
+func (h *Handle) Discard() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return os.ErrClosed
+	}
+	h.release()
+	return nil
+}
+
+func (h *Handle) release() {
+	h.closed = true
+	h.closeStream()
+	if h.tmp != nil {
+		h.tmp.Close()
+		os.Remove(h.tmp.Name())
+	}
+	h.cancel()
+}
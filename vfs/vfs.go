@@ -0,0 +1,251 @@
+// Package vfs is a virtual filesystem layer for mounts, independent of any
+// FUSE binding. It caches attributes and directory listings for a short
+// time, serves reads through a read-ahead buffer over a single sequential
+// download stream, and buffers writes in a local file that is uploaded, with
+// multipart for large files, when the handle is flushed or closed.
+package vfs
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/internxt/rclone-adapter/backend"
+	"github.com/internxt/rclone-adapter/errors"
+	"github.com/internxt/rclone-adapter/internal/spool"
+)
+
+const (
+	DefaultAttrTimeout = time.Second
+	DefaultReadAhead   = 4 * 1024 * 1024
+)
+
+// Options tunes a VFS. Zero values select the defaults.
+type Options struct {
+	AttrTimeout  time.Duration // How long Stat and ReadDir results are cached (default DefaultAttrTimeout)
+	ReadAhead    int64         // Bytes fetched ahead of sequential reads (default DefaultReadAhead)
+	CacheDir     string        // Directory for buffered writes (default os.TempDir())
+	EncryptCache bool          // Encrypt buffered writes on disk with a key held only in memory
+}
+
+// Attr describes a file or directory.
+type Attr struct {
+	Name    string // Base name; empty for the root
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+type cachedAttr struct {
+	attr    Attr
+	entry   backend.Entry
+	expires time.Time
+}
+
+type cachedDir struct {
+	attrs   []Attr
+	expires time.Time
+}
+
+// VFS is a filesystem view of an Fs. Paths are slash-separated and relative
+// to the Fs root.
+type VFS struct {
+	fs   *backend.Fs
+	opts Options
+	key  *spool.Key // Encrypts buffered writes, nil unless opts.EncryptCache
+
+	mu    sync.Mutex
+	attrs map[string]cachedAttr
+	dirs  map[string]cachedDir
+}
+
+// New returns a VFS over fs. opts may be nil.
+func New(fs *backend.Fs, opts *Options) *VFS {
+	v := &VFS{fs: fs, attrs: map[string]cachedAttr{}, dirs: map[string]cachedDir{}}
+	if opts != nil {
+		v.opts = *opts
+	}
+	if v.opts.AttrTimeout <= 0 {
+		v.opts.AttrTimeout = DefaultAttrTimeout
+	}
+	if v.opts.ReadAhead <= 0 {
+		v.opts.ReadAhead = DefaultReadAhead
+	}
+	if v.opts.CacheDir == "" {
+		v.opts.CacheDir = os.TempDir()
+	}
+	if v.opts.EncryptCache {
+		v.key = spool.NewKey()
+	}
+	return v
+}
+
+// Stat returns the attributes of name. A missing name yields an error
+// matching errors.ErrNotFound.
+func (v *VFS) Stat(ctx context.Context, name string) (Attr, error) {
+	attr, _, err := v.stat(ctx, name)
+	return attr, err
+}
+
+func (v *VFS) stat(ctx context.Context, name string) (Attr, backend.Entry, error) {
+	name = clean(name)
+	v.mu.Lock()
+	c, ok := v.attrs[name]
+	v.mu.Unlock()
+	if ok && time.Now().Before(c.expires) {
+		return c.attr, c.entry, nil
+	}
+
+	entry, err := v.fs.Stat(ctx, name)
+	if err != nil {
+		return Attr{}, nil, err
+	}
+	attr := attrOf(entry)
+	v.mu.Lock()
+	v.attrs[name] = cachedAttr{attr: attr, entry: entry, expires: time.Now().Add(v.opts.AttrTimeout)}
+	v.mu.Unlock()
+	return attr, entry, nil
+}
+
+// ReadDir returns the attributes of the entries in dir.
+func (v *VFS) ReadDir(ctx context.Context, dir string) ([]Attr, error) {
+	dir = clean(dir)
+	v.mu.Lock()
+	c, ok := v.dirs[dir]
+	v.mu.Unlock()
+	if ok && time.Now().Before(c.expires) {
+		return c.attrs, nil
+	}
+
+	entries, err := v.fs.List(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	expires := time.Now().Add(v.opts.AttrTimeout)
+	attrs := make([]Attr, len(entries))
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for i, entry := range entries {
+		attrs[i] = attrOf(entry)
+		v.attrs[entry.Remote()] = cachedAttr{attr: attrs[i], entry: entry, expires: expires}
+	}
+	v.dirs[dir] = cachedDir{attrs: attrs, expires: expires}
+	return attrs, nil
+}
+
+// Mkdir creates the directory name. Its parent must exist.
+func (v *VFS) Mkdir(ctx context.Context, name string) error {
+	name = clean(name)
+	if _, err := v.Stat(ctx, name); err == nil {
+		return fmt.Errorf("failed to create directory %q: %w", name, os.ErrExist)
+	}
+	if _, err := v.dirAttr(ctx, parent(name)); err != nil {
+		return err
+	}
+	defer v.invalidate(name)
+	return v.fs.Mkdir(ctx, name)
+}
+
+// Remove deletes the file or empty directory name.
+func (v *VFS) Remove(ctx context.Context, name string) error {
+	name = clean(name)
+	_, entry, err := v.stat(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer v.invalidate(name)
+	if obj, ok := entry.(*backend.Object); ok {
+		return obj.Remove(ctx)
+	}
+	return v.fs.Rmdir(ctx, name)
+}
+
+// Rename moves the file or directory oldName to newName, replacing an
+// existing file at newName.
+func (v *VFS) Rename(ctx context.Context, oldName, newName string) error {
+	oldName, newName = clean(oldName), clean(newName)
+	_, entry, err := v.stat(ctx, oldName)
+	if err != nil {
+		return err
+	}
+	if _, err := v.dirAttr(ctx, parent(newName)); err != nil {
+		return err
+	}
+	defer v.invalidate(oldName)
+	defer v.invalidate(newName)
+
+	if _, existing, err := v.stat(ctx, newName); err == nil {
+		obj, ok := existing.(*backend.Object)
+		if !ok {
+			return fmt.Errorf("failed to rename %q to %q: %w", oldName, newName, backend.ErrIsDir)
+		}
+		// Fs.Move replaces a file only once the move succeeds.
+		if _, isFile := entry.(*backend.Object); !isFile {
+			if err := obj.Remove(ctx); err != nil {
+				return err
+			}
+		}
+	} else if !stderrors.Is(err, errors.ErrNotFound) {
+		return err
+	}
+
+	if obj, ok := entry.(*backend.Object); ok {
+		_, err = v.fs.Move(ctx, obj, newName)
+		return err
+	}
+	return v.fs.DirMove(ctx, oldName, newName)
+}
+
+// dirAttr stats dir and checks it is a directory.
+func (v *VFS) dirAttr(ctx context.Context, dir string) (Attr, error) {
+	attr, err := v.Stat(ctx, dir)
+	if err != nil {
+		return Attr{}, err
+	}
+	if !attr.IsDir {
+		return Attr{}, fmt.Errorf("failed to open directory %q: %w", dir, backend.ErrIsFile)
+	}
+	return attr, nil
+}
+
+// invalidate drops cached attributes of name, its descendants and the
+// listing of its parent.
+func (v *VFS) invalidate(name string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for p := range v.attrs {
+		if p == name || strings.HasPrefix(p, name+"/") {
+			delete(v.attrs, p)
+		}
+	}
+	for p := range v.dirs {
+		if p == name || strings.HasPrefix(p, name+"/") {
+			delete(v.dirs, p)
+		}
+	}
+	delete(v.dirs, parent(name))
+}
+
+func attrOf(entry backend.Entry) Attr {
+	_, isDir := entry.(*backend.Directory)
+	name := path.Base("/" + entry.Remote())
+	if entry.Remote() == "" {
+		name = ""
+	}
+	return Attr{Name: name, Size: entry.Size(), ModTime: entry.ModTime(), IsDir: isDir}
+}
+
+func clean(name string) string {
+	return strings.Trim(path.Clean("/"+name), "/")
+}
+
+func parent(name string) string {
+	dir, _ := path.Split(name)
+	return clean(dir)
+}
@@ -0,0 +1,273 @@
+package vfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/internxt/rclone-adapter/backend"
+	sdkerrors "github.com/internxt/rclone-adapter/errors"
+	"github.com/internxt/rclone-adapter/internal/fakedrive"
+)
+
+func newTestVFS(t *testing.T, opts *Options) (*VFS, *fakedrive.Server) {
+	server := fakedrive.New()
+	t.Cleanup(server.Close)
+	if opts == nil {
+		opts = &Options{}
+	}
+	opts.CacheDir = t.TempDir()
+	return New(backend.NewFs(server.Config(), ""), opts), server
+}
+
+func TestStatReadDirCache(t *testing.T) {
+	v, server := newTestVFS(t, &Options{AttrTimeout: time.Hour})
+	ctx := context.Background()
+	server.AddFile(fakedrive.RootUUID, "a.txt", []byte("abc"), time.Now())
+
+	attrs, err := v.ReadDir(ctx, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(attrs) != 1 || attrs[0].Name != "a.txt" || attrs[0].Size != 3 || attrs[0].IsDir {
+		t.Fatalf("unexpected listing %+v", attrs)
+	}
+
+	// Changes made behind the VFS stay invisible until the cache expires...
+	server.AddFile(fakedrive.RootUUID, "b.txt", []byte("b"), time.Now())
+	if attrs, _ := v.ReadDir(ctx, ""); len(attrs) != 1 {
+		t.Errorf("expected cached listing, got %+v", attrs)
+	}
+	// ...but changes made through it are seen immediately.
+	if err := v.Mkdir(ctx, "docs"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attrs, _ := v.ReadDir(ctx, ""); len(attrs) != 3 {
+		t.Errorf("expected listing refreshed after Mkdir, got %+v", attrs)
+	}
+	if attr, err := v.Stat(ctx, "docs"); err != nil || !attr.IsDir {
+		t.Errorf("expected docs to be a directory, got %+v, %v", attr, err)
+	}
+	if _, err := v.Stat(ctx, "missing"); !errors.Is(err, sdkerrors.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := v.Mkdir(ctx, "docs"); !errors.Is(err, os.ErrExist) {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+}
+
+func TestReadAhead(t *testing.T) {
+	v, server := newTestVFS(t, &Options{ReadAhead: 64})
+	ctx := context.Background()
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	server.AddFile(fakedrive.RootUUID, "data.bin", data, time.Now())
+
+	h, err := v.Open(ctx, "data.bin", os.O_RDONLY)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer h.Close()
+
+	got, err := io.ReadAll(io.NewSectionReader(h, 0, h.Size()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("sequential read returned wrong data")
+	}
+	if n := server.ShardRequests(); n != 1 {
+		t.Errorf("expected sequential reads to share one download, got %d", n)
+	}
+
+	// Unaligned random read: the CTR stream must be positioned mid-block.
+	p := make([]byte, 10)
+	if _, err := h.ReadAt(p, 333); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(p, data[333:343]) {
+		t.Errorf("random read returned %v, expected %v", p, data[333:343])
+	}
+	if _, err := h.ReadAt(p, 995); err != io.EOF {
+		t.Errorf("expected EOF reading past the end, got %v", err)
+	}
+	if _, err := h.WriteAt(p, 0); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("expected ErrPermission writing a read-only handle, got %v", err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	v, server := newTestVFS(t, nil)
+	ctx := context.Background()
+
+	h, err := v.Open(ctx, "new.txt", os.O_RDWR|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h.WriteAt([]byte("hello world"), 0)
+	h.WriteAt([]byte("WORLD"), 6)
+	p := make([]byte, 11)
+	if _, err := h.ReadAt(p, 0); err != nil || string(p) != "hello WORLD" {
+		t.Errorf("expected to read back buffered writes, got %q, %v", p, err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	uuid, _, ok := server.Lookup("new.txt")
+	if content, _ := server.Content(uuid); !ok || string(content) != "hello WORLD" {
+		t.Fatalf("expected uploaded content, got %q", content)
+	}
+
+	// Partial overwrite of an existing file keeps the rest of its content.
+	h, err = v.Open(ctx, "new.txt", os.O_WRONLY)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h.WriteAt([]byte("J"), 0)
+	if err := h.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	uuid, _, _ = server.Lookup("new.txt")
+	if content, _ := server.Content(uuid); string(content) != "Jello WORLD" {
+		t.Errorf("expected partially overwritten content, got %q", content)
+	}
+
+	if _, err := v.Open(ctx, "new.txt", os.O_RDWR|os.O_CREATE|os.O_EXCL); !errors.Is(err, os.ErrExist) {
+		t.Errorf("expected ErrExist, got %v", err)
+	}
+	if _, err := v.Open(ctx, "missing/x.txt", os.O_RDWR|os.O_CREATE); !errors.Is(err, sdkerrors.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing parent, got %v", err)
+	}
+}
+
+func TestCloseRetry(t *testing.T) {
+	server := fakedrive.New()
+	t.Cleanup(server.Close)
+	cfg := server.Config()
+	fail := true
+	cfg.QuotaCheck = func(ctx context.Context, size int64) error {
+		if fail {
+			return sdkerrors.ErrQuotaExceeded
+		}
+		return nil
+	}
+	dir := t.TempDir()
+	v := New(backend.NewFs(cfg, ""), &Options{CacheDir: dir})
+	ctx := context.Background()
+
+	h, err := v.Open(ctx, "new.txt", os.O_WRONLY|os.O_CREATE)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h.WriteAt([]byte("keep me"), 0)
+	if err := h.Close(); !errors.Is(err, sdkerrors.ErrQuotaExceeded) {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("expected the write buffer to be kept, got %d files", len(entries))
+	}
+
+	fail = false
+	if err := h.Close(); err != nil {
+		t.Fatalf("unexpected error retrying Close: %v", err)
+	}
+	uuid, _, ok := server.Lookup("new.txt")
+	if content, _ := server.Content(uuid); !ok || string(content) != "keep me" {
+		t.Errorf("expected uploaded content, got %q", content)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected the write buffer to be removed, got %d files", len(entries))
+	}
+	if err := h.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+
+	h, err = v.Open(ctx, "dropped.txt", os.O_WRONLY|os.O_CREATE)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h.WriteAt([]byte("gone"), 0)
+	if err := h.Discard(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, ok := server.Lookup("dropped.txt"); ok {
+		t.Error("expected discarded writes not to be uploaded")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected the write buffer to be removed, got %d files", len(entries))
+	}
+}
+
+func TestEncryptCache(t *testing.T) {
+	v, server := newTestVFS(t, &Options{EncryptCache: true})
+	ctx := context.Background()
+
+	h, err := v.Open(ctx, "secret.txt", os.O_RDWR|os.O_CREATE)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h.WriteAt([]byte("top secret plans"), 0)
+	h.WriteAt([]byte("PLANS"), 11)
+	cached, _ := filepath.Glob(filepath.Join(v.opts.CacheDir, "vfs-*"))
+	for _, name := range cached {
+		if raw, _ := os.ReadFile(name); bytes.Contains(raw, []byte("secret")) {
+			t.Errorf("expected %s to be encrypted, got %q", name, raw)
+		}
+	}
+	if len(cached) != 1 {
+		t.Errorf("expected one cache file, got %v", cached)
+	}
+	p := make([]byte, 16)
+	if _, err := h.ReadAt(p, 0); err != nil || string(p) != "top secret PLANS" {
+		t.Errorf("expected to read back buffered writes, got %q, %v", p, err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	uuid, _, _ := server.Lookup("secret.txt")
+	if content, _ := server.Content(uuid); string(content) != "top secret PLANS" {
+		t.Errorf("expected uploaded content, got %q", content)
+	}
+}
+
+func TestRenameRemove(t *testing.T) {
+	v, server := newTestVFS(t, nil)
+	ctx := context.Background()
+	server.AddFile(fakedrive.RootUUID, "a.txt", []byte("a"), time.Now())
+	server.AddFile(fakedrive.RootUUID, "b.txt", []byte("b"), time.Now())
+	server.AddFolder(fakedrive.RootUUID, "docs")
+
+	if err := v.Rename(ctx, "a.txt", "b.txt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	uuid, _, ok := server.Lookup("b.txt")
+	if content, _ := server.Content(uuid); !ok || string(content) != "a" {
+		t.Errorf("expected b.txt replaced by a.txt, got %q", content)
+	}
+	if err := v.Rename(ctx, "b.txt", "docs/c.txt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, ok := server.Lookup("docs/c.txt"); !ok {
+		t.Error("expected docs/c.txt after rename")
+	}
+
+	if err := v.Remove(ctx, "docs"); !errors.Is(err, backend.ErrDirNotEmpty) {
+		t.Errorf("expected ErrDirNotEmpty, got %v", err)
+	}
+	if err := v.Remove(ctx, "docs/c.txt"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := v.Remove(ctx, "docs"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attrs, _ := v.ReadDir(ctx, ""); len(attrs) != 0 {
+		t.Errorf("expected empty root, got %+v", attrs)
+	}
+}